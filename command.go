@@ -14,6 +14,7 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/rpc"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/vmihailenco/msgpack"
 	"gopkg.in/urfave/cli.v1"
@@ -187,6 +188,93 @@ func getInfoCmd(c *cli.Context) error {
 	return err
 }
 
+func importSnapshotsCmd(c *cli.Context) error {
+	f, err := os.Open(c.String("file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	store, err := storage.NewBadgerStore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	imported, err := rpc.ImportSnapshots(store, f)
+	fmt.Printf("imported: %d\n", imported)
+	return err
+}
+
+func verifySyncCmd(c *cli.Context) error {
+	store, err := storage.NewBadgerStore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	local := kernel.NewStoreSyncSource(store)
+	peer := &rpcSyncSource{node: c.String("peer")}
+	report, err := kernel.VerifySync(local, peer, c.Int("samples"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("local: %d peer: %d\n", report.LocalTopology, report.PeerTopology)
+	switch report.Status {
+	case kernel.SyncStatusForked:
+		fmt.Printf("forked at: %d local: %s peer: %s\n", report.Divergence, report.LocalHash.String(), report.PeerHash.String())
+		return fmt.Errorf("local snapshots diverge from peer %s at %d", peer.node, report.Divergence)
+	case kernel.SyncStatusBehind:
+		fmt.Printf("behind peer by %d snapshots\n", report.Gap)
+	case kernel.SyncStatusAhead:
+		fmt.Printf("ahead of peer by %d snapshots\n", report.Gap)
+	default:
+		fmt.Println("synced")
+	}
+	return nil
+}
+
+type rpcSyncSource struct {
+	node string
+}
+
+func (s *rpcSyncSource) TopologySequence() (uint64, error) {
+	data, err := callRPC(s.node, "getinfo", []interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	var info struct {
+		Graph struct {
+			Topology uint64 `json:"topology"`
+		} `json:"graph"`
+		Error string `json:"error"`
+	}
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return 0, err
+	}
+	if info.Error != "" {
+		return 0, fmt.Errorf("peer getinfo error %s", info.Error)
+	}
+	return info.Graph.Topology, nil
+}
+
+func (s *rpcSyncSource) SnapshotHash(topology uint64) (crypto.Hash, error) {
+	data, err := callRPC(s.node, "listsnapshots", []interface{}{topology, 1, false})
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	err = json.Unmarshal(data, &snapshots)
+	if err != nil {
+		return crypto.Hash{}, fmt.Errorf("peer listsnapshots error %s", string(data))
+	}
+	if len(snapshots) != 1 || snapshots[0].TopologicalOrder != topology {
+		return crypto.Hash{}, nil
+	}
+	return snapshots[0].Hash, nil
+}
+
 func setupTestNetCmd(c *cli.Context) error {
 	var signers, payees []common.Address
 
@@ -12,6 +12,8 @@ import (
 
 const MainNetworkId = "XIN"
 
+var ErrAddressViewKeyMismatch = errors.New("invalid address view key not derived from spend key")
+
 type Address struct {
 	PrivateSpendKey crypto.Key
 	PrivateViewKey  crypto.Key
@@ -52,6 +54,21 @@ func NewAddressFromString(s string) (Address, error) {
 	return a, nil
 }
 
+// ValidateAddress checks the address format, and the view key must be derived
+// from the spend key deterministically, as required by the genesis nodes and
+// domains.
+func ValidateAddress(s string) error {
+	a, err := NewAddressFromString(s)
+	if err != nil {
+		return err
+	}
+	privateView := a.PublicSpendKey.DeterministicHashDerive()
+	if privateView.Public() != a.PublicViewKey {
+		return ErrAddressViewKeyMismatch
+	}
+	return nil
+}
+
 func (a Address) String() string {
 	data := append([]byte(MainNetworkId), a.PublicSpendKey[:]...)
 	data = append(data, a.PublicViewKey[:]...)
@@ -46,3 +46,28 @@ func TestAddress(t *testing.T) {
 	assert.Equal("0000000000000000000000000000000000000000000000000000000000000000", b.PrivateSpendKey.String())
 	assert.Equal("013ada6acca01c3ba1fce30afa922a029bb224d4ab158127428b9e85c7175c32", b.Hash().String())
 }
+
+func TestValidateAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	seed := make([]byte, 64)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i + 1)
+	}
+	a := NewAddressFromSeed(seed)
+	assert.Equal(ErrAddressViewKeyMismatch, ValidateAddress(a.String()))
+
+	a.PrivateViewKey = a.PublicSpendKey.DeterministicHashDerive()
+	a.PublicViewKey = a.PrivateViewKey.Public()
+	assert.Nil(ValidateAddress(a.String()))
+
+	err := ValidateAddress(a.String()[:95] + "7")
+	assert.NotNil(err)
+	assert.Equal("invalid address checksum", err.Error())
+	err = ValidateAddress("XIN8AJMgQUD11jZYN9gg")
+	assert.NotNil(err)
+	assert.Equal("invalid address format", err.Error())
+	err = ValidateAddress("MOB" + a.String()[3:])
+	assert.NotNil(err)
+	assert.Equal("invalid address network", err.Error())
+}
@@ -1,5 +1,8 @@
 package common
 
+import "github.com/MixinNetwork/mixin/crypto"
+
 type Domain struct {
-	Account Address
+	Account     Address
+	Transaction crypto.Hash
 }
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+var minimumFee atomic.Value
+
+// SetMinimumFee makes the validation reject the XIN transactions with the
+// inputs minus outputs less than the fee, the fee is burned. A zero fee keeps
+// the inputs and outputs amount equal as required without the fee. It's a
+// consensus rule, so the fee comes from the genesis, never the node config.
+func SetMinimumFee(fee string) error {
+	if fee == "" {
+		fee = "0"
+	}
+	if _, err := strconv.ParseFloat(fee, 64); err != nil {
+		return fmt.Errorf("invalid minimum fee %s", fee)
+	}
+	amount := NewIntegerFromString(fee)
+	if amount.Sign() < 0 {
+		return fmt.Errorf("invalid minimum fee %s", fee)
+	}
+	minimumFee.Store(amount)
+	return nil
+}
+
+func MinimumFee() Integer {
+	fee, _ := minimumFee.Load().(Integer)
+	return fee
+}
+
+// FeeExempt is true for the transactions not in the fee asset, or with any
+// input or output other than the plain script ones, i.e. the genesis,
+// deposit, mint and node transactions.
+func (tx *Transaction) FeeExempt() bool {
+	if tx.Asset != XINAssetId {
+		return true
+	}
+	for _, in := range tx.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || len(in.Mint) > 0 || len(in.Rebate) > 0 {
+			return true
+		}
+	}
+	for _, o := range tx.Outputs {
+		if o.Type != OutputTypeScript {
+			return true
+		}
+	}
+	return false
+}
+
+func validateFee(tx *Transaction, inputAmount, outputAmount Integer) error {
+	fee := MinimumFee()
+	if fee.Sign() == 0 || tx.FeeExempt() {
+		if inputAmount.Cmp(outputAmount) != 0 {
+			return NewValidationError(ErrInvalidAmount, "invalid input output amount %s %s", inputAmount.String(), outputAmount.String())
+		}
+		return nil
+	}
+	if inputAmount.Cmp(outputAmount) < 0 {
+		return NewValidationError(ErrInvalidAmount, "invalid input output amount %s %s", inputAmount.String(), outputAmount.String())
+	}
+	if paid := inputAmount.Sub(outputAmount); paid.Cmp(fee) < 0 {
+		return NewValidationError(ErrInvalidFee, "invalid transaction fee %s %s", paid.String(), fee.String())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionMinimumFee(t *testing.T) {
+	assert := assert.New(t)
+	defer SetMinimumFee("0")
+
+	accounts := make([]Address, 0)
+	for i := 0; i < 3; i++ {
+		accounts = append(accounts, randomAccount())
+	}
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	store := storeImpl{seed: seed, accounts: accounts}
+	script := Script{OperatorCmp, OperatorSum, 2}
+
+	build := func(amount string) *SignedTransaction {
+		tx := NewTransaction(XINAssetId)
+		tx.AddInput(crypto.Hash{}, 0)
+		tx.AddInput(crypto.Hash{}, 1)
+		tx.AddScriptOutput(accounts, script, NewIntegerFromString(amount))
+		signed := &SignedTransaction{Transaction: *tx}
+		for i := range signed.Inputs {
+			err := signed.SignInput(store, i, accounts)
+			assert.Nil(err)
+		}
+		return signed
+	}
+
+	assert.Equal(0, MinimumFee().Sign())
+	assert.Nil(build("20000").Validate(store))
+	err := build("19999").Validate(store)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid input output amount")
+
+	assert.NotNil(SetMinimumFee("-1"))
+	assert.NotNil(SetMinimumFee("fee"))
+	assert.Nil(SetMinimumFee("0.5"))
+	assert.Equal(0, MinimumFee().Cmp(NewIntegerFromString("0.5")))
+	assert.Nil(build("19999.5").Validate(store))
+	assert.Nil(build("19999").Validate(store))
+	err = build("19999.75").Validate(store)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid transaction fee")
+	err = build("20000").Validate(store)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid transaction fee")
+	err = build("20001").Validate(store)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid input output amount")
+
+	genesis := NewTransaction(XINAssetId)
+	genesis.Inputs = []*Input{{Genesis: seed}}
+	genesis.AddScriptOutput(accounts, script, NewInteger(10000))
+	assert.True(genesis.FeeExempt())
+	assert.False(build("20000").FeeExempt())
+	other := NewTransaction(crypto.NewHash([]byte("other")))
+	other.AddInput(crypto.Hash{}, 0)
+	assert.True(other.FeeExempt())
+}
@@ -1,5 +1,7 @@
 package common
 
+import "github.com/MixinNetwork/mixin/crypto"
+
 const (
 	NodeStatePledging  = "PLEDGING"
 	NodeStateAccepted  = "ACCEPTED"
@@ -7,9 +9,10 @@ const (
 )
 
 type Node struct {
-	Signer Address
-	Payee  Address
-	State  string
+	Signer      Address
+	Payee       Address
+	State       string
+	Transaction crypto.Hash
 }
 
 func (n *Node) IsAccepted() bool {
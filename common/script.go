@@ -1,27 +1,86 @@
 package common
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"strconv"
 )
 
 const (
-	Operator0   = 0x00
-	Operator64  = 0x40
-	OperatorSum = 0xfe
-	OperatorCmp = 0xff
+	Operator0        = 0x00
+	Operator64       = 0x40
+	OperatorTimeLock = 0xfd
+	OperatorSum      = 0xfe
+	OperatorCmp      = 0xff
 )
 
 type Script []uint8
 
+type ScriptNode interface {
+	Compile() Script
+}
+
+// ScriptThreshold requires at least Required signatures of the output keys.
+type ScriptThreshold struct {
+	Required uint8
+}
+
+func (t ScriptThreshold) Compile() Script {
+	return Script([]uint8{OperatorCmp, OperatorSum, t.Required})
+}
+
+// ScriptTimeLock is a threshold script that can't be spent until the snapshot
+// timestamp reaches Until, in nanoseconds.
+type ScriptTimeLock struct {
+	Required uint8
+	Until    uint64
+}
+
+func (t ScriptTimeLock) Compile() Script {
+	s := ScriptThreshold{Required: t.Required}.Compile()
+	s = append(s, OperatorTimeLock)
+	until := make([]byte, 8)
+	binary.BigEndian.PutUint64(until, t.Until)
+	return append(s, until...)
+}
+
+func ParseScript(s Script) (ScriptNode, error) {
+	err := s.VerifyFormat()
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 12 {
+		return ScriptTimeLock{Required: s[2], Until: s.TimeLock()}, nil
+	}
+	return ScriptThreshold{Required: s[2]}, nil
+}
+
 func (s Script) VerifyFormat() error {
-	if len(s) != 3 {
+	if len(s) != 3 && len(s) != 12 {
 		return fmt.Errorf("invalid script %d", len(s))
 	}
 	if s[0] != OperatorCmp || s[1] != OperatorSum {
 		return fmt.Errorf("invalid script %d %d", s[0], s[1])
 	}
+	if len(s) == 12 && s[3] != OperatorTimeLock {
+		return fmt.Errorf("invalid script time lock %d", s[3])
+	}
+	return nil
+}
+
+// TimeLock returns the time lock timestamp, or 0 if the script has no lock.
+func (s Script) TimeLock() uint64 {
+	if len(s) != 12 || s[3] != OperatorTimeLock {
+		return 0
+	}
+	return binary.BigEndian.Uint64(s[4:])
+}
+
+func (s Script) ValidateTimeLock(timestamp uint64) error {
+	if until := s.TimeLock(); timestamp < until {
+		return NewValidationError(ErrTimeLocked, "script time locked until %d %d", until, timestamp)
+	}
 	return nil
 }
 
@@ -31,11 +90,19 @@ func (s Script) Validate(sum int) error {
 		return err
 	}
 	if sum < int(s[2]) {
-		return fmt.Errorf("invalid signature keys %d %d", sum, s[2])
+		return NewValidationError(ErrInvalidSignature, "invalid signature keys %d %d", sum, s[2])
 	}
 	return nil
 }
 
+func (s Script) Threshold() (uint8, error) {
+	err := s.VerifyFormat()
+	if err != nil {
+		return 0, err
+	}
+	return s[2], nil
+}
+
 func (s Script) String() string {
 	return hex.EncodeToString(s[:])
 }
@@ -45,4 +45,46 @@ func TestScript(t *testing.T) {
 	err = s.Validate(1)
 	assert.Nil(err)
 	assert.Equal("fffe01", s.String())
+
+	s = Script([]uint8{OperatorCmp, OperatorSum, uint8(7*2/3 + 1)})
+	threshold, err := s.Threshold()
+	assert.Nil(err)
+	assert.Equal(uint8(5), threshold)
+	s = Script([]uint8{OperatorCmp, OperatorSum})
+	threshold, err = s.Threshold()
+	assert.NotNil(err)
+	assert.Equal(uint8(0), threshold)
+	s = Script([]uint8{OperatorSum, OperatorCmp, 5})
+	threshold, err = s.Threshold()
+	assert.NotNil(err)
+	assert.Equal(uint8(0), threshold)
+
+	genesis := Script([]uint8{OperatorCmp, OperatorSum, uint8(7*2/3 + 1)})
+	node, err := ParseScript(genesis)
+	assert.Nil(err)
+	assert.Equal(ScriptThreshold{Required: 5}, node)
+	assert.Equal(genesis, node.Compile())
+	assert.Equal(genesis, ScriptThreshold{Required: 5}.Compile())
+	node, err = ParseScript(Script([]uint8{OperatorCmp, OperatorCmp, 5}))
+	assert.NotNil(err)
+	assert.Nil(node)
+
+	lock := ScriptTimeLock{Required: 2, Until: 1551312000000000000}
+	s = lock.Compile()
+	assert.Len(s, 12)
+	assert.Nil(s.VerifyFormat())
+	assert.Equal(uint64(1551312000000000000), s.TimeLock())
+	threshold, err = s.Threshold()
+	assert.Nil(err)
+	assert.Equal(uint8(2), threshold)
+	node, err = ParseScript(s)
+	assert.Nil(err)
+	assert.Equal(lock, node)
+	assert.Nil(s.Validate(2))
+	assert.NotNil(s.ValidateTimeLock(1551311999999999999))
+	assert.Nil(s.ValidateTimeLock(1551312000000000000))
+	assert.Nil(genesis.ValidateTimeLock(0))
+	assert.Equal(uint64(0), genesis.TimeLock())
+	s[3] = OperatorSum
+	assert.NotNil(s.VerifyFormat())
 }
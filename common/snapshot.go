@@ -47,6 +47,12 @@ func (s *Snapshot) Payload() []byte {
 	return MsgpackMarshalPanic(p)
 }
 
+// PayloadBytes returns the exact preimage hashed by PayloadHash, to diff
+// the snapshots expected to be identical.
+func (s *Snapshot) PayloadBytes() []byte {
+	return s.Payload()
+}
+
 func (s *Snapshot) PayloadHash() crypto.Hash {
 	return crypto.NewHash(s.Payload())
 }
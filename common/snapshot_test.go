@@ -1,7 +1,9 @@
 package common
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/hex"
 	"testing"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -42,6 +44,33 @@ func TestSnapshot(t *testing.T) {
 	assert.True(checkSignature(s, key.Public()))
 }
 
+// The payload is encoded by msgpack, whose integers are always big endian,
+// so the hash never depends on the byte order of the platform.
+func TestSnapshotPayloadEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Snapshot{
+		NodeId:      crypto.NewHash([]byte("node")),
+		Transaction: crypto.NewHash([]byte("transaction")),
+		References: &RoundLink{
+			Self:     crypto.NewHash([]byte("self")),
+			External: crypto.NewHash([]byte("external")),
+		},
+		RoundNumber: 0x0102030405060708,
+		Timestamp:   0x1122334455667788,
+	}
+	payload := s.Payload()
+	assert.True(bytes.Contains(payload, []byte{0xcf, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}))
+	assert.True(bytes.Contains(payload, []byte{0xcf, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}))
+	assert.False(bytes.Contains(payload, []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}))
+	assert.Equal("86a64e6f64654964c42083036dd679ff194edb3f6aa4f42ef53d414cc0379c360480244f0772961c3b0fab5472616e73616374696f6ec42023c9dcf79a89a612e4a5afe9d528e6858c9c90f10c6c58427d80e3da8d82cb29aa5265666572656e63657382a453656c66c4203665a1ba68ac4de30801ab7414d9d88ac36bb969c309724ee7ff827ec09574dca845787465726e616cc42089556154f30252d09d22eb33f0435f11eecdc6c661039cce13b52a8266767902ab526f756e644e756d626572cf0102030405060708a954696d657374616d70cf1122334455667788aa5369676e617475726573c0", hex.EncodeToString(payload))
+	assert.Equal("50483a22ddb7f19ca26837d928b7ab263e8e95a73e95d512acb5479b8c49e0ba", s.PayloadHash().String())
+
+	s.Signatures = []*crypto.Signature{&crypto.Signature{}}
+	assert.Equal(payload, s.Payload())
+	assert.Equal("d60005f5e100", hex.EncodeToString(MsgpackMarshalPanic(NewInteger(1))))
+}
+
 func checkSignature(s *Snapshot, pub crypto.Key) bool {
 	msg := s.PayloadHash()
 	for _, sig := range s.Signatures {
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"time"
 
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -88,21 +89,42 @@ func (tx *Transaction) ViewGhostKey(a *crypto.Key) []*Output {
 }
 
 func (tx *SignedTransaction) Validate(store DataStore) error {
+	return tx.ValidateAt(store, uint64(time.Now().UnixNano()))
+}
+
+// ValidateAt validates the transaction as if it's included in a snapshot with
+// the timestamp, which unlocks the time locked inputs.
+func (tx *SignedTransaction) ValidateAt(store DataStore, timestamp uint64) error {
 	if tx.Version != TxVersion {
-		return fmt.Errorf("invalid tx version %d", tx.Version)
+		return NewValidationError(ErrInvalidVersion, "invalid tx version %d", tx.Version)
+	}
+
+	if len(tx.Inputs) > config.TransactionMaxInputs {
+		return NewTooManyInputsError(len(tx.Inputs), config.TransactionMaxInputs)
+	}
+	if len(tx.Outputs) > config.TransactionMaxOutputs {
+		return NewTooManyOutputsError(len(tx.Outputs), config.TransactionMaxOutputs)
 	}
 
 	if len(tx.Inputs) != len(tx.Signatures) {
-		return fmt.Errorf("invalid tx signature number %d %d", len(tx.Inputs), len(tx.Signatures))
+		return NewValidationError(ErrInvalidSignature, "invalid tx signature number %d %d", len(tx.Inputs), len(tx.Signatures))
 	}
 
 	if len(tx.Extra) > ExtraSizeLimit {
-		return fmt.Errorf("invalid extra size %d", len(tx.Extra))
+		return NewValidationError(ErrInvalidExtra, "invalid extra size %d", len(tx.Extra))
 	}
 
 	msg := MsgpackMarshalPanic(tx.Transaction)
 	if len(msg) > config.TransactionMaximumSize {
-		return fmt.Errorf("invalid transaction size %d", len(msg))
+		return NewValidationError(ErrInvalidSize, "invalid transaction size %d", len(msg))
+	}
+
+	var cacheKey crypto.Hash
+	var verified bool
+	cache := loadVerificationCache()
+	if cache != nil {
+		cacheKey = crypto.NewHash(tx.Marshal())
+		verified = cache.Check(cacheKey)
 	}
 
 	var inputAmount, outputAmount Integer
@@ -135,13 +157,17 @@ func (tx *SignedTransaction) Validate(store DataStore) error {
 			return err
 		}
 		if utxo == nil {
-			return fmt.Errorf("input not found %s:%d", in.Hash.String(), in.Index)
+			return NewValidationError(ErrInputNotFound, "input not found %s:%d", in.Hash.String(), in.Index)
 		}
 		if utxo.Asset.String() != tx.Asset.String() {
 			return fmt.Errorf("invalid input asset %s %s", utxo.Asset.String(), tx.Asset.String())
 		}
 
-		err = validateUTXO(utxo, tx.Signatures[i], msg)
+		if verified {
+			err = validateVerifiedUTXO(utxo, timestamp)
+		} else {
+			err = validateUTXO(utxo, tx.Signatures[i], msg, timestamp)
+		}
 		if err != nil {
 			return err
 		}
@@ -152,18 +178,21 @@ func (tx *SignedTransaction) Validate(store DataStore) error {
 	outputsFilter := make(map[crypto.Key]bool)
 	for _, o := range tx.Outputs {
 		if o.Amount.Sign() <= 0 {
-			return fmt.Errorf("invalid output amount %s", o.Amount.String())
+			return NewValidationError(ErrInvalidAmount, "invalid output amount %s", o.Amount.String())
 		}
 		for _, k := range o.Keys {
 			if outputsFilter[k] {
-				return fmt.Errorf("invalid output key %s", k.String())
+				return NewValidationError(ErrInvalidOutputKey, "invalid output key %s", k.String())
 			}
 			outputsFilter[k] = true
+			if !k.CheckSubgroup() {
+				return NewValidationError(ErrInvalidOutputKey, "invalid output key subgroup %s", k.String())
+			}
 			exist, err := store.CheckGhost(k)
 			if err != nil {
 				return err
 			} else if exist {
-				return fmt.Errorf("invalid output key %s", k.String())
+				return NewValidationError(ErrInvalidOutputKey, "invalid output key %s", k.String())
 			}
 		}
 		outputAmount = outputAmount.Add(o.Amount)
@@ -202,8 +231,12 @@ func (tx *SignedTransaction) Validate(store DataStore) error {
 		}
 	}
 
-	if inputAmount.Cmp(outputAmount) != 0 {
-		return fmt.Errorf("invalid input output amount %s %s", inputAmount.String(), outputAmount.String())
+	err := validateFee(&tx.Transaction, inputAmount, outputAmount)
+	if err != nil {
+		return err
+	}
+	if cache != nil && !verified {
+		cache.Add(cacheKey)
 	}
 	return nil
 }
@@ -224,18 +257,15 @@ func (tx *SignedTransaction) validateDepositInput(store DataStore, msg []byte) e
 		}
 	}
 	if !valid {
-		return fmt.Errorf("invalid domain signature for deposit")
+		return NewValidationError(ErrInvalidSignature, "invalid domain signature for deposit")
 	}
 	return nil
 }
 
-func validateUTXO(utxo *UTXO, sigs []crypto.Signature, msg []byte) error {
-	switch utxo.Type {
-	case OutputTypeScript:
-	case OutputTypeNodePledge:
-	case OutputTypeNodeAccept:
-	default:
-		return fmt.Errorf("invalid input type %d", utxo.Type)
+func validateUTXO(utxo *UTXO, sigs []crypto.Signature, msg []byte, timestamp uint64) error {
+	err := validateUTXOType(utxo)
+	if err != nil {
+		return err
 	}
 
 	var offset, valid int
@@ -251,7 +281,32 @@ func validateUTXO(utxo *UTXO, sigs []crypto.Signature, msg []byte) error {
 		}
 	}
 
-	return utxo.Script.Validate(valid)
+	err = utxo.Script.Validate(valid)
+	if err != nil {
+		return err
+	}
+	return utxo.Script.ValidateTimeLock(timestamp)
+}
+
+// validateVerifiedUTXO skips the signatures verified before, but the time
+// lock still depends on the timestamp.
+func validateVerifiedUTXO(utxo *UTXO, timestamp uint64) error {
+	err := validateUTXOType(utxo)
+	if err != nil {
+		return err
+	}
+	return utxo.Script.ValidateTimeLock(timestamp)
+}
+
+func validateUTXOType(utxo *UTXO) error {
+	switch utxo.Type {
+	case OutputTypeScript:
+	case OutputTypeNodePledge:
+	case OutputTypeNodeAccept:
+	default:
+		return fmt.Errorf("invalid input type %d", utxo.Type)
+	}
+	return nil
 }
 
 func (tx *Transaction) PayloadHash() crypto.Hash {
@@ -2,10 +2,39 @@ package common
 
 import (
 	"fmt"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/MixinNetwork/mixin/crypto"
 )
 
+const NodePledgeAmount = 10000
+
+var nodeAcceptAmount atomic.Value
+
+// SetNodeAcceptAmount sets the amount each node locks in the accept output,
+// it's the pledge amount unless the genesis sets a lower one, and the accept
+// transactions are validated against it, so it's a consensus rule too.
+func SetNodeAcceptAmount(amount string) error {
+	if _, err := strconv.ParseFloat(amount, 64); err != nil {
+		return fmt.Errorf("invalid node accept amount %s", amount)
+	}
+	a := NewIntegerFromString(amount)
+	if a.Sign() <= 0 || a.Cmp(NewInteger(NodePledgeAmount)) > 0 {
+		return fmt.Errorf("invalid node accept amount %s", amount)
+	}
+	nodeAcceptAmount.Store(a)
+	return nil
+}
+
+func NodeAcceptAmount() Integer {
+	amount, ok := nodeAcceptAmount.Load().(Integer)
+	if !ok {
+		return NewInteger(NodePledgeAmount)
+	}
+	return amount
+}
+
 func (tx *Transaction) validateNodePledge(store DataStore) error {
 	if len(tx.Outputs) != 1 {
 		return fmt.Errorf("invalid outputs count %d for pledge transaction", len(tx.Outputs))
@@ -15,7 +44,7 @@ func (tx *Transaction) validateNodePledge(store DataStore) error {
 	}
 
 	o := tx.Outputs[0]
-	if o.Amount.Cmp(NewInteger(10000)) != 0 {
+	if o.Amount.Cmp(NewInteger(NodePledgeAmount)) != 0 {
 		return fmt.Errorf("invalid pledge amount %s", o.Amount.String())
 	}
 	nodes := store.ReadConsensusNodes()
@@ -84,7 +113,11 @@ func (tx *Transaction) validateNodeAccept(store DataStore, inputAmount Integer)
 	if pledging == nil {
 		return fmt.Errorf("no pledging node needs to get accepted")
 	}
-	nodesAmount := NewInteger(uint64(10000 * len(nodes)))
+	// each accepted node locked the accept amount, and the pledging node the pledge
+	nodesAmount := NewInteger(NodePledgeAmount)
+	for i := 1; i < len(nodes); i++ {
+		nodesAmount = nodesAmount.Add(NodeAcceptAmount())
+	}
 	if inputAmount.Cmp(nodesAmount) != 0 {
 		return fmt.Errorf("invalid accept input amount %s %s", inputAmount.String(), nodesAmount.String())
 	}
@@ -2,8 +2,12 @@ package common
 
 import (
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/assert"
 )
@@ -42,11 +46,163 @@ func TestTransaction(t *testing.T) {
 	assert.Len(outputs, 1)
 	assert.NotEqual(outputs[0].Keys[1].String(), accounts[1].PublicSpendKey.String())
 	assert.NotEqual(outputs[0].Keys[1].String(), accounts[1].PublicViewKey.String())
+
+	crafted := randomAccount()
+	crafted.PublicSpendKey.UnmarshalJSON([]byte(`"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"`))
+	tx = NewTransaction(XINAssetId)
+	tx.AddInput(genesisHash, 0)
+	tx.AddInput(genesisHash, 1)
+	tx.AddScriptOutput([]Address{accounts[0], crafted}, script, NewInteger(20000))
+	signed = &SignedTransaction{Transaction: *tx}
+	for i, _ := range signed.Inputs {
+		err := signed.SignInput(store, i, accounts)
+		assert.Nil(err)
+	}
+	err = signed.Validate(store)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid output key subgroup")
+}
+
+func TestTransactionTimeLock(t *testing.T) {
+	assert := assert.New(t)
+
+	accounts := make([]Address, 0)
+	for i := 0; i < 3; i++ {
+		accounts = append(accounts, randomAccount())
+	}
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	lock := uint64(time.Now().Add(time.Hour).UnixNano())
+	store := storeImpl{seed: seed, accounts: accounts, lock: lock}
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddInput(crypto.Hash{}, 0)
+	tx.AddInput(crypto.Hash{}, 1)
+	tx.AddScriptOutput(accounts, Script{OperatorCmp, OperatorSum, 2}, NewInteger(20000))
+	signed := &SignedTransaction{Transaction: *tx}
+	for i, _ := range signed.Inputs {
+		err := signed.SignInput(store, i, accounts)
+		assert.Nil(err)
+	}
+
+	err := signed.Validate(store)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "script time locked")
+	err = signed.ValidateAt(store, lock-1)
+	assert.NotNil(err)
+	err = signed.ValidateAt(store, lock)
+	assert.Nil(err)
+	err = signed.ValidateAt(store, lock+uint64(time.Second))
+	assert.Nil(err)
+
+	store.lock = 0
+	err = signed.ValidateAt(store, lock-1)
+	assert.Nil(err)
+}
+
+func TestTransactionLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	accounts := []Address{randomAccount()}
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	script := Script{OperatorCmp, OperatorSum, 1}
+	store := storeImpl{seed: seed, accounts: accounts}
+
+	build := func(outputs int) *SignedTransaction {
+		tx := NewTransaction(XINAssetId)
+		tx.AddInput(crypto.Hash{}, 0)
+		for i := 1; i < outputs; i++ {
+			tx.AddScriptOutput(accounts, script, NewInteger(1))
+		}
+		tx.AddScriptOutput(accounts, script, NewInteger(10000).Sub(NewInteger(uint64(outputs-1))))
+		signed := &SignedTransaction{Transaction: *tx}
+		err := signed.SignInput(store, 0, accounts)
+		assert.Nil(err)
+		return signed
+	}
+
+	err := build(config.TransactionMaxOutputs).Validate(store)
+	assert.Nil(err)
+	err = build(config.TransactionMaxOutputs + 1).Validate(store)
+	assert.True(errors.Is(err, ErrTooManyOutputs))
+	assert.Equal(fmt.Sprintf("invalid tx outputs count %d/%d", config.TransactionMaxOutputs+1, config.TransactionMaxOutputs), err.Error())
+
+	tx := &SignedTransaction{Transaction: *NewTransaction(XINAssetId)}
+	for i := 0; i < config.TransactionMaxInputs; i++ {
+		tx.AddInput(crypto.Hash{}, i)
+	}
+	err = tx.Validate(store)
+	assert.NotNil(err)
+	assert.False(errors.Is(err, ErrTooManyInputs))
+	tx.AddInput(crypto.Hash{}, config.TransactionMaxInputs)
+	err = tx.Validate(store)
+	assert.True(errors.Is(err, ErrTooManyInputs))
+}
+
+func TestNodeAcceptAmount(t *testing.T) {
+	assert := assert.New(t)
+	defer SetNodeAcceptAmount("10000")
+
+	assert.Equal(NewInteger(NodePledgeAmount), NodeAcceptAmount())
+	assert.NotNil(SetNodeAcceptAmount("0"))
+	assert.NotNil(SetNodeAcceptAmount("10001"))
+	assert.NotNil(SetNodeAcceptAmount("invalid"))
+
+	accepted, pledging := randomAccount(), randomAccount()
+	for _, a := range []*Address{&accepted, &pledging} {
+		a.PrivateViewKey = a.PublicSpendKey.DeterministicHashDerive()
+		a.PublicViewKey = a.PrivateViewKey.Public()
+	}
+	store := nodeStoreImpl{
+		nodes: []*Node{
+			{Signer: accepted, State: NodeStateAccepted},
+			{Signer: randomAccount(), State: NodeStateAccepted},
+			{Signer: pledging, State: NodeStatePledging},
+		},
+		transactions: make(map[crypto.Hash]*SignedTransaction),
+	}
+	accept := &SignedTransaction{Transaction: *NewTransaction(XINAssetId)}
+	accept.Outputs = []*Output{{Type: OutputTypeNodeAccept}}
+	accept.Extra = accepted.PublicSpendKey[:]
+	pledge := &SignedTransaction{Transaction: *NewTransaction(XINAssetId)}
+	pledge.Outputs = []*Output{{Type: OutputTypeNodePledge}}
+	pledge.Extra = pledging.PublicSpendKey[:]
+	store.transactions[accept.PayloadHash()] = accept
+	store.transactions[pledge.PayloadHash()] = pledge
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddInput(accept.PayloadHash(), 0)
+	tx.AddInput(pledge.PayloadHash(), 0)
+	tx.Outputs = []*Output{{Type: OutputTypeNodeAccept}}
+	assert.Nil(tx.validateNodeAccept(store, NewInteger(30000)))
+
+	assert.Nil(SetNodeAcceptAmount("6000"))
+	assert.Equal(NewInteger(6000), NodeAcceptAmount())
+	err := tx.validateNodeAccept(store, NewInteger(30000))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid accept input amount")
+	assert.Nil(tx.validateNodeAccept(store, NewInteger(22000)))
+}
+
+type nodeStoreImpl struct {
+	storeImpl
+	nodes        []*Node
+	transactions map[crypto.Hash]*SignedTransaction
+}
+
+func (store nodeStoreImpl) ReadConsensusNodes() []*Node {
+	return store.nodes
+}
+
+func (store nodeStoreImpl) ReadTransaction(hash crypto.Hash) (*SignedTransaction, error) {
+	return store.transactions[hash], nil
 }
 
 type storeImpl struct {
 	seed     []byte
 	accounts []Address
+	lock     uint64
 }
 
 func (store storeImpl) ReadUTXO(hash crypto.Hash, index int) (*UTXO, error) {
@@ -63,6 +219,9 @@ func (store storeImpl) ReadUTXO(hash crypto.Hash, index int) (*UTXO, error) {
 		Script: Script{OperatorCmp, OperatorSum, uint8(index + 1)},
 		Mask:   genesisMaskR,
 	}
+	if store.lock > 0 {
+		out.Script = ScriptTimeLock{Required: uint8(index + 1), Until: store.lock}.Compile()
+	}
 	utxo := &UTXO{
 		Input:  in,
 		Output: out,
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidVersion   = errors.New("invalid tx version")
+	ErrInvalidSignature = errors.New("invalid tx signature")
+	ErrInputLocked      = errors.New("input locked")
+	ErrInputNotFound    = errors.New("input not found")
+	ErrInvalidExtra     = errors.New("invalid extra size")
+	ErrInvalidSize      = errors.New("invalid transaction size")
+	ErrInvalidOutputKey = errors.New("invalid output key")
+	ErrTimeLocked       = errors.New("script time locked")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrInvalidFee       = errors.New("invalid transaction fee")
+	ErrTooManyInputs    = errors.New("invalid tx inputs count over limit")
+	ErrTooManyOutputs   = errors.New("invalid tx outputs count over limit")
+)
+
+// ValidationError keeps the detailed message of a validation failure, while
+// errors.Is matches its kind, one of the errors above.
+type ValidationError struct {
+	Kind    error
+	Message string
+}
+
+func NewValidationError(kind error, format string, a ...interface{}) error {
+	return &ValidationError{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func NewTooManyInputsError(count, limit int) error {
+	return NewValidationError(ErrTooManyInputs, "invalid tx inputs count %d/%d", count, limit)
+}
+
+func NewTooManyOutputsError(count, limit int) error {
+	return NewValidationError(ErrTooManyOutputs, "invalid tx outputs count %d/%d", count, limit)
+}
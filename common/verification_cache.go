@@ -0,0 +1,64 @@
+package common
+
+import (
+	"sync/atomic"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/hashicorp/golang-lru"
+)
+
+// verificationCache remembers the transactions with all input signatures
+// verified, keyed by the hash of the whole signed transaction, so a copy with
+// any signature changed is never a hit. The signatures only depend on the
+// referenced outputs, which never change, thus the cache never invalidates.
+type verificationCache struct {
+	lru    *lru.Cache
+	hits   uint64
+	misses uint64
+}
+
+var verifications atomic.Value
+
+// EnableVerificationCache caches at most size verified transactions, or
+// disables the cache if size is less than 1.
+func EnableVerificationCache(size int) {
+	if size < 1 {
+		verifications.Store((*verificationCache)(nil))
+		return
+	}
+	if c := loadVerificationCache(); c != nil {
+		c.lru.Resize(size)
+		return
+	}
+	c, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+	verifications.Store(&verificationCache{lru: c})
+}
+
+func VerificationCacheInfo() (int, uint64, uint64) {
+	c := loadVerificationCache()
+	if c == nil {
+		return 0, 0, 0
+	}
+	return c.lru.Len(), atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+}
+
+func loadVerificationCache() *verificationCache {
+	c, _ := verifications.Load().(*verificationCache)
+	return c
+}
+
+func (c *verificationCache) Check(key crypto.Hash) bool {
+	if c.lru.Contains(key) {
+		atomic.AddUint64(&c.hits, 1)
+		return true
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return false
+}
+
+func (c *verificationCache) Add(key crypto.Hash) {
+	c.lru.Add(key, true)
+}
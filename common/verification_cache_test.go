@@ -0,0 +1,115 @@
+package common
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationCache(t *testing.T) {
+	assert := assert.New(t)
+
+	EnableVerificationCache(16)
+	defer EnableVerificationCache(0)
+
+	store, signed := testVerificationTransaction()
+	err := signed.Validate(store)
+	assert.Nil(err)
+	size, hits, misses := VerificationCacheInfo()
+	assert.Equal(1, size)
+	assert.Equal(uint64(0), hits)
+	assert.Equal(uint64(1), misses)
+	err = signed.Validate(store)
+	assert.Nil(err)
+	size, hits, misses = VerificationCacheInfo()
+	assert.Equal(1, size)
+	assert.Equal(uint64(1), hits)
+	assert.Equal(uint64(1), misses)
+
+	forged := &SignedTransaction{Transaction: signed.Transaction}
+	forged.Signatures = [][]crypto.Signature{signed.Signatures[0], {signed.Signatures[0][0]}}
+	err = forged.Validate(store)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid signature keys")
+	size, hits, misses = VerificationCacheInfo()
+	assert.Equal(1, size)
+	assert.Equal(uint64(1), hits)
+	assert.Equal(uint64(2), misses)
+	err = forged.Validate(store)
+	assert.NotNil(err)
+	size, _, _ = VerificationCacheInfo()
+	assert.Equal(1, size)
+
+	EnableVerificationCache(0)
+	size, hits, misses = VerificationCacheInfo()
+	assert.Equal(0, size)
+	assert.Equal(uint64(0), hits)
+	assert.Equal(uint64(0), misses)
+	err = signed.Validate(store)
+	assert.Nil(err)
+}
+
+// testUTXOStore reads the outputs derived in advance, so the benchmark is not
+// dominated by the ghost key derivation of storeImpl.
+type testUTXOStore struct {
+	storeImpl
+	utxos map[int]*UTXO
+}
+
+func (store testUTXOStore) ReadUTXO(hash crypto.Hash, index int) (*UTXO, error) {
+	return store.utxos[index], nil
+}
+
+func BenchmarkValidateGossiped(b *testing.B) {
+	impl, signed := testVerificationTransaction()
+	store := testUTXOStore{storeImpl: impl.(storeImpl), utxos: make(map[int]*UTXO)}
+	for _, in := range signed.Inputs {
+		utxo, _ := impl.ReadUTXO(in.Hash, in.Index)
+		store.utxos[in.Index] = utxo
+	}
+	benchmarkValidate(b, store, signed, 0)
+	benchmarkValidate(b, store, signed, 1024)
+}
+
+func benchmarkValidate(b *testing.B, store DataStore, signed *SignedTransaction, size int) {
+	name := "uncached"
+	if size > 0 {
+		name = "cached"
+	}
+	b.Run(name, func(b *testing.B) {
+		EnableVerificationCache(size)
+		defer EnableVerificationCache(0)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			err := signed.Validate(store)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func testVerificationTransaction() (DataStore, *SignedTransaction) {
+	accounts := make([]Address, 0)
+	for i := 0; i < 3; i++ {
+		accounts = append(accounts, randomAccount())
+	}
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	store := storeImpl{seed: seed, accounts: accounts}
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddInput(crypto.Hash{}, 0)
+	tx.AddInput(crypto.Hash{}, 1)
+	tx.AddScriptOutput(accounts, Script{OperatorCmp, OperatorSum, 2}, NewInteger(20000))
+	signed := &SignedTransaction{Transaction: *tx}
+	for i := range signed.Inputs {
+		err := signed.SignInput(store, i, accounts)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return store, signed
+}
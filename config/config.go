@@ -1,6 +1,14 @@
 package config
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"runtime"
+	"time"
+)
 
 const (
 	Debug        = true
@@ -10,4 +18,199 @@ const (
 	SnapshotReferenceThreshold = 10
 	TransactionMaximumSize     = 1024 * 1024
 	CacheTTL                   = 2 * time.Hour
+
+	RelayPolicyFull     = "full"
+	RelayPolicyAnnounce = "announce"
+
+	// The limits are consensus rules applied to all transaction validations,
+	// the max-inputs and max-outputs of the config file are lower limits
+	// applied only when a node accepts a new transaction to its mempool.
+	TransactionMaxInputs  = 256
+	TransactionMaxOutputs = 256
+)
+
+const (
+	FsyncPolicyImmediate = "immediate"
+	FsyncPolicyPeriodic  = "periodic"
+	FsyncPolicyOS        = "os"
 )
+
+// The timestamp granularity of new snapshots, the genesis snapshots are
+// always in nanoseconds.
+const (
+	TimestampGranularityNano  = "ns"
+	TimestampGranularityMicro = "us"
+	TimestampGranularityMilli = "ms"
+)
+
+const (
+	MetricsBackendPrometheus = "prometheus"
+	MetricsBackendStatsD     = "statsd"
+	MetricsBackendNone       = "none"
+)
+
+type Custom struct {
+	SnapshotVerifiers  int            `json:"snapshot-verifiers"`
+	VerifyChecksum     bool           `json:"verify-checksum"`
+	StoreRetrySeconds  int            `json:"store-retry-seconds"`
+	DNSSeeds           []string       `json:"dns-seeds"`
+	TransactionCache   int            `json:"transaction-cache"`
+	VerifyCache        int            `json:"verify-cache"`
+	LogRejections      bool           `json:"log-rejections"`
+	StallAlertSeconds  int            `json:"stall-alert-seconds"`
+	MmapScan           bool           `json:"mmap-scan"`
+	Quarantine         bool           `json:"quarantine-corrupted"`
+	MaxInputs          int            `json:"max-inputs"`
+	MaxOutputs         int            `json:"max-outputs"`
+	RecoverTopology    bool           `json:"recover-topology"`
+	GenesisParallel    bool           `json:"genesis-parallel"`
+	GenesisLight       bool           `json:"genesis-light-verification"`
+	GenesisLightSample int            `json:"genesis-light-sample"`
+	GenesisWriters     int            `json:"genesis-write-workers"`
+	RelayPolicy        string         `json:"relay-policy"`
+	EpochSeconds       int            `json:"epoch-seconds"`
+	FsyncPolicy        string         `json:"fsync-policy"`
+	FsyncSeconds       int            `json:"fsync-seconds"`
+	DialConcurrency    int            `json:"dial-concurrency"`
+	DialTimeout        int            `json:"dial-timeout-seconds"`
+	HandshakeTimeout   int            `json:"handshake-timeout-seconds"`
+	MetricsBackend     string         `json:"metrics-backend"`
+	StatsDAddress      string         `json:"statsd-address"`
+	FlowControl        bool           `json:"flow-control"`
+	ReplicaPrimary     string         `json:"replica-primary"`
+	MaxRoundGap        int            `json:"max-round-gap"`
+	AddressBook        bool           `json:"address-book"`
+	AddressBookMaxAge  int            `json:"address-book-max-age-seconds"`
+	OtherNetworks      []OtherNetwork `json:"other-networks"`
+	SignerReuseStrict  bool           `json:"signer-reuse-strict"`
+	CompactRounds      bool           `json:"compact-rounds"`
+	TimeGranularity    string         `json:"timestamp-granularity"`
+	MempoolMaxAge      int            `json:"mempool-max-age-seconds"`
+	MempoolMaxSize     int            `json:"mempool-max-size"`
+	RequestTimeout     int            `json:"request-timeout-seconds"`
+	RequestAttempts    int            `json:"request-attempts"`
+	GossipTopics       bool           `json:"gossip-topics"`
+}
+
+// OtherNetwork records the signer of a node run by the same operator on
+// another network, e.g. a testnet, to guard against the signer key reused.
+type OtherNetwork struct {
+	Network string `json:"network"`
+	Signer  string `json:"signer"`
+}
+
+func Initialize(file string) (*Custom, error) {
+	var custom Custom
+	f, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(f) > 0 {
+		err = json.Unmarshal(f, &custom)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if custom.SnapshotVerifiers < 1 {
+		custom.SnapshotVerifiers = runtime.NumCPU()
+	}
+	if custom.StoreRetrySeconds < 1 {
+		custom.StoreRetrySeconds = 10
+	}
+	if custom.TransactionCache < 1 {
+		custom.TransactionCache = 4096
+	}
+	if custom.StallAlertSeconds < 1 {
+		custom.StallAlertSeconds = 600
+	}
+	if custom.EpochSeconds < 1 {
+		custom.EpochSeconds = 86400
+	}
+	if custom.FsyncSeconds < 1 {
+		custom.FsyncSeconds = 1
+	}
+	if custom.DialConcurrency < 1 {
+		custom.DialConcurrency = 16
+	}
+	if custom.DialTimeout < 1 {
+		custom.DialTimeout = 10
+	}
+	if custom.HandshakeTimeout < 1 {
+		custom.HandshakeTimeout = 3
+	}
+	if custom.GenesisLightSample < 1 {
+		custom.GenesisLightSample = 3
+	}
+	if custom.GenesisWriters < 1 {
+		custom.GenesisWriters = 1
+	}
+	if custom.MaxRoundGap < 1 {
+		custom.MaxRoundGap = 1024
+	}
+	if custom.AddressBookMaxAge < 1 {
+		custom.AddressBookMaxAge = 604800
+	}
+	if custom.MempoolMaxAge < 1 {
+		custom.MempoolMaxAge = 3600
+	}
+	if custom.MempoolMaxSize < 1 {
+		custom.MempoolMaxSize = 65536
+	}
+	if custom.RequestTimeout < 1 {
+		custom.RequestTimeout = 5
+	}
+	if custom.RequestAttempts < 1 {
+		custom.RequestAttempts = 3
+	}
+	if custom.MaxInputs < 1 {
+		custom.MaxInputs = TransactionMaxInputs
+	}
+	if custom.MaxOutputs < 1 {
+		custom.MaxOutputs = TransactionMaxOutputs
+	}
+	switch custom.RelayPolicy {
+	case "":
+		custom.RelayPolicy = RelayPolicyFull
+	case RelayPolicyFull, RelayPolicyAnnounce:
+	default:
+		return nil, fmt.Errorf("invalid relay policy %s", custom.RelayPolicy)
+	}
+	switch custom.TimeGranularity {
+	case "":
+		custom.TimeGranularity = TimestampGranularityNano
+	case TimestampGranularityNano, TimestampGranularityMicro, TimestampGranularityMilli:
+	default:
+		return nil, fmt.Errorf("invalid timestamp granularity %s", custom.TimeGranularity)
+	}
+	switch custom.FsyncPolicy {
+	case "":
+		custom.FsyncPolicy = FsyncPolicyImmediate
+	case FsyncPolicyImmediate, FsyncPolicyPeriodic, FsyncPolicyOS:
+	default:
+		return nil, fmt.Errorf("invalid fsync policy %s", custom.FsyncPolicy)
+	}
+	switch custom.MetricsBackend {
+	case "":
+		custom.MetricsBackend = MetricsBackendPrometheus
+	case MetricsBackendPrometheus, MetricsBackendNone:
+	case MetricsBackendStatsD:
+		if custom.StatsDAddress == "" {
+			return nil, fmt.Errorf("invalid statsd address %s", custom.StatsDAddress)
+		}
+	default:
+		return nil, fmt.Errorf("invalid metrics backend %s", custom.MetricsBackend)
+	}
+	if custom.ReplicaPrimary != "" {
+		u, err := url.Parse(custom.ReplicaPrimary)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid replica primary %s", custom.ReplicaPrimary)
+		}
+	}
+	if custom.MaxInputs > TransactionMaxInputs {
+		return nil, fmt.Errorf("invalid max inputs %d/%d", custom.MaxInputs, TransactionMaxInputs)
+	}
+	if custom.MaxOutputs > TransactionMaxOutputs {
+		return nil, fmt.Errorf("invalid max outputs %d/%d", custom.MaxOutputs, TransactionMaxOutputs)
+	}
+	return &custom, nil
+}
@@ -12,6 +12,16 @@ import (
 
 type Key [32]byte
 
+var (
+	keyGroupOrder = [32]byte{
+		0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58,
+		0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+	}
+	keyIdentity = [32]byte{1}
+)
+
 func NewKeyFromSeed(seed []byte) Key {
 	var key [32]byte
 	var src [64]byte
@@ -28,6 +38,21 @@ func (k Key) Public() Key {
 	return tmp
 }
 
+// CheckSubgroup tells whether the key is a valid point in the prime-order
+// subgroup, i.e. multiplied by the group order it gives the identity point.
+func (k Key) CheckSubgroup() bool {
+	var point edwards25519.ExtendedGroupElement
+	var point2 edwards25519.ProjectiveGroupElement
+
+	tmp := [32]byte(k)
+	if !point.FromBytes(&tmp) {
+		return false
+	}
+	edwards25519.GeScalarMult(&point2, &keyGroupOrder, &point)
+	point2.ToBytes(&tmp)
+	return tmp == keyIdentity
+}
+
 func (k Key) DeterministicHashDerive() Key {
 	seed := NewHash(k[:])
 	return NewKeyFromSeed(append(seed[:], seed[:]...))
@@ -49,6 +49,29 @@ func TestGhostKey(t *testing.T) {
 	assert.True(A.Verify(a[:], sig))
 }
 
+func TestKeySubgroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var torsion Key
+	torsion.UnmarshalJSON([]byte(`"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"`))
+	assert.False(torsion.CheckSubgroup())
+	invalid := Key{2}
+	assert.False(invalid.CheckSubgroup())
+
+	for i := 0; i < 16; i++ {
+		a := randomKey()
+		A := a.Public()
+		assert.True(A.CheckSubgroup())
+		b := randomKey()
+		B := b.Public()
+		r := randomKey()
+		P := DeriveGhostPublicKey(&r, &A, &B, uint64(i))
+		assert.True(P.CheckSubgroup())
+		P = DeriveGhostPublicKey(&r, &A, &torsion, uint64(i))
+		assert.False(P.CheckSubgroup())
+	}
+}
+
 func randomKey() Key {
 	seed := make([]byte, 64)
 	rand.Read(seed)
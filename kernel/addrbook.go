@@ -0,0 +1,68 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/network"
+)
+
+const (
+	stateKeyAddressBook     = "address-book"
+	AddressBookSaveInterval = time.Minute
+)
+
+// loadAddressBook adds the consensus neighbors recently dialed successfully
+// before the ones from nodes.json, so a restarted node reconnects to the
+// addresses known to work first. The config is authoritative though, so an
+// entry with an address different from nodes.json is dropped from the book.
+func (node *Node) loadAddressBook() error {
+	neighbors, err := node.readNeighborsConfig()
+	if err != nil {
+		return err
+	}
+	var entries []network.AddressBookEntry
+	_, err = node.store.StateGet(stateKeyAddressBook, &entries)
+	if err != nil {
+		return err
+	}
+	book := node.Peer.AddressBook()
+	book.Load(entries)
+	pruned := book.Prune(node.addressBookMaxAge())
+	logger.Printf("ADDRESS BOOK load %d prune %d\n", len(entries), pruned)
+
+	for _, e := range book.Entries() {
+		if host, found := neighbors[e.Id]; found && host != e.Address {
+			book.Remove(e.Id)
+			continue
+		}
+		if e.Reputation < 1 || node.ConsensusNodes[e.Id] == nil {
+			continue
+		}
+		node.Peer.AddNeighbor(e.Id, e.Address)
+	}
+	return node.SaveAddressBook()
+}
+
+func (node *Node) SaveAddressBook() error {
+	book := node.Peer.AddressBook()
+	book.Prune(node.addressBookMaxAge())
+	return node.store.StateSet(stateKeyAddressBook, book.Entries())
+}
+
+func (node *Node) LoopSaveAddressBook() error {
+	ticker := time.NewTicker(AddressBookSaveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := node.SaveAddressBook()
+		if err != nil {
+			logger.Println("ADDRESS BOOK save error", err)
+		}
+	}
+	return nil
+}
+
+func (node *Node) addressBookMaxAge() time.Duration {
+	return time.Duration(node.custom.AddressBookMaxAge) * time.Second
+}
@@ -0,0 +1,84 @@
+package kernel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/network"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressBookReconnect(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	err := node.store.Close()
+	assert.Nil(err)
+
+	data := fmt.Sprintf(`{"signer":"%s","address-book":true,"address-book-max-age-seconds":3600}`, signers[0].PrivateSpendKey.String())
+	err = ioutil.WriteFile(dir+"/config.json", []byte(data), 0644)
+	assert.Nil(err)
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(err)
+	assert.Nil(node.Peer.GetNeighbor(signers[1].Hash().ForNetwork(node.networkId)))
+
+	good := signers[1].Hash().ForNetwork(node.networkId)
+	recent := signers[2].Hash().ForNetwork(node.networkId)
+	stale := signers[3].Hash().ForNetwork(node.networkId)
+	failing := signers[4].Hash().ForNetwork(node.networkId)
+	book := node.Peer.AddressBook()
+	book.Succeed(good, "127.0.0.1:18001")
+	book.Succeed(good, "127.0.0.1:18001")
+	book.Succeed(recent, "127.0.0.1:18002")
+	book.Load([]network.AddressBookEntry{{
+		Id:         stale,
+		Address:    "127.0.0.1:18003",
+		LastSeen:   time.Now().Add(-2 * time.Hour),
+		Reputation: 10,
+	}})
+	book.Succeed(failing, "127.0.0.1:18004")
+	book.Fail(failing, "127.0.0.1:18004")
+	book.Fail(failing, "127.0.0.1:18004")
+	err = node.SaveAddressBook()
+	assert.Nil(err)
+	err = store.Close()
+	assert.Nil(err)
+
+	store, err = storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	var entries []network.AddressBookEntry
+	found, err := store.StateGet(stateKeyAddressBook, &entries)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Len(entries, 3)
+	assert.Equal(good, entries[0].Id)
+	assert.Equal(2, entries[0].Reputation)
+	assert.Equal(recent, entries[1].Id)
+	assert.Equal(failing, entries[2].Id)
+	assert.Equal(-1, entries[2].Reputation)
+
+	testWriteNodes(t, dir, []common.Address{signers[0], signers[2]})
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(err)
+	peer := node.Peer.GetNeighbor(good)
+	assert.NotNil(peer)
+	assert.Equal("127.0.0.1:18001", peer.Address)
+	peer = node.Peer.GetNeighbor(recent)
+	assert.NotNil(peer)
+	assert.Equal("127.0.0.1:17241", peer.Address)
+	assert.Nil(node.Peer.GetNeighbor(stale))
+	assert.Nil(node.Peer.GetNeighbor(failing))
+	entries = node.Peer.AddressBook().Entries()
+	assert.Len(entries, 2)
+	assert.Equal(good, entries[0].Id)
+	assert.Equal(failing, entries[1].Id)
+}
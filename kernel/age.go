@@ -0,0 +1,24 @@
+package kernel
+
+import (
+	"fmt"
+	"time"
+)
+
+// SnapshotTimeRange returns the genesis epoch as the earliest, and the
+// timestamp of the snapshot at the topological tip as the latest.
+func (node *Node) SnapshotTimeRange() (earliest, latest time.Time, err error) {
+	seq := node.store.TopologySequence()
+	if seq == 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("no snapshots")
+	}
+	snapshots, err := node.store.ReadSnapshotsSinceTopology(seq-1, 1)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if len(snapshots) != 1 {
+		return time.Time{}, time.Time{}, fmt.Errorf("snapshot not found at topology %d", seq-1)
+	}
+	latest = time.Unix(0, int64(snapshots[0].Timestamp))
+	return node.epoch, latest, nil
+}
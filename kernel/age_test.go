@@ -0,0 +1,32 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotTimeRange(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	earliest, latest, err := node.SnapshotTimeRange()
+	assert.Nil(err)
+	assert.Equal(time.Unix(gns.Epoch, 0), earliest)
+	assert.Equal(time.Unix(gns.Epoch, 0).Add(DomainSnapshotTimestampOffset), latest)
+
+	tx := testMintTransaction(common.XINAssetId, 100)
+	s := testWriteSnapshot(t, node, tx)
+	earliest, latest, err = node.SnapshotTimeRange()
+	assert.Nil(err)
+	assert.Equal(time.Unix(gns.Epoch, 0), earliest)
+	assert.Equal(time.Unix(0, int64(s.Timestamp)), latest)
+}
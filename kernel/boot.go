@@ -1,13 +1,25 @@
 package kernel
 
 import (
+	"errors"
+	"sync/atomic"
+	"time"
+
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/network"
 	"github.com/MixinNetwork/mixin/storage"
 )
 
-var globalNode *Node
+var (
+	globalNode  *Node
+	loopRunning int32
+)
 
+// Loop runs the kernel until the node is shut down, the store is not closed
+// by it.
 func Loop(store storage.Store, addr string, dir string) error {
+	atomic.StoreInt32(&loopRunning, 1)
+	defer atomic.StoreInt32(&loopRunning, 0)
 	node, err := SetupNode(store, addr, dir)
 	if err != nil {
 		return err
@@ -16,7 +28,31 @@ func Loop(store storage.Store, addr string, dir string) error {
 	panicGo(node.ListenNeighbors)
 	panicGo(node.ConsumeMempool)
 	panicGo(node.LoadCacheToQueue)
-	return node.ConsumeQueue()
+	panicGo(node.LoopReloadSignal)
+	panicGo(node.LoopShutdownSignal)
+	panicGo(node.LoopWatchdog)
+	panicGo(node.LoopEvictMempool)
+	if node.custom.AddressBook {
+		panicGo(node.LoopSaveAddressBook)
+	}
+	panicGo(node.Peer.LoopBootstrapSeeds)
+	if node.custom.Quarantine {
+		panicGo(node.LoopRefetchQuarantinedSnapshots)
+	}
+	if len(node.custom.DNSSeeds) > 0 {
+		panicGo(func() error {
+			return node.Peer.LoopDNSSeeds(node.custom.DNSSeeds)
+		})
+	}
+	panicGo(node.ConsumeQueue)
+	<-node.done
+	return nil
+}
+
+// Running tells whether the kernel loop owns the store, all writes should go
+// through the kernel then.
+func Running() bool {
+	return atomic.LoadInt32(&loopRunning) == 1
 }
 
 func NetworkId() crypto.Hash {
@@ -40,6 +76,61 @@ func TopologicalOrder() uint64 {
 	return globalNode.TopoCounter.seq
 }
 
+func SnapshotBufferInfo() SnapshotBufferStats {
+	if globalNode == nil {
+		return SnapshotBufferStats{}
+	}
+	return globalNode.buffer.Stats()
+}
+
+func Degraded() bool {
+	if globalNode == nil {
+		return false
+	}
+	return globalNode.Degraded()
+}
+
+func PeerMetrics() []network.PeerMetricsInfo {
+	if globalNode == nil {
+		return []network.PeerMetricsInfo{}
+	}
+	return globalNode.Peer.PeerMetrics()
+}
+
+// MajorityLag is the lag behind the majority of the consensus peers, the
+// node is considered synced only when the lag is within tolerance.
+func MajorityLag() (uint64, bool) {
+	if globalNode == nil {
+		return 0, false
+	}
+	lag, err := globalNode.LagBehindMajority()
+	if err != nil {
+		return 0, false
+	}
+	return lag, lag <= MajorityLagTolerance
+}
+
+func GetGenesisInfo() GenesisInfo {
+	if globalNode == nil {
+		return GenesisInfo{}
+	}
+	return globalNode.GenesisInfo()
+}
+
+func ActiveNodes() ([]*ActiveNode, error) {
+	if globalNode == nil {
+		return []*ActiveNode{}, nil
+	}
+	return globalNode.ActiveNodes()
+}
+
+func NodeSnapshotRate(nodeId crypto.Hash, window time.Duration) (float64, error) {
+	if globalNode == nil {
+		return 0, errors.New("node not ready")
+	}
+	return globalNode.NodeSnapshotRate(nodeId, window)
+}
+
 func ConsensusNodes() []map[string]interface{} {
 	nodes := make([]map[string]interface{}, 0)
 	if globalNode == nil {
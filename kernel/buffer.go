@@ -0,0 +1,154 @@
+package kernel
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	SnapshotBufferSize     = 8192
+	SnapshotBufferRetryMin = 100 * time.Millisecond
+	SnapshotBufferRetryMax = 10 * time.Second
+)
+
+type SnapshotBufferStats struct {
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Peak     int    `json:"peak"`
+	Accepted uint64 `json:"accepted"`
+	Flushed  uint64 `json:"flushed"`
+	Blocked  uint64 `json:"blocked"`
+	Retried  uint64 `json:"retried"`
+}
+
+// SnapshotBuffer is a bounded write-ahead buffer for the verified snapshots,
+// it keeps accepting them while the store stalls and flushes them in the same
+// order when the store recovers. A full buffer blocks the producer, so the
+// gossip is slowed down instead of dropped or piled up in memory. A failed
+// commit is retried with backoff, the jobs behind it wait in the buffer.
+type SnapshotBuffer struct {
+	mutex    *sync.Mutex
+	notFull  *sync.Cond
+	notEmpty *sync.Cond
+	jobs     []*verifyJob
+	stats    SnapshotBufferStats
+	commit   func(job *verifyJob) error
+	closed   bool
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+func newSnapshotBuffer(capacity int, commit func(job *verifyJob) error) *SnapshotBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	b := &SnapshotBuffer{
+		mutex:  new(sync.Mutex),
+		jobs:   make([]*verifyJob, 0),
+		commit: commit,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	b.notFull = sync.NewCond(b.mutex)
+	b.notEmpty = sync.NewCond(b.mutex)
+	b.stats.Capacity = capacity
+	go b.loopFlush()
+	return b
+}
+
+func (b *SnapshotBuffer) Put(job *verifyJob) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.jobs) >= b.stats.Capacity {
+		b.stats.Blocked = b.stats.Blocked + 1
+	}
+	for len(b.jobs) >= b.stats.Capacity && !b.closed {
+		b.notFull.Wait()
+	}
+	if b.closed {
+		return fmt.Errorf("snapshot buffer closed")
+	}
+	b.jobs = append(b.jobs, job)
+	b.stats.Accepted = b.stats.Accepted + 1
+	if len(b.jobs) > b.stats.Peak {
+		b.stats.Peak = len(b.jobs)
+	}
+	b.notEmpty.Signal()
+	return nil
+}
+
+func (b *SnapshotBuffer) Stats() SnapshotBufferStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	stats := b.stats
+	stats.Depth = len(b.jobs)
+	return stats
+}
+
+// Close stops the flush loop and rejects the blocked and later puts, the jobs
+// not committed yet are discarded. A commit in progress is not interrupted, the
+// loop exits after it returns. It's safe to call more than once.
+func (b *SnapshotBuffer) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.quit)
+	b.notEmpty.Broadcast()
+	b.notFull.Broadcast()
+}
+
+// the job stays in the buffer until committed, so the depth includes the one
+// stuck in a stalled store
+func (b *SnapshotBuffer) loopFlush() {
+	defer close(b.done)
+
+	var retry time.Duration
+	for {
+		b.mutex.Lock()
+		for len(b.jobs) == 0 && !b.closed {
+			b.notEmpty.Wait()
+		}
+		if b.closed {
+			b.mutex.Unlock()
+			return
+		}
+		job := b.jobs[0]
+		b.mutex.Unlock()
+
+		err := b.commit(job)
+		if err != nil {
+			retry = retry * 2
+			if retry < SnapshotBufferRetryMin {
+				retry = SnapshotBufferRetryMin
+			}
+			if retry > SnapshotBufferRetryMax {
+				retry = SnapshotBufferRetryMax
+			}
+			logger.Println("snapshot buffer commit error", job.snapshot.Hash.String(), retry.String(), err)
+			b.mutex.Lock()
+			b.stats.Retried = b.stats.Retried + 1
+			b.mutex.Unlock()
+			select {
+			case <-b.quit:
+				return
+			case <-time.After(retry):
+			}
+			continue
+		}
+		retry = 0
+
+		b.mutex.Lock()
+		b.jobs[0] = nil
+		b.jobs = b.jobs[1:]
+		b.stats.Flushed = b.stats.Flushed + 1
+		b.notFull.Signal()
+		b.mutex.Unlock()
+	}
+}
@@ -0,0 +1,135 @@
+package kernel
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotBufferStall(t *testing.T) {
+	assert := assert.New(t)
+
+	var wg sync.WaitGroup
+	stall := make(chan struct{})
+	flushed := make([]uint64, 0)
+	commit := func(job *verifyJob) error {
+		<-stall
+		flushed = append(flushed, job.snapshot.RoundNumber)
+		wg.Done()
+		return nil
+	}
+	buffer := newSnapshotBuffer(4, commit)
+	wg.Add(6)
+	for i := 0; i < 4; i++ {
+		err := buffer.Put(&verifyJob{snapshot: &common.Snapshot{RoundNumber: uint64(i)}})
+		assert.Nil(err)
+	}
+	stats := buffer.Stats()
+	assert.Equal(4, stats.Depth)
+	assert.Equal(4, stats.Capacity)
+	assert.Equal(4, stats.Peak)
+	assert.Equal(uint64(4), stats.Accepted)
+	assert.Equal(uint64(0), stats.Flushed)
+
+	put := make(chan int, 2)
+	go func() {
+		for i := 4; i < 6; i++ {
+			buffer.Put(&verifyJob{snapshot: &common.Snapshot{RoundNumber: uint64(i)}})
+			put <- i
+		}
+	}()
+	select {
+	case <-put:
+		t.Fatal("buffer put should block when full")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(uint64(1), buffer.Stats().Blocked)
+
+	close(stall)
+	wg.Wait()
+	assert.Equal(4, <-put)
+	assert.Equal(5, <-put)
+	assert.Equal([]uint64{0, 1, 2, 3, 4, 5}, flushed)
+	stats = buffer.Stats()
+	assert.Equal(0, stats.Depth)
+	assert.Equal(4, stats.Peak)
+	assert.Equal(uint64(6), stats.Accepted)
+	assert.Equal(uint64(6), stats.Flushed)
+}
+
+func TestSnapshotBufferRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	var mutex sync.Mutex
+	failures := 2
+	flushed := make([]uint64, 0)
+	commit := func(job *verifyJob) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if job.snapshot.RoundNumber == 1 && failures > 0 {
+			failures = failures - 1
+			return fmt.Errorf("store stalled")
+		}
+		flushed = append(flushed, job.snapshot.RoundNumber)
+		return nil
+	}
+	buffer := newSnapshotBuffer(4, commit)
+	defer buffer.Close()
+
+	for i := 0; i < 3; i++ {
+		err := buffer.Put(&verifyJob{snapshot: &common.Snapshot{RoundNumber: uint64(i)}})
+		assert.Nil(err)
+	}
+	time.Sleep(SnapshotBufferRetryMin / 2)
+	stats := buffer.Stats()
+	assert.Equal(2, stats.Depth)
+	assert.Equal(uint64(1), stats.Flushed)
+	assert.Equal(uint64(1), stats.Retried)
+
+	for i := 0; i < 50 && buffer.Stats().Depth > 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	stats = buffer.Stats()
+	assert.Equal(0, stats.Depth)
+	assert.Equal(uint64(3), stats.Flushed)
+	assert.Equal(uint64(2), stats.Retried)
+	mutex.Lock()
+	assert.Equal([]uint64{0, 1, 2}, flushed)
+	mutex.Unlock()
+}
+
+func TestSnapshotBufferClose(t *testing.T) {
+	assert := assert.New(t)
+
+	commit := func(job *verifyJob) error {
+		return fmt.Errorf("store stalled")
+	}
+	buffer := newSnapshotBuffer(1, commit)
+	err := buffer.Put(&verifyJob{snapshot: &common.Snapshot{}})
+	assert.Nil(err)
+
+	put := make(chan error)
+	go func() {
+		put <- buffer.Put(&verifyJob{snapshot: &common.Snapshot{}})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	buffer.Close()
+	buffer.Close()
+	select {
+	case err = <-put:
+		assert.NotNil(err)
+	case <-time.After(time.Second):
+		t.Fatal("blocked put should return after close")
+	}
+	select {
+	case <-buffer.done:
+	case <-time.After(time.Second):
+		t.Fatal("flush loop should exit after close")
+	}
+	assert.Equal(uint64(0), buffer.Stats().Flushed)
+	assert.NotNil(buffer.Put(&verifyJob{snapshot: &common.Snapshot{}}))
+}
@@ -0,0 +1,44 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	ChallengeNonceMinimum = 16
+	ChallengeNonceMaximum = 256
+)
+
+// SignChallenge proves the control of the node signer key to a monitoring
+// service, which should verify the signature with VerifyChallenge against
+// the signer in genesis or the node pledge.
+func (node *Node) SignChallenge(nonce []byte) (crypto.Signature, error) {
+	msg, err := challengeMessage(node.networkId, node.Signer, nonce)
+	if err != nil {
+		return crypto.Signature{}, err
+	}
+	return node.Signer.PrivateSpendKey.Sign(msg), nil
+}
+
+func VerifyChallenge(networkId crypto.Hash, signer common.Address, nonce []byte, sig crypto.Signature) bool {
+	msg, err := challengeMessage(networkId, signer, nonce)
+	if err != nil {
+		return false
+	}
+	return signer.PublicSpendKey.Verify(msg, sig)
+}
+
+// The challenge message is prefixed to never collide with any snapshot or
+// peer authentication message, so a signed nonce can't be replayed there.
+func challengeMessage(networkId crypto.Hash, signer common.Address, nonce []byte) ([]byte, error) {
+	if len(nonce) < ChallengeNonceMinimum || len(nonce) > ChallengeNonceMaximum {
+		return nil, fmt.Errorf("invalid challenge nonce size %d", len(nonce))
+	}
+	nodeId := signer.Hash().ForNetwork(networkId)
+	msg := append([]byte("MIXINNODECHALLENGE"), networkId[:]...)
+	msg = append(msg, nodeId[:]...)
+	return append(msg, nonce...), nil
+}
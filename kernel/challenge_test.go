@@ -0,0 +1,43 @@
+package kernel
+
+import (
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChallenge(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	nonce := make([]byte, 32)
+	rand.Read(nonce)
+	sig, err := node.SignChallenge(nonce)
+	assert.Nil(err)
+	signer := signers[0]
+	signer.PrivateSpendKey = crypto.Key{}
+	assert.True(VerifyChallenge(node.networkId, signer, nonce, sig))
+
+	tampered := sig
+	tampered[7] ^= 0x01
+	assert.False(VerifyChallenge(node.networkId, signer, nonce, tampered))
+	other := append([]byte{}, nonce...)
+	other[0] ^= 0x01
+	assert.False(VerifyChallenge(node.networkId, signer, other, sig))
+	assert.False(VerifyChallenge(node.networkId, signers[1], nonce, sig))
+	assert.False(VerifyChallenge(crypto.NewHash(node.networkId[:]), signer, nonce, sig))
+	assert.False(node.Signer.PublicSpendKey.Verify(nonce, sig))
+
+	_, err = node.SignChallenge(nonce[:ChallengeNonceMinimum-1])
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid challenge nonce size 15")
+	_, err = node.SignChallenge(make([]byte, ChallengeNonceMaximum+1))
+	assert.NotNil(err)
+	assert.False(VerifyChallenge(node.networkId, signer, nonce[:8], sig))
+}
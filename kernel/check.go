@@ -0,0 +1,180 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	QuarantineRefetchInterval = 5 * time.Minute
+)
+
+// FindOrphanedTransactions reports the transactions without any snapshot
+// referencing them, and the transactions referenced by snapshots but missing
+// in the store, a healthy store should always return empty.
+func (node *Node) FindOrphanedTransactions(ctx context.Context) ([]crypto.Hash, error) {
+	return node.store.FindOrphanedTransactions(ctx)
+}
+
+// FindDuplicateTopologicalOrders reports the topological orders assigned to
+// more than one snapshot, a healthy store should always return empty.
+func (node *Node) FindDuplicateTopologicalOrders(ctx context.Context) ([]uint64, error) {
+	return node.store.FindDuplicateTopologies(ctx)
+}
+
+// FindQuarantinedSnapshots reports the corrupted snapshot records skipped by
+// the reads, they are flagged for re-fetch from peers.
+func (node *Node) FindQuarantinedSnapshots() ([]*storage.QuarantinedSnapshot, error) {
+	return node.store.ReadQuarantinedSnapshots()
+}
+
+// RefetchQuarantinedSnapshots requests the rounds of the quarantined snapshots
+// from the neighbors, a finalized snapshot received for a quarantined record
+// repairs it and clears the quarantine, and returns the requests sent.
+func (node *Node) RefetchQuarantinedSnapshots() (int, error) {
+	quarantined, err := node.store.ReadQuarantinedSnapshots()
+	if err != nil {
+		return 0, err
+	}
+	var sent int
+	for _, q := range quarantined {
+		sent = sent + node.Peer.RequestSnapshots(q.NodeId, q.RoundNumber)
+	}
+	return sent, nil
+}
+
+func (node *Node) LoopRefetchQuarantinedSnapshots() error {
+	ticker := time.NewTicker(QuarantineRefetchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := node.RefetchQuarantinedSnapshots()
+		if err != nil {
+			logger.Println("QUARANTINE refetch error", err)
+		}
+	}
+	return nil
+}
+
+// VerifyTopologicalContiguity checks the topological orders start at 0 without
+// any gap, and returns the first missing order if not ok.
+func (node *Node) VerifyTopologicalContiguity(ctx context.Context) (firstGap uint64, ok bool, err error) {
+	gap, found, err := node.store.FindTopologyGap(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return gap, !found, nil
+}
+
+// VerifyGenesisRounds rebuilds the genesis rounds from the genesis snapshots,
+// and checks the store has the final round 0 and the round 1 of each genesis
+// node as LoadGenesis wrote them, with the round 1 of the last node referring
+// to the first node to close the ring. The external reference of an empty
+// head round 1 may be updated by the consensus, so it's only checked to be a
+// final round of another node then.
+func (node *Node) VerifyGenesisRounds(ctx context.Context) error {
+	gns, err := readGenesis(node.configDir + "/genesis.json")
+	if err != nil {
+		return err
+	}
+	snapshots, err := node.GenesisSnapshots()
+	if err != nil {
+		return err
+	}
+	nodeIds := make([]crypto.Hash, len(gns.Nodes))
+	cacheRounds := make(map[crypto.Hash]*CacheRound)
+	for i, in := range gns.Nodes {
+		nodeIds[i] = in.Signer.Hash().ForNetwork(node.networkId)
+		cacheRounds[nodeIds[i]] = &CacheRound{NodeId: nodeIds[i]}
+	}
+	for _, s := range snapshots {
+		cache := cacheRounds[s.NodeId]
+		if cache == nil {
+			return fmt.Errorf("genesis snapshot of unknown node %s", s.NodeId.String())
+		}
+		snap := s.Snapshot
+		cache.Snapshots = append(cache.Snapshots, &snap)
+	}
+
+	expected := buildGenesisRounds(nodeIds, cacheRounds)
+	for i := 0; i < len(expected); i += 2 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		final, next := expected[i], expected[i+1]
+		round, err := node.store.ReadRound(final.Hash)
+		if err != nil {
+			return err
+		}
+		if round == nil {
+			return fmt.Errorf("genesis final round not found %s %s", final.NodeId.String(), final.Hash.String())
+		}
+		if round.NodeId != final.NodeId || round.Number != 0 || round.Timestamp != final.Timestamp {
+			return fmt.Errorf("genesis final round mismatch %s %d %d", final.NodeId.String(), round.Number, round.Timestamp)
+		}
+
+		round, empty, err := node.readGenesisNextRound(next.NodeId)
+		if err != nil {
+			return err
+		}
+		if round.NodeId != next.NodeId || round.Number != 1 || round.References == nil {
+			return fmt.Errorf("genesis round 1 mismatch %s %d", next.NodeId.String(), round.Number)
+		}
+		if round.References.Self != next.References.Self {
+			return fmt.Errorf("genesis round 1 self reference mismatch %s %s", next.NodeId.String(), round.References.Self.String())
+		}
+		if round.References.External == next.References.External {
+			continue
+		}
+		external, err := node.store.ReadRound(round.References.External)
+		if err != nil {
+			return err
+		}
+		if empty && external != nil && external.NodeId != next.NodeId {
+			continue
+		}
+		if i == len(expected)-2 {
+			return fmt.Errorf("genesis round ring not closed %s %s", next.NodeId.String(), round.References.External.String())
+		}
+		return fmt.Errorf("genesis round 1 external reference mismatch %s %s", next.NodeId.String(), round.References.External.String())
+	}
+	return nil
+}
+
+// readGenesisNextRound finds the round 1 of the node as the head round, or as
+// a final round by the hash of its snapshots, and whether it's an empty head.
+func (node *Node) readGenesisNextRound(nodeId crypto.Hash) (*common.Round, bool, error) {
+	head, err := node.store.ReadRound(nodeId)
+	if err != nil {
+		return nil, false, err
+	}
+	if head == nil {
+		return nil, false, fmt.Errorf("genesis node head round not found %s", nodeId.String())
+	}
+	snapshots, err := node.ReadSnapshotsByRound(nodeId, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	if head.Number == 1 {
+		return head, len(snapshots) == 0, nil
+	}
+	cache := &CacheRound{NodeId: nodeId, Number: 1, Snapshots: snapshots}
+	final := cache.asFinal()
+	if final == nil {
+		return nil, false, fmt.Errorf("genesis round 1 not found %s", nodeId.String())
+	}
+	round, err := node.store.ReadRound(final.Hash)
+	if err != nil {
+		return nil, false, err
+	}
+	if round == nil {
+		return nil, false, fmt.Errorf("genesis round 1 not found %s %s", nodeId.String(), final.Hash.String())
+	}
+	return round, false, nil
+}
@@ -0,0 +1,304 @@
+package kernel
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack"
+)
+
+func TestVerifyTopologicalContiguity(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+
+	gap, ok, err := node.VerifyTopologicalContiguity(context.Background())
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(uint64(0), gap)
+	err = node.store.Close()
+	assert.Nil(err)
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir + "/snapshots"
+	opts.ValueDir = dir + "/snapshots"
+	db, err := badger.Open(opts)
+	assert.Nil(err)
+	err = db.Update(func(txn *badger.Txn) error {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, 3)
+		return txn.Delete(append([]byte("TOPOLOGY"), key...))
+	})
+	assert.Nil(err)
+	err = db.Close()
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node.store = store
+	gap, ok, err = node.VerifyTopologicalContiguity(context.Background())
+	assert.Nil(err)
+	assert.False(ok)
+	assert.Equal(uint64(3), gap)
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	err := node.store.Close()
+	assert.Nil(err)
+
+	data := fmt.Sprintf(`{"signer":"%s","verify-checksum":true}`, signers[0].PrivateSpendKey.String())
+	err = ioutil.WriteFile(dir+"/config.json", []byte(data), 0644)
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(err)
+	assert.NotNil(node)
+	err = store.Close()
+	assert.Nil(err)
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir + "/snapshots"
+	opts.ValueDir = dir + "/snapshots"
+	db, err := badger.Open(opts)
+	assert.Nil(err)
+	err = db.Update(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte("SNAPSHOT")
+		it.Seek(prefix)
+		if !it.ValidForPrefix(prefix) {
+			return fmt.Errorf("snapshot not found")
+		}
+		return txn.Delete(it.Item().KeyCopy(nil))
+	})
+	assert.Nil(err)
+	err = db.Close()
+	assert.Nil(err)
+
+	store, err = storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(node)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "checksum")
+	assert.Contains(err.Error(), "restore from backup")
+}
+
+func TestQuarantineCorruptedSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	snapshots, err := node.store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	assert.True(len(snapshots) > 3)
+	err = node.store.Close()
+	assert.Nil(err)
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir + "/snapshots"
+	opts.ValueDir = dir + "/snapshots"
+	db, err := badger.Open(opts)
+	assert.Nil(err)
+	err = db.Update(func(txn *badger.Txn) error {
+		order := make([]byte, 8)
+		binary.BigEndian.PutUint64(order, 2)
+		item, err := txn.Get(append([]byte("TOPOLOGY"), order...))
+		if err != nil {
+			return err
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		item, err = txn.Get(key)
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var snap common.SnapshotWithTopologicalOrder
+		err = msgpack.Unmarshal(val, &snap)
+		if err != nil {
+			return err
+		}
+		snap.RoundNumber += 1
+		return txn.Set(key, common.MsgpackMarshalPanic(snap))
+	})
+	assert.Nil(err)
+	err = db.Close()
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	_, err = store.ReadSnapshotsSinceTopology(0, 100)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "snapshot record hash mismatch 2")
+	err = store.Close()
+	assert.Nil(err)
+
+	data := fmt.Sprintf(`{"signer":"%s","quarantine-corrupted":true}`, signers[0].PrivateSpendKey.String())
+	err = ioutil.WriteFile(dir+"/config.json", []byte(data), 0644)
+	assert.Nil(err)
+	store, err = storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(err)
+	assert.NotNil(node)
+
+	quarantined, err := node.FindQuarantinedSnapshots()
+	assert.Nil(err)
+	assert.Len(quarantined, 0)
+	read, err := node.store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	assert.Len(read, len(snapshots)-1)
+	for _, s := range read {
+		assert.NotEqual(uint64(2), s.TopologicalOrder)
+	}
+	var scanned int
+	err = node.store.ScanSnapshots(context.Background(), 0, 100, func(snap *common.SnapshotWithTopologicalOrder) error {
+		scanned++
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(len(snapshots)-1, scanned)
+
+	quarantined, err = node.FindQuarantinedSnapshots()
+	assert.Nil(err)
+	assert.Len(quarantined, 1)
+	assert.Equal(uint64(2), quarantined[0].TopologicalOrder)
+	assert.Contains(quarantined[0].Reason, "hash mismatch")
+	assert.Equal(snapshots[2].NodeId, quarantined[0].NodeId)
+	assert.Equal(snapshots[2].RoundNumber, quarantined[0].RoundNumber)
+	sent, err := node.RefetchQuarantinedSnapshots()
+	assert.Nil(err)
+	assert.Equal(0, sent)
+
+	repaired, err := node.store.RepairQuarantinedSnapshot(&snapshots[3].Snapshot)
+	assert.Nil(err)
+	assert.False(repaired)
+	repaired, err = node.store.RepairQuarantinedSnapshot(&snapshots[2].Snapshot)
+	assert.Nil(err)
+	assert.True(repaired)
+	quarantined, err = node.FindQuarantinedSnapshots()
+	assert.Nil(err)
+	assert.Len(quarantined, 0)
+	read, err = node.store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	assert.Len(read, len(snapshots))
+	assert.Equal(snapshots[2].Hash, read[2].Hash)
+}
+
+func TestFindDuplicateTopologicalOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+
+	duplicates, err := node.FindDuplicateTopologicalOrders(context.Background())
+	assert.Nil(err)
+	assert.Len(duplicates, 0)
+	snapshots, err := node.store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	err = node.store.Close()
+	assert.Nil(err)
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir + "/snapshots"
+	opts.ValueDir = dir + "/snapshots"
+	db, err := badger.Open(opts)
+	assert.Nil(err)
+	err = db.Update(func(txn *badger.Txn) error {
+		snap := *snapshots[3]
+		snap.Transaction = crypto.NewHash([]byte("duplicate"))
+		round := make([]byte, 8)
+		binary.BigEndian.PutUint64(round, snap.RoundNumber)
+		key := append([]byte("SNAPSHOT"), snap.NodeId[:]...)
+		key = append(key, round...)
+		key = append(key, snap.Transaction[:]...)
+		return txn.Set(key, common.MsgpackMarshalPanic(snap))
+	})
+	assert.Nil(err)
+	err = db.Close()
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node.store = store
+	duplicates, err = node.FindDuplicateTopologicalOrders(context.Background())
+	assert.Nil(err)
+	assert.Equal([]uint64{3}, duplicates)
+}
+
+func TestVerifyGenesisRounds(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+
+	err := node.VerifyGenesisRounds(context.Background())
+	assert.Nil(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(context.Canceled, node.VerifyGenesisRounds(ctx))
+	last := signers[len(signers)-1].Hash().ForNetwork(node.networkId)
+	err = node.store.Close()
+	assert.Nil(err)
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir + "/snapshots"
+	opts.ValueDir = dir + "/snapshots"
+	db, err := badger.Open(opts)
+	assert.Nil(err)
+	err = db.Update(func(txn *badger.Txn) error {
+		key := append([]byte("ROUND"), last[:]...)
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var round common.Round
+		err = msgpack.Unmarshal(val, &round)
+		if err != nil {
+			return err
+		}
+		round.References.External = crypto.NewHash([]byte("tampered"))
+		return txn.Set(key, common.MsgpackMarshalPanic(round))
+	})
+	assert.Nil(err)
+	err = db.Close()
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node.store = store
+	err = node.VerifyGenesisRounds(context.Background())
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "genesis round ring not closed")
+}
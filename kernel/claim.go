@@ -0,0 +1,66 @@
+package kernel
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// OutputClaim is a genesis output key the view key can claim, Index is the
+// position of the key in the output keys.
+type OutputClaim struct {
+	Type   uint8          `json:"type"`
+	Signer common.Address `json:"signer"`
+	Amount common.Integer `json:"amount"`
+	Key    crypto.Key     `json:"key"`
+	Index  int            `json:"index"`
+}
+
+// ScanGenesisOutputs derives the node accept and domain accept outputs the
+// genesis would commit, and returns the keys owned by the view key. A key is
+// owned when the spend key viewed from it is the spend key of the genesis
+// node it was derived for. The scan stops with ctx.Err() as soon as the
+// context is done.
+func ScanGenesisOutputs(ctx context.Context, gns *Genesis, viewKey crypto.Key) ([]OutputClaim, error) {
+	nodeKeys, _, err := deriveGenesisKeys(ctx, gns, 1, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make([]OutputClaim, 0)
+	scan := func(typ uint8, signer common.Address, amount common.Integer, mask crypto.Key, keys []crypto.Key) {
+		R := mask.Public()
+		for i, k := range keys {
+			B := crypto.ViewGhostOutputKey(&k, &viewKey, &R, 0)
+			if *B != gns.Nodes[i].Signer.PublicSpendKey {
+				continue
+			}
+			claims = append(claims, OutputClaim{
+				Type:   typ,
+				Signer: signer,
+				Amount: amount,
+				Key:    k,
+				Index:  i,
+			})
+		}
+	}
+
+	for i, in := range gns.Nodes {
+		mask := genesisNodeAcceptMask(in.Signer)
+		scan(common.OutputTypeNodeAccept, in.Signer, gns.acceptAmount(), mask, nodeKeys[i])
+	}
+	for _, d := range gns.Domains {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		mask := genesisDomainAcceptMask(d.Signer)
+		keys := make([]crypto.Key, 0)
+		for _, in := range gns.Nodes {
+			key := crypto.DeriveGhostPublicKey(&mask, &in.Signer.PublicViewKey, &in.Signer.PublicSpendKey, 0)
+			keys = append(keys, *key)
+		}
+		scan(common.OutputTypeDomainAccept, d.Signer, common.NewInteger(50000), mask, keys)
+	}
+	return claims, nil
+}
@@ -0,0 +1,40 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// CompactRemovedRounds archives the round index of the removed nodes, whose
+// node accept output is spent, they will never produce new rounds. The head
+// and last final rounds are kept in the index for the references of others.
+func (node *Node) CompactRemovedRounds() (map[crypto.Hash]int, error) {
+	compacted := make(map[crypto.Hash]int)
+	for _, cn := range node.store.ReadConsensusNodes() {
+		if !cn.IsAccepted() {
+			continue
+		}
+		utxo, err := node.store.ReadUTXOWithLock(cn.Transaction, 0)
+		if err != nil {
+			return compacted, err
+		}
+		if utxo == nil {
+			continue
+		}
+		spent, err := node.checkUTXOSpent(utxo)
+		if err != nil {
+			return compacted, err
+		}
+		if !spent {
+			continue
+		}
+		id := cn.Signer.Hash().ForNetwork(node.networkId)
+		archived, err := node.store.ArchiveRounds(id)
+		if err != nil {
+			return compacted, err
+		}
+		logger.Printf("COMPACT ROUNDS %s %d\n", id.String(), archived)
+		compacted[id] = archived
+	}
+	return compacted, nil
+}
@@ -0,0 +1,117 @@
+package kernel
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactRemovedRounds(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	compacted, err := node.CompactRemovedRounds()
+	assert.Nil(err)
+	assert.Len(compacted, 0)
+
+	removed := signers[1].Hash().ForNetwork(node.networkId)
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	var accept crypto.Hash
+	for _, s := range snapshots {
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		assert.Nil(err)
+		if s.NodeId == removed && tx.Outputs[0].Type == common.OutputTypeNodeAccept {
+			accept = s.Transaction
+		}
+	}
+	assert.True(accept.HasValue())
+
+	spend := common.NewTransaction(common.XINAssetId)
+	spend.AddInput(accept, 0)
+	spend.Outputs = []*common.Output{
+		{Type: common.OutputTypeScript, Amount: common.NewInteger(10000), Keys: []crypto.Key{randomTestKey()}},
+	}
+	testWriteSnapshot(t, node, &common.SignedTransaction{Transaction: *spend})
+
+	head, err := node.store.ReadRound(node.IdForNetwork)
+	assert.Nil(err)
+	external := head.References.Self
+	finals := make([]crypto.Hash, 0)
+	for n := uint64(2); n <= 4; n++ {
+		self := crypto.NewHash([]byte(fmt.Sprintf("%s:%d", removed.String(), n-1)))
+		finals = append(finals, self)
+		err = node.store.StartNewRound(removed, n, &common.RoundLink{Self: self, External: external}, uint64(time.Now().UnixNano()))
+		assert.Nil(err)
+	}
+	head, err = node.store.ReadRound(removed)
+	assert.Nil(err)
+	first := head
+	for first.References != nil {
+		first, err = node.store.ReadRound(first.References.Self)
+		assert.Nil(err)
+	}
+	genesis := first.Hash
+
+	compacted, err = node.CompactRemovedRounds()
+	assert.Nil(err)
+	assert.Equal(map[crypto.Hash]int{removed: 3}, compacted)
+	for i, hash := range []crypto.Hash{genesis, finals[0], finals[1]} {
+		round, err := node.store.ReadRound(hash)
+		assert.Nil(err)
+		assert.NotNil(round)
+		assert.Equal(hash, round.Hash)
+		assert.Equal(uint64(i), round.Number)
+		round, err = node.ReadRoundByHash(hash)
+		assert.Nil(err)
+		assert.NotNil(round)
+	}
+	last, err := node.store.ReadRound(finals[2])
+	assert.Nil(err)
+	assert.NotNil(last)
+	assert.Equal(uint64(3), last.Number)
+	head, err = node.store.ReadRound(removed)
+	assert.Nil(err)
+	assert.Equal(uint64(4), head.Number)
+
+	archived, err := node.store.ReadArchivedRounds(removed)
+	assert.Nil(err)
+	assert.Len(archived, 3)
+	for i, r := range archived {
+		assert.Equal(uint64(i), r.Number)
+		assert.Equal(removed, r.NodeId)
+	}
+	assert.Equal(genesis, archived[0].Hash)
+	assert.Nil(archived[0].References)
+	assert.Equal(finals[1], archived[2].Hash)
+
+	for _, in := range signers {
+		id := in.Hash().ForNetwork(node.networkId)
+		if id == removed {
+			continue
+		}
+		head, err := node.store.ReadRound(id)
+		assert.Nil(err)
+		final, err := node.store.ReadRound(head.References.Self)
+		assert.Nil(err)
+		assert.NotNil(final)
+		archived, err := node.store.ReadArchivedRounds(id)
+		assert.Nil(err)
+		assert.Len(archived, 0)
+	}
+
+	compacted, err = node.CompactRemovedRounds()
+	assert.Nil(err)
+	assert.Equal(map[crypto.Hash]int{removed: 0}, compacted)
+	archived, err = node.store.ReadArchivedRounds(removed)
+	assert.Nil(err)
+	assert.Len(archived, 3)
+}
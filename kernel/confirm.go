@@ -0,0 +1,26 @@
+package kernel
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// TransactionConfirmations returns the count of snapshots from the first
+// snapshot of the transaction to the topological tip inclusively, the depth
+// is 0 if the transaction is not in any snapshot yet.
+func (node *Node) TransactionConfirmations(ctx context.Context, hash crypto.Hash) (depth uint64, finalized bool, err error) {
+	finalized, err = node.store.CheckTransactionFinalization(hash)
+	if err != nil {
+		return 0, false, err
+	}
+	topology, found, err := node.store.ReadTransactionTopology(ctx, hash)
+	if err != nil || !found {
+		return 0, finalized, err
+	}
+	seq := node.store.TopologySequence()
+	if seq <= topology {
+		return 0, finalized, nil
+	}
+	return seq - topology, finalized, nil
+}
@@ -0,0 +1,34 @@
+package kernel
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionConfirmations(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	seq := node.store.TopologySequence()
+	for _, s := range snapshots {
+		depth, finalized, err := node.TransactionConfirmations(context.Background(), s.Transaction)
+		assert.Nil(err)
+		assert.True(finalized)
+		assert.True(depth > 0)
+		assert.Equal(seq-s.TopologicalOrder, depth)
+	}
+
+	depth, finalized, err := node.TransactionConfirmations(context.Background(), crypto.NewHash([]byte("unknown")))
+	assert.Nil(err)
+	assert.False(finalized)
+	assert.Equal(uint64(0), depth)
+}
@@ -0,0 +1,78 @@
+package kernel
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// Degraded tells whether the node stopped writing and consensus because the
+// store is read-only or full, it still serves reads and relays the gossip.
+func (node *Node) Degraded() bool {
+	return atomic.LoadInt32(&node.degraded) > 0
+}
+
+func (node *Node) handleSnapshotInputOrDegrade(s *common.Snapshot) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rerr, ok := r.(error)
+			if !ok || !storage.IsUnavailableError(rerr) {
+				panic(r)
+			}
+			err = rerr
+		}
+	}()
+	return node.handleSnapshotInput(s)
+}
+
+func (node *Node) commitVerifiedSnapshotOrDegrade(job *verifyJob) error {
+	return node.writeStoreOrDegrade(func() error {
+		return node.commitVerifiedSnapshot(job)
+	})
+}
+
+// writeStoreOrDegrade waits for the store to recover and writes again if the
+// write fails because the store is read-only or full, other errors are returned.
+func (node *Node) writeStoreOrDegrade(write func() error) error {
+	for {
+		err := write()
+		if !storage.IsUnavailableError(err) {
+			return err
+		}
+		err = node.waitStoreRecovery(err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitStoreRecovery blocks until the store is writable again, or returns the
+// cause if the node shuts down before that. All the writers waiting at the same
+// time keep the node degraded until the last one resumes.
+func (node *Node) waitStoreRecovery(cause error) error {
+	atomic.AddInt32(&node.degraded, 1)
+	defer atomic.AddInt32(&node.degraded, -1)
+
+	interval := time.Duration(node.custom.StoreRetrySeconds) * time.Second
+	for {
+		logger.Printf("!!!!!!!! STORE UNAVAILABLE, DEGRADED MODE, RETRY IN %s: %s\n", interval.String(), cause.Error())
+		select {
+		case <-node.done:
+			return cause
+		case <-time.After(interval):
+		}
+		err := node.store.StateSet("store-probe", time.Now().UnixNano())
+		if err == nil {
+			logger.Println("STORE RECOVERED, RESUME CONSENSUS")
+			return nil
+		}
+		if !storage.IsUnavailableError(err) {
+			panic(fmt.Errorf("store probe error %s", err.Error()))
+		}
+		cause = err
+	}
+}
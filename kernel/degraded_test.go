@@ -0,0 +1,163 @@
+package kernel
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type testReadOnlyStore struct {
+	storage.Store
+	readOnly int32
+}
+
+func (s *testReadOnlyStore) check() error {
+	if atomic.LoadInt32(&s.readOnly) == 1 {
+		return &os.PathError{Op: "write", Path: "snapshots", Err: syscall.EROFS}
+	}
+	return nil
+}
+
+func (s *testReadOnlyStore) StateSet(key string, val interface{}) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.Store.StateSet(key, val)
+}
+
+func (s *testReadOnlyStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.Store.WriteSnapshot(snap)
+}
+
+func (s *testReadOnlyStore) QueueAppendSnapshot(peerId crypto.Hash, snap *common.Snapshot, finalized bool) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.Store.QueueAppendSnapshot(peerId, snap, finalized)
+}
+
+func TestStoreDegradedMode(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	store := &testReadOnlyStore{Store: node.store}
+	node.store = store
+	node.custom.StoreRetrySeconds = 1
+
+	tx := testMintTransaction(common.XINAssetId, 100)
+	err := node.store.WriteTransaction(tx)
+	assert.Nil(err)
+
+	id := signers[1].Hash().ForNetwork(node.networkId)
+	cache := node.Graph.CacheRound[id]
+	s := &common.Snapshot{
+		NodeId:      id,
+		Transaction: tx.PayloadHash(),
+		References:  cache.References,
+		RoundNumber: cache.Number,
+		Timestamp:   uint64(time.Now().UnixNano()),
+	}
+	s.Hash = s.PayloadHash()
+	for _, signer := range signers[:len(signers)*2/3+1] {
+		sig := signer.PrivateSpendKey.Sign(s.Hash[:])
+		s.Signatures = append(s.Signatures, &sig)
+	}
+
+	atomic.StoreInt32(&store.readOnly, 1)
+	errChan := make(chan error)
+	go func() {
+		errChan <- node.ConsumeMempool()
+	}()
+	node.mempoolChan <- s
+	for i := 0; i < 100 && !node.Degraded(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(node.Degraded())
+	inNode, err := node.store.CheckTransactionInNode(id, tx.PayloadHash())
+	assert.Nil(err)
+	assert.False(inNode)
+
+	atomic.StoreInt32(&store.readOnly, 0)
+	for i := 0; i < 300 && node.Degraded(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(node.Degraded())
+	for i := 0; i < 100 && !inNode; i++ {
+		time.Sleep(10 * time.Millisecond)
+		inNode, err = node.store.CheckTransactionInNode(id, tx.PayloadHash())
+		assert.Nil(err)
+	}
+	assert.True(inNode)
+
+	select {
+	case err := <-errChan:
+		t.Fatal(err)
+	default:
+	}
+	assert.False(storage.IsUnavailableError(nil))
+	assert.False(storage.IsUnavailableError(os.ErrNotExist))
+	assert.True(storage.IsUnavailableError(&os.PathError{Op: "write", Path: "snapshots", Err: syscall.ENOSPC}))
+}
+
+func TestStoreDegradedBufferCommit(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	store := &testReadOnlyStore{Store: node.store}
+	node.store = store
+	node.custom.StoreRetrySeconds = 1
+
+	tx := testMintTransaction(common.XINAssetId, 100)
+	id := signers[1].Hash().ForNetwork(node.networkId)
+	s := &common.Snapshot{
+		NodeId:      id,
+		Transaction: tx.PayloadHash(),
+		Timestamp:   uint64(time.Now().UnixNano()),
+	}
+	s.Hash = s.PayloadHash()
+	for _, signer := range signers[:len(signers)*2/3+1] {
+		sig := signer.PrivateSpendKey.Sign(s.Hash[:])
+		s.Signatures = append(s.Signatures, &sig)
+	}
+	job := &verifyJob{peerId: id, snapshot: s, signatures: s.Signatures}
+
+	atomic.StoreInt32(&store.readOnly, 1)
+	err := node.buffer.Put(job)
+	assert.Nil(err)
+	for i := 0; i < 100 && !node.Degraded(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(node.Degraded())
+	stats := node.buffer.Stats()
+	assert.Equal(1, stats.Depth)
+	assert.Equal(uint64(0), stats.Flushed)
+
+	atomic.StoreInt32(&store.readOnly, 0)
+	for i := 0; i < 300 && node.buffer.Stats().Flushed == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(node.Degraded())
+	stats = node.buffer.Stats()
+	assert.Equal(0, stats.Depth)
+	assert.Equal(uint64(1), stats.Flushed)
+	assert.Equal(uint64(0), stats.Retried)
+	_, finals, _, err := node.store.QueueInfo()
+	assert.Nil(err)
+	assert.Equal(uint64(1), finals)
+}
@@ -0,0 +1,136 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type GenesisChange struct {
+	Signer string `json:"signer"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// GenesisDiff is the semantic changes from one genesis to another, nodes and
+// domains are matched by signer. Any change, including the nodes reordered,
+// changes the network id. The accept amount and minimum fee are compared by
+// the values in effect, so an omitted one equals its default.
+type GenesisDiff struct {
+	OldNetwork          crypto.Hash     `json:"old_network"`
+	NewNetwork          crypto.Hash     `json:"new_network"`
+	NetworkChanged      bool            `json:"network_changed"`
+	OldEpoch            int64           `json:"old_epoch"`
+	NewEpoch            int64           `json:"new_epoch"`
+	EpochChanged        bool            `json:"epoch_changed"`
+	OldAcceptAmount     string          `json:"old_accept_amount"`
+	NewAcceptAmount     string          `json:"new_accept_amount"`
+	AcceptAmountChanged bool            `json:"accept_amount_changed"`
+	OldMinimumFee       string          `json:"old_minimum_fee"`
+	NewMinimumFee       string          `json:"new_minimum_fee"`
+	MinimumFeeChanged   bool            `json:"minimum_fee_changed"`
+	AddedNodes          []string        `json:"added_nodes"`
+	RemovedNodes        []string        `json:"removed_nodes"`
+	ChangedNodes        []string        `json:"changed_nodes"`
+	NodesReordered      bool            `json:"nodes_reordered"`
+	Payees              []GenesisChange `json:"payees"`
+	Balances            []GenesisChange `json:"balances"`
+	AddedDomains        []string        `json:"added_domains"`
+	RemovedDomains      []string        `json:"removed_domains"`
+	DomainBalances      []GenesisChange `json:"domain_balances"`
+}
+
+func DiffGenesis(a, b *Genesis) (*GenesisDiff, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("invalid genesis to diff")
+	}
+	diff := &GenesisDiff{
+		OldNetwork:      a.Hash(),
+		NewNetwork:      b.Hash(),
+		OldEpoch:        a.Epoch,
+		NewEpoch:        b.Epoch,
+		OldAcceptAmount: a.acceptAmount().String(),
+		NewAcceptAmount: b.acceptAmount().String(),
+		OldMinimumFee:   a.minimumFee().String(),
+		NewMinimumFee:   b.minimumFee().String(),
+	}
+	diff.NetworkChanged = diff.OldNetwork != diff.NewNetwork
+	diff.EpochChanged = a.Epoch != b.Epoch
+	diff.AcceptAmountChanged = a.acceptAmount().Cmp(b.acceptAmount()) != 0
+	diff.MinimumFeeChanged = a.minimumFee().Cmp(b.minimumFee()) != 0
+
+	olds := make(map[string]int)
+	for i, in := range a.Nodes {
+		signer := in.Signer.String()
+		if _, found := olds[signer]; found {
+			return nil, fmt.Errorf("duplicated genesis node %s", signer)
+		}
+		olds[signer] = i
+	}
+	news := make(map[string]bool)
+	var shared []string
+	for _, in := range b.Nodes {
+		signer := in.Signer.String()
+		if news[signer] {
+			return nil, fmt.Errorf("duplicated genesis node %s", signer)
+		}
+		news[signer] = true
+		i, found := olds[signer]
+		if !found {
+			diff.AddedNodes = append(diff.AddedNodes, signer)
+			continue
+		}
+		shared = append(shared, signer)
+		old := a.Nodes[i]
+		var changed bool
+		if old.Payee.String() != in.Payee.String() {
+			diff.Payees = append(diff.Payees, GenesisChange{signer, old.Payee.String(), in.Payee.String()})
+			changed = true
+		}
+		if old.Balance.Cmp(in.Balance) != 0 {
+			diff.Balances = append(diff.Balances, GenesisChange{signer, old.Balance.String(), in.Balance.String()})
+			changed = true
+		}
+		if changed {
+			diff.ChangedNodes = append(diff.ChangedNodes, signer)
+		}
+	}
+	var kept []string
+	for _, in := range a.Nodes {
+		signer := in.Signer.String()
+		if news[signer] {
+			kept = append(kept, signer)
+		} else {
+			diff.RemovedNodes = append(diff.RemovedNodes, signer)
+		}
+	}
+	for i := range shared {
+		if shared[i] != kept[i] {
+			diff.NodesReordered = true
+			break
+		}
+	}
+
+	domains := make(map[string]int)
+	for i, d := range a.Domains {
+		domains[d.Signer.String()] = i
+	}
+	for _, d := range b.Domains {
+		signer := d.Signer.String()
+		i, found := domains[signer]
+		if !found {
+			diff.AddedDomains = append(diff.AddedDomains, signer)
+			continue
+		}
+		delete(domains, signer)
+		if old := a.Domains[i]; old.Balance.Cmp(d.Balance) != 0 {
+			diff.DomainBalances = append(diff.DomainBalances, GenesisChange{signer, old.Balance.String(), d.Balance.String()})
+		}
+	}
+	for _, d := range a.Domains {
+		if _, found := domains[d.Signer.String()]; found {
+			diff.RemovedDomains = append(diff.RemovedDomains, d.Signer.String())
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,104 @@
+package kernel
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffGenesis(t *testing.T) {
+	assert := assert.New(t)
+
+	a, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("diff"), time.Now().Unix())
+	assert.Nil(err)
+	copyGenesis := func() *Genesis {
+		data, err := json.Marshal(a)
+		assert.Nil(err)
+		var b Genesis
+		err = json.Unmarshal(data, &b)
+		assert.Nil(err)
+		return &b
+	}
+
+	diff, err := DiffGenesis(a, copyGenesis())
+	assert.Nil(err)
+	assert.False(diff.NetworkChanged)
+	assert.Equal(a.Hash(), diff.NewNetwork)
+	assert.False(diff.EpochChanged)
+	assert.False(diff.NodesReordered)
+	assert.Len(diff.ChangedNodes, 0)
+	assert.Len(diff.Payees, 0)
+	assert.False(diff.AcceptAmountChanged)
+	assert.False(diff.MinimumFeeChanged)
+
+	b := copyGenesis()
+	pledge := common.NewInteger(PledgeAmount)
+	b.AcceptAmount = &pledge
+	diff, err = DiffGenesis(a, b)
+	assert.Nil(err)
+	assert.True(diff.NetworkChanged)
+	assert.False(diff.AcceptAmountChanged)
+
+	b = copyGenesis()
+	amount := common.NewInteger(5000)
+	b.AcceptAmount = &amount
+	diff, err = DiffGenesis(a, b)
+	assert.Nil(err)
+	assert.True(diff.NetworkChanged)
+	assert.True(diff.AcceptAmountChanged)
+	assert.Equal(pledge.String(), diff.OldAcceptAmount)
+	assert.Equal(amount.String(), diff.NewAcceptAmount)
+	assert.False(diff.MinimumFeeChanged)
+	assert.Len(diff.ChangedNodes, 0)
+
+	b = copyGenesis()
+	fee := common.NewInteger(1)
+	b.MinimumFee = &fee
+	diff, err = DiffGenesis(a, b)
+	assert.Nil(err)
+	assert.True(diff.NetworkChanged)
+	assert.True(diff.MinimumFeeChanged)
+	assert.Equal(common.NewInteger(0).String(), diff.OldMinimumFee)
+	assert.Equal(fee.String(), diff.NewMinimumFee)
+	assert.False(diff.AcceptAmountChanged)
+	assert.False(diff.EpochChanged)
+
+	b = copyGenesis()
+	payee := common.NewAddressFromSeed(make([]byte, 64))
+	b.Nodes[2].Payee = payee
+	diff, err = DiffGenesis(a, b)
+	assert.Nil(err)
+	assert.True(diff.NetworkChanged)
+	assert.Equal(a.Hash(), diff.OldNetwork)
+	assert.Equal(b.Hash(), diff.NewNetwork)
+	assert.Equal([]string{a.Nodes[2].Signer.String()}, diff.ChangedNodes)
+	assert.Equal([]GenesisChange{{a.Nodes[2].Signer.String(), a.Nodes[2].Payee.String(), payee.String()}}, diff.Payees)
+	assert.Len(diff.Balances, 0)
+	assert.Len(diff.AddedNodes, 0)
+	assert.Len(diff.RemovedNodes, 0)
+	assert.False(diff.NodesReordered)
+	assert.False(diff.EpochChanged)
+
+	b = copyGenesis()
+	b.Epoch = a.Epoch + 1
+	b.Nodes = append(b.Nodes[1:2], b.Nodes[0])
+	b.Nodes[0].Balance = common.NewInteger(20000)
+	b.Domains[0].Balance = common.NewInteger(40000)
+	diff, err = DiffGenesis(a, b)
+	assert.Nil(err)
+	assert.True(diff.NetworkChanged)
+	assert.True(diff.EpochChanged)
+	assert.Equal(a.Epoch+1, diff.NewEpoch)
+	assert.True(diff.NodesReordered)
+	assert.Len(diff.RemovedNodes, MinimumNodeCount-2)
+	assert.Len(diff.Balances, 1)
+	assert.Equal(common.NewInteger(20000).String(), diff.Balances[0].New)
+	assert.Len(diff.DomainBalances, 1)
+	assert.Len(diff.AddedDomains, 0)
+
+	_, err = DiffGenesis(a, nil)
+	assert.NotNil(err)
+}
@@ -0,0 +1,74 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type DomainInfo struct {
+	Signer      common.Address `json:"signer"`
+	Balance     common.Integer `json:"balance"`
+	Keys        []crypto.Key   `json:"keys"`
+	Threshold   uint8          `json:"threshold"`
+	Transaction crypto.Hash    `json:"transaction"`
+	Snapshot    crypto.Hash    `json:"snapshot"`
+}
+
+// ListDomains reconstructs the domain signer from the domain accept
+// transaction extra, and the balance and keys from its first output.
+func (node *Node) ListDomains() ([]DomainInfo, error) {
+	domains := make([]DomainInfo, 0)
+	for _, d := range node.store.ReadDomains() {
+		tx, err := node.store.ReadTransaction(d.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil {
+			return nil, fmt.Errorf("domain accept transaction not found %s", d.Transaction.String())
+		}
+		var signer common.Address
+		if len(tx.Extra) != len(signer.PublicSpendKey) {
+			return nil, fmt.Errorf("invalid domain accept extra size %d", len(tx.Extra))
+		}
+		copy(signer.PublicSpendKey[:], tx.Extra)
+		signer.PrivateViewKey = signer.PublicSpendKey.DeterministicHashDerive()
+		signer.PublicViewKey = signer.PrivateViewKey.Public()
+
+		utxo, err := node.store.ReadUTXOWithLock(d.Transaction, 0)
+		if err != nil {
+			return nil, err
+		}
+		if utxo == nil || utxo.Type != common.OutputTypeDomainAccept {
+			return nil, fmt.Errorf("domain accept output not found %s", d.Transaction.String())
+		}
+		threshold, err := utxo.Script.Threshold()
+		if err != nil {
+			return nil, err
+		}
+
+		info := DomainInfo{
+			Signer:      signer,
+			Balance:     utxo.Amount,
+			Keys:        utxo.Keys,
+			Threshold:   threshold,
+			Transaction: d.Transaction,
+		}
+		nodeId := signer.Hash().ForNetwork(node.networkId)
+		snapshots, err := node.store.ReadSnapshotsForNodeRound(nodeId, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range snapshots {
+			if s.Transaction == d.Transaction {
+				info.Snapshot = s.Hash
+			}
+		}
+		if !info.Snapshot.HasValue() {
+			return nil, fmt.Errorf("domain accept snapshot not found %s", d.Transaction.String())
+		}
+		domains = append(domains, info)
+	}
+	return domains, nil
+}
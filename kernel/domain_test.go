@@ -0,0 +1,32 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListDomains(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	domains, err := node.ListDomains()
+	assert.Nil(err)
+	assert.Len(domains, 1)
+	domain := domains[0]
+	assert.Equal(signers[0].String(), domain.Signer.String())
+	assert.Equal(common.NewInteger(50000), domain.Balance)
+	assert.Len(domain.Keys, len(signers))
+	assert.Equal(uint8(len(signers)*2/3+1), domain.Threshold)
+
+	snapshots, err := node.store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	last := snapshots[len(snapshots)-1]
+	assert.Equal(last.Transaction, domain.Transaction)
+	assert.Equal(last.Hash, domain.Snapshot)
+}
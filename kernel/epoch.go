@@ -0,0 +1,19 @@
+package kernel
+
+import (
+	"fmt"
+	"time"
+)
+
+// CurrentEpoch counts the epochs elapsed since the genesis epoch, with the
+// epoch-seconds duration of the config, and the time elapsed in the current
+// epoch.
+func (node *Node) CurrentEpoch() (number uint64, elapsed time.Duration, err error) {
+	now := node.clock()
+	if now.Before(node.epoch) {
+		return 0, 0, fmt.Errorf("network epoch not started yet %s", node.epoch.String())
+	}
+	duration := time.Duration(node.custom.EpochSeconds) * time.Second
+	since := now.Sub(node.epoch)
+	return uint64(since / duration), since % duration, nil
+}
@@ -0,0 +1,54 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentEpoch(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	epoch := time.Unix(gns.Epoch, 0)
+	duration := time.Duration(node.custom.EpochSeconds) * time.Second
+	assert.Equal(24*time.Hour, duration)
+
+	now := epoch
+	node.clock = func() time.Time { return now }
+	number, elapsed, err := node.CurrentEpoch()
+	assert.Nil(err)
+	assert.Equal(uint64(0), number)
+	assert.Equal(time.Duration(0), elapsed)
+
+	now = epoch.Add(duration - time.Nanosecond)
+	number, elapsed, err = node.CurrentEpoch()
+	assert.Nil(err)
+	assert.Equal(uint64(0), number)
+	assert.Equal(duration-time.Nanosecond, elapsed)
+
+	for i := uint64(1); i < 5; i++ {
+		now = epoch.Add(time.Duration(i)*duration + time.Minute)
+		number, elapsed, err = node.CurrentEpoch()
+		assert.Nil(err)
+		assert.Equal(i, number)
+		assert.Equal(time.Minute, elapsed)
+	}
+
+	node.custom.EpochSeconds = 3600
+	number, elapsed, err = node.CurrentEpoch()
+	assert.Nil(err)
+	assert.Equal(uint64(96), number)
+	assert.Equal(time.Minute, elapsed)
+
+	now = epoch.Add(-time.Second)
+	_, _, err = node.CurrentEpoch()
+	assert.NotNil(err)
+}
@@ -19,6 +19,9 @@ func (node *Node) verifyExternalSnapshot(s *common.Snapshot) error {
 	if s.RoundNumber < cache.Number {
 		return nil
 	}
+	if node.deferRoundGap(s, cache.Number, false) {
+		return nil
+	}
 	if s.RoundNumber > cache.Number+1 {
 		return node.queueSnapshotOrPanic(s, false)
 	}
@@ -41,7 +44,9 @@ func (node *Node) verifyExternalSnapshot(s *common.Snapshot) error {
 			Timestamp:  s.Timestamp,
 			References: s.References,
 		}
-		err := node.store.StartNewRound(cache.NodeId, cache.Number, cache.References, final.Start)
+		err := node.writeStoreOrDegrade(func() error {
+			return node.store.StartNewRound(cache.NodeId, cache.Number, cache.References, final.Start)
+		})
 		if err != nil {
 			panic(err)
 		}
@@ -53,6 +58,7 @@ func (node *Node) verifyExternalSnapshot(s *common.Snapshot) error {
 	node.Graph.CacheRound[s.NodeId] = cache
 	node.Graph.FinalRound[s.NodeId] = final
 	node.Graph.RoundHistory[s.NodeId] = append(node.Graph.RoundHistory[s.NodeId], final.Copy())
+	node.releaseRoundGap(s.NodeId, cache.Number)
 	node.signSnapshot(s)
 	s.Signatures = []*crypto.Signature{node.SignaturesPool[s.Hash]}
 	return node.Peer.SendSnapshotMessage(s.NodeId, s, 0)
@@ -35,6 +35,9 @@ func (node *Node) handleSyncFinalSnapshot(s *common.Snapshot) error {
 	if s.RoundNumber < cache.Number {
 		return nil
 	}
+	if node.deferRoundGap(s, cache.Number, true) {
+		return nil
+	}
 	if s.RoundNumber > cache.Number+1 {
 		return node.queueSnapshotOrPanic(s, true)
 	}
@@ -45,10 +48,18 @@ func (node *Node) handleSyncFinalSnapshot(s *common.Snapshot) error {
 		if len(cache.Snapshots) != 0 {
 			return nil
 		}
-		err := node.store.UpdateEmptyHeadRound(cache.NodeId, cache.Number, s.References)
+		err := node.writeStoreOrDegrade(func() error {
+			return node.store.UpdateEmptyHeadRound(cache.NodeId, cache.Number, s.References)
+		})
 		if err != nil {
 			panic(err)
 		}
+		node.references.emit(&RoundReferenceChange{
+			NodeId: cache.NodeId,
+			Number: cache.Number,
+			Old:    cache.References,
+			New:    s.References,
+		})
 		cache.References = s.References
 		node.Graph.CacheRound[s.NodeId] = cache
 		return node.handleSnapshotInput(s)
@@ -67,7 +78,9 @@ func (node *Node) handleSyncFinalSnapshot(s *common.Snapshot) error {
 			Timestamp:  s.Timestamp,
 			References: s.References,
 		}
-		err := node.store.StartNewRound(cache.NodeId, cache.Number, cache.References, final.Start)
+		err := node.writeStoreOrDegrade(func() error {
+			return node.store.StartNewRound(cache.NodeId, cache.Number, cache.References, final.Start)
+		})
 		if err != nil {
 			panic(err)
 		}
@@ -80,10 +93,13 @@ func (node *Node) handleSyncFinalSnapshot(s *common.Snapshot) error {
 		Snapshot:         *s,
 		TopologicalOrder: node.TopoCounter.Next(),
 	}
-	err := node.store.WriteSnapshot(topo)
+	err := node.writeStoreOrDegrade(func() error {
+		return node.store.WriteSnapshot(topo)
+	})
 	if err != nil {
 		panic(err)
 	}
+	node.markFinalized()
 	if !cache.ValidateSnapshot(s, true) {
 		panic("should never be here")
 	}
@@ -91,5 +107,6 @@ func (node *Node) handleSyncFinalSnapshot(s *common.Snapshot) error {
 	node.Graph.CacheRound[s.NodeId] = cache
 	node.Graph.FinalRound[s.NodeId] = final
 	node.Graph.RoundHistory[s.NodeId] = append(node.Graph.RoundHistory[s.NodeId], final.Copy())
+	node.releaseRoundGap(s.NodeId, cache.Number)
 	return nil
 }
@@ -0,0 +1,102 @@
+package kernel
+
+import (
+	"sync"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	RoundGapDefault       = 1024
+	RoundGapDeferredLimit = 8192
+)
+
+type deferredSnapshot struct {
+	snapshot  *common.Snapshot
+	finalized bool
+}
+
+// roundGapQueue holds the snapshots with a round number too far ahead of the
+// cache round of its node, they are not trusted until the node round catches
+// up within the gap, and the ones beyond the limit are dropped.
+type roundGapQueue struct {
+	mutex     *sync.Mutex
+	gap       uint64
+	limit     int
+	count     int
+	snapshots map[crypto.Hash][]*deferredSnapshot
+}
+
+func newRoundGapQueue(gap uint64, limit int) *roundGapQueue {
+	if gap < 1 {
+		gap = RoundGapDefault
+	}
+	return &roundGapQueue{
+		mutex:     new(sync.Mutex),
+		gap:       gap,
+		limit:     limit,
+		snapshots: make(map[crypto.Hash][]*deferredSnapshot),
+	}
+}
+
+func (q *roundGapQueue) exceeds(s *common.Snapshot, number uint64) bool {
+	return s.RoundNumber > number+q.gap
+}
+
+func (q *roundGapQueue) put(s *common.Snapshot, finalized bool) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.count >= q.limit {
+		return false
+	}
+	q.snapshots[s.NodeId] = append(q.snapshots[s.NodeId], &deferredSnapshot{s, finalized})
+	q.count = q.count + 1
+	return true
+}
+
+// release returns the snapshots of the node within the gap of the number.
+func (q *roundGapQueue) release(nodeId crypto.Hash, number uint64) []*deferredSnapshot {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var released, kept []*deferredSnapshot
+	for _, d := range q.snapshots[nodeId] {
+		if q.exceeds(d.snapshot, number) {
+			kept = append(kept, d)
+		} else {
+			released = append(released, d)
+		}
+	}
+	if len(kept) == 0 {
+		delete(q.snapshots, nodeId)
+	} else {
+		q.snapshots[nodeId] = kept
+	}
+	q.count = q.count - len(released)
+	return released
+}
+
+func (q *roundGapQueue) size() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.count
+}
+
+// deferRoundGap returns true if the snapshot is too far ahead of the cache
+// round number, then it's deferred or dropped instead of queued.
+func (node *Node) deferRoundGap(s *common.Snapshot, number uint64, finalized bool) bool {
+	if !node.roundGaps.exceeds(s, number) {
+		return false
+	}
+	if !node.roundGaps.put(s, finalized) {
+		logger.Println("round gap deferred snapshots full, drop", s.NodeId.String(), s.RoundNumber)
+	}
+	return true
+}
+
+func (node *Node) releaseRoundGap(nodeId crypto.Hash, number uint64) {
+	for _, d := range node.roundGaps.release(nodeId, number) {
+		node.queueSnapshotOrPanic(d.snapshot, d.finalized)
+	}
+}
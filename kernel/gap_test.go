@@ -0,0 +1,59 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundGapDeferred(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	nodeId := node.IdForNetwork
+	cache := node.Graph.CacheRound[nodeId].Copy()
+	s := &common.Snapshot{
+		NodeId:      nodeId,
+		Transaction: crypto.NewHash([]byte("gap")),
+		RoundNumber: cache.Number + RoundGapDefault*1000,
+	}
+	err := node.handleSyncFinalSnapshot(s)
+	assert.Nil(err)
+	assert.Equal(1, node.roundGaps.size())
+	assert.Equal(cache.Number, node.Graph.CacheRound[nodeId].Number)
+
+	var external crypto.Hash
+	for id := range node.ConsensusNodes {
+		if id != nodeId {
+			external = id
+		}
+	}
+	err = node.verifyExternalSnapshot(&common.Snapshot{
+		NodeId:      external,
+		RoundNumber: node.Graph.CacheRound[external].Number + RoundGapDefault + 1,
+		Signatures:  []*crypto.Signature{{}},
+	})
+	assert.Nil(err)
+	assert.Equal(2, node.roundGaps.size())
+
+	released := node.roundGaps.release(nodeId, cache.Number+RoundGapDefault)
+	assert.Len(released, 0)
+	released = node.roundGaps.release(nodeId, s.RoundNumber-RoundGapDefault)
+	assert.Len(released, 1)
+	assert.Equal(s, released[0].snapshot)
+	assert.True(released[0].finalized)
+	assert.Equal(1, node.roundGaps.size())
+
+	q := newRoundGapQueue(4, 1)
+	assert.False(q.exceeds(&common.Snapshot{RoundNumber: 14}, 10))
+	assert.True(q.exceeds(&common.Snapshot{RoundNumber: 15}, 10))
+	assert.True(q.put(s, false))
+	assert.False(q.put(s, false))
+	assert.Equal(1, q.size())
+}
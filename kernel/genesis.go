@@ -1,23 +1,55 @@
 package kernel
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
 )
 
 const (
 	MinimumNodeCount = 7
-	PledgeAmount     = 10000
+	PledgeAmount     = common.NodePledgeAmount
+
+	// the domain snapshot must be ordered after all the node accept snapshots
+	DomainSnapshotTimestampOffset = 1
 )
 
+var ErrNetworkMismatch = errors.New("data dir belongs to a different network than genesis.json")
+
+type NetworkMismatchError struct {
+	Stored   crypto.Hash
+	Computed crypto.Hash
+}
+
+func (e *NetworkMismatchError) Error() string {
+	return fmt.Sprintf("invalid genesis for network %s, genesis.json computes network %s, %s", e.Stored.String(), e.Computed.String(), ErrNetworkMismatch.Error())
+}
+
+func (e *NetworkMismatchError) Is(target error) bool {
+	return target == ErrNetworkMismatch
+}
+
+// AcceptAmount is the amount of each node accept output, the pledge amount if
+// omitted, and then it's not in the JSON either, so the network id is kept.
+// MinimumFee is a consensus rule all nodes must agree on, no fee if omitted.
 type Genesis struct {
-	Epoch int64 `json:"epoch"`
-	Nodes []struct {
+	Epoch        int64           `json:"epoch"`
+	AcceptAmount *common.Integer `json:"accept_amount,omitempty"`
+	MinimumFee   *common.Integer `json:"minimum_fee,omitempty"`
+	Nodes        []struct {
 		Signer  common.Address `json:"signer"`
 		Payee   common.Address `json:"payee"`
 		Balance common.Integer `json:"balance"`
@@ -31,16 +63,28 @@ type Genesis struct {
 func (node *Node) LoadGenesis(configDir string) error {
 	const stateKeyNetwork = "network"
 
-	gns, err := readGenesis(configDir + "/genesis.json")
+	gns, err := parseGenesis(configDir + "/genesis.json")
 	if err != nil {
 		return err
 	}
 
-	data, err := json.Marshal(gns)
+	err = common.SetMinimumFee(gns.minimumFee().String())
+	if err != nil {
+		return err
+	}
+	err = common.SetNodeAcceptAmount(gns.acceptAmount().String())
 	if err != nil {
 		return err
 	}
-	node.networkId = crypto.NewHash(data)
+
+	node.networkId = gns.Hash()
+	node.epoch = time.Unix(gns.Epoch, 0)
+	node.genesis = GenesisInfo{
+		Hash:    node.networkId,
+		Nodes:   len(gns.Nodes),
+		Domains: len(gns.Domains),
+		Epoch:   gns.Epoch,
+	}
 	node.IdForNetwork = node.Signer.Hash().ForNetwork(node.networkId)
 
 	var state struct {
@@ -51,47 +95,31 @@ func (node *Node) LoadGenesis(configDir string) error {
 		return err
 	}
 	if found && state.Id != node.networkId {
-		return fmt.Errorf("invalid genesis for network %s", state.Id.String())
+		return &NetworkMismatchError{Stored: state.Id, Computed: node.networkId}
 	}
 	loaded, err := node.store.CheckGenesisLoad()
 	if err != nil || loaded {
 		return err
 	}
+	sample := node.genesisSample(len(gns.Nodes))
+	err = gns.validate(sample)
+	if err != nil {
+		return err
+	}
+
+	workers := 1
+	if node.custom != nil && node.custom.GenesisParallel {
+		workers = runtime.NumCPU()
+	}
+	transactions, err := buildGenesisTransactions(gns, node.networkId, workers, sample)
+	if err != nil {
+		return err
+	}
 
 	var snapshots []*common.SnapshotWithTopologicalOrder
-	var transactions []*common.SignedTransaction
 	cacheRounds := make(map[crypto.Hash]*CacheRound)
-	for _, in := range gns.Nodes {
-		seed := crypto.NewHash([]byte(in.Signer.String() + "NODEACCEPT"))
-		r := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
-		R := r.Public()
-		var keys []crypto.Key
-		for _, d := range gns.Nodes {
-			key := crypto.DeriveGhostPublicKey(&r, &d.Signer.PublicViewKey, &d.Signer.PublicSpendKey, 0)
-			keys = append(keys, *key)
-		}
-
-		tx := common.Transaction{
-			Version: common.TxVersion,
-			Asset:   common.XINAssetId,
-			Inputs: []*common.Input{
-				{
-					Genesis: node.networkId[:],
-				},
-			},
-			Outputs: []*common.Output{
-				{
-					Type:   common.OutputTypeNodeAccept,
-					Script: common.Script([]uint8{common.OperatorCmp, common.OperatorSum, uint8(len(gns.Nodes)*2/3 + 1)}),
-					Amount: common.NewInteger(PledgeAmount),
-					Keys:   keys,
-					Mask:   R,
-				},
-			},
-		}
-		tx.Extra = append(in.Signer.PublicSpendKey[:], in.Payee.PublicSpendKey[:]...)
-
-		signed := &common.SignedTransaction{Transaction: tx}
+	for i, in := range gns.Nodes {
+		signed := transactions[i]
 		nodeId := in.Signer.Hash().ForNetwork(node.networkId)
 		snapshot := common.Snapshot{
 			NodeId:      nodeId,
@@ -105,7 +133,6 @@ func (node *Node) LoadGenesis(configDir string) error {
 			TopologicalOrder: node.TopoCounter.Next(),
 		}
 		snapshots = append(snapshots, topo)
-		transactions = append(transactions, signed)
 		cacheRounds[snapshot.NodeId] = &CacheRound{
 			NodeId:    snapshot.NodeId,
 			Number:    0,
@@ -113,24 +140,48 @@ func (node *Node) LoadGenesis(configDir string) error {
 		}
 	}
 
-	domain := gns.Domains[0]
-	if in := gns.Nodes[0]; domain.Signer.String() != in.Signer.String() {
-		return fmt.Errorf("invalid genesis domain input account %s %s", domain.Signer.String(), in.Signer.String())
-	}
-	topo, signed := node.buildDomainSnapshot(domain.Signer, gns)
+	topo := node.buildDomainSnapshot(gns.Domains[0].Signer, transactions[len(gns.Nodes)], gns)
 	snapshots = append(snapshots, topo)
-	transactions = append(transactions, signed)
 	snap := &topo.Snapshot
 	snap.Hash = snap.PayloadHash()
 	cacheRounds[topo.NodeId].Snapshots = append(cacheRounds[topo.NodeId].Snapshots, snap)
 
-	rounds := make([]*common.Round, 0)
+	nodeIds := make([]crypto.Hash, len(gns.Nodes))
 	for i, in := range gns.Nodes {
-		id := in.Signer.Hash().ForNetwork(node.networkId)
-		external := gns.Nodes[0].Signer.Hash().ForNetwork(node.networkId)
-		if i != len(gns.Nodes)-1 {
-			external = gns.Nodes[i+1].Signer.Hash().ForNetwork(node.networkId)
-		}
+		nodeIds[i] = in.Signer.Hash().ForNetwork(node.networkId)
+	}
+	rounds := buildGenesisRounds(nodeIds, cacheRounds)
+	err = verifyDomainThreshold(len(gns.Nodes), transactions)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = node.store.LoadGenesis(rounds, snapshots, transactions)
+	if err != nil {
+		return err
+	}
+	metrics.Histogram("mixin_genesis_load_seconds", time.Since(start).Seconds())
+	// the genesis is loaded with the latest schema, a store without the schema
+	// after a crash here just runs the migrations again
+	schema := struct{ Version uint64 }{StoreSchemaVersion()}
+	err = node.store.StateSet(stateKeySchema, schema)
+	if err != nil {
+		return err
+	}
+
+	state.Id = node.networkId
+	return node.store.StateSet(stateKeyNetwork, state)
+}
+
+// buildGenesisRounds links the nodes in a ring, the first round of each node
+// references the next node, and the last node wraps to the first. The nodeIds
+// must be in the canonical order, which is the order of the genesis nodes, so
+// all nodes build the same rounds.
+func buildGenesisRounds(nodeIds []crypto.Hash, cacheRounds map[crypto.Hash]*CacheRound) []*common.Round {
+	rounds := make([]*common.Round, 0)
+	for i, id := range nodeIds {
+		external := nodeIds[(i+1)%len(nodeIds)]
 		selfFinal := cacheRounds[id].asFinal()
 		externalFinal := cacheRounds[external].asFinal()
 		rounds = append(rounds, &common.Round{
@@ -149,19 +200,176 @@ func (node *Node) LoadGenesis(configDir string) error {
 			},
 		})
 	}
+	return rounds
+}
 
-	err = node.store.LoadGenesis(rounds, snapshots, transactions)
+func genesisNodeAcceptMask(signer common.Address) crypto.Key {
+	seed := crypto.NewHash([]byte(signer.String() + "NODEACCEPT"))
+	return crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+}
+
+func genesisDomainAcceptMask(domain common.Address) crypto.Key {
+	seed := crypto.NewHash([]byte(domain.String() + "DOMAINACCEPT"))
+	return crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+}
+
+// genesisSample is the genesis nodes verified, all of them when it's nil.
+type genesisSample map[int]bool
+
+func (s genesisSample) has(i int) bool {
+	return s == nil || s[i]
+}
+
+// genesisSample is nil for the full verification. The light verification for
+// constrained devices trusts the network id, and verifies only a random sample
+// of the genesis nodes, always with the first one because it's the domain.
+func (node *Node) genesisSample(nodes int) genesisSample {
+	if node.custom == nil || !node.custom.GenesisLight {
+		return nil
+	}
+	sample := genesisSample{0: true}
+	count := node.custom.GenesisLightSample
+	if count > nodes {
+		count = nodes
+	}
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, i := range random.Perm(nodes)[:count] {
+		sample[i] = true
+	}
+	logger.Printf("GENESIS light verification of %d/%d nodes, full verification skipped\n", len(sample), nodes)
+	return sample
+}
+
+// deriveGenesisKeys derives the node accept output keys of all genesis nodes,
+// the nodes are spread across the workers, while the result and the error
+// returned are always the same as the serial derivation with one worker. The
+// keys of all nodes are derived because they are committed in the outputs,
+// but only the keys of the nodes in the sample are checked, and the number of
+// keys checked is returned.
+func deriveGenesisKeys(ctx context.Context, gns *Genesis, workers int, sample genesisSample) ([][]crypto.Key, int, error) {
+	keys := make([][]crypto.Key, len(gns.Nodes))
+	checks := make([]int, len(gns.Nodes))
+	errs := make([]error, len(gns.Nodes))
+	derive := func(i int) {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			return
+		}
+		r := genesisNodeAcceptMask(gns.Nodes[i].Signer)
+		for _, d := range gns.Nodes {
+			key := crypto.DeriveGhostPublicKey(&r, &d.Signer.PublicViewKey, &d.Signer.PublicSpendKey, 0)
+			if sample.has(i) {
+				checks[i] = checks[i] + 1
+				if !key.CheckSubgroup() {
+					errs[i] = fmt.Errorf("invalid genesis output key subgroup %s %s", d.Signer.String(), key.String())
+					return
+				}
+			}
+			keys[i] = append(keys[i], *key)
+		}
+	}
+
+	if workers < 2 {
+		for i := range gns.Nodes {
+			derive(i)
+			if errs[i] != nil {
+				return nil, 0, errs[i]
+			}
+		}
+		return keys, sumGenesisKeyChecks(checks), nil
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan int, len(gns.Nodes))
+	for i := range gns.Nodes {
+		jobs <- i
+	}
+	close(jobs)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				derive(i)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return keys, sumGenesisKeyChecks(checks), nil
+}
+
+func sumGenesisKeyChecks(checks []int) int {
+	var sum int
+	for _, c := range checks {
+		sum = sum + c
+	}
+	return sum
+}
+
+// BuildGenesisTransactions builds the node accept transactions in the genesis
+// nodes order, followed by the domain accept transaction, exactly as they are
+// committed by LoadGenesis, so they can be inspected before the commit.
+func BuildGenesisTransactions(gns *Genesis, networkId crypto.Hash) ([]*common.SignedTransaction, error) {
+	err := gns.validate(nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return buildGenesisTransactions(gns, networkId, 1, nil)
+}
 
-	state.Id = node.networkId
-	return node.store.StateSet(stateKeyNetwork, state)
+func buildGenesisTransactions(gns *Genesis, networkId crypto.Hash, workers int, sample genesisSample) ([]*common.SignedTransaction, error) {
+	domain := gns.Domains[0]
+	if in := gns.Nodes[0]; domain.Signer.String() != in.Signer.String() {
+		return nil, fmt.Errorf("invalid genesis domain input account %s %s", domain.Signer.String(), in.Signer.String())
+	}
+	nodeKeys, _, err := deriveGenesisKeys(context.Background(), gns, workers, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []*common.SignedTransaction
+	for i, in := range gns.Nodes {
+		R := genesisNodeAcceptMask(in.Signer).Public()
+		tx := common.Transaction{
+			Version: common.TxVersion,
+			Asset:   common.XINAssetId,
+			Inputs: []*common.Input{
+				{
+					Genesis: networkId[:],
+				},
+			},
+			Outputs: []*common.Output{
+				{
+					Type:   common.OutputTypeNodeAccept,
+					Script: common.ScriptThreshold{Required: quorumThreshold(len(gns.Nodes))}.Compile(),
+					Amount: gns.acceptAmount(),
+					Keys:   nodeKeys[i],
+					Mask:   R,
+				},
+			},
+		}
+		tx.Extra = append(in.Signer.PublicSpendKey[:], in.Payee.PublicSpendKey[:]...)
+		transactions = append(transactions, &common.SignedTransaction{Transaction: tx})
+	}
+
+	// the domain output has a key derived for each node, so it covers all the
+	// nodes skipped by the sample
+	signed := buildDomainTransaction(domain.Signer, gns, networkId)
+	for i, key := range signed.Outputs[0].Keys {
+		if !key.CheckSubgroup() {
+			return nil, fmt.Errorf("invalid genesis domain key subgroup %s %s", gns.Nodes[i].Signer.String(), key.String())
+		}
+	}
+	return append(transactions, signed), nil
 }
 
-func (node *Node) buildDomainSnapshot(domain common.Address, gns *Genesis) (*common.SnapshotWithTopologicalOrder, *common.SignedTransaction) {
-	seed := crypto.NewHash([]byte(domain.String() + "DOMAINACCEPT"))
-	r := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+func buildDomainTransaction(domain common.Address, gns *Genesis, networkId crypto.Hash) *common.SignedTransaction {
+	r := genesisDomainAcceptMask(domain)
 	R := r.Public()
 	keys := make([]crypto.Key, 0)
 	for _, d := range gns.Nodes {
@@ -174,13 +382,13 @@ func (node *Node) buildDomainSnapshot(domain common.Address, gns *Genesis) (*com
 		Asset:   common.XINAssetId,
 		Inputs: []*common.Input{
 			{
-				Genesis: node.networkId[:],
+				Genesis: networkId[:],
 			},
 		},
 		Outputs: []*common.Output{
 			{
 				Type:   common.OutputTypeDomainAccept,
-				Script: common.Script([]uint8{common.OperatorCmp, common.OperatorSum, uint8(len(gns.Nodes)*2/3 + 1)}),
+				Script: common.ScriptThreshold{Required: quorumThreshold(len(gns.Nodes))}.Compile(),
 				Amount: common.NewInteger(50000),
 				Keys:   keys,
 				Mask:   R,
@@ -189,22 +397,278 @@ func (node *Node) buildDomainSnapshot(domain common.Address, gns *Genesis) (*com
 	}
 	tx.Extra = make([]byte, len(domain.PublicSpendKey))
 	copy(tx.Extra, domain.PublicSpendKey[:])
+	return &common.SignedTransaction{Transaction: tx}
+}
 
-	signed := &common.SignedTransaction{Transaction: tx}
+func (node *Node) buildDomainSnapshot(domain common.Address, signed *common.SignedTransaction, gns *Genesis) *common.SnapshotWithTopologicalOrder {
 	nodeId := domain.Hash().ForNetwork(node.networkId)
 	snapshot := common.Snapshot{
 		NodeId:      nodeId,
 		Transaction: signed.PayloadHash(),
 		RoundNumber: 0,
-		Timestamp:   uint64(time.Unix(gns.Epoch, 0).UnixNano() + 1),
+		Timestamp:   uint64(time.Unix(gns.Epoch, 0).UnixNano() + DomainSnapshotTimestampOffset),
 	}
 	return &common.SnapshotWithTopologicalOrder{
 		Snapshot:         snapshot,
 		TopologicalOrder: node.TopoCounter.Next(),
-	}, signed
+	}
+}
+
+type GenesisInfo struct {
+	Hash    crypto.Hash `json:"hash"`
+	Nodes   int         `json:"nodes"`
+	Domains int         `json:"domains"`
+	Epoch   int64       `json:"epoch"`
+}
+
+// GenesisInfo is the summary of the genesis loaded, two nodes on the same
+// network always have the same one.
+func (node *Node) GenesisInfo() GenesisInfo {
+	return node.genesis
+}
+
+func (gns *Genesis) acceptAmount() common.Integer {
+	if gns.AcceptAmount != nil {
+		return *gns.AcceptAmount
+	}
+	return common.NewInteger(PledgeAmount)
+}
+
+func (gns *Genesis) minimumFee() common.Integer {
+	if gns.MinimumFee != nil {
+		return *gns.MinimumFee
+	}
+	return common.NewInteger(0)
+}
+
+// ExpectedSnapshotCount is the number of snapshots committed by LoadGenesis,
+// one node accept snapshot for each node and one for each domain.
+func (gns *Genesis) ExpectedSnapshotCount() int {
+	return len(gns.Nodes) + len(gns.Domains)
+}
+
+// Hash is the network id, which hashes the JSON of the parsed genesis instead
+// of the file, so the formatting and field order of genesis.json are ignored.
+func (gns *Genesis) Hash() crypto.Hash {
+	data, err := json.Marshal(gns)
+	if err != nil {
+		panic(err)
+	}
+	return crypto.NewHash(data)
+}
+
+// GenesisSnapshots returns the node accept snapshots in the genesis nodes
+// order, followed by the domain accept snapshot, as committed by LoadGenesis.
+func (node *Node) GenesisSnapshots() ([]*common.SnapshotWithTopologicalOrder, error) {
+	gns, err := readGenesis(node.configDir + "/genesis.json")
+	if err != nil {
+		return nil, err
+	}
+	count := uint64(gns.ExpectedSnapshotCount())
+	snapshots, err := node.store.ReadSnapshotsSinceTopology(0, count)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(snapshots)) != count {
+		return nil, fmt.Errorf("invalid genesis snapshots count %d/%d", len(snapshots), count)
+	}
+	for _, s := range snapshots {
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil || len(tx.Inputs) != 1 || bytes.Compare(tx.Inputs[0].Genesis, node.networkId[:]) != 0 {
+			return nil, fmt.Errorf("invalid genesis snapshot %s", s.Hash.String())
+		}
+	}
+	return snapshots, nil
+}
+
+// GenesisUTXOSet returns all outputs of the genesis snapshots in topological
+// order, i.e. the node accept outputs followed by the domain accept output.
+// They are returned as UTXO because an output has no asset of its own.
+func (node *Node) GenesisUTXOSet() ([]*common.UTXO, error) {
+	snapshots, err := node.GenesisSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	var utxos []*common.UTXO
+	for _, s := range snapshots {
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		for i, out := range tx.Outputs {
+			utxos = append(utxos, &common.UTXO{
+				Input:  common.Input{Hash: s.Transaction, Index: i},
+				Output: *out,
+				Asset:  tx.Asset,
+			})
+		}
+	}
+	return utxos, nil
+}
+
+// VerifyGenesisKeyUniqueness checks no ghost key is used twice in all genesis
+// outputs, each output derives its keys with a distinct mask, so a duplicated
+// key is a derivation bug.
+func (node *Node) VerifyGenesisKeyUniqueness() error {
+	utxos, err := node.GenesisUTXOSet()
+	if err != nil {
+		return err
+	}
+	return checkGhostKeysUnique(utxos)
+}
+
+func checkGhostKeysUnique(utxos []*common.UTXO) error {
+	filter := make(map[crypto.Key]*common.UTXO)
+	for _, utxo := range utxos {
+		for _, k := range utxo.Keys {
+			if dup := filter[k]; dup != nil {
+				return fmt.Errorf("duplicated genesis ghost key %s %s:%d %s:%d", k.String(), dup.Hash.String(), dup.Index, utxo.Hash.String(), utxo.Index)
+			}
+			filter[k] = utxo
+		}
+	}
+	return nil
+}
+
+// VerifyDomainThreshold checks the domain accept outputs in genesis require
+// the same quorum of the genesis nodes as the consensus, so the domain is
+// never controlled by fewer nodes.
+func (node *Node) VerifyDomainThreshold() error {
+	snapshots, err := node.GenesisSnapshots()
+	if err != nil {
+		return err
+	}
+	var transactions []*common.SignedTransaction
+	for _, s := range snapshots {
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, tx)
+	}
+	return verifyDomainThreshold(node.genesis.Nodes, transactions)
+}
+
+// verifyDomainThreshold is also checked by LoadGenesis on the transactions
+// built, before any of them is committed.
+func verifyDomainThreshold(nodes int, transactions []*common.SignedTransaction) error {
+	expected := quorumThreshold(nodes)
+	var domains int
+	for _, tx := range transactions {
+		for _, out := range tx.Outputs {
+			if out.Type != common.OutputTypeDomainAccept {
+				continue
+			}
+			threshold, err := out.Script.Threshold()
+			if err != nil {
+				return err
+			}
+			if threshold != expected {
+				return fmt.Errorf("genesis domain threshold mismatch %d %d", threshold, expected)
+			}
+			domains = domains + 1
+		}
+	}
+	if domains == 0 {
+		return fmt.Errorf("genesis domain output not found")
+	}
+	return nil
+}
+
+// VerifyOwnGenesisParticipation confirms the node accept output of the node
+// in genesis has the ghost key derived for the node itself, so the pledge is
+// co-controlled by the signer of the node.
+func (node *Node) VerifyOwnGenesisParticipation() error {
+	snapshots, err := node.GenesisSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		if s.NodeId != node.IdForNetwork {
+			continue
+		}
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		if err != nil {
+			return err
+		}
+		out := tx.Outputs[0]
+		if out.Type != common.OutputTypeNodeAccept {
+			continue
+		}
+		r := genesisNodeAcceptMask(node.Signer)
+		if out.Mask != r.Public() {
+			return fmt.Errorf("genesis node accept mask mismatch %s", out.Mask.String())
+		}
+		key := crypto.DeriveGhostPublicKey(&r, &node.Signer.PublicViewKey, &node.Signer.PublicSpendKey, 0)
+		for _, k := range out.Keys {
+			if k == *key {
+				return nil
+			}
+		}
+		return fmt.Errorf("genesis node accept key not found %s", key.String())
+	}
+	return fmt.Errorf("not a genesis node %s", node.IdForNetwork.String())
+}
+
+// GenerateTestGenesis derives all the signer and payee keys from the seed, so
+// the same arguments always produce the same genesis, and the signer spend keys
+// are returned in the nodes order.
+func GenerateTestGenesis(nodeCount int, seed []byte, epoch int64) (*Genesis, []crypto.Key, error) {
+	if nodeCount < MinimumNodeCount {
+		return nil, nil, fmt.Errorf("invalid genesis inputs number %d/%d", nodeCount, MinimumNodeCount)
+	}
+
+	gns := &Genesis{Epoch: epoch}
+	keys := make([]crypto.Key, 0)
+	for i := 0; i < nodeCount; i++ {
+		signer := deriveTestGenesisAddress(seed, "SIGNER", i)
+		payee := deriveTestGenesisAddress(seed, "PAYEE", i)
+		gns.Nodes = append(gns.Nodes, struct {
+			Signer  common.Address `json:"signer"`
+			Payee   common.Address `json:"payee"`
+			Balance common.Integer `json:"balance"`
+		}{
+			Signer:  signer,
+			Payee:   payee,
+			Balance: common.NewInteger(PledgeAmount),
+		})
+		keys = append(keys, signer.PrivateSpendKey)
+	}
+	gns.Domains = append(gns.Domains, struct {
+		Signer  common.Address `json:"signer"`
+		Balance common.Integer `json:"balance"`
+	}{
+		Signer:  gns.Nodes[0].Signer,
+		Balance: common.NewInteger(50000),
+	})
+	return gns, keys, nil
+}
+
+func deriveTestGenesisAddress(seed []byte, role string, index int) common.Address {
+	h1 := crypto.NewHash([]byte(fmt.Sprintf("%x%s%d", seed, role, index)))
+	h2 := crypto.NewHash(h1[:])
+	account := common.NewAddressFromSeed(append(h1[:], h2[:]...))
+	account.PrivateViewKey = account.PublicSpendKey.DeterministicHashDerive()
+	account.PublicViewKey = account.PrivateViewKey.Public()
+	return account
 }
 
 func readGenesis(path string) (*Genesis, error) {
+	gns, err := parseGenesis(path)
+	if err != nil {
+		return nil, err
+	}
+	err = gns.validate(nil)
+	if err != nil {
+		return nil, err
+	}
+	return gns, nil
+}
+
+func parseGenesis(path string) (*Genesis, error) {
 	f, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -215,41 +679,92 @@ func readGenesis(path string) (*Genesis, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = checkGenesisKeys(json.NewDecoder(bytes.NewReader(f)))
+	if err != nil {
+		return nil, err
+	}
+	return &gns, nil
+}
+
+// checkGenesisKeys rejects the duplicate keys in all objects of the genesis,
+// including the keys only differ in case, because json.Unmarshal matches them
+// to the same field and silently takes the last value.
+func checkGenesisKeys(dec *json.Decoder) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := t.(json.Delim)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for dec.More() {
+		if delim == '{' {
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := t.(string)
+			for _, k := range keys {
+				if strings.EqualFold(k, key) {
+					return fmt.Errorf("invalid genesis duplicate key %s %s", k, key)
+				}
+			}
+			keys = append(keys, key)
+		}
+		err := checkGenesisKeys(dec)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func (gns *Genesis) validate(sample genesisSample) error {
 	if len(gns.Nodes) < MinimumNodeCount {
-		return nil, fmt.Errorf("invalid genesis inputs number %d/%d", len(gns.Nodes), MinimumNodeCount)
+		return fmt.Errorf("invalid genesis inputs number %d/%d", len(gns.Nodes), MinimumNodeCount)
+	}
+
+	if a := gns.AcceptAmount; a != nil && (a.Sign() <= 0 || a.Cmp(common.NewInteger(PledgeAmount)) > 0) {
+		return fmt.Errorf("invalid genesis node accept amount %s", a.String())
 	}
 
 	inputsFilter := make(map[string]bool)
-	for _, in := range gns.Nodes {
+	for i, in := range gns.Nodes {
 		_, err := common.NewAddressFromString(in.Signer.String())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if in.Balance.Cmp(common.NewInteger(PledgeAmount)) != 0 {
-			return nil, fmt.Errorf("invalid genesis node input amount %s", in.Balance.String())
+			return fmt.Errorf("invalid genesis node input amount %s", in.Balance.String())
 		}
 		if inputsFilter[in.Signer.String()] {
-			return nil, fmt.Errorf("duplicated genesis node input %s", in.Signer.String())
+			return fmt.Errorf("duplicated genesis node input %s", in.Signer.String())
+		}
+		if !sample.has(i) {
+			continue
 		}
 		privateView := in.Signer.PublicSpendKey.DeterministicHashDerive()
 		if privateView.Public() != in.Signer.PublicViewKey {
-			return nil, fmt.Errorf("invalid node key format %s %s", privateView.Public().String(), in.Signer.PublicViewKey.String())
+			return fmt.Errorf("invalid node key format %s %s", privateView.Public().String(), in.Signer.PublicViewKey.String())
 		}
 		privateView = in.Payee.PublicSpendKey.DeterministicHashDerive()
 		if privateView.Public() != in.Payee.PublicViewKey {
-			return nil, fmt.Errorf("invalid node key format %s %s", privateView.Public().String(), in.Payee.PublicViewKey.String())
+			return fmt.Errorf("invalid node key format %s %s", privateView.Public().String(), in.Payee.PublicViewKey.String())
 		}
 	}
 
 	if len(gns.Domains) != 1 {
-		return nil, fmt.Errorf("invalid genesis domain inputs count %d", len(gns.Domains))
+		return fmt.Errorf("invalid genesis domain inputs count %d", len(gns.Domains))
 	}
 	domain := gns.Domains[0]
 	if domain.Signer.String() != gns.Nodes[0].Signer.String() {
-		return nil, fmt.Errorf("invalid genesis domain input account %s %s", domain.Signer.String(), gns.Nodes[0].Signer.String())
+		return fmt.Errorf("invalid genesis domain input account %s %s", domain.Signer.String(), gns.Nodes[0].Signer.String())
 	}
 	if domain.Balance.Cmp(common.NewInteger(50000)) != 0 {
-		return nil, fmt.Errorf("invalid genesis domain input amount %s", domain.Balance.String())
+		return fmt.Errorf("invalid genesis domain input amount %s", domain.Balance.String())
 	}
-	return &gns, nil
+	return nil
 }
@@ -0,0 +1,876 @@
+package kernel
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTestGenesis(t *testing.T) {
+	assert := assert.New(t)
+
+	seed := []byte("mixin-kernel-test-genesis")
+	gns, keys, err := GenerateTestGenesis(MinimumNodeCount, seed, 1551312000)
+	assert.Nil(err)
+	assert.Len(gns.Nodes, MinimumNodeCount)
+	assert.Len(keys, MinimumNodeCount)
+	for i, in := range gns.Nodes {
+		assert.Equal(in.Signer.PublicSpendKey, keys[i].Public())
+	}
+
+	again, againKeys, err := GenerateTestGenesis(MinimumNodeCount, seed, 1551312000)
+	assert.Nil(err)
+	assert.Equal(keys, againKeys)
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	againData, err := json.Marshal(again)
+	assert.Nil(err)
+	assert.Equal(string(data), string(againData))
+
+	other, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("mixin-kernel-test-other"), 1551312000)
+	assert.Nil(err)
+	otherData, err := json.Marshal(other)
+	assert.Nil(err)
+	assert.NotEqual(string(data), string(otherData))
+
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+	loaded, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	assert.Equal(gns.Epoch, loaded.Epoch)
+	assert.Len(loaded.Nodes, MinimumNodeCount)
+
+	gns, keys, err = GenerateTestGenesis(MinimumNodeCount-1, seed, 1551312000)
+	assert.NotNil(err)
+	assert.Nil(gns)
+	assert.Nil(keys)
+}
+
+func TestGenesisDomainSnapshotOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	epoch := uint64(time.Unix(gns.Epoch, 0).UnixNano())
+
+	snapshots, err := node.store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	assert.Len(snapshots, len(signers)+1)
+	for i, s := range snapshots {
+		assert.Equal(uint64(i), s.TopologicalOrder)
+		assert.Equal(s.PayloadHash(), crypto.NewHash(s.PayloadBytes()))
+		assert.Equal(s.Hash, crypto.NewHash(s.PayloadBytes()))
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		assert.Nil(err)
+		assert.NotNil(tx)
+		if i < len(signers) {
+			assert.Equal(uint8(common.OutputTypeNodeAccept), tx.Outputs[0].Type)
+			assert.Equal(epoch, s.Timestamp)
+			continue
+		}
+		assert.Equal(uint8(common.OutputTypeDomainAccept), tx.Outputs[0].Type)
+		assert.Equal(epoch+DomainSnapshotTimestampOffset, s.Timestamp)
+		assert.Equal(signers[0].Hash().ForNetwork(node.networkId), s.NodeId)
+		for _, n := range snapshots[:i] {
+			assert.True(s.Timestamp > n.Timestamp)
+		}
+	}
+}
+
+func TestGenesisSnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 100))
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	assert.Len(snapshots, len(signers)+1)
+	for i, s := range snapshots {
+		assert.Equal(uint64(i), s.TopologicalOrder)
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		assert.Nil(err)
+		assert.True(len(tx.Inputs)*16 <= config.TransactionMaxInputs)
+		assert.True(len(tx.Outputs)*16 <= config.TransactionMaxOutputs)
+		if i < len(signers) {
+			assert.Equal(signers[i].Hash().ForNetwork(node.networkId), s.NodeId)
+			assert.Equal(uint8(common.OutputTypeNodeAccept), tx.Outputs[0].Type)
+		} else {
+			assert.Equal(uint8(common.OutputTypeDomainAccept), tx.Outputs[0].Type)
+		}
+	}
+}
+
+func TestGenesisUTXOSet(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	utxos, err := node.GenesisUTXOSet()
+	assert.Nil(err)
+	assert.Len(utxos, len(signers)+1)
+	for i, utxo := range utxos {
+		stored, err := node.store.ReadUTXOWithLock(utxo.Hash, utxo.Index)
+		assert.Nil(err)
+		assert.Equal(stored.UTXO, *utxo)
+		assert.Equal(common.XINAssetId, utxo.Asset)
+		assert.Len(utxo.Keys, len(signers))
+		assert.NotEqual(crypto.Key{}, utxo.Mask)
+		if i < len(signers) {
+			assert.Equal(uint8(common.OutputTypeNodeAccept), utxo.Type)
+			assert.Equal(common.NewInteger(PledgeAmount), utxo.Amount)
+		} else {
+			assert.Equal(uint8(common.OutputTypeDomainAccept), utxo.Type)
+			assert.Equal(common.NewInteger(50000), utxo.Amount)
+		}
+	}
+}
+
+func TestVerifyGenesisKeyUniqueness(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	err := node.VerifyGenesisKeyUniqueness()
+	assert.Nil(err)
+
+	utxos, err := node.GenesisUTXOSet()
+	assert.Nil(err)
+	assert.Nil(checkGhostKeysUnique(utxos))
+	dup := utxos[0].Keys[3]
+	utxos[len(signers)].Keys[5] = dup
+	err = checkGhostKeysUnique(utxos)
+	assert.NotNil(err)
+	assert.True(strings.HasPrefix(err.Error(), "duplicated genesis ghost key "+dup.String()))
+}
+
+func TestVerifyDomainThreshold(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	err := node.VerifyDomainThreshold()
+	assert.Nil(err)
+	utxos, err := node.GenesisUTXOSet()
+	assert.Nil(err)
+	domain := utxos[len(utxos)-1]
+	assert.Equal(uint8(common.OutputTypeDomainAccept), domain.Type)
+	threshold, err := domain.Script.Threshold()
+	assert.Nil(err)
+	assert.Equal(uint8(len(signers)*2/3+1), threshold)
+
+	node.genesis.Nodes = len(signers) + 3
+	err = node.VerifyDomainThreshold()
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("genesis domain threshold mismatch %d %d", threshold, threshold+2), err.Error())
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	transactions, err := BuildGenesisTransactions(gns, node.networkId)
+	assert.Nil(err)
+	err = verifyDomainThreshold(len(gns.Nodes), transactions)
+	assert.Nil(err)
+	err = verifyDomainThreshold(len(gns.Nodes)+3, transactions)
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("genesis domain threshold mismatch %d %d", threshold, threshold+2), err.Error())
+	err = verifyDomainThreshold(len(gns.Nodes), transactions[:len(gns.Nodes)])
+	assert.NotNil(err)
+	assert.Equal("genesis domain output not found", err.Error())
+}
+
+func TestVerifyOwnGenesisParticipation(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	err := node.VerifyOwnGenesisParticipation()
+	assert.Nil(err)
+	for _, signer := range signers[1:] {
+		node.Signer = signer
+		node.IdForNetwork = signer.Hash().ForNetwork(node.networkId)
+		err = node.VerifyOwnGenesisParticipation()
+		assert.Nil(err)
+	}
+
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	other := common.NewAddressFromSeed(seed)
+	node.Signer = other
+	node.IdForNetwork = other.Hash().ForNetwork(node.networkId)
+	err = node.VerifyOwnGenesisParticipation()
+	assert.NotNil(err)
+	assert.Equal("not a genesis node "+node.IdForNetwork.String(), err.Error())
+
+	node.Signer = other
+	node.IdForNetwork = signers[1].Hash().ForNetwork(node.networkId)
+	err = node.VerifyOwnGenesisParticipation()
+	assert.NotNil(err)
+}
+
+func TestGenesisHash(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	assert.Equal(node.networkId, gns.Hash())
+
+	assert.Equal(GenesisInfo{}, GetGenesisInfo())
+	globalNode = node
+	defer func() { globalNode = nil }()
+	info := GetGenesisInfo()
+	assert.Equal(node.networkId, info.Hash)
+	assert.Equal(len(gns.Nodes), info.Nodes)
+	assert.Equal(len(gns.Domains), info.Domains)
+	assert.Equal(gns.Epoch, info.Epoch)
+
+	data, err := ioutil.ReadFile(dir + "/genesis.json")
+	assert.Nil(err)
+	var formatted bytes.Buffer
+	err = json.Indent(&formatted, data, "", "    ")
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", formatted.Bytes(), 0644)
+	assert.Nil(err)
+	again, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	assert.Equal(node.networkId, again.Hash())
+
+	again.Epoch += 1
+	assert.NotEqual(node.networkId, again.Hash())
+}
+
+func TestGenesisDuplicateKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("mixin-kernel-test-duplicate"), 1551312000)
+	assert.Nil(err)
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+	parsed, err := parseGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	assert.Equal(gns.Hash(), parsed.Hash())
+
+	signer := fmt.Sprintf(`"signer":"%s",`, gns.Nodes[3].Signer.String())
+	for _, c := range []struct {
+		data string
+		keys string
+	}{
+		{`{"epoch":1551312000,` + string(data[1:]), "epoch epoch"},
+		{`{"Epoch":1551312000,` + string(data[1:]), "Epoch epoch"},
+		{strings.Replace(string(data), signer, signer+signer, 1), "signer signer"},
+		{strings.Replace(string(data), signer, signer+`"Payee":"`+gns.Nodes[3].Payee.String()+`",`, 1), "Payee payee"},
+	} {
+		err = ioutil.WriteFile(dir+"/genesis.json", []byte(c.data), 0644)
+		assert.Nil(err)
+		parsed, err = parseGenesis(dir + "/genesis.json")
+		assert.Nil(parsed)
+		assert.NotNil(err)
+		assert.Equal("invalid genesis duplicate key "+c.keys, err.Error())
+	}
+}
+
+func TestGenesisExpectedSnapshotCount(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	assert.Equal(len(signers)+1, gns.ExpectedSnapshotCount())
+	assert.Equal(uint64(gns.ExpectedSnapshotCount()), node.store.TopologySequence())
+
+	gns.Domains = append(gns.Domains, gns.Domains[0])
+	gns.Domains[1].Signer = signers[1]
+	assert.Equal(len(signers)+2, gns.ExpectedSnapshotCount())
+}
+
+func TestLoadGenesisLoaded(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	networkId, seq := node.networkId, node.TopoCounter.seq
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	for i := 0; i < 3; i++ {
+		err = node.LoadGenesis(dir)
+		assert.Nil(err)
+		assert.Equal(networkId, node.networkId)
+		assert.Equal(seq, node.TopoCounter.seq)
+		assert.Equal(seq, node.store.TopologySequence())
+	}
+	reloaded, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	assert.Equal(snapshots, reloaded)
+}
+
+func BenchmarkLoadGenesisLoaded(b *testing.B) {
+	node, _, dir := testSetupNode(b)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := node.LoadGenesis(dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type testFailedGenesisStore struct {
+	storage.Store
+}
+
+func (s *testFailedGenesisStore) LoadGenesis(rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.SignedTransaction) error {
+	return errors.New("genesis commit failed")
+}
+
+func TestLoadGenesisSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("mixin-kernel-test-schema"), 1551312000)
+	assert.Nil(err)
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+
+	node := &Node{
+		store:       &testFailedGenesisStore{Store: store},
+		TopoCounter: getTopologyCounter(store),
+		configDir:   dir,
+	}
+	err = node.LoadGenesis(dir)
+	assert.NotNil(err)
+	assert.Equal("genesis commit failed", err.Error())
+	var schema struct{ Version uint64 }
+	found, err := store.StateGet(stateKeySchema, &schema)
+	assert.Nil(err)
+	assert.False(found)
+
+	node.store = store
+	err = node.LoadGenesis(dir)
+	assert.Nil(err)
+	found, err = store.StateGet(stateKeySchema, &schema)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal(StoreSchemaVersion(), schema.Version)
+}
+
+func TestGenesisNetworkMismatch(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	stored := node.networkId
+	err := node.store.Close()
+	assert.Nil(err)
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("mixin-kernel-test-other"), 1551312000)
+	assert.Nil(err)
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+	computed := crypto.NewHash(data)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(node)
+	assert.NotNil(err)
+	assert.True(errors.Is(err, ErrNetworkMismatch))
+	var mismatch *NetworkMismatchError
+	assert.True(errors.As(err, &mismatch))
+	assert.Equal(stored, mismatch.Stored)
+	assert.Equal(computed, mismatch.Computed)
+	assert.Contains(err.Error(), stored.String())
+	assert.Contains(err.Error(), computed.String())
+	assert.Contains(err.Error(), "data dir belongs to a different network than genesis.json")
+}
+
+func TestGenesisOutputKeySubgroup(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("mixin-kernel-test-subgroup"), 1551312000)
+	assert.Nil(err)
+	signer := &gns.Nodes[1].Signer
+	err = signer.PublicSpendKey.UnmarshalJSON([]byte(`"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"`))
+	assert.Nil(err)
+	signer.PrivateViewKey = signer.PublicSpendKey.DeterministicHashDerive()
+	signer.PublicViewKey = signer.PrivateViewKey.Public()
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+	testWriteConfig(t, dir, gns.Nodes[0].Signer.PrivateSpendKey.String())
+	testWriteNodes(t, dir, nil)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err := SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(node)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid genesis output key subgroup "+signer.String())
+	loaded, err := store.CheckGenesisLoad()
+	assert.Nil(err)
+	assert.False(loaded)
+}
+
+func TestMainnetGenesisHashes(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("../config/genesis.json")
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node := &Node{store: store, TopoCounter: getTopologyCounter(store), configDir: dir}
+	err = node.LoadGenesis(dir)
+	assert.Nil(err)
+	assert.Equal("6430225c42bb015b4da03102fa962e4f4ef3969e03e04345db229f8377ef7997", node.networkId.String())
+
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	assert.Len(snapshots, 16)
+	first, domain := snapshots[0], snapshots[15]
+	assert.Equal("75eabab3b5e3fe0a811bc2969f32716cc58bac7260b112380be45a23fc839939", first.PayloadHash().String())
+	assert.Equal("f3a94f83f0a579d1a1b87f713d934df44e9b888216938667e7b2817aba71ef93", first.Transaction.String())
+	assert.Equal("35882901dbeae376b01cf61d7ef0d58d3f9545878c0f9649c086628f1eaf9ab7", domain.PayloadHash().String())
+	assert.Equal("4e24675df8a9d1592c82d6fa9ef86881fb2dfafe2a06b2a51134daf5a98f8411", domain.Transaction.String())
+	for _, s := range snapshots {
+		assert.Equal(s.Hash, s.PayloadHash())
+	}
+}
+
+func TestDeriveGenesisKeysParallel(t *testing.T) {
+	assert := assert.New(t)
+
+	gns, _, err := GenerateTestGenesis(100, []byte("mixin-kernel-test-parallel"), 1551312000)
+	assert.Nil(err)
+	serial, checked, err := deriveGenesisKeys(context.Background(), gns, 1, nil)
+	assert.Nil(err)
+	assert.Len(serial, 100)
+	assert.Equal(100*100, checked)
+	parallel, checked, err := deriveGenesisKeys(context.Background(), gns, 8, nil)
+	assert.Nil(err)
+	assert.Equal(serial, parallel)
+	assert.Equal(100*100, checked)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = deriveGenesisKeys(ctx, gns, 1, nil)
+	assert.Equal(context.Canceled, err)
+	_, _, err = deriveGenesisKeys(ctx, gns, 8, nil)
+	assert.Equal(context.Canceled, err)
+
+	for _, i := range []int{80, 37} {
+		signer := &gns.Nodes[i].Signer
+		err = signer.PublicSpendKey.UnmarshalJSON([]byte(`"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"`))
+		assert.Nil(err)
+	}
+	_, _, serialErr := deriveGenesisKeys(context.Background(), gns, 1, nil)
+	assert.NotNil(serialErr)
+	_, _, parallelErr := deriveGenesisKeys(context.Background(), gns, 8, nil)
+	assert.Equal(serialErr, parallelErr)
+	assert.Contains(serialErr.Error(), gns.Nodes[37].Signer.String())
+}
+
+func TestLoadGenesisParallel(t *testing.T) {
+	assert := assert.New(t)
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("mixin-kernel-test-parallel"), 1551312000)
+	assert.Nil(err)
+	var loaded [][]*common.SnapshotWithTopologicalOrder
+	for _, parallel := range []bool{false, true} {
+		node, dir := testLoadGenesis(t, gns, &config.Custom{GenesisParallel: parallel})
+		defer os.RemoveAll(dir)
+		defer node.store.Close()
+		snapshots, err := node.GenesisSnapshots()
+		assert.Nil(err)
+		loaded = append(loaded, snapshots)
+	}
+	assert.Equal(loaded[0], loaded[1])
+}
+
+func TestLoadGenesisLight(t *testing.T) {
+	assert := assert.New(t)
+
+	gns, _, err := GenerateTestGenesis(60, []byte("mixin-kernel-test-light"), 1551312000)
+	assert.Nil(err)
+	var loaded [][]*common.SnapshotWithTopologicalOrder
+	for _, light := range []bool{false, true} {
+		node, dir := testLoadGenesis(t, gns, &config.Custom{GenesisLight: light, GenesisLightSample: 3})
+		defer os.RemoveAll(dir)
+		defer node.store.Close()
+		snapshots, err := node.GenesisSnapshots()
+		assert.Nil(err)
+		loaded = append(loaded, snapshots)
+	}
+	assert.Equal(loaded[0], loaded[1])
+
+	for _, sample := range []genesisSample{nil, {0: true, 7: true, 42: true}} {
+		keys, checked, err := deriveGenesisKeys(context.Background(), gns, 4, sample)
+		assert.Nil(err)
+		assert.Len(keys, 60)
+		if sample == nil {
+			assert.Equal(60*60, checked)
+		} else {
+			assert.Equal(len(sample)*60, checked)
+		}
+		transactions, err := buildGenesisTransactions(gns, gns.Hash(), 4, sample)
+		assert.Nil(err)
+		assert.Equal(keys[5], transactions[5].Outputs[0].Keys)
+		assert.Equal(loaded[0][5].Transaction, transactions[5].PayloadHash())
+	}
+
+	node := &Node{custom: &config.Custom{GenesisLight: true, GenesisLightSample: 3}}
+	sample := node.genesisSample(60)
+	assert.True(sample.has(0))
+	assert.True(len(sample) >= 3 && len(sample) <= 4)
+	assert.Len(node.genesisSample(2), 2)
+	node.custom.GenesisLight = false
+	assert.Nil(node.genesisSample(60))
+	assert.True(node.genesisSample(60).has(59))
+
+	signer := &gns.Nodes[30].Signer
+	err = signer.PublicSpendKey.UnmarshalJSON([]byte(`"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"`))
+	assert.Nil(err)
+	signer.PrivateViewKey = signer.PublicSpendKey.DeterministicHashDerive()
+	signer.PublicViewKey = signer.PrivateViewKey.Public()
+	_, err = buildGenesisTransactions(gns, gns.Hash(), 1, genesisSample{})
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid genesis domain key subgroup "+signer.String())
+}
+
+func TestGenesisLightNetworkMismatch(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	stored := node.networkId
+	err := node.store.Close()
+	assert.Nil(err)
+
+	data := fmt.Sprintf(`{"signer":"%s","genesis-light-verification":true}`, signers[0].PrivateSpendKey.String())
+	err = ioutil.WriteFile(dir+"/config.json", []byte(data), 0644)
+	assert.Nil(err)
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	gns.Epoch = gns.Epoch + 1
+	genesis, err := json.Marshal(gns)
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", genesis, 0644)
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(node)
+	assert.NotNil(err)
+	assert.True(errors.Is(err, ErrNetworkMismatch))
+	var mismatch *NetworkMismatchError
+	assert.True(errors.As(err, &mismatch))
+	assert.Equal(stored, mismatch.Stored)
+	assert.Equal(gns.Hash(), mismatch.Computed)
+}
+
+func BenchmarkLoadGenesis(b *testing.B) {
+	gns, _, err := GenerateTestGenesis(100, []byte("mixin-kernel-test-parallel"), 1551312000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, parallel := range []bool{false, true} {
+		name := "serial"
+		if parallel {
+			name = "parallel"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				node, dir := testLoadGenesis(b, gns, &config.Custom{GenesisParallel: parallel})
+				node.store.Close()
+				os.RemoveAll(dir)
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// testLoadGenesis times only the LoadGenesis of a fresh store when it's run
+// with the timer stopped by a benchmark.
+func testLoadGenesis(tb testing.TB, gns *Genesis, custom *config.Custom) (*Node, string) {
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	data, err := json.Marshal(gns)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	store, err := storage.NewBadgerStore(dir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	node := &Node{
+		store:       store,
+		TopoCounter: getTopologyCounter(store),
+		configDir:   dir,
+		custom:      custom,
+	}
+	if b, ok := tb.(*testing.B); ok {
+		b.StartTimer()
+		defer b.StopTimer()
+	}
+	err = node.LoadGenesis(dir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return node, dir
+}
+
+func TestGenesisAcceptAmount(t *testing.T) {
+	assert := assert.New(t)
+	defer common.SetNodeAcceptAmount("10000")
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("accept"), time.Now().Unix())
+	assert.Nil(err)
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	assert.NotContains(string(data), "accept_amount")
+	network := gns.Hash()
+
+	amount := common.NewInteger(PledgeAmount * 2)
+	gns.AcceptAmount = &amount
+	_, err = BuildGenesisTransactions(gns, gns.Hash())
+	assert.NotNil(err)
+	assert.Equal("invalid genesis node accept amount "+amount.String(), err.Error())
+
+	amount = common.NewInteger(6000)
+	gns.AcceptAmount = &amount
+	assert.NotEqual(network, gns.Hash())
+	node, dir := testLoadGenesis(t, gns, &config.Custom{})
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+	assert.Equal(gns.Hash(), node.networkId)
+	assert.Equal(amount, common.NodeAcceptAmount())
+
+	utxos, err := node.GenesisUTXOSet()
+	assert.Nil(err)
+	assert.Len(utxos, len(gns.Nodes)+1)
+	for i, in := range gns.Nodes {
+		assert.Equal(uint8(common.OutputTypeNodeAccept), utxos[i].Type)
+		assert.Equal(amount, utxos[i].Amount)
+		assert.Equal(common.NewInteger(PledgeAmount), in.Balance)
+	}
+	claims, err := ScanGenesisOutputs(context.Background(), gns, gns.Nodes[0].Signer.PrivateViewKey)
+	assert.Nil(err)
+	assert.Equal(amount, claims[0].Amount)
+}
+
+func TestGenesisMinimumFee(t *testing.T) {
+	assert := assert.New(t)
+	defer common.SetMinimumFee("0")
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("fee"), time.Now().Unix())
+	assert.Nil(err)
+	network := gns.Hash()
+	fee := common.NewIntegerFromString("0.5")
+	gns.MinimumFee = &fee
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	assert.Contains(string(data), "minimum_fee")
+	assert.NotEqual(network, gns.Hash())
+
+	node, dir := testLoadGenesis(t, gns, &config.Custom{})
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+	assert.Equal(gns.Hash(), node.networkId)
+	assert.Equal(0, common.MinimumFee().Cmp(fee))
+}
+
+func TestScanGenesisOutputs(t *testing.T) {
+	assert := assert.New(t)
+
+	gns, _, err := GenerateTestGenesis(7, []byte("claim"), time.Now().Unix())
+	assert.Nil(err)
+
+	claims, err := ScanGenesisOutputs(context.Background(), gns, gns.Nodes[0].Signer.PrivateViewKey)
+	assert.Nil(err)
+	assert.Len(claims, len(gns.Nodes)+len(gns.Domains))
+	for i, in := range gns.Nodes {
+		c := claims[i]
+		assert.Equal(uint8(common.OutputTypeNodeAccept), c.Type)
+		assert.Equal(in.Signer.String(), c.Signer.String())
+		assert.Equal(common.NewInteger(PledgeAmount), c.Amount)
+		assert.Equal(0, c.Index)
+	}
+	domain := claims[len(gns.Nodes)]
+	assert.Equal(uint8(common.OutputTypeDomainAccept), domain.Type)
+	assert.Equal(gns.Domains[0].Signer.String(), domain.Signer.String())
+	assert.Equal(common.NewInteger(50000), domain.Amount)
+	assert.Equal(0, domain.Index)
+
+	mask := genesisDomainAcceptMask(domain.Signer).Public()
+	priv := crypto.DeriveGhostPrivateKey(&mask, &gns.Nodes[0].Signer.PrivateViewKey, &gns.Nodes[0].Signer.PrivateSpendKey, 0)
+	assert.Equal(domain.Key, priv.Public())
+
+	claims, err = ScanGenesisOutputs(context.Background(), gns, gns.Nodes[3].Signer.PrivateViewKey)
+	assert.Nil(err)
+	assert.Len(claims, len(gns.Nodes)+len(gns.Domains))
+	for _, c := range claims {
+		assert.Equal(3, c.Index)
+	}
+
+	seed := crypto.NewHash([]byte("claim-stranger"))
+	stranger := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+	claims, err = ScanGenesisOutputs(context.Background(), gns, stranger)
+	assert.Nil(err)
+	assert.Len(claims, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	claims, err = ScanGenesisOutputs(ctx, gns, gns.Nodes[0].Signer.PrivateViewKey)
+	assert.Equal(context.Canceled, err)
+	assert.Nil(claims)
+}
+
+func TestBuildGenesisRounds(t *testing.T) {
+	assert := assert.New(t)
+
+	var nodeIds []crypto.Hash
+	cacheRounds := make(map[crypto.Hash]*CacheRound)
+	for i := 0; i < 7; i++ {
+		id := crypto.NewHash([]byte(fmt.Sprintf("genesis-round-%d", i)))
+		nodeIds = append(nodeIds, id)
+		cacheRounds[id] = &CacheRound{
+			NodeId: id,
+			Snapshots: []*common.Snapshot{{
+				NodeId:      id,
+				Transaction: crypto.NewHash(id[:]),
+				Timestamp:   uint64(i + 1),
+			}},
+		}
+	}
+
+	rounds := buildGenesisRounds(nodeIds, cacheRounds)
+	assert.Len(rounds, len(nodeIds)*2)
+	for i, id := range nodeIds {
+		final, head := rounds[i*2], rounds[i*2+1]
+		assert.Equal(id, final.NodeId)
+		assert.Equal(uint64(0), final.Number)
+		assert.Equal(cacheRounds[id].asFinal().Hash, final.Hash)
+		assert.Nil(final.References)
+
+		next := nodeIds[0]
+		if i < len(nodeIds)-1 {
+			next = nodeIds[i+1]
+		}
+		assert.Equal(id, head.Hash)
+		assert.Equal(id, head.NodeId)
+		assert.Equal(uint64(1), head.Number)
+		assert.Equal(final.Hash, head.References.Self)
+		assert.Equal(cacheRounds[next].asFinal().Hash, head.References.External)
+	}
+	last := rounds[len(rounds)-1]
+	assert.Equal(rounds[0].Hash, last.References.External)
+}
+
+func TestBuildGenesisTransactions(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	transactions, err := BuildGenesisTransactions(gns, node.networkId)
+	assert.Nil(err)
+	assert.Len(transactions, gns.ExpectedSnapshotCount())
+
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	assert.Len(snapshots, len(transactions))
+	for i, tx := range transactions {
+		assert.Equal(snapshots[i].Transaction, tx.PayloadHash())
+		stored, err := node.store.ReadTransaction(tx.PayloadHash())
+		assert.Nil(err)
+		assert.NotNil(stored)
+		assert.True(tx.FeeExempt())
+	}
+	last := transactions[len(transactions)-1]
+	assert.Equal(uint8(common.OutputTypeDomainAccept), last.Outputs[0].Type)
+
+	other, err := BuildGenesisTransactions(gns, crypto.NewHash([]byte("other")))
+	assert.Nil(err)
+	assert.NotEqual(transactions[0].PayloadHash(), other[0].PayloadHash())
+}
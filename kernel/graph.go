@@ -103,7 +103,9 @@ func (node *Node) CacheVerify(snap crypto.Hash, sig crypto.Signature, pub crypto
 }
 
 func (node *Node) queueSnapshotOrPanic(s *common.Snapshot, finalized bool) error {
-	err := node.store.QueueAppendSnapshot(node.IdForNetwork, s, finalized)
+	err := node.writeStoreOrDegrade(func() error {
+		return node.store.QueueAppendSnapshot(node.IdForNetwork, s, finalized)
+	})
 	if err != nil {
 		panic(err)
 	}
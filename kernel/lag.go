@@ -0,0 +1,78 @@
+package kernel
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	PeerStatusTimeout    = 60 * time.Second
+	MajorityLagTolerance = 16
+)
+
+type peerStatus struct {
+	topology  uint64
+	timestamp time.Time
+}
+
+type peerStatusMap struct {
+	sync.RWMutex
+	m map[crypto.Hash]peerStatus
+}
+
+func (s *peerStatusMap) Set(k crypto.Hash, topology uint64, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	s.m[k] = peerStatus{topology: topology, timestamp: now}
+}
+
+func (s *peerStatusMap) Tips(since time.Time) []uint64 {
+	s.RLock()
+	defer s.RUnlock()
+	tips := make([]uint64, 0, len(s.m))
+	for _, p := range s.m {
+		if p.timestamp.Before(since) {
+			continue
+		}
+		tips = append(tips, p.topology)
+	}
+	return tips
+}
+
+func (node *Node) TopologySequence() uint64 {
+	node.TopoCounter.Lock()
+	defer node.TopoCounter.Unlock()
+	return node.TopoCounter.seq
+}
+
+// UpdatePeerStatus records the topological tip beacon of a consensus peer.
+func (node *Node) UpdatePeerStatus(peerId crypto.Hash, topology uint64) {
+	if node.ConsensusNodes[peerId] == nil {
+		return
+	}
+	node.PeerStatus.Set(peerId, topology, node.clock())
+}
+
+// LagBehindMajority reports how many topological orders this node is behind
+// the median tip of the consensus peers which sent a recent status beacon.
+func (node *Node) LagBehindMajority() (uint64, error) {
+	tips := node.PeerStatus.Tips(node.clock().Add(-PeerStatusTimeout))
+	if len(tips) == 0 {
+		return 0, errors.New("no peer status beacons")
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i] < tips[j] })
+	median := tips[len(tips)/2]
+	if len(tips)%2 == 0 {
+		a, b := tips[len(tips)/2-1], median
+		median = a + (b-a)/2
+	}
+	local := node.TopologySequence()
+	if median <= local {
+		return 0, nil
+	}
+	return median - local, nil
+}
@@ -0,0 +1,86 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLagBehindMajority(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	now := time.Now()
+	node.clock = func() time.Time { return now }
+	local := node.TopologySequence()
+
+	lag, err := node.LagBehindMajority()
+	assert.NotNil(err)
+	assert.Equal(uint64(0), lag)
+
+	var peers []crypto.Hash
+	for id := range node.ConsensusNodes {
+		if id != node.IdForNetwork {
+			peers = append(peers, id)
+		}
+	}
+	assert.True(len(peers) >= 3)
+
+	node.UpdatePeerStatus(crypto.NewHash([]byte("unknown")), local+1000)
+	lag, err = node.LagBehindMajority()
+	assert.NotNil(err)
+
+	node.UpdatePeerStatus(peers[0], local+10)
+	lag, err = node.LagBehindMajority()
+	assert.Nil(err)
+	assert.Equal(uint64(10), lag)
+
+	node.UpdatePeerStatus(peers[1], local+1000)
+	node.UpdatePeerStatus(peers[2], local+20)
+	lag, err = node.LagBehindMajority()
+	assert.Nil(err)
+	assert.Equal(uint64(20), lag)
+
+	node.UpdatePeerStatus(peers[0], local)
+	lag, err = node.LagBehindMajority()
+	assert.Nil(err)
+	assert.Equal(uint64(20), lag)
+	node.UpdatePeerStatus(peers[2], local)
+	lag, err = node.LagBehindMajority()
+	assert.Nil(err)
+	assert.Equal(uint64(0), lag)
+
+	for _, id := range peers[:3] {
+		node.UpdatePeerStatus(id, local+30)
+	}
+	if len(peers) > 3 {
+		node.UpdatePeerStatus(peers[3], local+50)
+	}
+	lag, err = node.LagBehindMajority()
+	assert.Nil(err)
+	assert.Equal(uint64(30), lag)
+
+	globalNode = node
+	defer func() { globalNode = nil }()
+	lag, synced := MajorityLag()
+	assert.Equal(uint64(30), lag)
+	assert.False(synced)
+	for _, id := range peers {
+		node.UpdatePeerStatus(id, local+MajorityLagTolerance)
+	}
+	lag, synced = MajorityLag()
+	assert.Equal(uint64(MajorityLagTolerance), lag)
+	assert.True(synced)
+
+	now = now.Add(PeerStatusTimeout + time.Second)
+	lag, err = node.LagBehindMajority()
+	assert.NotNil(err)
+	lag, synced = MajorityLag()
+	assert.False(synced)
+}
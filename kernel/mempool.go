@@ -0,0 +1,46 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+const (
+	MempoolSweepInterval = time.Minute
+)
+
+// EvictMempool drops the pending transactions older than the max age, e.g.
+// the double spends lost to others, then the oldest ones over the max size.
+// The numbers dropped by each reason are counted in the metrics.
+func (node *Node) EvictMempool() (int, int, error) {
+	maxAge := time.Duration(node.custom.MempoolMaxAge) * time.Second
+	aged, sized, err := node.store.CacheEvictTransactions(node.clock().Add(-maxAge), node.custom.MempoolMaxSize)
+	if err != nil {
+		return aged, sized, err
+	}
+	if aged > 0 {
+		metrics.Counter("mixin_mempool_evicted_age_total", uint64(aged))
+	}
+	if sized > 0 {
+		metrics.Counter("mixin_mempool_evicted_size_total", uint64(sized))
+	}
+	if aged > 0 || sized > 0 {
+		logger.Printf("MEMPOOL EVICTED %d by age and %d by size\n", aged, sized)
+	}
+	return aged, sized, nil
+}
+
+func (node *Node) LoopEvictMempool() error {
+	ticker := time.NewTicker(MempoolSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, _, err := node.EvictMempool()
+		if err != nil {
+			logger.Println("MEMPOOL evict error", err)
+		}
+	}
+	return nil
+}
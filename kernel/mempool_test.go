@@ -0,0 +1,76 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictMempool(t *testing.T) {
+	assert := assert.New(t)
+
+	backend := &testMetricsBackend{
+		counters:   make(map[string]uint64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]int),
+	}
+	metrics.Use(backend)
+	defer metrics.Use(metrics.NewPrometheus())
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	now := time.Now()
+	node.clock = func() time.Time { return now }
+	stale := testMintTransaction(common.XINAssetId, 1)
+	err := node.store.CachePutTransaction(stale)
+	assert.Nil(err)
+	aged, sized, err := node.EvictMempool()
+	assert.Nil(err)
+	assert.Equal(0, aged)
+	assert.Equal(0, sized)
+	tx, err := node.store.CacheGetTransaction(stale.PayloadHash())
+	assert.Nil(err)
+	assert.NotNil(tx)
+
+	now = now.Add(time.Duration(node.custom.MempoolMaxAge)*time.Second + time.Minute)
+	aged, sized, err = node.EvictMempool()
+	assert.Nil(err)
+	assert.Equal(1, aged)
+	assert.Equal(0, sized)
+	tx, err = node.store.CacheGetTransaction(stale.PayloadHash())
+	assert.Nil(err)
+	assert.Nil(tx)
+
+	now = time.Now()
+	node.custom.MempoolMaxSize = 2
+	for i := 0; i < 3; i++ {
+		err = node.store.CachePutTransaction(testMintTransaction(common.XINAssetId, uint64(i+2)))
+		assert.Nil(err)
+	}
+	final := testMintTransaction(common.XINAssetId, 100)
+	err = node.store.CachePutTransaction(final)
+	assert.Nil(err)
+	testWriteSnapshot(t, node, final)
+	aged, sized, err = node.EvictMempool()
+	assert.Nil(err)
+	assert.Equal(0, aged)
+	assert.Equal(1, sized)
+	var pending int
+	err = node.store.CacheListTransactions(func(tx *common.SignedTransaction) error {
+		pending++
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, pending)
+
+	backend.Lock()
+	defer backend.Unlock()
+	assert.Equal(uint64(1), backend.counters["mixin_mempool_evicted_age_total"])
+	assert.Equal(uint64(1), backend.counters["mixin_mempool_evicted_size_total"])
+}
@@ -0,0 +1,58 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const merkleReadBatch = 1000
+
+// SnapshotMerkleRoot is the merkle root of the snapshot hashes in topological
+// order from inclusive to exclusive. Each pair of hashes is hashed together
+// level by level, and the odd hash of a level is carried to the next level as
+// is, so two nodes with the same snapshots in the range get the same root.
+func (node *Node) SnapshotMerkleRoot(from, to uint64) (crypto.Hash, error) {
+	seq := node.store.TopologySequence()
+	if from >= to || to > seq {
+		return crypto.Hash{}, fmt.Errorf("invalid merkle range %d %d %d", from, to, seq)
+	}
+
+	leaves := make([]crypto.Hash, 0, to-from)
+	for offset := from; offset < to; {
+		count := to - offset
+		if count > merkleReadBatch {
+			count = merkleReadBatch
+		}
+		snapshots, err := node.store.ReadSnapshotsSinceTopology(offset, count)
+		if err != nil {
+			return crypto.Hash{}, err
+		}
+		for _, s := range snapshots {
+			if s.TopologicalOrder != offset {
+				return crypto.Hash{}, fmt.Errorf("snapshot not found at topology %d", offset)
+			}
+			leaves = append(leaves, s.Hash)
+			offset = offset + 1
+		}
+		if len(snapshots) == 0 {
+			return crypto.Hash{}, fmt.Errorf("snapshot not found at topology %d", offset)
+		}
+	}
+	return merkleRoot(leaves), nil
+}
+
+func merkleRoot(level []crypto.Hash) crypto.Hash {
+	for len(level) > 1 {
+		next := make([]crypto.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, crypto.NewHash(append(level[i][:], level[i+1][:]...)))
+		}
+		level = next
+	}
+	return level[0]
+}
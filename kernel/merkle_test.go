@@ -0,0 +1,99 @@
+package kernel
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack"
+)
+
+func TestSnapshotMerkleRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, []byte("merkle"), time.Now().Unix())
+	assert.Nil(err)
+	a, adir := testLoadGenesis(t, gns, nil)
+	defer os.RemoveAll(adir)
+	defer a.store.Close()
+	b, bdir := testLoadGenesis(t, gns, nil)
+	defer os.RemoveAll(bdir)
+
+	seq := a.store.TopologySequence()
+	assert.Equal(seq, b.store.TopologySequence())
+	root, err := a.SnapshotMerkleRoot(0, seq)
+	assert.Nil(err)
+	assert.True(root.HasValue())
+	other, err := b.SnapshotMerkleRoot(0, seq)
+	assert.Nil(err)
+	assert.Equal(root, other)
+	part, err := a.SnapshotMerkleRoot(1, seq)
+	assert.Nil(err)
+	assert.NotEqual(root, part)
+
+	snapshots, err := a.store.ReadSnapshotsSinceTopology(0, seq)
+	assert.Nil(err)
+	single, err := a.SnapshotMerkleRoot(2, 3)
+	assert.Nil(err)
+	assert.Equal(snapshots[2].Hash, single)
+	pair, err := a.SnapshotMerkleRoot(2, 4)
+	assert.Nil(err)
+	assert.Equal(crypto.NewHash(append(snapshots[2].Hash[:], snapshots[3].Hash[:]...)), pair)
+
+	_, err = a.SnapshotMerkleRoot(3, 3)
+	assert.NotNil(err)
+	_, err = a.SnapshotMerkleRoot(0, seq+1)
+	assert.NotNil(err)
+
+	err = b.store.Close()
+	assert.Nil(err)
+	opts := badger.DefaultOptions
+	opts.Dir = bdir + "/snapshots"
+	opts.ValueDir = bdir + "/snapshots"
+	db, err := badger.Open(opts)
+	assert.Nil(err)
+	err = db.Update(func(txn *badger.Txn) error {
+		order := make([]byte, 8)
+		binary.BigEndian.PutUint64(order, seq/2)
+		item, err := txn.Get(append([]byte("TOPOLOGY"), order...))
+		if err != nil {
+			return err
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		item, err = txn.Get(key)
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var snap common.SnapshotWithTopologicalOrder
+		err = msgpack.Unmarshal(val, &snap)
+		if err != nil {
+			return err
+		}
+		snap.Timestamp = snap.Timestamp + 1
+		return txn.Set(key, common.MsgpackMarshalPanic(snap))
+	})
+	assert.Nil(err)
+	err = db.Close()
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(bdir)
+	assert.Nil(err)
+	defer store.Close()
+	b = &Node{store: store}
+	tampered, err := b.SnapshotMerkleRoot(0, seq)
+	assert.Nil(err)
+	assert.NotEqual(root, tampered)
+}
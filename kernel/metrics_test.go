@@ -0,0 +1,74 @@
+package kernel
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetricsBackend struct {
+	sync.Mutex
+	counters   map[string]uint64
+	gauges     map[string]float64
+	histograms map[string]int
+}
+
+func (b *testMetricsBackend) Counter(name string, delta uint64) {
+	b.Lock()
+	defer b.Unlock()
+	b.counters[name] = b.counters[name] + delta
+}
+
+func (b *testMetricsBackend) Gauge(name string, value float64) {
+	b.Lock()
+	defer b.Unlock()
+	b.gauges[name] = value
+}
+
+func (b *testMetricsBackend) Histogram(name string, value float64) {
+	b.Lock()
+	defer b.Unlock()
+	b.histograms[name] = b.histograms[name] + 1
+}
+
+func TestMetricsBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	backend := &testMetricsBackend{
+		counters:   make(map[string]uint64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]int),
+	}
+	metrics.Use(backend)
+	defer metrics.Use(metrics.NewPrometheus())
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	seq := node.store.TopologySequence()
+	backend.Lock()
+	assert.Equal(uint64(seq), backend.counters["mixin_store_genesis_snapshots_total"])
+	assert.Equal(float64(seq), backend.gauges["mixin_store_topology"])
+	assert.Equal(1, backend.histograms["mixin_genesis_load_seconds"])
+	assert.Equal(uint64(0), backend.counters["mixin_store_snapshots_total"])
+	backend.Unlock()
+
+	tx := testMintTransaction(common.XINAssetId, 100)
+	testWriteSnapshot(t, node, tx)
+	backend.Lock()
+	assert.Equal(uint64(1), backend.counters["mixin_store_snapshots_total"])
+	assert.Equal(float64(seq+1), backend.gauges["mixin_store_topology"])
+	assert.Equal(1, backend.histograms["mixin_store_snapshot_commit_seconds"])
+	backend.Unlock()
+
+	_, err := QueueTransaction(node.store, &common.SignedTransaction{})
+	assert.NotNil(err)
+	backend.Lock()
+	assert.Equal(uint64(1), backend.counters["mixin_transactions_rejected_total"])
+	backend.Unlock()
+}
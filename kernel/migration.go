@@ -0,0 +1,61 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const stateKeySchema = "schema"
+
+type storeMigration struct {
+	Name    string
+	Migrate func(ctx context.Context, store storage.Store) error
+}
+
+// The store schema version is the count of migrations applied, so a new
+// migration is always appended, and never removed or reordered.
+var storeMigrations = []*storeMigration{
+	{Name: "asset index", Migrate: func(ctx context.Context, store storage.Store) error { return store.IndexAssets(ctx) }},
+	{Name: "ghost key index", Migrate: func(ctx context.Context, store storage.Store) error { return store.IndexGhostKeys(ctx) }},
+	{Name: "transaction topology index", Migrate: func(ctx context.Context, store storage.Store) error {
+		return store.IndexTransactionTopology(ctx)
+	}},
+}
+
+func StoreSchemaVersion() uint64 {
+	return uint64(len(storeMigrations))
+}
+
+// A store without the schema version is older than the migrations, and all
+// migrations are applied to it, while a new store is initialized with the
+// latest version by LoadGenesis.
+func (node *Node) migrateStore(ctx context.Context, migrations []*storeMigration) error {
+	var state struct {
+		Version uint64
+	}
+	_, err := node.store.StateGet(stateKeySchema, &state)
+	if err != nil {
+		return err
+	}
+	latest := uint64(len(migrations))
+	if state.Version > latest {
+		return fmt.Errorf("store schema version %d newer than the binary %d", state.Version, latest)
+	}
+	for state.Version < latest {
+		m := migrations[state.Version]
+		logger.Printf("MIGRATE STORE SCHEMA %d %s\n", state.Version+1, m.Name)
+		err := m.Migrate(ctx, node.store)
+		if err != nil {
+			return fmt.Errorf("store migration %d %s error %s", state.Version+1, m.Name, err.Error())
+		}
+		state.Version += 1
+		err = node.store.StateSet(stateKeySchema, state)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package kernel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+
+	var schema struct{ Version uint64 }
+	found, err := node.store.StateGet(stateKeySchema, &schema)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal(StoreSchemaVersion(), schema.Version)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = node.store.StateSet(stateKeySchema, struct{ Version uint64 }{0})
+	assert.Nil(err)
+	err = node.migrateStore(ctx, storeMigrations)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), context.Canceled.Error())
+	found, err = node.store.StateGet(stateKeySchema, &schema)
+	assert.Nil(err)
+	assert.Equal(uint64(0), schema.Version)
+	err = node.migrateStore(context.Background(), storeMigrations)
+	assert.Nil(err)
+
+	var applied []string
+	migrations := append([]*storeMigration{}, storeMigrations...)
+	for _, name := range []string{"first", "second"} {
+		name := name
+		migrations = append(migrations, &storeMigration{
+			Name: name,
+			Migrate: func(ctx context.Context, store storage.Store) error {
+				applied = append(applied, name)
+				return store.StateSet("migration-"+name, true)
+			},
+		})
+	}
+	migrations = append(migrations, &storeMigration{
+		Name: "broken",
+		Migrate: func(ctx context.Context, store storage.Store) error {
+			return errors.New("broken")
+		},
+	})
+
+	err = node.migrateStore(context.Background(), migrations[:len(migrations)-1])
+	assert.Nil(err)
+	assert.Equal([]string{"first", "second"}, applied)
+	var done bool
+	found, err = node.store.StateGet("migration-second", &done)
+	assert.Nil(err)
+	assert.True(found && done)
+	found, err = node.store.StateGet(stateKeySchema, &schema)
+	assert.Nil(err)
+	assert.Equal(StoreSchemaVersion()+2, schema.Version)
+
+	err = node.migrateStore(context.Background(), migrations[:len(migrations)-1])
+	assert.Nil(err)
+	assert.Len(applied, 2)
+
+	err = node.migrateStore(context.Background(), migrations)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "broken")
+	found, err = node.store.StateGet(stateKeySchema, &schema)
+	assert.Nil(err)
+	assert.Equal(StoreSchemaVersion()+2, schema.Version)
+
+	err = node.migrateStore(context.Background(), storeMigrations)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "newer than the binary")
+	err = node.store.Close()
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(node)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "newer than the binary")
+}
@@ -1,9 +1,11 @@
 package kernel
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"sync"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
 	"github.com/MixinNetwork/mixin/network"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/patrickmn/go-cache"
@@ -30,29 +33,84 @@ type Node struct {
 	SnapshotsPool   map[crypto.Hash][]*crypto.Signature
 	SignaturesPool  map[crypto.Hash]*crypto.Signature
 	signaturesCache *cache.Cache
+	authFailures    *cache.Cache
 	Peer            *network.Peer
 	SyncPoints      *syncMap
-
-	networkId   crypto.Hash
-	store       storage.Store
-	mempoolChan chan *common.Snapshot
-	configDir   string
+	PeerStatus      *peerStatusMap
+
+	degraded      int32
+	lastFinalized int64
+	clock         func() time.Time
+	epoch         time.Time
+	genesis       GenesisInfo
+	networkId     crypto.Hash
+	store         storage.Store
+	custom        *config.Custom
+	verifier      *SnapshotVerifier
+	buffer        *SnapshotBuffer
+	roundGaps     *roundGapQueue
+	mempoolChan   chan *common.Snapshot
+	references    *referenceFeed
+	configDir     string
+	shutdown      *sync.Once
+	done          chan struct{}
 }
 
 func SetupNode(store storage.Store, addr string, dir string) (*Node, error) {
+	custom, err := config.Initialize(dir + "/config.json")
+	if err != nil {
+		return nil, err
+	}
+	var truncated []uint64
+	if custom.RecoverTopology {
+		truncated, err = recoverTopology(store)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var node = &Node{
 		ConsensusNodes:  make(map[crypto.Hash]*common.Node),
 		SnapshotsPool:   make(map[crypto.Hash][]*crypto.Signature),
 		SignaturesPool:  make(map[crypto.Hash]*crypto.Signature),
 		SyncPoints:      &syncMap{mutex: new(sync.RWMutex), m: make(map[crypto.Hash]*network.SyncPoint)},
+		PeerStatus:      &peerStatusMap{m: make(map[crypto.Hash]peerStatus)},
 		store:           store,
 		mempoolChan:     make(chan *common.Snapshot, MempoolSize),
+		references:      newReferenceFeed(),
 		configDir:       dir,
 		TopoCounter:     getTopologyCounter(store),
 		signaturesCache: cache.New(config.CacheTTL, 10*time.Minute),
+		authFailures:    cache.New(config.CacheTTL, 10*time.Minute),
+		clock:           time.Now,
+		shutdown:        new(sync.Once),
+		done:            make(chan struct{}),
 	}
 
-	err := node.LoadNodeState()
+	node.custom = custom
+	node.roundGaps = newRoundGapQueue(uint64(custom.MaxRoundGap), RoundGapDeferredLimit)
+	err = useMetricsBackend(custom)
+	if err != nil {
+		return nil, err
+	}
+	store.ResizeTransactionCache(custom.TransactionCache)
+	common.EnableVerificationCache(custom.VerifyCache)
+	rejections.enable(custom.LogRejections)
+	store.EnableQuarantine(custom.Quarantine)
+	store.SetGenesisWriters(custom.GenesisWriters)
+	if custom.MmapScan && !store.EnableMmapScan(true) {
+		logger.Println("memory mapped scan not viable, fallback to normal reads")
+	}
+	if custom.VerifyChecksum {
+		err = store.VerifyChecksum(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("data directory checksum error %s, re-sync or restore from backup", err.Error())
+		}
+	}
+	node.buffer = newSnapshotBuffer(SnapshotBufferSize, node.commitVerifiedSnapshotOrDegrade)
+	node.verifier = newSnapshotVerifier(custom.SnapshotVerifiers, node.verifySnapshotSignatures, node.buffer.Put)
+
+	err = node.LoadNodeState()
 	if err != nil {
 		return nil, err
 	}
@@ -62,11 +120,40 @@ func SetupNode(store storage.Store, addr string, dir string) (*Node, error) {
 		return nil, err
 	}
 
+	if len(truncated) > 0 {
+		err = node.verifyGenesisSnapshots()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = node.checkSignerReuse()
+	if err != nil {
+		return nil, err
+	}
+
+	err = node.migrateStore(context.Background(), storeMigrations)
+	if err != nil {
+		return nil, err
+	}
+
 	err = node.LoadConsensusNodes()
 	if err != nil {
 		return nil, err
 	}
 
+	if custom.CompactRounds {
+		_, err = node.CompactRemovedRounds()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = node.loadLastFinalized()
+	if err != nil {
+		return nil, err
+	}
+
 	graph, err := LoadRoundGraph(node.store, node.networkId, node.IdForNetwork)
 	if err != nil {
 		return nil, err
@@ -74,6 +161,19 @@ func SetupNode(store storage.Store, addr string, dir string) (*Node, error) {
 	node.Graph = graph
 
 	node.Peer = network.NewPeer(node, node.IdForNetwork, addr)
+	node.Peer.SetDialLimits(custom.DialConcurrency, time.Duration(custom.DialTimeout)*time.Second)
+	node.Peer.SetHandshakeTimeout(time.Duration(custom.HandshakeTimeout) * time.Second)
+	node.Peer.SetRequestPolicy(time.Duration(custom.RequestTimeout)*time.Second, custom.RequestAttempts)
+	node.Peer.SetGossipTopics(custom.GossipTopics)
+	if custom.FlowControl {
+		node.verifier.SetPressureHook(node.Peer.FlowControl)
+	}
+	if custom.AddressBook {
+		err = node.loadAddressBook()
+		if err != nil {
+			return nil, err
+		}
+	}
 	err = node.AddNeighborsFromConfig()
 	if err != nil {
 		return nil, err
@@ -143,18 +243,32 @@ func (node *Node) LoadConsensusNodes() error {
 }
 
 func (node *Node) AddNeighborsFromConfig() error {
-	f, err := ioutil.ReadFile(node.configDir + "/nodes.json")
+	neighbors, err := node.readNeighborsConfig()
 	if err != nil {
 		return err
 	}
+	for id, host := range neighbors {
+		node.Peer.AddNeighbor(id, host)
+	}
+	return nil
+}
+
+// readNeighborsConfig returns the hosts of the consensus nodes in nodes.json,
+// except this node itself.
+func (node *Node) readNeighborsConfig() (map[crypto.Hash]string, error) {
+	f, err := ioutil.ReadFile(node.configDir + "/nodes.json")
+	if err != nil {
+		return nil, err
+	}
 	var inputs []struct {
 		Signer common.Address `json:"signer"`
 		Host   string         `json:"host"`
 	}
 	err = json.Unmarshal(f, &inputs)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	neighbors := make(map[crypto.Hash]string)
 	for _, in := range inputs {
 		if in.Signer.String() == node.Signer.String() {
 			continue
@@ -163,10 +277,9 @@ func (node *Node) AddNeighborsFromConfig() error {
 		if node.ConsensusNodes[id] == nil {
 			continue
 		}
-		node.Peer.AddNeighbor(id, in.Host)
+		neighbors[id] = in.Host
 	}
-
-	return nil
+	return neighbors, nil
 }
 
 func (node *Node) ListenNeighbors() error {
@@ -186,43 +299,74 @@ func (node *Node) BuildAuthenticationMessage() []byte {
 	binary.BigEndian.PutUint64(data, uint64(time.Now().Unix()))
 	hash := node.Signer.Hash().ForNetwork(node.networkId)
 	data = append(data, hash[:]...)
+	data = append(data, node.networkId[:]...)
 	sig := node.Signer.PrivateSpendKey.Sign(data)
 	return append(data, sig[:]...)
 }
 
+// Authenticate requires the peer to announce the same network id and prove
+// the control of its signer key, all failed peer ids are noted.
 func (node *Node) Authenticate(msg []byte) (crypto.Hash, error) {
+	var peerId, networkId crypto.Hash
+	var sig crypto.Signature
+	if len(msg) != 8+len(peerId)+len(networkId)+len(sig) {
+		return crypto.Hash{}, fmt.Errorf("peer authentication message size invalid %d", len(msg))
+	}
+	copy(peerId[:], msg[8:40])
+	copy(networkId[:], msg[40:72])
+	copy(sig[:], msg[72:])
+
 	ts := binary.BigEndian.Uint64(msg[:8])
 	if time.Now().Unix()-int64(ts) > 3 {
-		return crypto.Hash{}, errors.New("peer authentication message timeout")
+		return node.authenticateFailed(peerId, errors.New("peer authentication message timeout"))
+	}
+	if networkId != node.networkId {
+		return node.authenticateFailed(peerId, fmt.Errorf("peer authentication network mismatch %s", networkId.String()))
 	}
 
-	var peerId crypto.Hash
-	copy(peerId[:], msg[8:40])
 	peer := node.ConsensusNodes[peerId]
 	if peer == nil {
-		return crypto.Hash{}, errors.New("peer authentication invalid consensus peer")
+		return node.authenticateFailed(peerId, errors.New("peer authentication invalid consensus peer"))
 	}
-
-	var sig crypto.Signature
-	copy(sig[:], msg[40:])
-	if peer.Signer.PublicSpendKey.Verify(msg[:40], sig) {
+	if peer.Signer.PublicSpendKey.Verify(msg[:72], sig) {
 		return peerId, nil
 	}
-	return crypto.Hash{}, errors.New("peer authentication message signature invalid")
+	return node.authenticateFailed(peerId, errors.New("peer authentication message signature invalid"))
+}
+
+func (node *Node) authenticateFailed(peerId crypto.Hash, err error) (crypto.Hash, error) {
+	node.authFailures.Set(peerId.String(), err, cache.DefaultExpiration)
+	return crypto.Hash{}, err
 }
 
+func (node *Node) AuthenticationFailure(peerId crypto.Hash) error {
+	err, found := node.authFailures.Get(peerId.String())
+	if !found {
+		return nil
+	}
+	return err.(error)
+}
+
+// QueueAppendSnapshot submits the snapshot without waiting for the result, so
+// the snapshots from the same peer are verified in parallel too. The result is
+// only logged and counted, a full verifier blocks the peer instead.
 func (node *Node) QueueAppendSnapshot(peerId crypto.Hash, s *common.Snapshot) error {
+	result := node.verifier.Submit(peerId, s)
+	go func() {
+		err := <-result
+		if err != nil {
+			metrics.Counter("mixin_peer_snapshots_rejected_total", 1)
+			logger.Println("peer snapshot error", peerId.String(), err)
+		}
+	}()
+	return nil
+}
+
+func (node *Node) verifySnapshotSignatures(job *verifyJob) error {
+	s := job.snapshot
 	s.Hash = s.PayloadHash()
 	if len(s.Signatures) != 1 && !node.verifyFinalization(s.Signatures) {
-		return node.Peer.SendSnapshotConfirmMessage(peerId, s.Hash, 0)
-	}
-	inNode, err := node.store.CheckTransactionInNode(s.NodeId, s.Transaction)
-	if err != nil {
-		return err
-	}
-	if inNode {
-		node.Peer.ConfirmSnapshotForPeer(peerId, s.Hash, 1)
-		return node.Peer.SendSnapshotConfirmMessage(peerId, s.Hash, 1)
+		return nil
 	}
 
 	sigs := make([]*crypto.Signature, 0)
@@ -244,7 +388,37 @@ func (node *Node) QueueAppendSnapshot(peerId crypto.Hash, s *common.Snapshot) er
 		}
 		signaturesFilter[sig.String()] = true
 	}
-	s.Signatures = sigs
+	job.signatures = sigs
+	job.signers = signersMap
+	if len(sigs) == 0 {
+		return fmt.Errorf("snapshot signatures invalid %s", s.Hash.String())
+	}
+	return nil
+}
+
+func (node *Node) commitVerifiedSnapshot(job *verifyJob) error {
+	peerId, s := job.peerId, job.snapshot
+	if len(s.Signatures) != 1 && !node.verifyFinalization(s.Signatures) {
+		return node.Peer.SendSnapshotConfirmMessage(peerId, s.Hash, 0)
+	}
+	inNode, err := node.store.CheckTransactionInNode(s.NodeId, s.Transaction)
+	if err != nil {
+		return err
+	}
+	if inNode {
+		if node.custom.Quarantine && node.verifyFinalization(job.signatures) {
+			s.Signatures = job.signatures
+			_, err = node.store.RepairQuarantinedSnapshot(s)
+			if err != nil {
+				return err
+			}
+		}
+		node.Peer.ConfirmSnapshotForPeer(peerId, s.Hash, 1)
+		return node.Peer.SendSnapshotConfirmMessage(peerId, s.Hash, 1)
+	}
+
+	signersMap := job.signers
+	s.Signatures = job.signatures
 
 	if node.verifyFinalization(s.Signatures) {
 		node.Peer.ConfirmSnapshotForPeer(peerId, s.Hash, 1)
@@ -271,20 +445,25 @@ func (node *Node) QueueAppendSnapshot(peerId crypto.Hash, s *common.Snapshot) er
 	return node.store.QueueAppendSnapshot(peerId, s, false)
 }
 
+// SendTransactionToPeer serves the transaction requests of the peers, which
+// are the only way to get the transactions announced by a node with the
+// announce relay policy.
 func (node *Node) SendTransactionToPeer(peerId, hash crypto.Hash) error {
-	tx, err := node.store.ReadTransaction(hash)
-	if err != nil {
+	tx, err := node.readRelayTransaction(hash)
+	if err != nil || tx == nil {
 		return err
 	}
-	if tx == nil {
-		tx, err = node.store.CacheGetTransaction(hash)
-		if err != nil || tx == nil {
-			return err
-		}
-	}
 	return node.Peer.SendTransactionMessage(peerId, tx)
 }
 
+func (node *Node) readRelayTransaction(hash crypto.Hash) (*common.SignedTransaction, error) {
+	tx, err := node.store.ReadTransaction(hash)
+	if err != nil || tx != nil {
+		return tx, err
+	}
+	return node.store.CacheGetTransaction(hash)
+}
+
 func (node *Node) CachePutTransaction(tx *common.SignedTransaction) error {
 	return node.store.CachePutTransaction(tx)
 }
@@ -297,6 +476,29 @@ func (node *Node) ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round
 	return node.store.ReadSnapshotsForNodeRound(nodeIdWithNetwork, round)
 }
 
+// ReadSnapshotsByRound uses the node round index of the snapshots, ordered by
+// the snapshot timestamp.
+func (node *Node) ReadSnapshotsByRound(nodeId crypto.Hash, round uint64) ([]*common.Snapshot, error) {
+	topos, err := node.store.ReadSnapshotsForNodeRound(nodeId, round)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*common.Snapshot, len(topos))
+	for i, topo := range topos {
+		snapshots[i] = &topo.Snapshot
+	}
+	return snapshots, nil
+}
+
+func (node *Node) ReadRoundByHash(hash crypto.Hash) (*common.Round, error) {
+	round, err := node.store.ReadRound(hash)
+	if err != nil || round == nil {
+		return nil, err
+	}
+	round.Hash = hash
+	return round, nil
+}
+
 func (node *Node) UpdateSyncPoint(peerId crypto.Hash, points []*network.SyncPoint) {
 	if node.ConsensusNodes[peerId] == nil {
 		return
@@ -342,14 +544,42 @@ func (node *Node) ConsumeMempool() error {
 	for {
 		select {
 		case s := <-node.mempoolChan:
-			err := node.handleSnapshotInput(s)
-			if err != nil {
-				return err
+			for {
+				err := node.handleSnapshotInputOrDegrade(s)
+				if err == nil {
+					break
+				}
+				if !storage.IsUnavailableError(err) {
+					return err
+				}
+				err = node.waitStoreRecovery(err)
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
 }
 
+// useMetricsBackend keeps the backend in use for the default prometheus, so a
+// backend injected before the node setup is respected, otherwise the backend
+// replaced is closed.
+func useMetricsBackend(custom *config.Custom) error {
+	switch custom.MetricsBackend {
+	case config.MetricsBackendStatsD:
+		b, err := metrics.NewStatsD(custom.StatsDAddress, "mixin.")
+		if err != nil {
+			return err
+		}
+		metrics.Close()
+		metrics.Use(b)
+	case config.MetricsBackendNone:
+		metrics.Close()
+		metrics.Use(metrics.Noop{})
+	}
+	return nil
+}
+
 type syncMap struct {
 	mutex *sync.RWMutex
 	m     map[crypto.Hash]*network.SyncPoint
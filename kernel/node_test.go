@@ -0,0 +1,207 @@
+package kernel
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticate(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	peer := &Node{Signer: signers[1], networkId: node.networkId}
+	peerId := signers[1].Hash().ForNetwork(node.networkId)
+	id, err := node.Authenticate(peer.BuildAuthenticationMessage())
+	assert.Nil(err)
+	assert.Equal(peerId, id)
+	assert.Nil(node.AuthenticationFailure(peerId))
+
+	peer = &Node{Signer: signers[2], networkId: crypto.NewHash([]byte("other-network"))}
+	peerId = signers[2].Hash().ForNetwork(peer.networkId)
+	id, err = node.Authenticate(peer.BuildAuthenticationMessage())
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "network mismatch")
+	assert.Equal(crypto.Hash{}, id)
+	assert.Equal(err, node.AuthenticationFailure(peerId))
+
+	forged := signers[3]
+	forged.PrivateSpendKey = randomTestKey()
+	peer = &Node{Signer: forged, networkId: node.networkId}
+	peerId = signers[3].Hash().ForNetwork(node.networkId)
+	id, err = node.Authenticate(peer.BuildAuthenticationMessage())
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "signature invalid")
+	assert.Equal(crypto.Hash{}, id)
+	assert.Equal(err, node.AuthenticationFailure(peerId))
+
+	msg := peer.BuildAuthenticationMessage()
+	_, err = node.Authenticate(msg[:40])
+	assert.NotNil(err)
+}
+
+func TestNodeShutdown(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	node.Shutdown()
+	node.Shutdown()
+	select {
+	case <-node.done:
+	default:
+		t.Fatal("node not shut down")
+	}
+	assert.NotNil(node.buffer.Put(&verifyJob{snapshot: &common.Snapshot{}}))
+	assert.Len(node.Peer.PeerMetrics(), 0)
+}
+
+func TestQueueAppendSnapshotError(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	peerId := signers[1].Hash().ForNetwork(node.networkId)
+	s := &common.Snapshot{NodeId: peerId, Transaction: crypto.NewHash([]byte("tx"))}
+	hash := s.PayloadHash()
+	key := randomTestKey()
+	sig := key.Sign(hash[:])
+	s.Signatures = []*crypto.Signature{&sig}
+	backend := &testMetricsBackend{
+		counters:   make(map[string]uint64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]int),
+	}
+	metrics.Use(backend)
+	defer metrics.Use(metrics.NewPrometheus())
+	rejected := func() uint64 {
+		backend.Lock()
+		defer backend.Unlock()
+		return backend.counters["mixin_peer_snapshots_rejected_total"]
+	}
+	err := node.QueueAppendSnapshot(peerId, s)
+	assert.Nil(err)
+	for i := 0; i < 50 && rejected() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(uint64(1), rejected())
+
+	sig = signers[1].PrivateSpendKey.Sign(hash[:])
+	s.Signatures = []*crypto.Signature{&sig}
+	err = node.QueueAppendSnapshot(peerId, s)
+	assert.Nil(err)
+	for i := 0; i < 50 && node.buffer.Stats().Flushed == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(uint64(1), node.buffer.Stats().Flushed)
+	assert.Equal(uint64(1), rejected())
+}
+
+func TestQueueAppendSnapshotParallel(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	var mutex sync.Mutex
+	var active, peak int
+	overlapped := make(chan struct{})
+	var once sync.Once
+	verify := func(job *verifyJob) error {
+		mutex.Lock()
+		active = active + 1
+		if active > peak {
+			peak = active
+		}
+		if active == 2 {
+			once.Do(func() { close(overlapped) })
+		}
+		mutex.Unlock()
+		select {
+		case <-overlapped:
+		case <-time.After(3 * time.Second):
+		}
+		mutex.Lock()
+		active = active - 1
+		mutex.Unlock()
+		return nil
+	}
+	var wg sync.WaitGroup
+	commit := func(job *verifyJob) error {
+		wg.Done()
+		return nil
+	}
+	node.verifier = newSnapshotVerifier(4, verify, commit)
+
+	peerId := signers[1].Hash().ForNetwork(node.networkId)
+	start := time.Now()
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		err := node.QueueAppendSnapshot(peerId, &common.Snapshot{NodeId: peerId, RoundNumber: uint64(i)})
+		assert.Nil(err)
+	}
+	wg.Wait()
+	assert.True(time.Since(start) < 3*time.Second)
+	mutex.Lock()
+	assert.True(peak >= 2)
+	mutex.Unlock()
+}
+
+func TestQueueAppendSnapshotPressure(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	commit := func(job *verifyJob) error {
+		<-release
+		wg.Done()
+		return nil
+	}
+	node.verifier = newSnapshotVerifier(1, func(job *verifyJob) error { return nil }, commit)
+	transitions := make(chan bool, 8)
+	blocked := make(chan struct{})
+	node.verifier.SetPressureHook(func(saturated bool) {
+		transitions <- saturated
+		<-blocked
+	})
+	next := func() interface{} {
+		select {
+		case saturated := <-transitions:
+			return saturated
+		case <-time.After(3 * time.Second):
+			return nil
+		}
+	}
+
+	peerId := signers[1].Hash().ForNetwork(node.networkId)
+	wg.Add(60)
+	for i := 0; i < 60; i++ {
+		err := node.QueueAppendSnapshot(peerId, &common.Snapshot{NodeId: peerId, RoundNumber: uint64(i)})
+		assert.Nil(err)
+	}
+	assert.Equal(true, next())
+
+	close(release)
+	wg.Wait()
+	assert.Len(transitions, 0)
+	close(blocked)
+	assert.Equal(false, next())
+}
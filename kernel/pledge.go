@@ -0,0 +1,259 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type PledgeStatus struct {
+	NodeId      crypto.Hash    `json:"node"`
+	Amount      common.Integer `json:"amount"`
+	Transaction crypto.Hash    `json:"transaction"`
+	Snapshot    crypto.Hash    `json:"snapshot"`
+	RoundNumber uint64         `json:"round"`
+	Timestamp   uint64         `json:"timestamp"`
+	Spent       bool           `json:"spent"`
+}
+
+// NodePledgeStatus only finds the accept snapshots of the genesis nodes for
+// now, which are always in the round 0 of the node itself.
+func (node *Node) NodePledgeStatus(nodeId crypto.Hash) (*PledgeStatus, error) {
+	cn := node.ConsensusNodes[nodeId]
+	if cn == nil {
+		return nil, fmt.Errorf("consensus node not found %s", nodeId.String())
+	}
+
+	utxo, err := node.store.ReadUTXOWithLock(cn.Transaction, 0)
+	if err != nil {
+		return nil, err
+	}
+	if utxo == nil || utxo.Type != common.OutputTypeNodeAccept {
+		return nil, fmt.Errorf("node accept output not found %s", cn.Transaction.String())
+	}
+
+	snapshots, err := node.store.ReadSnapshotsForNodeRound(nodeId, 0)
+	if err != nil {
+		return nil, err
+	}
+	spent, err := node.checkUTXOSpent(utxo)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.Transaction != cn.Transaction {
+			continue
+		}
+		return &PledgeStatus{
+			NodeId:      nodeId,
+			Amount:      utxo.Amount,
+			Transaction: cn.Transaction,
+			Snapshot:    s.Hash,
+			RoundNumber: s.RoundNumber,
+			Timestamp:   s.Timestamp,
+			Spent:       spent,
+		}, nil
+	}
+	return nil, fmt.Errorf("node accept snapshot not found %s", cn.Transaction.String())
+}
+
+// NodePayee reconstructs the payee address from the node accept transaction
+// extra, which packs the signer and payee public spend keys.
+func (node *Node) NodePayee(nodeId crypto.Hash) (common.Address, error) {
+	var payee common.Address
+	cn := node.ConsensusNodes[nodeId]
+	if cn == nil {
+		return payee, fmt.Errorf("consensus node not found %s", nodeId.String())
+	}
+	tx, err := node.store.ReadTransaction(cn.Transaction)
+	if err != nil {
+		return payee, err
+	}
+	if tx == nil {
+		return payee, fmt.Errorf("node accept transaction not found %s", cn.Transaction.String())
+	}
+	if len(tx.Extra) != len(payee.PublicSpendKey)*2 {
+		return payee, fmt.Errorf("invalid node accept extra size %d", len(tx.Extra))
+	}
+	copy(payee.PublicSpendKey[:], tx.Extra[len(payee.PublicSpendKey):])
+	payee.PrivateViewKey = payee.PublicSpendKey.DeterministicHashDerive()
+	payee.PublicViewKey = payee.PrivateViewKey.Public()
+	return payee, nil
+}
+
+// NodesForPayee returns the consensus nodes paying to the payee, an operator
+// may run several nodes with the same payee. The payees are compared by the
+// public spend key only, because the view key is derived from it.
+func (node *Node) NodesForPayee(payee common.Address) ([]crypto.Hash, error) {
+	var nodes []crypto.Hash
+	for id := range node.ConsensusNodes {
+		p, err := node.NodePayee(id)
+		if err != nil {
+			return nil, err
+		}
+		if p.PublicSpendKey == payee.PublicSpendKey {
+			nodes = append(nodes, id)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return bytes.Compare(nodes[i][:], nodes[j][:]) < 0 })
+	return nodes, nil
+}
+
+// ExportNodeRoster writes the genesis nodes as CSV rows of node id, signer,
+// payee and pledge, with the signer and payee reconstructed from the node
+// accept transaction extra.
+func (node *Node) ExportNodeRoster(w io.Writer) error {
+	snapshots, err := node.GenesisSnapshots()
+	if err != nil {
+		return err
+	}
+	out := csv.NewWriter(w)
+	err = out.Write([]string{"node", "signer", "payee", "pledge"})
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		tx, err := node.store.ReadTransaction(s.Transaction)
+		if err != nil {
+			return err
+		}
+		if tx.Outputs[0].Type != common.OutputTypeNodeAccept {
+			continue
+		}
+		var signer, payee common.Address
+		if len(tx.Extra) != len(signer.PublicSpendKey)*2 {
+			return fmt.Errorf("invalid node accept extra size %d", len(tx.Extra))
+		}
+		copy(signer.PublicSpendKey[:], tx.Extra[:len(signer.PublicSpendKey)])
+		signer.PrivateViewKey = signer.PublicSpendKey.DeterministicHashDerive()
+		signer.PublicViewKey = signer.PrivateViewKey.Public()
+		copy(payee.PublicSpendKey[:], tx.Extra[len(payee.PublicSpendKey):])
+		payee.PrivateViewKey = payee.PublicSpendKey.DeterministicHashDerive()
+		payee.PublicViewKey = payee.PrivateViewKey.Public()
+		err = out.Write([]string{
+			signer.Hash().ForNetwork(node.networkId).String(),
+			signer.String(),
+			payee.String(),
+			tx.Outputs[0].Amount.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// SignerForNodeId finds the node accept transaction in the store instead of
+// the loaded consensus nodes, and reconstructs the signer from its extra.
+func (node *Node) SignerForNodeId(nodeId crypto.Hash) (common.Address, error) {
+	var signer common.Address
+	for _, cn := range node.store.ReadConsensusNodes() {
+		if cn.Signer.Hash().ForNetwork(node.networkId) != nodeId {
+			continue
+		}
+		tx, err := node.store.ReadTransaction(cn.Transaction)
+		if err != nil {
+			return signer, err
+		}
+		if tx == nil {
+			return signer, fmt.Errorf("node accept transaction not found %s", cn.Transaction.String())
+		}
+		if len(tx.Extra) != len(signer.PublicSpendKey)*2 {
+			return signer, fmt.Errorf("invalid node accept extra size %d", len(tx.Extra))
+		}
+		copy(signer.PublicSpendKey[:], tx.Extra[:len(signer.PublicSpendKey)])
+		signer.PrivateViewKey = signer.PublicSpendKey.DeterministicHashDerive()
+		signer.PublicViewKey = signer.PrivateViewKey.Public()
+		if signer.Hash().ForNetwork(node.networkId) != nodeId {
+			return common.Address{}, fmt.Errorf("node accept signer mismatch %s", nodeId.String())
+		}
+		return signer, nil
+	}
+	return signer, fmt.Errorf("node not found %s", nodeId.String())
+}
+
+// OutputThreshold finds the output controlled by the key, and returns the
+// signatures required by its script and the total number of its keys.
+func (node *Node) OutputThreshold(key crypto.Key) (required uint8, total int, err error) {
+	utxo, err := node.store.ReadGhostUTXO(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	if utxo == nil {
+		return 0, 0, fmt.Errorf("output not found for key %s", key.String())
+	}
+	required, err = utxo.Script.Threshold()
+	if err != nil {
+		return 0, 0, err
+	}
+	return required, len(utxo.Keys), nil
+}
+
+type ActiveNode struct {
+	NodeId      crypto.Hash    `json:"node"`
+	Signer      common.Address `json:"signer"`
+	Payee       common.Address `json:"payee"`
+	Pledge      common.Integer `json:"pledge"`
+	Transaction crypto.Hash    `json:"transaction"`
+}
+
+// ActiveNodes reads the accepted nodes from the store instead of the loaded
+// consensus nodes, and only those with the node accept output not spent by a
+// finalized transaction.
+func (node *Node) ActiveNodes() ([]*ActiveNode, error) {
+	nodes := make([]*ActiveNode, 0)
+	for _, cn := range node.store.ReadConsensusNodes() {
+		if cn.State != common.NodeStateAccepted {
+			continue
+		}
+		utxo, err := node.store.ReadUTXOWithLock(cn.Transaction, 0)
+		if err != nil {
+			return nil, err
+		}
+		if utxo == nil || utxo.Type != common.OutputTypeNodeAccept {
+			continue
+		}
+		spent, err := node.checkUTXOSpent(utxo)
+		if err != nil {
+			return nil, err
+		}
+		if spent {
+			continue
+		}
+		nodes = append(nodes, &ActiveNode{
+			NodeId:      cn.Signer.Hash().ForNetwork(node.networkId),
+			Signer:      cn.Signer,
+			Payee:       cn.Payee,
+			Pledge:      utxo.Amount,
+			Transaction: cn.Transaction,
+		})
+	}
+	return nodes, nil
+}
+
+// CurrentQuorumThreshold is the signatures required over the active nodes,
+// by the same formula of the node accept scripts in genesis.
+func (node *Node) CurrentQuorumThreshold() (uint8, int, error) {
+	nodes, err := node.ActiveNodes()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(nodes) == 0 {
+		return 0, 0, fmt.Errorf("no active nodes")
+	}
+	if len(nodes)*2/3+1 > math.MaxUint8 {
+		return 0, len(nodes), fmt.Errorf("quorum threshold overflow %d", len(nodes))
+	}
+	return quorumThreshold(len(nodes)), len(nodes), nil
+}
+
+func quorumThreshold(total int) uint8 {
+	return uint8(total*2/3 + 1)
+}
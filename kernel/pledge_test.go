@@ -0,0 +1,276 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodePledgeStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	epoch := uint64(time.Unix(gns.Epoch, 0).UnixNano())
+
+	assert.Len(node.ConsensusNodes, len(signers))
+	for _, signer := range signers {
+		id := signer.Hash().ForNetwork(node.networkId)
+		status, err := node.NodePledgeStatus(id)
+		assert.Nil(err)
+		assert.NotNil(status)
+		assert.Equal(id, status.NodeId)
+		assert.Equal(common.NewInteger(PledgeAmount), status.Amount)
+		assert.Equal(uint64(0), status.RoundNumber)
+		assert.Equal(epoch, status.Timestamp)
+		assert.True(status.Snapshot.HasValue())
+		assert.False(status.Spent)
+	}
+
+	id := signers[0].Hash().ForNetwork(node.networkId)
+	spend := testSpendTransaction(node.ConsensusNodes[id].Transaction, PledgeAmount)
+	_, err = node.store.LockUTXO(node.ConsensusNodes[id].Transaction, 0, spend.PayloadHash(), false)
+	assert.Nil(err)
+	status, err := node.NodePledgeStatus(id)
+	assert.Nil(err)
+	assert.False(status.Spent)
+	testWriteSnapshot(t, node, spend)
+	status, err = node.NodePledgeStatus(id)
+	assert.Nil(err)
+	assert.True(status.Spent)
+
+	status, err = node.NodePledgeStatus(crypto.NewHash([]byte("unknown")))
+	assert.Nil(status)
+	assert.NotNil(err)
+}
+
+func TestNodePayee(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	for i, in := range gns.Nodes {
+		id := signers[i].Hash().ForNetwork(node.networkId)
+		payee, err := node.NodePayee(id)
+		assert.Nil(err)
+		assert.Equal(in.Payee.String(), payee.String())
+		assert.Equal(in.Payee.PublicViewKey, payee.PublicViewKey)
+	}
+
+	_, err = node.NodePayee(crypto.NewHash([]byte("unknown")))
+	assert.NotNil(err)
+}
+
+func TestNodesForPayee(t *testing.T) {
+	assert := assert.New(t)
+
+	gns, keys, err := GenerateTestGenesis(MinimumNodeCount, []byte("payee"), time.Now().Unix())
+	assert.Nil(err)
+	gns.Nodes[4].Payee = gns.Nodes[1].Payee
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	data, err := json.Marshal(gns)
+	assert.Nil(err)
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+	testWriteConfig(t, dir, keys[0].String())
+	testWriteNodes(t, dir, nil)
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err := SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(err)
+
+	ids := func(nodes ...int) []crypto.Hash {
+		var hashes []crypto.Hash
+		for _, i := range nodes {
+			hashes = append(hashes, gns.Nodes[i].Signer.Hash().ForNetwork(node.networkId))
+		}
+		sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+		return hashes
+	}
+	nodes, err := node.NodesForPayee(gns.Nodes[1].Payee)
+	assert.Nil(err)
+	assert.Equal(ids(1, 4), nodes)
+	nodes, err = node.NodesForPayee(gns.Nodes[2].Payee)
+	assert.Nil(err)
+	assert.Equal(ids(2), nodes)
+	nodes, err = node.NodesForPayee(gns.Nodes[2].Signer)
+	assert.Nil(err)
+	assert.Len(nodes, 0)
+}
+
+func TestExportNodeRoster(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	var buf bytes.Buffer
+	err := node.ExportNodeRoster(&buf)
+	assert.Nil(err)
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(err)
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	assert.Len(rows, len(gns.Nodes)+1)
+	assert.Equal([]string{"node", "signer", "payee", "pledge"}, rows[0])
+	for i, in := range gns.Nodes {
+		row := rows[i+1]
+		assert.Equal(signers[i].Hash().ForNetwork(node.networkId).String(), row[0])
+		assert.Equal(in.Signer.String(), row[1])
+		assert.Equal(in.Payee.String(), row[2])
+		assert.Equal(common.NewInteger(PledgeAmount).String(), row[3])
+	}
+}
+
+func TestSignerForNodeId(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	for i, in := range gns.Nodes {
+		id := signers[i].Hash().ForNetwork(node.networkId)
+		signer, err := node.SignerForNodeId(id)
+		assert.Nil(err)
+		assert.Equal(in.Signer.String(), signer.String())
+		assert.Equal(in.Signer.PublicViewKey, signer.PublicViewKey)
+	}
+
+	_, err = node.SignerForNodeId(crypto.NewHash([]byte("unknown")))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "node not found")
+}
+
+func TestActiveNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	nodes, err := node.ActiveNodes()
+	assert.Nil(err)
+	assert.Len(nodes, len(gns.Nodes))
+	active := make(map[crypto.Hash]*ActiveNode)
+	for _, n := range nodes {
+		active[n.NodeId] = n
+	}
+	for i, in := range gns.Nodes {
+		id := signers[i].Hash().ForNetwork(node.networkId)
+		n := active[id]
+		assert.NotNil(n)
+		assert.Equal(in.Signer.String(), n.Signer.String())
+		assert.Equal(in.Payee.String(), n.Payee.String())
+		assert.Equal(common.NewInteger(PledgeAmount), n.Pledge)
+		assert.Equal(node.ConsensusNodes[id].Transaction, n.Transaction)
+	}
+
+	spent := nodes[0]
+	spend := testSpendTransaction(spent.Transaction, PledgeAmount)
+	_, err = node.store.LockUTXO(spent.Transaction, 0, spend.PayloadHash(), false)
+	assert.Nil(err)
+	nodes, err = node.ActiveNodes()
+	assert.Nil(err)
+	assert.Len(nodes, len(gns.Nodes))
+	testWriteSnapshot(t, node, spend)
+	nodes, err = node.ActiveNodes()
+	assert.Nil(err)
+	assert.Len(nodes, len(gns.Nodes)-1)
+	for _, n := range nodes {
+		assert.NotEqual(spent.NodeId, n.NodeId)
+	}
+}
+
+func TestOutputThreshold(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	domain := snapshots[len(snapshots)-1]
+	tx, err := node.store.ReadTransaction(domain.Transaction)
+	assert.Nil(err)
+	assert.Equal(uint8(common.OutputTypeDomainAccept), tx.Outputs[0].Type)
+	for _, key := range tx.Outputs[0].Keys {
+		required, total, err := node.OutputThreshold(key)
+		assert.Nil(err)
+		assert.Equal(uint8(len(signers)*2/3+1), required)
+		assert.Equal(len(signers), total)
+	}
+
+	required, total, err := node.OutputThreshold(randomTestKey().Public())
+	assert.NotNil(err)
+	assert.Equal(uint8(0), required)
+	assert.Equal(0, total)
+}
+
+func TestCurrentQuorumThreshold(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	required, total, err := node.CurrentQuorumThreshold()
+	assert.Nil(err)
+	assert.Equal(uint8(len(signers)*2/3+1), required)
+	assert.Equal(len(signers), total)
+
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	tx, err := node.store.ReadTransaction(snapshots[0].Transaction)
+	assert.Nil(err)
+	script := common.ScriptThreshold{Required: required}.Compile()
+	assert.Equal(script, tx.Outputs[0].Script)
+
+	spend := testSpendTransaction(snapshots[0].Transaction, PledgeAmount)
+	_, err = node.store.LockUTXO(snapshots[0].Transaction, 0, spend.PayloadHash(), false)
+	assert.Nil(err)
+	required, total, err = node.CurrentQuorumThreshold()
+	assert.Nil(err)
+	assert.Equal(len(signers), total)
+	testWriteSnapshot(t, node, spend)
+	required, total, err = node.CurrentQuorumThreshold()
+	assert.Nil(err)
+	assert.Equal(uint8((len(signers)-1)*2/3+1), required)
+	assert.Equal(len(signers)-1, total)
+}
+
+func testSpendTransaction(hash crypto.Hash, amount int) *common.SignedTransaction {
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.AddInput(hash, 0)
+	tx.Outputs = []*common.Output{
+		{Type: common.OutputTypeScript, Amount: common.NewInteger(uint64(amount)), Keys: []crypto.Key{randomTestKey()}},
+	}
+	return &common.SignedTransaction{Transaction: *tx}
+}
@@ -6,22 +6,55 @@ import (
 	"github.com/MixinNetwork/mixin/storage"
 )
 
+// QueueTransaction is the mempool entry of the RPC, the mempool limits of the
+// node apply once it's set up.
 func QueueTransaction(store storage.Store, tx *common.SignedTransaction) (string, error) {
+	if globalNode == nil {
+		return queueTransaction(store, crypto.Hash{}, tx)
+	}
+	return globalNode.QueueTransaction(tx)
+}
+
+func (node *Node) QueueTransaction(tx *common.SignedTransaction) (string, error) {
+	err := node.checkMempoolLimits(tx)
+	if err != nil {
+		rejections.record(tx.PayloadHash(), err)
+		return "", err
+	}
+	return queueTransaction(node.store, node.IdForNetwork, tx)
+}
+
+func queueTransaction(store storage.Store, nodeId crypto.Hash, tx *common.SignedTransaction) (string, error) {
 	err := tx.Validate(store)
 	if err != nil {
+		rejections.record(tx.PayloadHash(), err)
 		return "", err
 	}
 	err = store.CachePutTransaction(tx)
 	if err != nil {
 		return "", err
 	}
-	err = store.QueueAppendSnapshot(NodeIdForNetwork(), &common.Snapshot{
-		NodeId:      NodeIdForNetwork(),
+	err = store.QueueAppendSnapshot(nodeId, &common.Snapshot{
+		NodeId:      nodeId,
 		Transaction: tx.PayloadHash(),
 	}, false)
 	return tx.PayloadHash().String(), err
 }
 
+// checkMempoolLimits applies the max-inputs and max-outputs of the node config,
+// they are local mempool policies lower than the network limits, so only the
+// new transactions to the mempool are checked, never the snapshots from peers
+// or the transactions already in the cache.
+func (node *Node) checkMempoolLimits(tx *common.SignedTransaction) error {
+	if len(tx.Inputs) > node.custom.MaxInputs {
+		return common.NewTooManyInputsError(len(tx.Inputs), node.custom.MaxInputs)
+	}
+	if len(tx.Outputs) > node.custom.MaxOutputs {
+		return common.NewTooManyOutputsError(len(tx.Outputs), node.custom.MaxOutputs)
+	}
+	return nil
+}
+
 func (node *Node) LoadCacheToQueue() error {
 	return node.store.CacheListTransactions(func(tx *common.SignedTransaction) error {
 		return node.store.QueueAppendSnapshot(node.IdForNetwork, &common.Snapshot{
@@ -54,7 +87,9 @@ func (node *Node) ConsumeQueue() error {
 			return nil
 		}
 		node.Peer.SendTransactionRequestMessage(peerId, snap.Transaction)
-		return node.store.QueueAppendSnapshot(peerId, snap, node.verifyFinalization(snap.Signatures))
+		return node.writeStoreOrDegrade(func() error {
+			return node.store.QueueAppendSnapshot(peerId, snap, node.verifyFinalization(snap.Signatures))
+		})
 	})
 	return nil
 }
@@ -0,0 +1,48 @@
+package kernel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// NodeSnapshotRate is the snapshots per second the node produced within the
+// window until now, a node producing nothing in a long window is not live.
+// The rounds of the node are walked back from the head until a round ends
+// before the window.
+func (node *Node) NodeSnapshotRate(nodeId crypto.Hash, window time.Duration) (float64, error) {
+	if window <= 0 {
+		return 0, fmt.Errorf("invalid snapshot rate window %s", window)
+	}
+	head, err := node.store.ReadRound(nodeId)
+	if err != nil {
+		return 0, err
+	}
+	if head == nil {
+		return 0, fmt.Errorf("node not found %s", nodeId)
+	}
+
+	now := node.clock()
+	since, until := uint64(now.Add(-window).UnixNano()), uint64(now.UnixNano())
+	var count int
+	for n := head.Number; ; n-- {
+		snapshots, err := node.store.ReadSnapshotsForNodeRound(nodeId, n)
+		if err != nil {
+			return 0, err
+		}
+		var last uint64
+		for _, s := range snapshots {
+			if s.Timestamp > last {
+				last = s.Timestamp
+			}
+			if s.Timestamp >= since && s.Timestamp <= until {
+				count++
+			}
+		}
+		if n == 0 || (len(snapshots) > 0 && last < since) {
+			break
+		}
+	}
+	return float64(count) / window.Seconds(), nil
+}
@@ -0,0 +1,63 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeSnapshotRate(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+
+	now := time.Now().Add(time.Hour)
+	node.clock = func() time.Time { return now }
+	rate, err := node.NodeSnapshotRate(node.IdForNetwork, time.Minute)
+	assert.Nil(err)
+	assert.Equal(float64(0), rate)
+
+	cache, err := node.store.ReadRound(node.IdForNetwork)
+	assert.Nil(err)
+	for i, ago := range []time.Duration{90, 50, 40, 30, 20, 10, 5, -5} {
+		tx := testMintTransaction(common.XINAssetId, uint64(i+1))
+		err = node.store.WriteTransaction(tx)
+		assert.Nil(err)
+		snap := &common.SnapshotWithTopologicalOrder{
+			Snapshot: common.Snapshot{
+				NodeId:      node.IdForNetwork,
+				Transaction: tx.PayloadHash(),
+				References:  cache.References,
+				RoundNumber: cache.Number,
+				Timestamp:   uint64(now.Add(-ago * time.Second).UnixNano()),
+			},
+			TopologicalOrder: node.TopoCounter.Next(),
+		}
+		snap.Hash = snap.PayloadHash()
+		err = node.store.WriteSnapshot(snap)
+		assert.Nil(err)
+	}
+
+	rate, err = node.NodeSnapshotRate(node.IdForNetwork, time.Minute)
+	assert.Nil(err)
+	assert.Equal(float64(6)/60, rate)
+	rate, err = node.NodeSnapshotRate(node.IdForNetwork, 10*time.Second)
+	assert.Nil(err)
+	assert.Equal(float64(2)/10, rate)
+	rate, err = node.NodeSnapshotRate(node.IdForNetwork, 2*time.Minute)
+	assert.Nil(err)
+	assert.Equal(float64(7)/120, rate)
+	rate, err = node.NodeSnapshotRate(node.IdForNetwork, 2*time.Hour)
+	assert.Nil(err)
+	assert.True(rate > float64(7)/7200)
+
+	_, err = node.NodeSnapshotRate(node.IdForNetwork, 0)
+	assert.NotNil(err)
+	_, err = node.NodeSnapshotRate(crypto.NewHash([]byte("unknown")), time.Minute)
+	assert.NotNil(err)
+}
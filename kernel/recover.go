@@ -0,0 +1,32 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// recoverTopology truncates the tip snapshots with missing records, only when
+// the recover-topology is set by the operator after checking the store, and
+// it must run before the topology counter is loaded.
+func recoverTopology(store storage.Store) ([]uint64, error) {
+	truncated, err := store.RecoverTopology()
+	if err != nil {
+		return nil, fmt.Errorf("topology recovery error %s", err.Error())
+	}
+	for _, order := range truncated {
+		logger.Printf("RECOVER truncate partial snapshot commit at topology %d\n", order)
+	}
+	return truncated, nil
+}
+
+// the genesis is a single atomic write, so it's never partially committed, but
+// it's verified after a recovery in case the truncation reached it.
+func (node *Node) verifyGenesisSnapshots() error {
+	_, err := node.GenesisSnapshots()
+	if err != nil {
+		return fmt.Errorf("genesis snapshots inconsistent %s, re-sync or restore from backup", err.Error())
+	}
+	return nil
+}
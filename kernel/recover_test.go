@@ -0,0 +1,71 @@
+package kernel
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverPartialCommit(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+
+	seq := node.TopologySequence()
+	testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 100))
+	partial := testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 100))
+	assert.Equal(seq+2, node.store.TopologySequence())
+	node.store.Close()
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir + "/snapshots"
+	opts.ValueDir = opts.Dir
+	db, err := badger.Open(opts)
+	assert.Nil(err)
+	err = db.Update(func(txn *badger.Txn) error {
+		order := make([]byte, 8)
+		binary.BigEndian.PutUint64(order, partial.TopologicalOrder)
+		item, err := txn.Get(append([]byte("TOPOLOGY"), order...))
+		if err != nil {
+			return err
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return txn.Delete(key)
+	})
+	assert.Nil(err)
+	err = db.Close()
+	assert.Nil(err)
+
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	_, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.NotNil(err)
+	assert.Equal(seq+2, store.TopologySequence())
+	store.Close()
+
+	data := fmt.Sprintf(`{"signer":"%s","recover-topology":true}`, signers[0].PrivateSpendKey.String())
+	err = ioutil.WriteFile(dir+"/config.json", []byte(data), 0644)
+	assert.Nil(err)
+	store, err = storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(err)
+	assert.Equal(seq+1, node.TopologySequence())
+	assert.Equal(seq+1, store.TopologySequence())
+	_, err = node.GenesisSnapshots()
+	assert.Nil(err)
+	assert.Nil(store.VerifyChecksum(context.Background()))
+}
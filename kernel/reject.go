@@ -0,0 +1,118 @@
+package kernel
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+const (
+	RejectionLogLimit  = 10
+	RejectionLogWindow = time.Second
+)
+
+const (
+	RejectReasonVersion      = "version"
+	RejectReasonInputs       = "too-many-inputs"
+	RejectReasonOutputs      = "too-many-outputs"
+	RejectReasonSignature    = "bad-signature"
+	RejectReasonSpentInput   = "spent-input"
+	RejectReasonMissingInput = "missing-input"
+	RejectReasonExtra        = "oversized-extra"
+	RejectReasonSize         = "oversized-transaction"
+	RejectReasonOutputKey    = "invalid-output-key"
+	RejectReasonTimeLock     = "time-locked"
+	RejectReasonAmount       = "invalid-amount"
+	RejectReasonFee          = "insufficient-fee"
+	RejectReasonOther        = "other"
+)
+
+// rejectReasons maps the validation error kinds to the reasons, matched with
+// errors.Is against the typed errors from the common and storage packages.
+var rejectReasons = []struct {
+	err    error
+	reason string
+}{
+	{common.ErrInvalidVersion, RejectReasonVersion},
+	{common.ErrTooManyInputs, RejectReasonInputs},
+	{common.ErrTooManyOutputs, RejectReasonOutputs},
+	{common.ErrInvalidSignature, RejectReasonSignature},
+	{common.ErrInputLocked, RejectReasonSpentInput},
+	{common.ErrInputNotFound, RejectReasonMissingInput},
+	{common.ErrInvalidExtra, RejectReasonExtra},
+	{common.ErrInvalidSize, RejectReasonSize},
+	{common.ErrInvalidOutputKey, RejectReasonOutputKey},
+	{common.ErrTimeLocked, RejectReasonTimeLock},
+	{common.ErrInvalidAmount, RejectReasonAmount},
+	{common.ErrInvalidFee, RejectReasonFee},
+}
+
+func rejectReason(err error) string {
+	for _, r := range rejectReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return RejectReasonOther
+}
+
+// rejectionLog always counts the rejected transactions by reason, but only
+// logs them when enabled, and at most RejectionLogLimit in each window.
+type rejectionLog struct {
+	mutex   *sync.Mutex
+	enabled bool
+	counts  map[string]uint64
+	window  time.Time
+	logged  int
+}
+
+var rejections = &rejectionLog{
+	mutex:  new(sync.Mutex),
+	counts: make(map[string]uint64),
+}
+
+func (l *rejectionLog) enable(enabled bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.enabled = enabled
+}
+
+func (l *rejectionLog) record(hash crypto.Hash, err error) string {
+	reason := rejectReason(err)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.counts[reason] = l.counts[reason] + 1
+	metrics.Counter("mixin_transactions_rejected_total", 1)
+	if !l.enabled {
+		return reason
+	}
+	now := time.Now()
+	if now.Sub(l.window) >= RejectionLogWindow {
+		l.window, l.logged = now, 0
+	}
+	if l.logged < RejectionLogLimit {
+		l.logged = l.logged + 1
+		logger.Printf("TRANSACTION REJECTED %s %s %s\n", hash.String(), reason, err.Error())
+	}
+	return reason
+}
+
+func (l *rejectionLog) Counts() map[string]uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	counts := make(map[string]uint64, len(l.counts))
+	for r, c := range l.counts {
+		counts[r] = c
+	}
+	return counts
+}
+
+func RejectionCounts() map[string]uint64 {
+	return rejections.Counts()
+}
@@ -0,0 +1,180 @@
+package kernel
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectionLog(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	rejections.enable(true)
+	defer rejections.enable(false)
+
+	build := func() *common.SignedTransaction {
+		return &common.SignedTransaction{Transaction: *common.NewTransaction(common.XINAssetId)}
+	}
+	version := build()
+	version.Version = 2
+	extra := build()
+	extra.Extra = make([]byte, common.ExtraSizeLimit+1)
+	missing := build()
+	missing.AddInput(crypto.NewHash([]byte("missing")), 0)
+	missing.Signatures = [][]crypto.Signature{{}}
+	signature := build()
+	signature.AddInput(crypto.NewHash([]byte("missing")), 0)
+	amount := build()
+	amount.Outputs = []*common.Output{{Type: common.OutputTypeScript, Amount: common.NewInteger(0)}}
+	inputs := build()
+	for i := 0; i <= config.TransactionMaxInputs; i++ {
+		inputs.AddInput(crypto.NewHash([]byte("missing")), i)
+	}
+	outputs := build()
+	for i := 0; i <= config.TransactionMaxOutputs; i++ {
+		outputs.Outputs = append(outputs.Outputs, &common.Output{Type: common.OutputTypeScript, Amount: common.NewInteger(1)})
+	}
+	size := build()
+	size.Outputs = []*common.Output{{Type: common.OutputTypeScript, Amount: common.NewInteger(1)}}
+	for len(size.Outputs[0].Keys)*len(crypto.Key{}) <= config.TransactionMaximumSize {
+		size.Outputs[0].Keys = append(size.Outputs[0].Keys, randomTestKey().Public())
+	}
+	key := randomTestKey().Public()
+	outputKey := build()
+	outputKey.Outputs = []*common.Output{{Type: common.OutputTypeScript, Amount: common.NewInteger(1), Keys: []crypto.Key{key, key}}}
+
+	common.SetMinimumFee("1")
+	defer common.SetMinimumFee("0")
+	spend := func(script common.Script, amount int) *common.SignedTransaction {
+		priv := randomTestKey()
+		mint := testMintTransaction(common.XINAssetId, 1000)
+		mint.Outputs[0].Keys = []crypto.Key{priv.Public()}
+		mint.Outputs[0].Script = script
+		testWriteSnapshot(t, node, mint)
+		tx := testSpendTransaction(mint.PayloadHash(), amount)
+		tx.Outputs[0].Keys = []crypto.Key{randomTestKey().Public()}
+		tx.Outputs[0].Script = common.ScriptThreshold{Required: 1}.Compile()
+		msg := common.MsgpackMarshalPanic(tx.Transaction)
+		tx.Signatures = [][]crypto.Signature{{priv.Sign(msg)}}
+		return tx
+	}
+	until := uint64(time.Now().Add(time.Hour).UnixNano())
+	timeLock := spend(common.ScriptTimeLock{Required: 1, Until: until}.Compile(), 1000)
+	fee := spend(common.ScriptThreshold{Required: 1}.Compile(), 1000)
+
+	before := RejectionCounts()
+	for _, c := range []struct {
+		tx     *common.SignedTransaction
+		reason string
+	}{
+		{version, RejectReasonVersion},
+		{extra, RejectReasonExtra},
+		{missing, RejectReasonMissingInput},
+		{signature, RejectReasonSignature},
+		{amount, RejectReasonAmount},
+		{inputs, RejectReasonInputs},
+		{outputs, RejectReasonOutputs},
+		{size, RejectReasonSize},
+		{outputKey, RejectReasonOutputKey},
+		{timeLock, RejectReasonTimeLock},
+		{fee, RejectReasonFee},
+	} {
+		rejections.mutex.Lock()
+		rejections.logged = 0
+		rejections.mutex.Unlock()
+		buf.Reset()
+		_, err := QueueTransaction(node.store, c.tx)
+		assert.NotNil(err)
+		assert.Equal(c.reason, rejectReason(err))
+		assert.Contains(buf.String(), "TRANSACTION REJECTED "+c.tx.PayloadHash().String()+" "+c.reason)
+		assert.Equal(before[c.reason]+1, RejectionCounts()[c.reason])
+	}
+	assert.Equal(RejectReasonOther, rejectReason(errors.New("unknown")))
+
+	spent := spend(common.ScriptThreshold{Required: 1}.Compile(), 999)
+	assert.Nil(spent.Validate(node.store))
+	_, err := node.store.LockUTXO(spent.Inputs[0].Hash, 0, crypto.NewHash([]byte("spent")), false)
+	assert.Nil(err)
+	assert.Nil(node.store.CachePutTransaction(spent))
+	rejections.mutex.Lock()
+	rejections.logged = 0
+	rejections.mutex.Unlock()
+	buf.Reset()
+	before = RejectionCounts()
+	_, err = node.checkCacheSnapshotTransaction(&common.Snapshot{NodeId: node.IdForNetwork, Transaction: spent.PayloadHash()})
+	assert.NotNil(err)
+	assert.Equal(RejectReasonSpentInput, rejectReason(err))
+	assert.Contains(buf.String(), "TRANSACTION REJECTED "+spent.PayloadHash().String()+" "+RejectReasonSpentInput)
+	assert.Equal(before[RejectReasonSpentInput]+1, RejectionCounts()[RejectReasonSpentInput])
+
+	buf.Reset()
+	rejections.mutex.Lock()
+	rejections.logged = 0
+	rejections.mutex.Unlock()
+	before = RejectionCounts()
+	for i := 0; i < RejectionLogLimit*2; i++ {
+		_, err := QueueTransaction(node.store, extra)
+		assert.NotNil(err)
+	}
+	lines := strings.Count(buf.String(), "TRANSACTION REJECTED")
+	assert.True(lines <= RejectionLogLimit)
+	assert.True(lines > 0)
+	assert.Equal(before[RejectReasonExtra]+RejectionLogLimit*2, RejectionCounts()[RejectReasonExtra])
+
+	rejections.enable(false)
+	buf.Reset()
+	_, err = QueueTransaction(node.store, version)
+	assert.NotNil(err)
+	assert.NotContains(buf.String(), "TRANSACTION REJECTED")
+}
+
+func TestMempoolLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	assert.Equal(config.TransactionMaxInputs, node.custom.MaxInputs)
+	assert.Equal(config.TransactionMaxOutputs, node.custom.MaxOutputs)
+	node.custom.MaxInputs = 1
+	node.custom.MaxOutputs = 1
+
+	tx := &common.SignedTransaction{Transaction: *common.NewTransaction(common.XINAssetId)}
+	tx.AddInput(crypto.NewHash([]byte("missing")), 0)
+	tx.Outputs = []*common.Output{{Type: common.OutputTypeScript}, {Type: common.OutputTypeScript}}
+	err := tx.Validate(node.store)
+	assert.NotNil(err)
+	assert.False(errors.Is(err, common.ErrTooManyOutputs))
+	_, err = node.QueueTransaction(tx)
+	assert.True(errors.Is(err, common.ErrTooManyOutputs))
+	assert.Equal("invalid tx outputs count 2/1", err.Error())
+
+	tx.Outputs = tx.Outputs[:1]
+	tx.AddInput(crypto.NewHash([]byte("missing")), 1)
+	err = tx.Validate(node.store)
+	assert.NotNil(err)
+	assert.False(errors.Is(err, common.ErrTooManyInputs))
+	_, err = node.QueueTransaction(tx)
+	assert.True(errors.Is(err, common.ErrTooManyInputs))
+	assert.Equal("invalid tx inputs count 2/1", err.Error())
+	counts := RejectionCounts()
+	assert.True(counts[RejectReasonInputs] > 0)
+	assert.True(counts[RejectReasonOutputs] > 0)
+}
@@ -0,0 +1,105 @@
+package kernel
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayPolicyAnnounce(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+	assert.Equal(config.RelayPolicyFull, node.custom.RelayPolicy)
+	node.custom.RelayPolicy = config.RelayPolicyAnnounce
+
+	peerDir, err := ioutil.TempDir("", "mixin-kernel-test")
+	assert.Nil(err)
+	defer os.RemoveAll(peerDir)
+	data, err := ioutil.ReadFile(dir + "/genesis.json")
+	assert.Nil(err)
+	err = ioutil.WriteFile(peerDir+"/genesis.json", data, 0644)
+	assert.Nil(err)
+	testWriteConfig(t, peerDir, signers[1].PrivateSpendKey.String())
+	testWriteNodes(t, peerDir, nil)
+	store, err := storage.NewBadgerStore(peerDir)
+	assert.Nil(err)
+	defer store.Close()
+	peer, err := SetupNode(store, "127.0.0.1:17239", peerDir)
+	assert.Nil(err)
+	assert.Equal(node.networkId, peer.networkId)
+
+	var announced []*common.SnapshotWithTopologicalOrder
+	for i := 0; i < 5; i++ {
+		tx := testMintTransaction(common.XINAssetId, uint64(100+i))
+		announced = append(announced, testWriteSnapshot(t, node, tx))
+	}
+	tip := announced[len(announced)-1].TopologicalOrder
+
+	for _, s := range announced {
+		tx, err := peer.readRelayTransaction(s.Transaction)
+		assert.Nil(err)
+		assert.Nil(tx)
+		tx, err = node.readRelayTransaction(s.Transaction)
+		assert.Nil(err)
+		assert.NotNil(tx)
+		assert.Equal(s.Transaction, tx.PayloadHash())
+		err = peer.CachePutTransaction(tx)
+		assert.Nil(err)
+
+		tx, err = peer.readRelayTransaction(s.Transaction)
+		assert.Nil(err)
+		assert.NotNil(tx)
+		err = peer.store.WriteTransaction(tx)
+		assert.Nil(err)
+		topo := &common.SnapshotWithTopologicalOrder{
+			Snapshot:         s.Snapshot,
+			TopologicalOrder: peer.TopoCounter.Next(),
+		}
+		err = peer.store.WriteSnapshot(topo)
+		assert.Nil(err)
+		assert.Equal(s.TopologicalOrder, topo.TopologicalOrder)
+	}
+
+	expected, err := node.ReplayTo(context.Background(), tip)
+	assert.Nil(err)
+	state, err := peer.ReplayTo(context.Background(), tip)
+	assert.Nil(err)
+	assert.Equal(expected, state)
+	base, err := peer.ReplayTo(context.Background(), announced[0].TopologicalOrder-1)
+	assert.Nil(err)
+	assert.Equal(common.NewInteger(510), state.Balances[common.XINAssetId].Sub(base.Balances[common.XINAssetId]))
+}
+
+func TestRelayPolicyMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	neighbor := signers[1].Hash().ForNetwork(node.networkId)
+	node.Peer.AddNeighbor(neighbor, "127.0.0.1:1")
+	backlog := node.Peer.GossipBacklog(neighbor)
+	assert.Equal(0, backlog["control"])
+	assert.Equal(0, backlog["snapshot"])
+
+	for i, policy := range []string{config.RelayPolicyFull, config.RelayPolicyAnnounce} {
+		node.custom.RelayPolicy = policy
+		tx := testMintTransaction(common.XINAssetId, uint64(i+1))
+		s := &common.Snapshot{NodeId: node.IdForNetwork, Transaction: tx.PayloadHash()}
+		err := node.signSelfSnapshot(s, tx)
+		assert.Nil(err)
+		backlog = node.Peer.GossipBacklog(neighbor)
+		assert.Equal(1, backlog["control"], policy)
+		assert.Equal(i+1, backlog["snapshot"], policy)
+	}
+}
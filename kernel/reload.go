@@ -0,0 +1,49 @@
+package kernel
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// ReloadConfig re-reads the config directory and applies only the settings
+// safe to change at runtime, i.e. new neighbors in nodes.json. The signer and
+// the snapshot verifiers are kept as is, and the genesis is never reloaded.
+func (node *Node) ReloadConfig() error {
+	signer, err := node.readSignerFromConfig()
+	if err != nil {
+		return err
+	}
+	if signer.String() != node.Signer.String() {
+		logger.Printf("RELOAD ignore immutable signer change %s => %s\n", node.Signer.String(), signer.String())
+	}
+
+	custom, err := config.Initialize(node.configDir + "/config.json")
+	if err != nil {
+		return err
+	}
+	if custom.SnapshotVerifiers != node.custom.SnapshotVerifiers {
+		logger.Printf("RELOAD ignore immutable snapshot-verifiers change %d => %d\n", node.custom.SnapshotVerifiers, custom.SnapshotVerifiers)
+	}
+
+	return node.AddNeighborsFromConfig()
+}
+
+func (node *Node) LoopReloadSignal() error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	return node.loopReload(sig)
+}
+
+func (node *Node) loopReload(sig <-chan os.Signal) error {
+	for range sig {
+		err := node.ReloadConfig()
+		if err != nil {
+			logger.Println("RELOAD config error", err)
+		}
+	}
+	return nil
+}
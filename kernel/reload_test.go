@@ -0,0 +1,114 @@
+package kernel
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	go node.loopReload(sig)
+
+	for i := 1; i < 4; i++ {
+		id := signers[i].Hash().ForNetwork(node.networkId)
+		assert.Nil(node.Peer.GetNeighbor(id))
+	}
+	testWriteConfig(t, dir, randomTestKey().String())
+	testWriteNodes(t, dir, signers[1:4])
+	err := syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	assert.Nil(err)
+
+	for i := 1; i < 4; i++ {
+		id := signers[i].Hash().ForNetwork(node.networkId)
+		for n := 0; n < 100 && node.Peer.GetNeighbor(id) == nil; n++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.NotNil(node.Peer.GetNeighbor(id))
+	}
+	assert.Nil(node.Peer.GetNeighbor(signers[4].Hash().ForNetwork(node.networkId)))
+	assert.Equal(signers[0].String(), node.Signer.String())
+}
+
+func testSetupNode(t testing.TB) (*Node, []common.Address, string) {
+	dir, err := ioutil.TempDir("", "mixin-kernel-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	gns, _, err := GenerateTestGenesis(MinimumNodeCount, seed, time.Now().Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signers []common.Address
+	for _, in := range gns.Nodes {
+		signers = append(signers, in.Signer)
+	}
+	data, err := json.Marshal(gns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(dir+"/genesis.json", data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testWriteConfig(t, dir, signers[0].PrivateSpendKey.String())
+	testWriteNodes(t, dir, nil)
+
+	store, err := storage.NewBadgerStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := SetupNode(store, "127.0.0.1:17239", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(node.buffer.Close)
+	return node, signers, dir
+}
+
+func testWriteConfig(t testing.TB, dir string, signer string) {
+	data := fmt.Sprintf(`{"signer":"%s"}`, signer)
+	err := ioutil.WriteFile(dir+"/config.json", []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWriteNodes(t testing.TB, dir string, signers []common.Address) {
+	nodes := make([]map[string]string, 0)
+	for i, a := range signers {
+		nodes = append(nodes, map[string]string{
+			"host":   fmt.Sprintf("127.0.0.1:%d", 17240+i),
+			"signer": a.String(),
+		})
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(dir+"/nodes.json", data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package kernel
+
+import (
+	"sync"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// RoundReferenceChange is emitted when the empty head round of a node is
+// switched to other references by a finalized snapshot during catch-up.
+type RoundReferenceChange struct {
+	NodeId crypto.Hash       `json:"node"`
+	Number uint64            `json:"number"`
+	Old    *common.RoundLink `json:"old"`
+	New    *common.RoundLink `json:"new"`
+}
+
+// referenceFeed never blocks the consensus, a change is dropped for the
+// subscriber whose channel is full.
+type referenceFeed struct {
+	mutex       *sync.Mutex
+	next        int
+	subscribers map[int]chan *RoundReferenceChange
+}
+
+func newReferenceFeed() *referenceFeed {
+	return &referenceFeed{
+		mutex:       new(sync.Mutex),
+		subscribers: make(map[int]chan *RoundReferenceChange),
+	}
+}
+
+// SubscribeReferenceChanges returns the channel of the round reference
+// changes, and the function to cancel the subscription.
+func (node *Node) SubscribeReferenceChanges(size int) (<-chan *RoundReferenceChange, func()) {
+	f := node.references
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	id := f.next
+	f.next = f.next + 1
+	ch := make(chan *RoundReferenceChange, size)
+	f.subscribers[id] = ch
+	return ch, func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if f.subscribers[id] != nil {
+			delete(f.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// the genesis rounds 0 are final and have no references to change
+func (f *referenceFeed) emit(c *RoundReferenceChange) {
+	if c.Number == 0 || c.Old == nil || c.Old.Equal(c.New) {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, ch := range f.subscribers {
+		select {
+		case ch <- c:
+		default:
+			logger.Printf("ROUND REFERENCE CHANGE dropped %s %d\n", c.NodeId.String(), c.Number)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeReferenceChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	changes, cancel := node.SubscribeReferenceChanges(8)
+	idle, cancelIdle := node.SubscribeReferenceChanges(8)
+	cancelIdle()
+
+	peer := signers[1].Hash().ForNetwork(node.networkId)
+	cache := node.Graph.CacheRound[peer].Copy()
+	assert.Equal(uint64(1), cache.Number)
+	assert.Len(cache.Snapshots, 0)
+	old := cache.References
+
+	var external crypto.Hash
+	for _, in := range signers {
+		id := in.Hash().ForNetwork(node.networkId)
+		if id == peer {
+			continue
+		}
+		head, err := node.store.ReadRound(id)
+		assert.Nil(err)
+		if head.References.Self != old.External {
+			external = head.References.Self
+			break
+		}
+	}
+	assert.True(external.HasValue())
+
+	genesis := &common.Snapshot{
+		NodeId:      peer,
+		RoundNumber: 0,
+		References:  &common.RoundLink{Self: old.Self, External: external},
+	}
+	err := node.handleSyncFinalSnapshot(genesis)
+	assert.Nil(err)
+	assert.Len(changes, 0)
+
+	s := &common.Snapshot{
+		NodeId:      peer,
+		Transaction: crypto.NewHash([]byte("reference-change")),
+		RoundNumber: 1,
+		References:  &common.RoundLink{Self: old.Self, External: external},
+	}
+	err = node.handleSyncFinalSnapshot(s)
+	assert.Nil(err)
+	assert.Len(changes, 1)
+	change := <-changes
+	assert.Equal(peer, change.NodeId)
+	assert.Equal(uint64(1), change.Number)
+	assert.Equal(old.Self, change.Old.Self)
+	assert.Equal(old.External, change.Old.External)
+	assert.Equal(old.Self, change.New.Self)
+	assert.Equal(external, change.New.External)
+	head, err := node.store.ReadRound(peer)
+	assert.Nil(err)
+	assert.Equal(external, head.References.External)
+	_, open := <-idle
+	assert.False(open)
+
+	cancel()
+	_, open = <-changes
+	assert.False(open)
+}
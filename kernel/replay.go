@@ -0,0 +1,152 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type StateSnapshot struct {
+	Topology uint64                         `json:"topology"`
+	Balances map[crypto.Hash]common.Integer `json:"balances"`
+	Nodes    map[crypto.Hash]common.Integer `json:"nodes"`
+}
+
+type replayOutput struct {
+	typ    uint8
+	asset  crypto.Hash
+	amount common.Integer
+	node   crypto.Hash
+}
+
+// ReplayTo rebuilds the state from genesis by applying the transactions of
+// all snapshots until the topological order topo inclusively to an in-memory
+// UTXO set, without reading any UTXO from the store. The balances are the sum
+// of unspent outputs for each asset, and a node is active as long as its node
+// accept output is unspent.
+func (node *Node) ReplayTo(ctx context.Context, topo uint64) (*StateSnapshot, error) {
+	if seq := node.store.TopologySequence(); topo >= seq {
+		return nil, fmt.Errorf("topology not reached yet %d %d", topo, seq)
+	}
+
+	utxos := make(map[string]*replayOutput)
+	err := node.replaySnapshots(ctx, topo, utxos, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &StateSnapshot{
+		Topology: topo,
+		Balances: make(map[crypto.Hash]common.Integer),
+		Nodes:    make(map[crypto.Hash]common.Integer),
+	}
+	for _, out := range utxos {
+		state.Balances[out.asset] = state.Balances[out.asset].Add(out.amount)
+		if out.node.HasValue() {
+			state.Nodes[out.node] = out.amount
+		}
+	}
+	return state, nil
+}
+
+// replaySnapshots applies the snapshots from genesis until topo inclusively to
+// the UTXO set, and calls the hook with each transition if it's not nil.
+func (node *Node) replaySnapshots(ctx context.Context, topo uint64, utxos map[string]*replayOutput, hook func(*StateTransition) error) error {
+	next := uint64(0)
+	err := node.store.ScanSnapshots(ctx, 0, topo, func(s *common.SnapshotWithTopologicalOrder) error {
+		if s.TopologicalOrder != next {
+			return fmt.Errorf("snapshot not found at %d", next)
+		}
+		next = s.TopologicalOrder + 1
+		return node.replayTransaction(utxos, s, hook)
+	})
+	if err != nil {
+		return err
+	}
+	if next <= topo {
+		return fmt.Errorf("snapshot not found at %d", next)
+	}
+	return nil
+}
+
+func (node *Node) replayTransaction(utxos map[string]*replayOutput, s *common.SnapshotWithTopologicalOrder, hook func(*StateTransition) error) error {
+	hash := s.Transaction
+	tx, err := node.store.ReadTransaction(hash)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return fmt.Errorf("snapshot transaction not found %s", hash.String())
+	}
+
+	kind := StateTransitionOutput
+	for _, in := range tx.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || len(in.Mint) > 0 || len(in.Rebate) > 0 {
+			kind = StateTransitionMint
+			continue
+		}
+		key := fmt.Sprintf("%s:%d", in.Hash.String(), in.Index)
+		out := utxos[key]
+		if out == nil {
+			return fmt.Errorf("replay input not found %s", key)
+		}
+		delete(utxos, key)
+		if hook == nil {
+			continue
+		}
+		err := hook(&StateTransition{
+			Topology:    s.TopologicalOrder,
+			Snapshot:    s.Hash,
+			Transaction: hash,
+			Kind:        StateTransitionSpend,
+			Hash:        in.Hash,
+			Index:       in.Index,
+			Type:        out.typ,
+			Asset:       out.asset,
+			Amount:      out.amount,
+			Delta:       "-" + out.amount.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, out := range tx.Outputs {
+		if out.Type == common.OutputTypeWithdrawal {
+			continue
+		}
+		ro := &replayOutput{typ: out.Type, asset: tx.Asset, amount: out.Amount}
+		if out.Type == common.OutputTypeNodeAccept {
+			var signer common.Address
+			if len(tx.Extra) != len(signer.PublicSpendKey)*2 {
+				return fmt.Errorf("invalid node accept extra size %d", len(tx.Extra))
+			}
+			copy(signer.PublicSpendKey[:], tx.Extra)
+			signer.PrivateViewKey = signer.PublicSpendKey.DeterministicHashDerive()
+			signer.PublicViewKey = signer.PrivateViewKey.Public()
+			ro.node = signer.Hash().ForNetwork(node.networkId)
+		}
+		utxos[fmt.Sprintf("%s:%d", hash.String(), i)] = ro
+		if hook == nil {
+			continue
+		}
+		err := hook(&StateTransition{
+			Topology:    s.TopologicalOrder,
+			Snapshot:    s.Hash,
+			Transaction: hash,
+			Kind:        kind,
+			Hash:        hash,
+			Index:       i,
+			Type:        out.Type,
+			Asset:       tx.Asset,
+			Amount:      out.Amount,
+			Delta:       out.Amount.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
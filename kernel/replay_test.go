@@ -0,0 +1,53 @@
+package kernel
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayTo(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	last := uint64(gns.ExpectedSnapshotCount() - 1)
+	state, err := node.ReplayTo(context.Background(), last)
+	assert.Nil(err)
+	assert.Equal(last, state.Topology)
+	assert.Len(state.Nodes, len(signers))
+	for _, signer := range signers {
+		id := signer.Hash().ForNetwork(node.networkId)
+		assert.Equal(common.NewInteger(PledgeAmount), state.Nodes[id])
+	}
+	assert.Len(state.Balances, 1)
+	total := common.NewInteger(PledgeAmount * uint64(len(signers))).Add(common.NewInteger(50000))
+	assert.Equal(total, state.Balances[common.XINAssetId])
+	supply, err := node.AssetSupplyAt(common.XINAssetId, last)
+	assert.Nil(err)
+	assert.Equal(supply, state.Balances[common.XINAssetId])
+
+	state, err = node.ReplayTo(context.Background(), 0)
+	assert.Nil(err)
+	assert.Len(state.Nodes, 1)
+	assert.Equal(common.NewInteger(PledgeAmount), state.Balances[common.XINAssetId])
+
+	_, err = node.ReplayTo(context.Background(), last+1)
+	assert.NotNil(err)
+
+	asset := crypto.NewHash([]byte("replay-asset"))
+	mint := testWriteSnapshot(t, node, testMintTransaction(asset, 300))
+	state, err = node.ReplayTo(context.Background(), mint.TopologicalOrder)
+	assert.Nil(err)
+	assert.Len(state.Nodes, len(signers))
+	assert.Equal(common.NewInteger(300), state.Balances[asset])
+	assert.Equal(total, state.Balances[common.XINAssetId])
+}
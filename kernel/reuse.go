@@ -0,0 +1,35 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// checkSignerReuse returns the other networks recorded in the config with the
+// same signer key, the node id differs on each network but a signature by the
+// key is valid on all of them. It warns by default, and fails in strict mode.
+func (node *Node) checkSignerReuse() ([]string, error) {
+	var reused []string
+	for _, other := range node.custom.OtherNetworks {
+		signer, err := common.NewAddressFromString(other.Signer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid other network signer %s %s", other.Network, other.Signer)
+		}
+		if other.Network == node.networkId.String() {
+			continue
+		}
+		if signer.PublicSpendKey != node.Signer.PublicSpendKey {
+			continue
+		}
+		reused = append(reused, other.Network)
+	}
+	for _, network := range reused {
+		if node.custom.SignerReuseStrict {
+			return reused, fmt.Errorf("signer key reused on network %s", network)
+		}
+		logger.Printf("!!!!!!!! SIGNER KEY REUSED ON NETWORK %s\n", network)
+	}
+	return reused, nil
+}
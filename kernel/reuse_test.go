@@ -0,0 +1,82 @@
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogWriter struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *testLogWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *testLogWriter) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.String()
+}
+
+func TestSignerReuse(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	networkId := node.networkId
+	reused, err := node.checkSignerReuse()
+	assert.Nil(err)
+	assert.Len(reused, 0)
+	err = node.store.Close()
+	assert.Nil(err)
+
+	testnet, devnet := crypto.NewHash([]byte("testnet")), crypto.NewHash([]byte("devnet"))
+	writeConfig := func(strict bool) {
+		data := fmt.Sprintf(`{"signer":"%s","signer-reuse-strict":%t,"other-networks":[{"network":"%s","signer":"%s"},{"network":"%s","signer":"%s"},{"network":"%s","signer":"%s"}]}`,
+			signers[0].PrivateSpendKey.String(), strict,
+			testnet.String(), signers[0].String(),
+			devnet.String(), signers[1].String(),
+			networkId.String(), signers[0].String())
+		err := ioutil.WriteFile(dir+"/config.json", []byte(data), 0644)
+		assert.Nil(err)
+	}
+
+	buf := new(testLogWriter)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	writeConfig(false)
+	store, err := storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(err)
+	assert.NotNil(node)
+	assert.Contains(buf.String(), "SIGNER KEY REUSED ON NETWORK "+testnet.String())
+	assert.NotContains(buf.String(), devnet.String())
+	reused, err = node.checkSignerReuse()
+	assert.Nil(err)
+	assert.Equal([]string{testnet.String()}, reused)
+	err = store.Close()
+	assert.Nil(err)
+
+	writeConfig(true)
+	store, err = storage.NewBadgerStore(dir)
+	assert.Nil(err)
+	defer store.Close()
+	node, err = SetupNode(store, "127.0.0.1:17239", dir)
+	assert.Nil(node)
+	assert.NotNil(err)
+	assert.Equal("signer key reused on network "+testnet.String(), err.Error())
+}
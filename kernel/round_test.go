@@ -0,0 +1,95 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRoundByHash(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	finals := make([]crypto.Hash, len(signers))
+	for i, signer := range signers {
+		id := signer.Hash().ForNetwork(node.networkId)
+		topos, err := node.ReadSnapshotsForNodeRound(id, 0)
+		assert.Nil(err)
+		cache := &CacheRound{NodeId: id, Number: 0}
+		for _, topo := range topos {
+			cache.Snapshots = append(cache.Snapshots, &topo.Snapshot)
+		}
+		finals[i] = cache.asFinal().Hash
+	}
+
+	for i, signer := range signers {
+		id := signer.Hash().ForNetwork(node.networkId)
+		round, err := node.ReadRoundByHash(id)
+		assert.Nil(err)
+		assert.NotNil(round)
+		assert.Equal(id, round.Hash)
+		assert.Equal(id, round.NodeId)
+		assert.Equal(uint64(1), round.Number)
+		assert.Equal(&common.RoundLink{
+			Self:     finals[i],
+			External: finals[(i+1)%len(signers)],
+		}, round.References)
+
+		final, err := node.ReadRoundByHash(round.References.Self)
+		assert.Nil(err)
+		assert.NotNil(final)
+		assert.Equal(finals[i], final.Hash)
+		assert.Equal(id, final.NodeId)
+		assert.Equal(uint64(0), final.Number)
+		assert.Nil(final.References)
+	}
+
+	round, err := node.ReadRoundByHash(crypto.NewHash([]byte("mixin-kernel-test-round")))
+	assert.Nil(err)
+	assert.Nil(round)
+}
+
+func TestReadSnapshotsByRound(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	genesis, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	domain := genesis[len(signers)]
+	for i, signer := range signers {
+		id := signer.Hash().ForNetwork(node.networkId)
+		snapshots, err := node.ReadSnapshotsByRound(id, 0)
+		assert.Nil(err)
+		if i == 0 {
+			assert.Len(snapshots, 2)
+			assert.Equal(domain.Hash, snapshots[1].Hash)
+			assert.Equal(domain.Transaction, snapshots[1].Transaction)
+		} else {
+			assert.Len(snapshots, 1)
+		}
+		assert.Equal(genesis[i].Hash, snapshots[0].Hash)
+		assert.Equal(genesis[i].Transaction, snapshots[0].Transaction)
+		for _, s := range snapshots {
+			assert.Equal(id, s.NodeId)
+			assert.Equal(uint64(0), s.RoundNumber)
+			assert.Equal(s.PayloadHash(), s.Hash)
+		}
+
+		snapshots, err = node.ReadSnapshotsByRound(id, 1)
+		assert.Nil(err)
+		assert.Len(snapshots, 0)
+	}
+
+	snapshots, err := node.ReadSnapshotsByRound(crypto.NewHash([]byte("mixin-kernel-test-round")), 0)
+	assert.Nil(err)
+	assert.Len(snapshots, 0)
+}
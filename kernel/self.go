@@ -29,13 +29,19 @@ func (node *Node) checkCacheSnapshotTransaction(s *common.Snapshot) (*common.Sig
 		return nil, err
 	}
 
-	err = tx.Validate(node.store)
+	timestamp := s.Timestamp
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	err = tx.ValidateAt(node.store, timestamp)
 	if err != nil {
+		rejections.record(s.Transaction, err)
 		return nil, nil
 	}
 
 	err = tx.LockInputs(node.store, false)
 	if err != nil {
+		rejections.record(s.Transaction, err)
 		return nil, err
 	}
 
@@ -81,10 +87,13 @@ func (node *Node) collectSelfSignatures(s *common.Snapshot) error {
 		Snapshot:         *s,
 		TopologicalOrder: node.TopoCounter.Next(),
 	}
-	err := node.store.WriteSnapshot(topo)
+	err := node.writeStoreOrDegrade(func() error {
+		return node.store.WriteSnapshot(topo)
+	})
 	if err != nil {
 		panic(err)
 	}
+	node.markFinalized()
 	if !cache.ValidateSnapshot(s, true) {
 		panic("should never be here")
 	}
@@ -122,6 +131,18 @@ func (node *Node) determinBestRound() *FinalRound {
 	return best
 }
 
+// snapshotTimestamp truncates the time to the timestamp granularity in the
+// config, so the timestamps of new snapshots carry no finer digits.
+func (node *Node) snapshotTimestamp(t time.Time) uint64 {
+	switch node.custom.TimeGranularity {
+	case config.TimestampGranularityMicro:
+		t = t.Truncate(time.Microsecond)
+	case config.TimestampGranularityMilli:
+		t = t.Truncate(time.Millisecond)
+	}
+	return uint64(t.UnixNano())
+}
+
 func (node *Node) signSelfSnapshot(s *common.Snapshot, tx *common.SignedTransaction) error {
 	if s.NodeId != node.IdForNetwork || len(s.Signatures) != 0 || s.Timestamp != 0 {
 		panic("should never be here")
@@ -131,7 +152,7 @@ func (node *Node) signSelfSnapshot(s *common.Snapshot, tx *common.SignedTransact
 	final := node.Graph.FinalRound[s.NodeId].Copy()
 
 	for {
-		s.Timestamp = uint64(time.Now().UnixNano())
+		s.Timestamp = node.snapshotTimestamp(time.Now())
 		if s.Timestamp > cache.Timestamp {
 			break
 		}
@@ -153,7 +174,9 @@ func (node *Node) signSelfSnapshot(s *common.Snapshot, tx *common.SignedTransact
 				External: best.Hash,
 			},
 		}
-		err := node.store.StartNewRound(cache.NodeId, cache.Number, cache.References, final.Start)
+		err := node.writeStoreOrDegrade(func() error {
+			return node.store.StartNewRound(cache.NodeId, cache.Number, cache.References, final.Start)
+		})
 		if err != nil {
 			panic(err)
 		}
@@ -168,11 +191,13 @@ func (node *Node) signSelfSnapshot(s *common.Snapshot, tx *common.SignedTransact
 	node.signSnapshot(s)
 	s.Signatures = []*crypto.Signature{node.SignaturesPool[s.Hash]}
 	for peerId, _ := range node.ConsensusNodes {
-		err := node.Peer.SendTransactionMessage(peerId, tx)
-		if err != nil {
-			return err
+		if node.custom.RelayPolicy == config.RelayPolicyFull {
+			err := node.Peer.SendTransactionMessage(peerId, tx)
+			if err != nil {
+				return err
+			}
 		}
-		err = node.Peer.SendSnapshotMessage(peerId, s, 0)
+		err := node.Peer.SendSnapshotMessage(peerId, s, 0)
 		if err != nil {
 			return err
 		}
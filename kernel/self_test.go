@@ -0,0 +1,47 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotTimestampGranularity(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	now := time.Unix(1560000000, 123456789)
+	assert.Equal(config.TimestampGranularityNano, node.custom.TimeGranularity)
+	assert.Equal(uint64(1560000000123456789), node.snapshotTimestamp(now))
+	node.custom.TimeGranularity = config.TimestampGranularityMicro
+	assert.Equal(uint64(1560000000123456000), node.snapshotTimestamp(now))
+	node.custom.TimeGranularity = config.TimestampGranularityMilli
+	assert.Equal(uint64(1560000000123000000), node.snapshotTimestamp(now))
+
+	var last uint64
+	for i := 0; i < 3; i++ {
+		tx := testMintTransaction(common.XINAssetId, uint64(i+1))
+		s := &common.Snapshot{NodeId: node.IdForNetwork, Transaction: tx.PayloadHash()}
+		err := node.signSelfSnapshot(s, tx)
+		assert.Nil(err)
+		assert.Equal(uint64(0), s.Timestamp%uint64(time.Millisecond))
+		assert.True(s.Timestamp > last)
+		last = s.Timestamp
+	}
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	epoch := uint64(time.Unix(gns.Epoch, 0).UnixNano())
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	for _, s := range snapshots {
+		assert.Contains([]uint64{epoch, epoch + DomainSnapshotTimestampOffset}, s.Timestamp)
+	}
+}
@@ -0,0 +1,47 @@
+package kernel
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+// LoopShutdownSignal shuts the node down on SIGINT or SIGTERM, a second signal
+// kills the process as usual if the shutdown hangs.
+func (node *Node) LoopShutdownSignal() error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	s := <-sig
+	signal.Stop(sig)
+	logger.Println("SHUTDOWN on signal", s)
+	node.Shutdown()
+	return nil
+}
+
+// Shutdown says goodbye to all neighbors, so they know the node left instead
+// of crashed, stops the snapshot buffer and closes the metrics backend, then
+// makes the kernel loop return, and the store should be closed by the caller of
+// the loop. It's safe to call more than once.
+func (node *Node) Shutdown() {
+	node.shutdown.Do(func() {
+		if node.custom.AddressBook {
+			err := node.SaveAddressBook()
+			if err != nil {
+				logger.Println("SHUTDOWN address book error", err)
+			}
+		}
+		err := node.Peer.Shutdown()
+		if err != nil {
+			logger.Println("SHUTDOWN goodbye error", err)
+		}
+		node.buffer.Close()
+		err = metrics.Close()
+		if err != nil {
+			logger.Println("SHUTDOWN metrics error", err)
+		}
+		close(node.done)
+	})
+}
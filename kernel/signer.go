@@ -0,0 +1,45 @@
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type SignerRecord struct {
+	Signer      common.Address `json:"signer"`
+	Transaction crypto.Hash    `json:"transaction"`
+	Snapshot    crypto.Hash    `json:"snapshot"`
+	Timestamp   uint64         `json:"timestamp"`
+}
+
+// NodeSignerHistory lists the signer keys of the node from the oldest to the
+// current one. There is no rotation in consensus yet, so it is always the
+// signer in the node accept transaction.
+func (node *Node) NodeSignerHistory(nodeId crypto.Hash) ([]SignerRecord, error) {
+	status, err := node.NodePledgeStatus(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := node.store.ReadTransaction(status.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("node accept transaction not found %s", status.Transaction.String())
+	}
+
+	signer := node.ConsensusNodes[nodeId].Signer
+	size := len(signer.PublicSpendKey)
+	if len(tx.Extra) != size*2 || !bytes.Equal(tx.Extra[:size], signer.PublicSpendKey[:]) {
+		return nil, fmt.Errorf("node accept signer mismatch %s", status.Transaction.String())
+	}
+	return []SignerRecord{{
+		Signer:      signer,
+		Transaction: status.Transaction,
+		Snapshot:    status.Snapshot,
+		Timestamp:   status.Timestamp,
+	}}, nil
+}
@@ -0,0 +1,35 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeSignerHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	for _, signer := range signers {
+		id := signer.Hash().ForNetwork(node.networkId)
+		history, err := node.NodeSignerHistory(id)
+		assert.Nil(err)
+		assert.Len(history, 1)
+		status, err := node.NodePledgeStatus(id)
+		assert.Nil(err)
+		record := history[0]
+		assert.Equal(signer.String(), record.Signer.String())
+		assert.Equal(status.Transaction, record.Transaction)
+		assert.Equal(status.Snapshot, record.Snapshot)
+		assert.Equal(status.Timestamp, record.Timestamp)
+	}
+
+	history, err := node.NodeSignerHistory(crypto.NewHash([]byte("unknown")))
+	assert.Nil(history)
+	assert.NotNil(err)
+}
@@ -0,0 +1,60 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type SpentOutputRecord struct {
+	TopologicalOrder uint64         `json:"topology"`
+	Transaction      crypto.Hash    `json:"transaction"`
+	Hash             crypto.Hash    `json:"hash"`
+	Index            int            `json:"index"`
+	Keys             []crypto.Key   `json:"keys"`
+	Amount           common.Integer `json:"amount"`
+	Asset            crypto.Hash    `json:"asset"`
+}
+
+// ListSpentOutputs lists the outputs spent by the snapshots with topological
+// order in [from, to], the Transaction is the spending transaction.
+func (node *Node) ListSpentOutputs(ctx context.Context, from, to uint64) ([]SpentOutputRecord, error) {
+	records := make([]SpentOutputRecord, 0)
+	err := node.store.ReadSpentOutputs(ctx, from, to, func(topo uint64, utxo *common.UTXOWithLock) error {
+		records = append(records, SpentOutputRecord{
+			TopologicalOrder: topo,
+			Transaction:      utxo.LockHash,
+			Hash:             utxo.Hash,
+			Index:            utxo.Index,
+			Keys:             utxo.Keys,
+			Amount:           utxo.Amount,
+			Asset:            utxo.Asset,
+		})
+		return nil
+	})
+	return records, err
+}
+
+// IsOutputSpent checks the output controlled by the key is spent, an output
+// locked by a transaction not finalized yet is still unspent.
+func (node *Node) IsOutputSpent(key crypto.Key) (bool, error) {
+	utxo, err := node.store.ReadGhostUTXO(key)
+	if err != nil {
+		return false, err
+	}
+	if utxo == nil {
+		return false, fmt.Errorf("output not found for key %s", key.String())
+	}
+	return node.checkUTXOSpent(utxo)
+}
+
+// checkUTXOSpent decides the spend by the finalization of the locking
+// transaction, the lock of an unfinalized or fork losing transaction is not.
+func (node *Node) checkUTXOSpent(utxo *common.UTXOWithLock) (bool, error) {
+	if !utxo.LockHash.HasValue() {
+		return false, nil
+	}
+	return node.store.CheckTransactionFinalization(utxo.LockHash)
+}
@@ -0,0 +1,101 @@
+package kernel
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSpentOutputs(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	records, err := node.ListSpentOutputs(context.Background(), 0, 100)
+	assert.Nil(err)
+	assert.Len(records, 0)
+
+	mint := testMintTransaction(common.XINAssetId, 1000)
+	testWriteSnapshot(t, node, mint)
+	testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 10))
+	spend := common.NewTransaction(common.XINAssetId)
+	spend.AddInput(mint.PayloadHash(), 0)
+	spend.Outputs = []*common.Output{
+		{Type: common.OutputTypeScript, Amount: common.NewInteger(1000), Keys: []crypto.Key{randomTestKey()}},
+	}
+	signed := &common.SignedTransaction{Transaction: *spend}
+	snap := testWriteSnapshot(t, node, signed)
+	testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 20))
+
+	records, err = node.ListSpentOutputs(context.Background(), 0, snap.TopologicalOrder-1)
+	assert.Nil(err)
+	assert.Len(records, 0)
+	records, err = node.ListSpentOutputs(context.Background(), snap.TopologicalOrder+1, 100)
+	assert.Nil(err)
+	assert.Len(records, 0)
+
+	for _, r := range [][2]uint64{{snap.TopologicalOrder, snap.TopologicalOrder}, {0, 100}} {
+		records, err = node.ListSpentOutputs(context.Background(), r[0], r[1])
+		assert.Nil(err)
+		assert.Len(records, 1)
+		record := records[0]
+		assert.Equal(snap.TopologicalOrder, record.TopologicalOrder)
+		assert.Equal(signed.PayloadHash(), record.Transaction)
+		assert.Equal(mint.PayloadHash(), record.Hash)
+		assert.Equal(0, record.Index)
+		assert.Equal(mint.Outputs[0].Keys, record.Keys)
+		assert.Equal(common.NewInteger(1000).String(), record.Amount.String())
+		assert.Equal(common.XINAssetId, record.Asset)
+	}
+}
+
+func TestIsOutputSpent(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	snapshots, err := node.GenesisSnapshots()
+	assert.Nil(err)
+	accept, err := node.store.ReadTransaction(snapshots[0].Transaction)
+	assert.Nil(err)
+	assert.Equal(uint8(common.OutputTypeNodeAccept), accept.Outputs[0].Type)
+	for _, key := range accept.Outputs[0].Keys {
+		spent, err := node.IsOutputSpent(key)
+		assert.Nil(err)
+		assert.False(spent)
+	}
+	_, err = node.IsOutputSpent(randomTestKey())
+	assert.NotNil(err)
+
+	mint := testMintTransaction(common.XINAssetId, 1000)
+	testWriteSnapshot(t, node, mint)
+	key := mint.Outputs[0].Keys[0]
+	spent, err := node.IsOutputSpent(key)
+	assert.Nil(err)
+	assert.False(spent)
+
+	spend := common.NewTransaction(common.XINAssetId)
+	spend.AddInput(mint.PayloadHash(), 0)
+	spend.Outputs = []*common.Output{
+		{Type: common.OutputTypeScript, Amount: common.NewInteger(1000), Keys: []crypto.Key{randomTestKey()}},
+	}
+	signed := &common.SignedTransaction{Transaction: *spend}
+	_, err = node.store.LockUTXO(mint.PayloadHash(), 0, signed.PayloadHash(), false)
+	assert.Nil(err)
+	spent, err = node.IsOutputSpent(key)
+	assert.Nil(err)
+	assert.False(spent)
+
+	testWriteSnapshot(t, node, signed)
+	spent, err = node.IsOutputSpent(key)
+	assert.Nil(err)
+	assert.True(spent)
+}
@@ -0,0 +1,131 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	AssetSupplyCheckpointInterval = 1024
+)
+
+// AssetSupplyAt sums the outputs minted and subtracts the outputs withdrawn by
+// all snapshots until the topological order topo inclusively. The supply at
+// every interval is saved as a checkpoint in the state store, so a query only
+// rescans the snapshots since the nearest checkpoint.
+func (node *Node) AssetSupplyAt(asset crypto.Hash, topo uint64) (common.Integer, error) {
+	return node.assetSupplyAt(asset, topo, AssetSupplyCheckpointInterval)
+}
+
+// ListAssets returns all distinct asset ids appeared in any output of the
+// snapshot transactions.
+func (node *Node) ListAssets() ([]crypto.Hash, error) {
+	return node.store.ReadAssets()
+}
+
+func (node *Node) assetSupplyAt(asset crypto.Hash, topo, interval uint64) (common.Integer, error) {
+	var supply common.Integer
+	if seq := node.store.TopologySequence(); topo >= seq {
+		return supply, fmt.Errorf("topology not reached yet %d %d", topo, seq)
+	}
+
+	var stored uint64
+	_, err := node.store.StateGet(supplyCheckpointTipKey(asset, interval), &stored)
+	if err != nil {
+		return supply, err
+	}
+	tip := stored
+	if n := (topo + 1) / interval; n < tip {
+		tip = n
+	}
+	if tip > 0 {
+		found, err := node.store.StateGet(supplyCheckpointKey(asset, interval, tip), &supply)
+		if err != nil {
+			return supply, err
+		}
+		if !found {
+			return supply, fmt.Errorf("supply checkpoint not found %s %d", asset.String(), tip)
+		}
+	}
+
+	offset, next := tip*interval, (tip+1)*interval
+	for {
+		snapshots, err := node.store.ReadSnapshotsSinceTopology(offset, 100)
+		if err != nil {
+			return supply, err
+		}
+		for _, s := range snapshots {
+			if s.TopologicalOrder > topo {
+				return supply, nil
+			}
+			for ; s.TopologicalOrder >= next; next += interval {
+				if n := next / interval; n > stored {
+					err := node.writeSupplyCheckpoint(asset, interval, n, supply)
+					if err != nil {
+						return supply, err
+					}
+					stored = n
+				}
+			}
+			supply, err = node.applySnapshotSupply(asset, supply, s.Transaction)
+			if err != nil {
+				return supply, err
+			}
+			offset = s.TopologicalOrder + 1
+		}
+		if len(snapshots) < 100 {
+			return supply, nil
+		}
+	}
+}
+
+func (node *Node) applySnapshotSupply(asset crypto.Hash, supply common.Integer, hash crypto.Hash) (common.Integer, error) {
+	tx, err := node.store.ReadTransaction(hash)
+	if err != nil {
+		return supply, err
+	}
+	if tx == nil {
+		return supply, fmt.Errorf("snapshot transaction not found %s", hash.String())
+	}
+	if tx.Asset != asset {
+		return supply, nil
+	}
+	for _, out := range tx.Outputs {
+		if out.Type == common.OutputTypeWithdrawal {
+			continue
+		}
+		supply = supply.Add(out.Amount)
+	}
+	for _, in := range tx.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || len(in.Mint) > 0 || len(in.Rebate) > 0 {
+			continue
+		}
+		utxo, err := node.store.ReadUTXO(in.Hash, in.Index)
+		if err != nil {
+			return supply, err
+		}
+		if utxo == nil {
+			return supply, fmt.Errorf("input not found %s:%d", in.Hash.String(), in.Index)
+		}
+		supply = supply.Sub(utxo.Amount)
+	}
+	return supply, nil
+}
+
+func (node *Node) writeSupplyCheckpoint(asset crypto.Hash, interval, n uint64, supply common.Integer) error {
+	err := node.store.StateSet(supplyCheckpointKey(asset, interval, n), supply)
+	if err != nil {
+		return err
+	}
+	return node.store.StateSet(supplyCheckpointTipKey(asset, interval), n)
+}
+
+func supplyCheckpointKey(asset crypto.Hash, interval, n uint64) string {
+	return fmt.Sprintf("supply-%s-%d-%d", asset.String(), interval, n)
+}
+
+func supplyCheckpointTipKey(asset crypto.Hash, interval uint64) string {
+	return fmt.Sprintf("supply-%s-%d", asset.String(), interval)
+}
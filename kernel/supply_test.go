@@ -0,0 +1,151 @@
+package kernel
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssetSupplyAt(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	genesis := common.NewInteger(PledgeAmount*MinimumNodeCount + 50000)
+	assert.Equal(uint64(MinimumNodeCount+1), node.store.TopologySequence())
+
+	mint := testMintTransaction(common.XINAssetId, 1000)
+	testWriteSnapshot(t, node, mint)
+	spend := common.NewTransaction(common.XINAssetId)
+	spend.AddInput(mint.PayloadHash(), 0)
+	spend.Outputs = []*common.Output{
+		{Type: common.OutputTypeWithdrawal, Amount: common.NewInteger(300)},
+		{Type: common.OutputTypeScript, Amount: common.NewInteger(700), Keys: []crypto.Key{randomTestKey()}},
+	}
+	testWriteSnapshot(t, node, &common.SignedTransaction{Transaction: *spend})
+	other := testMintTransaction(crypto.NewHash([]byte("other-asset")), 5000)
+	testWriteSnapshot(t, node, other)
+	testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 20))
+
+	expected := []common.Integer{
+		common.NewInteger(PledgeAmount),
+		common.NewInteger(PledgeAmount * 2),
+		common.NewInteger(PledgeAmount * 7),
+		genesis,
+		genesis.Add(common.NewInteger(1000)),
+		genesis.Add(common.NewInteger(700)),
+		genesis.Add(common.NewInteger(700)),
+		genesis.Add(common.NewInteger(720)),
+	}
+	topos := []uint64{0, 1, 6, 7, 8, 9, 10, 11}
+	for _, interval := range []uint64{1, 3, 5, AssetSupplyCheckpointInterval} {
+		for round := 0; round < 2; round++ {
+			for i, topo := range topos {
+				supply, err := node.assetSupplyAt(common.XINAssetId, topo, interval)
+				assert.Nil(err)
+				assert.Equal(expected[i].String(), supply.String())
+			}
+			for i := len(topos) - 1; i >= 0; i-- {
+				supply, err := node.assetSupplyAt(common.XINAssetId, topos[i], interval)
+				assert.Nil(err)
+				assert.Equal(expected[i].String(), supply.String())
+			}
+		}
+	}
+
+	supply, err := node.AssetSupplyAt(other.Asset, 11)
+	assert.Nil(err)
+	assert.Equal(common.NewInteger(5000).String(), supply.String())
+	supply, err = node.AssetSupplyAt(other.Asset, 9)
+	assert.Nil(err)
+	assert.Equal(0, supply.Sign())
+	_, err = node.AssetSupplyAt(common.XINAssetId, 12)
+	assert.NotNil(err)
+}
+
+func testMintTransaction(asset crypto.Hash, amount uint64) *common.SignedTransaction {
+	key := randomTestKey()
+	seed := crypto.NewHash(key[:])
+	tx := common.NewTransaction(asset)
+	tx.Inputs = []*common.Input{{Genesis: seed[:]}}
+	tx.Outputs = []*common.Output{{
+		Type:   common.OutputTypeScript,
+		Amount: common.NewInteger(amount),
+		Script: common.Script{common.OperatorCmp, common.OperatorSum, 1},
+		Keys:   []crypto.Key{randomTestKey().Public()},
+	}}
+	return &common.SignedTransaction{Transaction: *tx}
+}
+
+// testWriteSnapshot finalizes the transaction in the cache round of the node
+// itself, bypassing the consensus and all transaction validations.
+func testWriteSnapshot(t *testing.T, node *Node, tx *common.SignedTransaction) *common.SnapshotWithTopologicalOrder {
+	hash := tx.PayloadHash()
+	for _, in := range tx.Inputs {
+		if len(in.Genesis) > 0 {
+			continue
+		}
+		_, err := node.store.LockUTXO(in.Hash, in.Index, hash, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := node.store.WriteTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := node.store.ReadRound(node.IdForNetwork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := &common.SnapshotWithTopologicalOrder{
+		Snapshot: common.Snapshot{
+			NodeId:      node.IdForNetwork,
+			Transaction: hash,
+			References:  cache.References,
+			RoundNumber: cache.Number,
+			Timestamp:   uint64(time.Now().UnixNano()),
+		},
+		TopologicalOrder: node.TopoCounter.Next(),
+	}
+	snap.Hash = snap.PayloadHash()
+	err = node.store.WriteSnapshot(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func TestListAssets(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	assets, err := node.ListAssets()
+	assert.Nil(err)
+	assert.Equal([]crypto.Hash{common.XINAssetId}, assets)
+
+	asset := crypto.NewHash([]byte("list-assets"))
+	testWriteSnapshot(t, node, testMintTransaction(asset, 100))
+	testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 100))
+	assets, err = node.ListAssets()
+	assert.Nil(err)
+	assert.Len(assets, 2)
+	assert.Contains(assets, common.XINAssetId)
+	assert.Contains(assets, asset)
+
+	err = node.store.IndexAssets(context.Background())
+	assert.Nil(err)
+	rebuilt, err := node.ListAssets()
+	assert.Nil(err)
+	assert.Equal(assets, rebuilt)
+}
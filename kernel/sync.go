@@ -0,0 +1,147 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	SyncStatusSynced = "synced"
+	SyncStatusBehind = "behind"
+	SyncStatusAhead  = "ahead"
+	SyncStatusForked = "forked"
+
+	SyncVerifySamples = 16
+)
+
+// SyncSource reads the topology sequence, i.e. the next topological order,
+// and the snapshot hash at a topological order, of either the local store or
+// a remote peer.
+type SyncSource interface {
+	TopologySequence() (uint64, error)
+	SnapshotHash(topology uint64) (crypto.Hash, error)
+}
+
+type SyncReport struct {
+	Status        string      `json:"status"`
+	LocalTopology uint64      `json:"local"`
+	PeerTopology  uint64      `json:"peer"`
+	Gap           uint64      `json:"gap"`
+	Divergence    uint64      `json:"divergence"`
+	LocalHash     crypto.Hash `json:"local_hash"`
+	PeerHash      crypto.Hash `json:"peer_hash"`
+}
+
+type storeSyncSource struct {
+	store storage.Store
+}
+
+func NewStoreSyncSource(store storage.Store) SyncSource {
+	return &storeSyncSource{store: store}
+}
+
+func (s *storeSyncSource) TopologySequence() (uint64, error) {
+	return s.store.TopologySequence(), nil
+}
+
+func (s *storeSyncSource) SnapshotHash(topology uint64) (crypto.Hash, error) {
+	snapshots, err := s.store.ReadSnapshotsSinceTopology(topology, 1)
+	if err != nil || len(snapshots) != 1 || snapshots[0].TopologicalOrder != topology {
+		return crypto.Hash{}, err
+	}
+	return snapshots[0].Hash, nil
+}
+
+// VerifySync compares the snapshot hashes sampled at several topological
+// orders both have, a mismatch is narrowed down to the first divergence by
+// bisection. Without a mismatch, the gap between the two tips is reported.
+func VerifySync(local, peer SyncSource, samples int) (*SyncReport, error) {
+	lt, err := local.TopologySequence()
+	if err != nil {
+		return nil, err
+	}
+	pt, err := peer.TopologySequence()
+	if err != nil {
+		return nil, err
+	}
+	report := &SyncReport{LocalTopology: lt, PeerTopology: pt}
+
+	shared := lt
+	if pt < shared {
+		shared = pt
+	}
+	if samples < 2 {
+		samples = 2
+	}
+
+	var lo uint64
+	for i := 0; shared > 0 && i < samples; i++ {
+		h := (shared - 1) * uint64(i) / uint64(samples-1)
+		if h < lo {
+			continue
+		}
+		match, err := compareSyncHash(local, peer, h, report)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			lo = h + 1
+			continue
+		}
+
+		hi := h
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			match, err := compareSyncHash(local, peer, mid, report)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		_, err = compareSyncHash(local, peer, lo, report)
+		if err != nil {
+			return nil, err
+		}
+		report.Status = SyncStatusForked
+		report.Divergence = lo
+		return report, nil
+	}
+
+	report.LocalHash, report.PeerHash = crypto.Hash{}, crypto.Hash{}
+	switch {
+	case pt > lt:
+		report.Status = SyncStatusBehind
+		report.Gap = pt - lt
+	case pt < lt:
+		report.Status = SyncStatusAhead
+		report.Gap = lt - pt
+	default:
+		report.Status = SyncStatusSynced
+	}
+	return report, nil
+}
+
+func compareSyncHash(local, peer SyncSource, topology uint64, report *SyncReport) (bool, error) {
+	lh, err := local.SnapshotHash(topology)
+	if err != nil {
+		return false, err
+	}
+	if !lh.HasValue() {
+		return false, fmt.Errorf("local snapshot not found at %d", topology)
+	}
+	ph, err := peer.SnapshotHash(topology)
+	if err != nil {
+		return false, err
+	}
+	if !ph.HasValue() {
+		return false, fmt.Errorf("peer snapshot not found at %d", topology)
+	}
+	report.LocalHash, report.PeerHash = lh, ph
+	return lh == ph, nil
+}
@@ -0,0 +1,82 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSyncSource struct {
+	hashes []crypto.Hash
+}
+
+func (s *testSyncSource) TopologySequence() (uint64, error) {
+	return uint64(len(s.hashes)), nil
+}
+
+func (s *testSyncSource) SnapshotHash(topology uint64) (crypto.Hash, error) {
+	if topology >= uint64(len(s.hashes)) {
+		return crypto.Hash{}, nil
+	}
+	return s.hashes[topology], nil
+}
+
+func TestVerifySync(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	local := NewStoreSyncSource(node.store)
+	seq, err := local.TopologySequence()
+	assert.Nil(err)
+	assert.True(seq > 4)
+	hashes := make([]crypto.Hash, seq)
+	for i := range hashes {
+		hashes[i], err = local.SnapshotHash(uint64(i))
+		assert.Nil(err)
+		assert.True(hashes[i].HasValue())
+	}
+
+	peer := &testSyncSource{hashes: hashes}
+	report, err := VerifySync(local, peer, 4)
+	assert.Nil(err)
+	assert.Equal(SyncStatusSynced, report.Status)
+	assert.Equal(seq, report.LocalTopology)
+	assert.Equal(seq, report.PeerTopology)
+
+	ahead := append([]crypto.Hash{}, hashes...)
+	for i := 0; i < 3; i++ {
+		ahead = append(ahead, crypto.NewHash([]byte{byte(i)}))
+	}
+	report, err = VerifySync(local, &testSyncSource{hashes: ahead}, 4)
+	assert.Nil(err)
+	assert.Equal(SyncStatusBehind, report.Status)
+	assert.Equal(uint64(3), report.Gap)
+	assert.Equal(seq+3, report.PeerTopology)
+
+	report, err = VerifySync(local, &testSyncSource{hashes: hashes[:seq-2]}, 4)
+	assert.Nil(err)
+	assert.Equal(SyncStatusAhead, report.Status)
+	assert.Equal(uint64(2), report.Gap)
+
+	fork := uint64(3)
+	divergent := append([]crypto.Hash{}, ahead...)
+	for i := fork; i < uint64(len(divergent)); i++ {
+		divergent[i] = crypto.NewHash(divergent[i][:])
+	}
+	for _, samples := range []int{2, 3, 16} {
+		report, err = VerifySync(local, &testSyncSource{hashes: divergent}, samples)
+		assert.Nil(err)
+		assert.Equal(SyncStatusForked, report.Status)
+		assert.Equal(fork, report.Divergence)
+		assert.Equal(hashes[fork], report.LocalHash)
+		assert.Equal(divergent[fork], report.PeerHash)
+	}
+
+	_, err = VerifySync(local, &testSyncSource{hashes: append(hashes[:1:1], crypto.Hash{})}, 4)
+	assert.NotNil(err)
+}
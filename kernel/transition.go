@@ -0,0 +1,58 @@
+package kernel
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	StateTransitionMint   = "mint"
+	StateTransitionSpend  = "spend"
+	StateTransitionOutput = "output"
+)
+
+// StateTransition is a balance change of an output, the Transaction is the
+// transaction in the snapshot, and the Hash and Index locate the output, which
+// for a spend is the output consumed by the input of the Transaction. The
+// Amount is always the output amount, while the Delta is negative for a spend.
+type StateTransition struct {
+	Topology    uint64         `json:"topology"`
+	Snapshot    crypto.Hash    `json:"snapshot"`
+	Transaction crypto.Hash    `json:"transaction"`
+	Kind        string         `json:"kind"`
+	Hash        crypto.Hash    `json:"hash"`
+	Index       int            `json:"index"`
+	Type        uint8          `json:"type"`
+	Asset       crypto.Hash    `json:"asset"`
+	Amount      common.Integer `json:"amount"`
+	Delta       string         `json:"delta"`
+}
+
+var errStopStateTransitions = errors.New("state transitions stopped")
+
+// StreamStateTransitions replays all snapshots from genesis in topological
+// order with the same replay of ReplayTo, and calls fn with each transition,
+// until fn returns false or the ctx is done. Outputs of a transaction with a
+// genesis, deposit, mint or rebate input are mints, and the amount of a spend
+// is the amount of the output replayed earlier, so the sum of the deltas of
+// all transitions of an asset is always its supply.
+func (node *Node) StreamStateTransitions(ctx context.Context, fn func(*StateTransition) bool) error {
+	seq := node.store.TopologySequence()
+	if seq == 0 {
+		return nil
+	}
+	utxos := make(map[string]*replayOutput)
+	err := node.replaySnapshots(ctx, seq-1, utxos, func(st *StateTransition) error {
+		if !fn(st) {
+			return errStopStateTransitions
+		}
+		return nil
+	})
+	if err == errStopStateTransitions {
+		return nil
+	}
+	return err
+}
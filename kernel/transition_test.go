@@ -0,0 +1,121 @@
+package kernel
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamStateTransitions(t *testing.T) {
+	assert := assert.New(t)
+
+	node, signers, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	count := uint64(gns.ExpectedSnapshotCount())
+	var transitions []*StateTransition
+	err = node.StreamStateTransitions(context.Background(), func(st *StateTransition) bool {
+		transitions = append(transitions, st)
+		return true
+	})
+	assert.Nil(err)
+	assert.Len(transitions, len(signers)+1)
+
+	var accepts int
+	supply := common.NewInteger(0)
+	for i, st := range transitions {
+		assert.Equal(StateTransitionMint, st.Kind)
+		assert.Equal(common.XINAssetId, st.Asset)
+		assert.True(st.Topology < count)
+		if i > 0 {
+			assert.True(st.Topology >= transitions[i-1].Topology)
+		}
+		switch st.Type {
+		case common.OutputTypeNodeAccept:
+			accepts++
+			assert.Equal(common.NewInteger(PledgeAmount), st.Amount)
+		case common.OutputTypeDomainAccept:
+			assert.Equal(common.NewInteger(50000), st.Amount)
+		default:
+			t.Fatalf("unexpected genesis output type %d", st.Type)
+		}
+		supply = supply.Add(st.Amount)
+	}
+	assert.Equal(len(signers), accepts)
+	state, err := node.ReplayTo(context.Background(), count-1)
+	assert.Nil(err)
+	assert.Equal(state.Balances[common.XINAssetId], supply)
+
+	mint := testMintTransaction(common.XINAssetId, 1000)
+	testWriteSnapshot(t, node, mint)
+	spend := common.NewTransaction(common.XINAssetId)
+	spend.AddInput(mint.PayloadHash(), 0)
+	spend.Outputs = []*common.Output{
+		{Type: common.OutputTypeScript, Amount: common.NewInteger(400), Keys: []crypto.Key{randomTestKey()}},
+		{Type: common.OutputTypeScript, Amount: common.NewInteger(600), Keys: []crypto.Key{randomTestKey()}},
+	}
+	signed := &common.SignedTransaction{Transaction: *spend}
+	snap := testWriteSnapshot(t, node, signed)
+
+	transitions = nil
+	err = node.StreamStateTransitions(context.Background(), func(st *StateTransition) bool {
+		transitions = append(transitions, st)
+		return true
+	})
+	assert.Nil(err)
+	assert.Len(transitions, len(signers)+5)
+	tail := transitions[len(signers)+1:]
+	assert.Equal(StateTransitionMint, tail[0].Kind)
+	assert.Equal(mint.PayloadHash(), tail[0].Hash)
+	for _, st := range tail[1:] {
+		assert.Equal(snap.TopologicalOrder, st.Topology)
+		assert.Equal(signed.PayloadHash(), st.Transaction)
+	}
+	assert.Equal(StateTransitionSpend, tail[1].Kind)
+	assert.Equal(mint.PayloadHash(), tail[1].Hash)
+	assert.Equal(common.NewInteger(1000), tail[1].Amount)
+	assert.Equal(StateTransitionOutput, tail[2].Kind)
+	assert.Equal(common.NewInteger(400), tail[2].Amount)
+	assert.Equal(StateTransitionOutput, tail[3].Kind)
+	assert.Equal(1, tail[3].Index)
+	assert.Equal("-1000.00000000", tail[1].Delta)
+	assert.Equal("400.00000000", tail[2].Delta)
+
+	supply = common.NewInteger(0)
+	for _, st := range transitions {
+		if strings.HasPrefix(st.Delta, "-") {
+			supply = supply.Sub(common.NewIntegerFromString(st.Delta[1:]))
+		} else {
+			supply = supply.Add(common.NewIntegerFromString(st.Delta))
+		}
+	}
+	state, err = node.ReplayTo(context.Background(), snap.TopologicalOrder)
+	assert.Nil(err)
+	assert.Equal(state.Balances[common.XINAssetId], supply)
+
+	var n int
+	err = node.StreamStateTransitions(context.Background(), func(st *StateTransition) bool {
+		n++
+		return n < 2
+	})
+	assert.Nil(err)
+	assert.Equal(2, n)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n = 0
+	err = node.StreamStateTransitions(ctx, func(st *StateTransition) bool {
+		n++
+		return true
+	})
+	assert.Equal(context.Canceled, err)
+	assert.Equal(0, n)
+}
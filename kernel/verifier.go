@@ -0,0 +1,138 @@
+package kernel
+
+import (
+	"sync"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type verifyJob struct {
+	peerId     crypto.Hash
+	snapshot   *common.Snapshot
+	signatures []*crypto.Signature
+	signers    map[crypto.Hash]bool
+	err        error
+	done       chan struct{}
+	result     chan error
+}
+
+// SnapshotVerifier verifies snapshots with a pool of workers, while the
+// commit hook is always called in the same order as the snapshots submitted.
+// A snapshot failed the verification is not committed.
+type SnapshotVerifier struct {
+	mutex     *sync.Mutex
+	jobs      chan *verifyJob
+	order     chan *verifyJob
+	verify    func(job *verifyJob) error
+	commit    func(job *verifyJob) error
+	pmutex    *sync.Mutex
+	pressure  func(saturated bool)
+	saturated bool
+	signal    chan struct{}
+}
+
+func newSnapshotVerifier(workers int, verify func(job *verifyJob) error, commit func(job *verifyJob) error) *SnapshotVerifier {
+	if workers < 1 {
+		workers = 1
+	}
+	v := &SnapshotVerifier{
+		mutex:  new(sync.Mutex),
+		pmutex: new(sync.Mutex),
+		jobs:   make(chan *verifyJob, workers*64),
+		order:  make(chan *verifyJob, workers*64),
+		verify: verify,
+		commit: commit,
+		signal: make(chan struct{}, 1),
+	}
+	for i := 0; i < workers; i++ {
+		go v.loopVerify()
+	}
+	go v.loopCommit()
+	go v.loopPressure()
+	return v
+}
+
+// Submit returns a channel to receive the error of the verification or the
+// commit hook, which is sent exactly once.
+func (v *SnapshotVerifier) Submit(peerId crypto.Hash, s *common.Snapshot) <-chan error {
+	job := &verifyJob{
+		peerId:   peerId,
+		snapshot: s,
+		done:     make(chan struct{}),
+		result:   make(chan error, 1),
+	}
+	v.mutex.Lock()
+	v.order <- job
+	v.jobs <- job
+	v.mutex.Unlock()
+	v.checkPressure()
+	return job.result
+}
+
+// SetPressureHook makes the verifier report when the snapshots pending reach
+// 3/4 of the capacity as saturated, and when they drain to 1/4 as not.
+func (v *SnapshotVerifier) SetPressureHook(hook func(saturated bool)) {
+	v.pmutex.Lock()
+	defer v.pmutex.Unlock()
+	v.pressure = hook
+}
+
+// checkPressure only signals the transition without waiting, because the hook
+// may block on the network, and it must never stall the submit or the commit.
+func (v *SnapshotVerifier) checkPressure() {
+	v.pmutex.Lock()
+	defer v.pmutex.Unlock()
+	if v.pressure == nil {
+		return
+	}
+	depth, capacity := len(v.order), cap(v.order)
+	switch {
+	case !v.saturated && depth >= capacity*3/4:
+		v.saturated = true
+	case v.saturated && depth <= capacity/4:
+		v.saturated = false
+	default:
+		return
+	}
+	select {
+	case v.signal <- struct{}{}:
+	default:
+	}
+}
+
+// loopPressure calls the hook with the latest pressure state when it differs
+// from the last reported, so the transitions signaled while the hook is busy
+// are coalesced, and the hook always sees them alternate.
+func (v *SnapshotVerifier) loopPressure() {
+	var reported bool
+	for range v.signal {
+		v.pmutex.Lock()
+		hook, saturated := v.pressure, v.saturated
+		v.pmutex.Unlock()
+		if hook == nil || saturated == reported {
+			continue
+		}
+		hook(saturated)
+		reported = saturated
+	}
+}
+
+func (v *SnapshotVerifier) loopVerify() {
+	for job := range v.jobs {
+		job.err = v.verify(job)
+		close(job.done)
+	}
+}
+
+func (v *SnapshotVerifier) loopCommit() {
+	for job := range v.order {
+		<-job.done
+		err := job.err
+		if err == nil {
+			err = v.commit(job)
+		}
+		job.result <- err
+		v.checkPressure()
+	}
+}
@@ -0,0 +1,135 @@
+package kernel
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotVerifierOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	snapshots := make([]*common.Snapshot, 100)
+	for i := range snapshots {
+		snapshots[i] = &common.Snapshot{RoundNumber: uint64(i)}
+	}
+
+	for _, workers := range []int{1, 2, 8, 32} {
+		var wg sync.WaitGroup
+		committed := make([]uint64, 0)
+		verify := func(job *verifyJob) error {
+			n, _ := rand.Int(rand.Reader, big.NewInt(1000))
+			time.Sleep(time.Duration(n.Int64()) * time.Microsecond)
+			return nil
+		}
+		commit := func(job *verifyJob) error {
+			committed = append(committed, job.snapshot.RoundNumber)
+			wg.Done()
+			return nil
+		}
+		verifier := newSnapshotVerifier(workers, verify, commit)
+		wg.Add(len(snapshots))
+		for _, s := range snapshots {
+			verifier.Submit(crypto.Hash{}, s)
+		}
+		wg.Wait()
+
+		assert.Len(committed, len(snapshots))
+		for i, n := range committed {
+			assert.Equal(uint64(i), n)
+		}
+	}
+}
+
+func TestSnapshotVerifierErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	verify := func(job *verifyJob) error {
+		if job.snapshot.RoundNumber%2 == 1 {
+			return fmt.Errorf("verify %d", job.snapshot.RoundNumber)
+		}
+		return nil
+	}
+	committed := make([]uint64, 0)
+	commit := func(job *verifyJob) error {
+		committed = append(committed, job.snapshot.RoundNumber)
+		if job.snapshot.RoundNumber%4 == 2 {
+			return fmt.Errorf("commit %d", job.snapshot.RoundNumber)
+		}
+		return nil
+	}
+	verifier := newSnapshotVerifier(4, verify, commit)
+
+	results := make([]<-chan error, 8)
+	for i := range results {
+		results[i] = verifier.Submit(crypto.Hash{}, &common.Snapshot{RoundNumber: uint64(i)})
+	}
+	for i, result := range results {
+		err := <-result
+		switch i % 4 {
+		case 0:
+			assert.Nil(err)
+		case 2:
+			assert.Equal(fmt.Sprintf("commit %d", i), err.Error())
+		default:
+			assert.Equal(fmt.Sprintf("verify %d", i), err.Error())
+		}
+	}
+	assert.Equal([]uint64{0, 2, 4, 6}, committed)
+}
+
+func BenchmarkSnapshotVerifier(b *testing.B) {
+	key := randomTestKey()
+	pub := key.Public()
+	snapshots := make([]*common.Snapshot, 256)
+	for i := range snapshots {
+		s := &common.Snapshot{RoundNumber: uint64(i), Timestamp: uint64(time.Now().UnixNano())}
+		hash := s.PayloadHash()
+		sig := key.Sign(hash[:])
+		s.Signatures = []*crypto.Signature{&sig}
+		snapshots[i] = s
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			var wg sync.WaitGroup
+			verify := func(job *verifyJob) error {
+				s := job.snapshot
+				s.Hash = s.PayloadHash()
+				for _, sig := range s.Signatures {
+					if !pub.Verify(s.Hash[:], *sig) {
+						panic(s.Hash)
+					}
+				}
+				return nil
+			}
+			commit := func(job *verifyJob) error {
+				wg.Done()
+				return nil
+			}
+			verifier := newSnapshotVerifier(workers, verify, commit)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				wg.Add(len(snapshots))
+				for _, s := range snapshots {
+					verifier.Submit(crypto.Hash{}, s)
+				}
+				wg.Wait()
+			}
+		})
+	}
+}
+
+func randomTestKey() crypto.Key {
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	return crypto.NewKeyFromSeed(seed)
+}
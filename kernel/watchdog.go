@@ -0,0 +1,58 @@
+package kernel
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	WatchdogCheckInterval = 10 * time.Second
+)
+
+// TimeSinceLastFinalized is measured from the timestamp of the last snapshot
+// in the store when the node starts, i.e. the genesis snapshots for a new
+// network, and then from the time a snapshot is finalized by this node.
+func (node *Node) TimeSinceLastFinalized() time.Duration {
+	last := atomic.LoadInt64(&node.lastFinalized)
+	return node.clock().Sub(time.Unix(0, last))
+}
+
+func (node *Node) markFinalized() {
+	atomic.StoreInt64(&node.lastFinalized, node.clock().UnixNano())
+}
+
+func (node *Node) loadLastFinalized() error {
+	seq := node.store.TopologySequence()
+	if seq == 0 {
+		atomic.StoreInt64(&node.lastFinalized, node.clock().UnixNano())
+		return nil
+	}
+	snapshots, err := node.store.ReadSnapshotsSinceTopology(seq-1, 1)
+	if err != nil || len(snapshots) == 0 {
+		return err
+	}
+	atomic.StoreInt64(&node.lastFinalized, int64(snapshots[0].Timestamp))
+	return nil
+}
+
+func (node *Node) checkStalled() bool {
+	since := node.TimeSinceLastFinalized()
+	threshold := time.Duration(node.custom.StallAlertSeconds) * time.Second
+	if since < threshold {
+		return false
+	}
+	logger.Printf("!!!!!!!! CONSENSUS STALLED, NO SNAPSHOT FINALIZED IN %s\n", since.String())
+	return true
+}
+
+func (node *Node) LoopWatchdog() error {
+	ticker := time.NewTicker(WatchdogCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		node.checkStalled()
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	assert := assert.New(t)
+
+	node, _, dir := testSetupNode(t)
+	defer os.RemoveAll(dir)
+	defer node.store.Close()
+
+	gns, err := readGenesis(dir + "/genesis.json")
+	assert.Nil(err)
+	baseline := time.Unix(gns.Epoch, 0).Add(DomainSnapshotTimestampOffset)
+	threshold := time.Duration(node.custom.StallAlertSeconds) * time.Second
+
+	now := baseline.Add(time.Second)
+	node.clock = func() time.Time { return now }
+	assert.Equal(time.Second, node.TimeSinceLastFinalized())
+	assert.False(node.checkStalled())
+
+	now = baseline.Add(threshold - time.Nanosecond)
+	assert.False(node.checkStalled())
+	now = baseline.Add(threshold)
+	assert.True(node.checkStalled())
+
+	testWriteSnapshot(t, node, testMintTransaction(common.XINAssetId, 100))
+	node.markFinalized()
+	assert.Equal(time.Duration(0), node.TimeSinceLastFinalized())
+	assert.False(node.checkStalled())
+	now = now.Add(threshold + time.Minute)
+	assert.Equal(threshold+time.Minute, node.TimeSinceLastFinalized())
+	assert.True(node.checkStalled())
+}
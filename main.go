@@ -6,7 +6,9 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"runtime"
+	"time"
 
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/kernel"
 	"github.com/MixinNetwork/mixin/rpc"
 	"github.com/MixinNetwork/mixin/storage"
@@ -34,6 +36,10 @@ func main() {
 					Value: 7239,
 					Usage: "the peer port to listen",
 				},
+				cli.StringFlag{
+					Name:  "admin-token-file",
+					Usage: "the admin API token file, or set it in the " + rpc.AdminTokenEnv + " environment",
+				},
 			},
 		},
 		{
@@ -172,6 +178,42 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "verify-sync",
+			Usage:  "Verify the local snapshots against a trusted peer",
+			Action: verifySyncCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the data directory",
+				},
+				cli.StringFlag{
+					Name:  "peer",
+					Value: "127.0.0.1:8239",
+					Usage: "the trusted peer RPC endpoint",
+				},
+				cli.IntFlag{
+					Name:  "samples",
+					Value: kernel.SyncVerifySamples,
+					Usage: "the number of topological orders to sample",
+				},
+			},
+		},
+		{
+			Name:   "importsnapshots",
+			Usage:  "Import the exported snapshots into a stopped node",
+			Action: importSnapshotsCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the data directory",
+				},
+				cli.StringFlag{
+					Name:  "file,f",
+					Usage: "the snapshots export file, plain or gzip compressed",
+				},
+			},
+		},
 		{
 			Name:   "getinfo",
 			Usage:  "Get info from the node",
@@ -194,18 +236,36 @@ func main() {
 func kernelCmd(c *cli.Context) error {
 	runtime.GOMAXPROCS(128)
 
-	store, err := storage.NewBadgerStore(c.String("dir"))
+	custom, err := config.Initialize(c.String("dir") + "/config.json")
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(custom.FsyncSeconds) * time.Second
+	store, err := storage.NewBadgerStoreWithFsync(c.String("dir"), custom.FsyncPolicy, interval)
 	if err != nil {
 		return err
 	}
 	defer store.Close()
 
 	go func() {
-		err := rpc.StartHTTP(store, c.Int("port")+1000)
+		err := rpc.StartHTTP(store, c.Int("port")+1000, custom.ReplicaPrimary)
 		if err != nil {
 			panic(err)
 		}
 	}()
+	token, err := rpc.LoadAdminToken(c.String("admin-token-file"))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		go func() {
+			err := rpc.StartAdminHTTP(store, c.Int("port")+3000, token)
+			if err != nil {
+				panic(err)
+			}
+		}()
+	}
+	http.Handle("/metrics", rpc.PrometheusHandler())
 	go func() {
 		err := http.ListenAndServe(fmt.Sprintf(":%d", c.Int("port")+2000), http.DefaultServeMux)
 		if err != nil {
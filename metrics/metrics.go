@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// Backend receives all the metrics emitted by the node, the counters only
+// increase, the gauges are set to the latest value, and the histograms
+// observe durations in seconds.
+type Backend interface {
+	Counter(name string, delta uint64)
+	Gauge(name string, value float64)
+	Histogram(name string, value float64)
+}
+
+type Noop struct{}
+
+func (Noop) Counter(name string, delta uint64)    {}
+func (Noop) Gauge(name string, value float64)     {}
+func (Noop) Histogram(name string, value float64) {}
+
+type holder struct {
+	backend Backend
+}
+
+var current atomic.Value
+
+func init() {
+	Use(NewPrometheus())
+}
+
+// Use replaces the backend of the metrics emitted afterwards.
+func Use(b Backend) {
+	if b == nil {
+		b = Noop{}
+	}
+	current.Store(holder{b})
+}
+
+func Current() Backend {
+	return current.Load().(holder).backend
+}
+
+// Close closes the backend in use if it holds a connection, e.g. StatsD, and
+// drops the metrics emitted afterwards.
+func Close() error {
+	c, ok := Current().(io.Closer)
+	if !ok {
+		return nil
+	}
+	Use(Noop{})
+	return c.Close()
+}
+
+// Labeled names a series of the metric by the label name and value pairs, in
+// the Prometheus form name{label="value"}, and StatsD sends them as tags.
+func Labeled(name string, pairs ...string) string {
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+	}
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
+
+// splitLabeled returns the metric name and the label pairs of a series named
+// by Labeled.
+func splitLabeled(series string) (string, []string) {
+	i := strings.IndexByte(series, '{')
+	if i < 0 || !strings.HasSuffix(series, "}") {
+		return series, nil
+	}
+	var pairs []string
+	for _, l := range strings.Split(series[i+1:len(series)-1], ",") {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		pairs = append(pairs, kv[0], strings.Trim(kv[1], `"`))
+	}
+	return series[:i], pairs
+}
+
+func Counter(name string, delta uint64) {
+	Current().Counter(name, delta)
+}
+
+func Gauge(name string, value float64) {
+	Current().Gauge(name, value)
+}
+
+func Histogram(name string, value float64) {
+	Current().Histogram(name, value)
+}
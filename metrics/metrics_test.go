@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheus(t *testing.T) {
+	assert := assert.New(t)
+
+	p := NewPrometheus()
+	p.Counter("mixin_test_total", 2)
+	p.Counter("mixin_test_total", 3)
+	p.Gauge("mixin_test_gauge", 7)
+	p.Gauge("mixin_test_gauge", 1.5)
+	p.Histogram("mixin_test_seconds", 0.003)
+	p.Histogram("mixin_test_seconds", 2)
+	p.Counter(Labeled("mixin_test_peer_total", "peer", "b"), 2)
+	p.Counter(Labeled("mixin_test_peer_total", "peer", "a"), 1)
+
+	var buf bytes.Buffer
+	p.Write(&buf)
+	out := buf.String()
+	assert.Contains(out, "# TYPE mixin_test_total counter\nmixin_test_total 5\n")
+	assert.Contains(out, "# TYPE mixin_test_gauge gauge\nmixin_test_gauge 1.5\n")
+	assert.Contains(out, "mixin_test_seconds_bucket{le=\"0.001\"} 0\n")
+	assert.Contains(out, "mixin_test_seconds_bucket{le=\"0.005\"} 1\n")
+	assert.Contains(out, "mixin_test_seconds_bucket{le=\"5\"} 2\n")
+	assert.Contains(out, "mixin_test_seconds_bucket{le=\"+Inf\"} 2\n")
+	assert.Contains(out, "mixin_test_seconds_count 2\n")
+	assert.Contains(out, "# TYPE mixin_test_peer_total counter\nmixin_test_peer_total{peer=\"a\"} 1\nmixin_test_peer_total{peer=\"b\"} 2\n")
+	assert.Equal(1, strings.Count(out, "# TYPE mixin_test_peer_total"))
+
+	_, ok := Current().(*Prometheus)
+	assert.True(ok)
+	Use(nil)
+	defer Use(NewPrometheus())
+	assert.Equal(Noop{}, Current())
+	Counter("mixin_test_total", 1)
+}
+
+func TestStatsD(t *testing.T) {
+	assert := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer conn.Close()
+	s, err := NewStatsD(conn.LocalAddr().String(), "mixin.")
+	assert.Nil(err)
+	defer s.Close()
+
+	s.Counter("snapshots", 3)
+	s.Gauge("topology", 12)
+	s.Histogram("commit", 0.25)
+	s.Gauge(Labeled("latency", "peer", "a", "address", "127.0.0.1:7001"), 0.5)
+	buf := make([]byte, 128)
+	for _, line := range []string{"mixin.snapshots:3|c", "mixin.topology:12|g", "mixin.commit:250|ms", "mixin.latency:0.5|g|#peer:a,address:127.0.0.1:7001"} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		assert.Nil(err)
+		assert.Equal(line, string(buf[:n]))
+	}
+
+	Use(s)
+	defer Use(NewPrometheus())
+	assert.Nil(Close())
+	assert.Equal(Noop{}, Current())
+	assert.Nil(Close())
+}
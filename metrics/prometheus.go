@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+var HistogramBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// Prometheus keeps the metrics in memory, and writes them in the Prometheus
+// text format when scraped.
+type Prometheus struct {
+	mutex      *sync.Mutex
+	counters   map[string]uint64
+	gauges     map[string]float64
+	histograms map[string]*histogram
+}
+
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		mutex:      new(sync.Mutex),
+		counters:   make(map[string]uint64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]*histogram),
+	}
+}
+
+func (p *Prometheus) Counter(name string, delta uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.counters[name] = p.counters[name] + delta
+}
+
+func (p *Prometheus) Gauge(name string, value float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.gauges[name] = value
+}
+
+func (p *Prometheus) Histogram(name string, value float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	h := p.histograms[name]
+	if h == nil {
+		h = &histogram{buckets: make([]uint64, len(HistogramBuckets))}
+		p.histograms[name] = h
+	}
+	for i, b := range HistogramBuckets {
+		if value <= b {
+			h.buckets[i] = h.buckets[i] + 1
+		}
+	}
+	h.sum = h.sum + value
+	h.count = h.count + 1
+}
+
+// Write writes the series of a labeled metric under a single type line.
+func (p *Prometheus) Write(w io.Writer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	typed := make(map[string]bool)
+	writeType := func(series, kind string) {
+		name, _ := splitLabeled(series)
+		if !typed[name] {
+			typed[name] = true
+			fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+		}
+	}
+	for _, name := range sortedNames(p.counters) {
+		writeType(name, "counter")
+		fmt.Fprintf(w, "%s %d\n", name, p.counters[name])
+	}
+	for _, name := range sortedNames(p.gauges) {
+		writeType(name, "gauge")
+		fmt.Fprintf(w, "%s %g\n", name, p.gauges[name])
+	}
+	for _, name := range sortedNames(p.histograms) {
+		h := p.histograms[name]
+		writeType(name, "histogram")
+		for i, b := range HistogramBuckets {
+			fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, b, h.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+		fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, h.sum, name, h.count)
+	}
+}
+
+func sortedNames(m interface{}) []string {
+	var names []string
+	switch m := m.(type) {
+	case map[string]uint64:
+		for n := range m {
+			names = append(names, n)
+		}
+	case map[string]float64:
+		for n := range m {
+			names = append(names, n)
+		}
+	case map[string]*histogram:
+		for n := range m {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
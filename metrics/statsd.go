@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+)
+
+// StatsD sends each metric in a UDP packet, and the histograms are sent as
+// timers in milliseconds, and the labels of a series as the tags extension.
+// A failed send is dropped silently.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+}
+
+func NewStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{conn: conn, prefix: prefix}, nil
+}
+
+func (s *StatsD) Counter(name string, delta uint64) {
+	s.send(name, fmt.Sprintf("%d|c", delta))
+}
+
+func (s *StatsD) Gauge(name string, value float64) {
+	s.send(name, fmt.Sprintf("%g|g", value))
+}
+
+func (s *StatsD) Histogram(name string, value float64) {
+	s.send(name, fmt.Sprintf("%g|ms", value*1000))
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(series, value string) {
+	name, pairs := splitLabeled(series)
+	line := s.prefix + name + ":" + value
+	for i := 0; i+1 < len(pairs); i += 2 {
+		sep := ","
+		if i == 0 {
+			sep = "|#"
+		}
+		line = line + sep + pairs[i] + ":" + pairs[i+1]
+	}
+	s.conn.Write([]byte(line))
+}
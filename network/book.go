@@ -0,0 +1,113 @@
+package network
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const AddressBookReputationMaximum = 100
+
+type AddressBookEntry struct {
+	Id         crypto.Hash `json:"id"`
+	Address    string      `json:"address"`
+	LastSeen   time.Time   `json:"last_seen"`
+	Reputation int         `json:"reputation"`
+}
+
+// AddressBook remembers the neighbors dialed, the reputation goes up by one
+// on each successful dial and down by one on each failure.
+type AddressBook struct {
+	mutex   *sync.Mutex
+	entries map[crypto.Hash]*AddressBookEntry
+}
+
+func NewAddressBook() *AddressBook {
+	return &AddressBook{
+		mutex:   new(sync.Mutex),
+		entries: make(map[crypto.Hash]*AddressBookEntry),
+	}
+}
+
+func (b *AddressBook) Load(entries []AddressBookEntry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, e := range entries {
+		entry := e
+		b.entries[e.Id] = &entry
+	}
+}
+
+func (b *AddressBook) Succeed(id crypto.Hash, addr string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	e := b.entries[id]
+	if e == nil {
+		e = &AddressBookEntry{Id: id}
+		b.entries[id] = e
+	}
+	e.Address = addr
+	e.LastSeen = time.Now()
+	if e.Reputation < AddressBookReputationMaximum {
+		e.Reputation = e.Reputation + 1
+	}
+}
+
+func (b *AddressBook) Fail(id crypto.Hash, addr string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	e := b.entries[id]
+	if e == nil || e.Address != addr {
+		return
+	}
+	if e.Reputation > -AddressBookReputationMaximum {
+		e.Reputation = e.Reputation - 1
+	}
+}
+
+func (b *AddressBook) Remove(id crypto.Hash) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.entries, id)
+}
+
+// Entries are sorted with the best reputation first, and the most recently
+// seen first for the same reputation.
+func (b *AddressBook) Entries() []AddressBookEntry {
+	b.mutex.Lock()
+	entries := make([]AddressBookEntry, 0, len(b.entries))
+	for _, e := range b.entries {
+		entries = append(entries, *e)
+	}
+	b.mutex.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Reputation != b.Reputation {
+			return a.Reputation > b.Reputation
+		}
+		return a.LastSeen.After(b.LastSeen)
+	})
+	return entries
+}
+
+// Prune removes the entries not seen since the age, and returns the count
+// of entries removed.
+func (b *AddressBook) Prune(age time.Duration) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var pruned int
+	for id, e := range b.entries {
+		if time.Since(e.LastSeen) > age {
+			delete(b.entries, id)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+func (me *Peer) AddressBook() *AddressBook {
+	return me.book
+}
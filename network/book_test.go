@@ -0,0 +1,43 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressBook(t *testing.T) {
+	assert := assert.New(t)
+
+	a, b, c := crypto.NewHash([]byte("a")), crypto.NewHash([]byte("b")), crypto.NewHash([]byte("c"))
+	book := NewAddressBook()
+	book.Succeed(a, "127.0.0.1:7001")
+	book.Succeed(b, "127.0.0.1:7002")
+	book.Succeed(b, "127.0.0.1:7002")
+	book.Fail(c, "127.0.0.1:7003")
+	assert.Len(book.Entries(), 2)
+
+	entries := book.Entries()
+	assert.Equal(b, entries[0].Id)
+	assert.Equal(2, entries[0].Reputation)
+	assert.Equal(a, entries[1].Id)
+
+	book.Fail(b, "127.0.0.1:7009")
+	book.Fail(a, "127.0.0.1:7001")
+	book.Fail(a, "127.0.0.1:7001")
+	entries = book.Entries()
+	assert.Equal(2, entries[0].Reputation)
+	assert.Equal(-1, entries[1].Reputation)
+
+	for i := 0; i < AddressBookReputationMaximum+10; i++ {
+		book.Succeed(a, "127.0.0.1:7001")
+	}
+	assert.Equal(AddressBookReputationMaximum, book.Entries()[0].Reputation)
+
+	book.Load([]AddressBookEntry{{Id: c, Address: "127.0.0.1:7003", LastSeen: time.Now().Add(-time.Hour)}})
+	assert.Len(book.Entries(), 3)
+	assert.Equal(1, book.Prune(time.Minute))
+	assert.Len(book.Entries(), 2)
+}
@@ -0,0 +1,91 @@
+package network
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+const (
+	DialConcurrencyDefault = 16
+	DialTimeoutDefault     = 10 * time.Second
+)
+
+// dialLimiter bounds the outbound dials in flight with a semaphore, a dial
+// waiting for a slot gives up when the peer quits. A dial exceeding the
+// timeout fails, but holds the slot until the underlying dial returns, and
+// the client connected too late is closed.
+type dialLimiter struct {
+	slots    chan struct{}
+	timeout  time.Duration
+	inflight int64
+}
+
+func newDialLimiter(concurrency int, timeout time.Duration) *dialLimiter {
+	if concurrency < 1 {
+		concurrency = DialConcurrencyDefault
+	}
+	if timeout <= 0 {
+		timeout = DialTimeoutDefault
+	}
+	return &dialLimiter{
+		slots:   make(chan struct{}, concurrency),
+		timeout: timeout,
+	}
+}
+
+func (l *dialLimiter) dial(addr string, quit <-chan struct{}, dial func(addr string) (Client, error)) (Client, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-quit:
+		return nil, errPeerGoodbye
+	}
+	metrics.Gauge("mixin_peer_dials_in_flight", float64(atomic.AddInt64(&l.inflight, 1)))
+	release := func() {
+		metrics.Gauge("mixin_peer_dials_in_flight", float64(atomic.AddInt64(&l.inflight, -1)))
+		<-l.slots
+	}
+
+	type result struct {
+		client Client
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		client, err := dial(addr)
+		done <- result{client, err}
+	}()
+
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		release()
+		return r.client, r.err
+	case <-timer.C:
+		go func() {
+			r := <-done
+			if r.err == nil {
+				r.client.Close()
+			}
+			release()
+		}()
+		return nil, fmt.Errorf("dial timeout %s %s", addr, l.timeout)
+	}
+}
+
+func (l *dialLimiter) inFlight() int {
+	return int(atomic.LoadInt64(&l.inflight))
+}
+
+// SetDialLimits applies to the dials started after it, the dials in flight
+// keep the slots of the previous limits.
+func (me *Peer) SetDialLimits(concurrency int, timeout time.Duration) {
+	me.dialer = newDialLimiter(concurrency, timeout)
+}
+
+func (me *Peer) DialsInFlight() int {
+	return me.dialer.inFlight()
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	meId := crypto.NewHash([]byte("me"))
+	me := NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7005")
+	me.SetDialLimits(4, 200*time.Millisecond)
+	assert.Equal(0, me.DialsInFlight())
+
+	var mutex sync.Mutex
+	var current, max, dials, gauge int
+	me.dial = func(addr string) (Client, error) {
+		mutex.Lock()
+		current, dials = current+1, dials+1
+		if current > max {
+			max = current
+		}
+		if n := me.DialsInFlight(); n > gauge {
+			gauge = n
+		}
+		mutex.Unlock()
+		defer func() {
+			mutex.Lock()
+			current = current - 1
+			mutex.Unlock()
+		}()
+		if addr == "127.0.0.1:8000" {
+			time.Sleep(500 * time.Millisecond)
+		} else {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return nil, errors.New("refused")
+	}
+
+	for i := 0; i < 40; i++ {
+		id := crypto.NewHash([]byte(fmt.Sprintf("candidate-%d", i)))
+		me.AddNeighbor(id, fmt.Sprintf("127.0.0.1:%d", 8000+i))
+	}
+	time.Sleep(1500 * time.Millisecond)
+
+	mutex.Lock()
+	assert.Equal(4, max)
+	assert.True(gauge <= 4)
+	assert.True(dials >= 40)
+	mutex.Unlock()
+	assert.True(me.seeds.Backoff("127.0.0.1:8000") > SeedBackoffBase)
+
+	err := me.Shutdown()
+	assert.Nil(err)
+	for i := 0; i < 50 && me.DialsInFlight() > 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(0, me.DialsInFlight())
+}
+
+func TestDialTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := newDialLimiter(1, 50*time.Millisecond)
+	release := make(chan struct{})
+	client, _ := testPipe()
+	_, err := limiter.dial("slow", nil, func(addr string) (Client, error) {
+		<-release
+		return client, nil
+	})
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "dial timeout slow")
+	assert.Equal(1, limiter.inFlight())
+
+	quit := make(chan struct{})
+	close(quit)
+	_, err = limiter.dial("blocked", quit, func(addr string) (Client, error) {
+		return nil, nil
+	})
+	assert.Equal(errPeerGoodbye, err)
+
+	close(release)
+	for i := 0; i < 50 && limiter.inFlight() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(0, limiter.inFlight())
+	_, err = client.Receive()
+	assert.NotNil(err)
+}
@@ -0,0 +1,92 @@
+package network
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	DNSSeedRefreshInterval = 10 * time.Minute
+)
+
+// ResolveDNSSeeds resolves the host:port seeds with the lookup function, and
+// returns the valid unicast ip:port addresses without duplication.
+func ResolveDNSSeeds(lookup func(host string) ([]string, error), seeds []string) []string {
+	filter := make(map[string]bool)
+	addrs := make([]string, 0)
+	for _, seed := range seeds {
+		host, port, err := net.SplitHostPort(seed)
+		if err != nil {
+			logger.Println("invalid DNS seed", seed, err)
+			continue
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			logger.Println("invalid DNS seed port", seed)
+			continue
+		}
+		ips, err := lookup(host)
+		if err != nil {
+			logger.Println("DNS seed lookup error", seed, err)
+			continue
+		}
+		for _, s := range ips {
+			ip := net.ParseIP(s)
+			if ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+				continue
+			}
+			addr := net.JoinHostPort(ip.String(), port)
+			if filter[addr] {
+				continue
+			}
+			filter[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// AddDNSSeeds adds the resolved addresses as candidate seed peers, and returns
+// the ones not known before.
+func (me *Peer) AddDNSSeeds(lookup func(host string) ([]string, error), seeds []string) []string {
+	added := make([]string, 0)
+	for _, addr := range ResolveDNSSeeds(lookup, seeds) {
+		if addr == me.Address || me.seeds.Has(addr) {
+			continue
+		}
+		me.seeds.Add(addr)
+		added = append(added, addr)
+	}
+	return added
+}
+
+// DialDNSSeeds adds the resolved seeds and dials the new ones, every seed
+// responding to the handshake becomes a neighbor, and the dialed ones returned.
+func (me *Peer) DialDNSSeeds(lookup func(host string) ([]string, error), seeds []string) []string {
+	dialed := make([]string, 0)
+	for _, addr := range me.AddDNSSeeds(lookup, seeds) {
+		err := me.dialSeed(addr)
+		if err != nil {
+			logger.Println("DNS seed dial error", addr, err)
+			me.seeds.Fail(addr)
+			continue
+		}
+		me.seeds.Succeed(addr)
+		dialed = append(dialed, addr)
+	}
+	return dialed
+}
+
+func (me *Peer) LoopDNSSeeds(seeds []string) error {
+	for {
+		dialed := me.DialDNSSeeds(net.LookupHost, seeds)
+		logger.Println("DNS seeds refreshed", len(dialed))
+		select {
+		case <-me.quit:
+			return nil
+		case <-time.After(DNSSeedRefreshInterval):
+		}
+	}
+}
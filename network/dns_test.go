@@ -0,0 +1,73 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSSeeds(t *testing.T) {
+	assert := assert.New(t)
+
+	records := map[string][]string{
+		"seed1.mixin.test": {"10.0.0.1", "10.0.0.2", "invalid", "0.0.0.0", "224.0.0.1"},
+		"seed2.mixin.test": {"10.0.0.2", "10.0.0.3", "2001:db8::1"},
+	}
+	lookup := func(host string) ([]string, error) {
+		ips, found := records[host]
+		if !found {
+			return nil, errors.New("no such host")
+		}
+		return ips, nil
+	}
+
+	seeds := []string{"seed1.mixin.test:7239", "seed2.mixin.test:7239", "seed3.mixin.test:7239", "seed1.mixin.test", "seed2.mixin.test:0"}
+	addrs := ResolveDNSSeeds(lookup, seeds)
+	assert.Equal([]string{"10.0.0.1:7239", "10.0.0.2:7239", "10.0.0.3:7239", "[2001:db8::1]:7239"}, addrs)
+
+	peer := NewPeer(nil, crypto.Hash{}, "10.0.0.3:7239")
+	peer.AddNeighbor(crypto.NewHash([]byte("neighbor")), "10.0.0.1:7239")
+	added := peer.AddDNSSeeds(lookup, seeds)
+	assert.Equal([]string{"10.0.0.2:7239", "[2001:db8::1]:7239"}, added)
+	for _, addr := range added {
+		assert.True(peer.seeds.Has(addr))
+	}
+	assert.True(peer.seeds.Has("10.0.0.1:7239"))
+	assert.False(peer.seeds.Has("10.0.0.3:7239"))
+
+	added = peer.AddDNSSeeds(lookup, seeds)
+	assert.Len(added, 0)
+}
+
+func TestDialDNSSeeds(t *testing.T) {
+	assert := assert.New(t)
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	me := NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7005")
+	remote := NewPeer(&testGoodbyeHandle{id: remoteId}, remoteId, "127.0.0.1:7006")
+	remote.neighbors.Put(meId, NewPeer(nil, meId, me.Address))
+	me.dial = func(addr string) (Client, error) {
+		if addr != remote.Address {
+			return nil, errors.New("dead seed")
+		}
+		client, server := testPipe()
+		go remote.acceptNeighborConnection(server)
+		return client, nil
+	}
+	lookup := func(host string) ([]string, error) {
+		return []string{"127.0.0.1", "127.0.0.2"}, nil
+	}
+
+	dialed := me.DialDNSSeeds(lookup, []string{"seed.mixin.test:7006"})
+	assert.Equal([]string{remote.Address}, dialed)
+	assert.False(me.seeds.Available("127.0.0.2:7006"))
+	neighbor := me.GetNeighbor(remoteId)
+	assert.NotNil(neighbor)
+	assert.Equal(remote.Address, neighbor.Address)
+
+	dialed = me.DialDNSSeeds(lookup, []string{"seed.mixin.test:7006"})
+	assert.Len(dialed, 0)
+	assert.Nil(me.Shutdown())
+}
@@ -0,0 +1,47 @@
+package network
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+// a pause expires by itself, so a lost resume message never stalls the gossip
+const FlowPauseTimeout = 10 * time.Second
+
+// FlowControl tells all neighbors to pause or resume the snapshot gossip to
+// this node, the snapshots paused are kept in the sender queue instead of
+// dropped, and all other messages are not affected.
+func (me *Peer) FlowControl(saturated bool) {
+	typ := byte(PeerMessageTypeResume)
+	if saturated {
+		typ = PeerMessageTypePause
+		metrics.Counter("mixin_network_flow_pauses_total", 1)
+	}
+	now := make([]byte, 8)
+	binary.BigEndian.PutUint64(now, uint64(time.Now().UnixNano()))
+	for _, p := range me.neighbors.Slice() {
+		key := p.IdForNetwork.ForNetwork(me.IdForNetwork)
+		key = crypto.NewHash(append(append(key[:], typ, 'F', 'C'), now...))
+		err := p.SendHigh(key, []byte{typ})
+		if err != nil {
+			logger.Println("neighbor flow control error", p.Address, err)
+		}
+	}
+}
+
+func (p *Peer) flowPause() {
+	atomic.StoreInt64(&p.pausedUntil, time.Now().Add(FlowPauseTimeout).UnixNano())
+}
+
+func (p *Peer) flowResume() {
+	atomic.StoreInt64(&p.pausedUntil, 0)
+}
+
+func (p *Peer) flowPaused() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&p.pausedUntil)
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFlowHandle struct {
+	testGoodbyeHandle
+	snapshots int64
+}
+
+func (h *testFlowHandle) QueueAppendSnapshot(peerId crypto.Hash, s *common.Snapshot) error {
+	atomic.AddInt64(&h.snapshots, 1)
+	return nil
+}
+
+func TestPeerFlowControl(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, typ := range []byte{PeerMessageTypePause, PeerMessageTypeResume} {
+		msg, err := parseNetworkMessage([]byte{typ})
+		assert.Nil(err)
+		assert.Equal(typ, msg.Type)
+	}
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	handle := &testFlowHandle{testGoodbyeHandle: testGoodbyeHandle{id: remoteId}}
+	me := NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7005")
+	remote := NewPeer(handle, remoteId, "127.0.0.1:7006")
+	me.dial = func(addr string) (Client, error) {
+		client, server := testPipe()
+		go remote.acceptNeighborConnection(server)
+		return client, nil
+	}
+	remote.dial = func(addr string) (Client, error) {
+		client, server := testPipe()
+		go me.acceptNeighborConnection(server)
+		return client, nil
+	}
+	me.AddNeighbor(remoteId, remote.Address)
+	remote.AddNeighbor(meId, me.Address)
+	neighbor := me.GetNeighbor(remoteId)
+	assert.False(neighbor.flowPaused())
+
+	send := func(round uint64) {
+		s := &common.Snapshot{RoundNumber: round}
+		err := neighbor.SendNormal(crypto.NewHash(s.Payload()), buildSnapshotMessage(s))
+		assert.Nil(err)
+	}
+	send(0)
+	for i := 0; i < 50 && atomic.LoadInt64(&handle.snapshots) < 1; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(int64(1), atomic.LoadInt64(&handle.snapshots))
+
+	remote.FlowControl(true)
+	for i := 0; i < 50 && !neighbor.flowPaused(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.True(neighbor.flowPaused())
+	for i := uint64(1); i <= 5; i++ {
+		send(i)
+	}
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(int64(1), atomic.LoadInt64(&handle.snapshots))
+	assert.Len(neighbor.normal, 5)
+
+	remote.FlowControl(false)
+	for i := 0; i < 50 && atomic.LoadInt64(&handle.snapshots) < 6; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.False(neighbor.flowPaused())
+	assert.Equal(int64(6), atomic.LoadInt64(&handle.snapshots))
+
+	neighbor.flowPause()
+	atomic.StoreInt64(&neighbor.pausedUntil, time.Now().UnixNano())
+	assert.False(neighbor.flowPaused())
+
+	assert.Nil(me.Shutdown())
+	assert.Nil(remote.Shutdown())
+}
@@ -0,0 +1,142 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	GoodbyeReasonShutdown            = 1
+	GoodbyeReasonRateLimited         = 2
+	GoodbyeReasonVersionIncompatible = 3
+	GoodbyeReasonBlacklisted         = 4
+
+	GoodbyeTimeout = 3 * time.Second
+)
+
+var errPeerGoodbye = errors.New("peer goodbye")
+
+type goodbyeError struct {
+	reason uint8
+}
+
+func (e *goodbyeError) Error() string {
+	return fmt.Sprintf("peer goodbye %s", GoodbyeReasonString(e.reason))
+}
+
+func GoodbyeReasonString(reason uint8) string {
+	switch reason {
+	case GoodbyeReasonShutdown:
+		return "shutdown"
+	case GoodbyeReasonRateLimited:
+		return "rate-limited"
+	case GoodbyeReasonVersionIncompatible:
+		return "version-incompatible"
+	case GoodbyeReasonBlacklisted:
+		return "blacklisted"
+	}
+	return "unknown"
+}
+
+// goodbyeBackoff is how long to wait before dialing a peer said goodbye, a
+// shutdown peer may restart soon, while it's useless to retry a peer that
+// rejects us until it's upgraded or reconfigured.
+func goodbyeBackoff(reason uint8) time.Duration {
+	switch reason {
+	case GoodbyeReasonShutdown:
+		return 10 * SeedBackoffBase
+	case GoodbyeReasonRateLimited:
+		return time.Minute
+	case GoodbyeReasonVersionIncompatible, GoodbyeReasonBlacklisted:
+		return SeedBackoffMaximum
+	}
+	return SeedBackoffBase
+}
+
+func buildGoodbyeMessage(reason uint8) []byte {
+	return []byte{PeerMessageTypeGoodbye, reason}
+}
+
+// Disconnect says goodbye to the neighbor with the reason, and stops dialing
+// and accepting it. It waits until the goodbye is sent, or the stream to the
+// neighbor is found not open. A blacklisted neighbor is refused with the same
+// goodbye whenever it connects again.
+func (me *Peer) Disconnect(idForNetwork crypto.Hash, reason uint8) error {
+	if reason == GoodbyeReasonBlacklisted {
+		me.blacklist.Store(idForNetwork, true)
+	}
+	peer := me.neighbors.Delete(idForNetwork)
+	if peer == nil {
+		return nil
+	}
+	peer.disconnect(reason)
+	return peer.waitGoodbye()
+}
+
+// Shutdown says goodbye to all neighbors, it should be called only once right
+// before the node exits.
+func (me *Peer) Shutdown() error {
+	peers := me.neighbors.Slice()
+	for _, p := range peers {
+		me.neighbors.Delete(p.IdForNetwork)
+		p.disconnect(GoodbyeReasonShutdown)
+	}
+	var err error
+	for _, p := range peers {
+		if e := p.waitGoodbye(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Goodbye returns the reason of the last goodbye received from the peer, and
+// the time received, or zero if the peer never said goodbye.
+func (p *Peer) Goodbye() (uint8, time.Time) {
+	reason := atomic.LoadUint32(&p.goodbyeReason)
+	at := atomic.LoadInt64(&p.goodbyeAt)
+	if reason == 0 {
+		return 0, time.Time{}
+	}
+	return uint8(reason), time.Unix(0, at)
+}
+
+func (p *Peer) disconnect(reason uint8) {
+	p.quitOnce.Do(func() {
+		p.quitReason = reason
+		close(p.quit)
+	})
+}
+
+func (p *Peer) waitGoodbye() error {
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(GoodbyeTimeout):
+		return fmt.Errorf("peer goodbye timeout %s", p.IdForNetwork.String())
+	}
+}
+
+func (p *Peer) recordGoodbye(reason uint8) {
+	logger.Printf("PEER GOODBYE %s %s %s\n", p.IdForNetwork.String(), p.Address, GoodbyeReasonString(reason))
+	atomic.StoreInt64(&p.goodbyeAt, time.Now().UnixNano())
+	atomic.StoreUint32(&p.goodbyeReason, uint32(reason))
+}
+
+// goodbyeDelay is the remaining backoff required by the last goodbye.
+func (p *Peer) goodbyeDelay() time.Duration {
+	reason, at := p.Goodbye()
+	if reason == 0 {
+		return 0
+	}
+	d := time.Until(at.Add(goodbyeBackoff(reason)))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,188 @@
+package network
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testGoodbyeHandle struct {
+	SyncHandle
+	id crypto.Hash
+}
+
+func (h *testGoodbyeHandle) BuildAuthenticationMessage() []byte {
+	return h.id[:]
+}
+
+func (h *testGoodbyeHandle) Authenticate(msg []byte) (crypto.Hash, error) {
+	var id crypto.Hash
+	copy(id[:], msg)
+	return id, nil
+}
+
+func (h *testGoodbyeHandle) BuildGraph() []*SyncPoint {
+	return nil
+}
+
+func (h *testGoodbyeHandle) UpdateSyncPoint(peerId crypto.Hash, points []*SyncPoint) {
+}
+
+func (h *testGoodbyeHandle) TopologySequence() uint64 {
+	return 0
+}
+
+func (h *testGoodbyeHandle) UpdatePeerStatus(peerId crypto.Hash, topology uint64) {
+}
+
+type testPipeClient struct {
+	in    chan []byte
+	out   chan []byte
+	once  *sync.Once
+	close chan struct{}
+}
+
+func testPipe() (*testPipeClient, *testPipeClient) {
+	a, b := make(chan []byte, 16), make(chan []byte, 16)
+	closed := make(chan struct{})
+	once := new(sync.Once)
+	return &testPipeClient{in: a, out: b, once: once, close: closed}, &testPipeClient{in: b, out: a, once: once, close: closed}
+}
+
+func (c *testPipeClient) Receive() ([]byte, error) {
+	select {
+	case data := <-c.in:
+		return data, nil
+	case <-c.close:
+	}
+	select {
+	case data := <-c.in:
+		return data, nil
+	default:
+		return nil, errors.New("closed")
+	}
+}
+
+func (c *testPipeClient) Send(data []byte) error {
+	select {
+	case c.out <- data:
+		return nil
+	case <-c.close:
+		return errors.New("closed")
+	}
+}
+
+func (c *testPipeClient) Close() error {
+	c.once.Do(func() { close(c.close) })
+	return nil
+}
+
+func TestPeerGoodbye(t *testing.T) {
+	assert := assert.New(t)
+
+	for reason := uint8(GoodbyeReasonShutdown); reason <= GoodbyeReasonBlacklisted; reason++ {
+		msg, err := parseNetworkMessage(buildGoodbyeMessage(reason))
+		assert.Nil(err)
+		assert.Equal(uint8(PeerMessageTypeGoodbye), msg.Type)
+		assert.Equal(reason, msg.Reason)
+		assert.NotEqual("unknown", GoodbyeReasonString(reason))
+		assert.True(goodbyeBackoff(reason) >= SeedBackoffBase)
+	}
+	assert.True(goodbyeBackoff(GoodbyeReasonBlacklisted) > goodbyeBackoff(GoodbyeReasonShutdown))
+	_, err := parseNetworkMessage([]byte{PeerMessageTypeGoodbye})
+	assert.NotNil(err)
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	me := NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7005")
+	remote := NewPeer(&testGoodbyeHandle{id: remoteId}, remoteId, "127.0.0.1:7006")
+	neighbor := NewPeer(nil, meId, me.Address)
+	remote.neighbors.Put(meId, neighbor)
+	go remote.syncToNeighborLoop(neighbor)
+	me.dial = func(addr string) (Client, error) {
+		client, server := testPipe()
+		go remote.acceptNeighborConnection(server)
+		return client, nil
+	}
+
+	me.AddNeighbor(remoteId, remote.Address)
+	for i := 0; i < 50 && neighbor.metrics.Info().MessagesReceived == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.True(neighbor.metrics.Info().MessagesReceived > 0)
+	reason, _ := neighbor.Goodbye()
+	assert.Equal(uint8(0), reason)
+	assert.Equal(time.Duration(0), neighbor.goodbyeDelay())
+
+	err = me.Shutdown()
+	assert.Nil(err)
+	assert.Nil(me.GetNeighbor(remoteId))
+	for i := 0; i < 50; i++ {
+		if reason, _ = neighbor.Goodbye(); reason != 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	reason, at := neighbor.Goodbye()
+	assert.Equal(uint8(GoodbyeReasonShutdown), reason)
+	assert.True(time.Since(at) < time.Minute)
+	assert.True(neighbor.goodbyeDelay() > goodbyeBackoff(GoodbyeReasonShutdown)-time.Minute)
+	assert.True(neighbor.goodbyeDelay() <= goodbyeBackoff(GoodbyeReasonShutdown))
+
+	err = me.Disconnect(remoteId, GoodbyeReasonBlacklisted)
+	assert.Nil(err)
+}
+
+func TestPeerGoodbyeReasons(t *testing.T) {
+	assert := assert.New(t)
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	me := NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7005")
+	remote := NewPeer(&testGoodbyeHandle{id: remoteId}, remoteId, "127.0.0.1:7006")
+	neighbor := NewPeer(nil, meId, me.Address)
+	remote.neighbors.Put(meId, neighbor)
+	remote.dial = func(addr string) (Client, error) {
+		return nil, errors.New("offline")
+	}
+	go remote.openPeerStreamLoop(neighbor)
+
+	receiveGoodbye := func(client Client) uint8 {
+		data, err := client.Receive()
+		assert.Nil(err)
+		msg, err := parseNetworkMessage(data)
+		assert.Nil(err)
+		assert.Equal(uint8(PeerMessageTypeGoodbye), msg.Type)
+		return msg.Reason
+	}
+
+	client, server := testPipe()
+	go remote.acceptNeighborConnection(server)
+	incompatible := ProtocolVersions{Minimum: ProtocolVersionMaximum + 1, Maximum: ProtocolVersionMaximum + 1}
+	err := client.Send(buildAuthenticationMessage(incompatible, meId[:]))
+	assert.Nil(err)
+	assert.Equal(uint8(GoodbyeReasonVersionIncompatible), receiveGoodbye(client))
+
+	remote.handshakes.limit = 0
+	client, server = testPipe()
+	go remote.acceptNeighborConnection(server)
+	assert.Equal(uint8(GoodbyeReasonRateLimited), receiveGoodbye(client))
+	remote.handshakes.limit = HandshakePendingLimit
+
+	err = remote.Disconnect(meId, GoodbyeReasonBlacklisted)
+	assert.Nil(err)
+	remote.neighbors.Put(meId, NewPeer(nil, meId, me.Address))
+	me.dial = func(addr string) (Client, error) {
+		client, server := testPipe()
+		go remote.acceptNeighborConnection(server)
+		return client, nil
+	}
+	neighbor = NewPeer(nil, remoteId, remote.Address)
+	_, err = me.openPeerStream(neighbor, nil)
+	assert.NotNil(err)
+	reason, _ := neighbor.Goodbye()
+	assert.Equal(uint8(GoodbyeReasonBlacklisted), reason)
+	assert.True(neighbor.goodbyeDelay() > goodbyeBackoff(GoodbyeReasonShutdown))
+}
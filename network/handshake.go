@@ -0,0 +1,175 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+const (
+	HandshakeTimeoutDefault = 3 * time.Second
+	HandshakePendingLimit   = 1024
+)
+
+// handshakeReaper tracks the accepted connections not authenticated yet, and
+// closes the ones pending longer than the timeout, so a peer connecting but
+// never sending the handshake can't hold the connection open.
+type handshakeReaper struct {
+	mutex   *sync.Mutex
+	pending map[Client]time.Time
+	timeout time.Duration
+	limit   int
+	reaped  uint64
+}
+
+func newHandshakeReaper(timeout time.Duration) *handshakeReaper {
+	if timeout <= 0 {
+		timeout = HandshakeTimeoutDefault
+	}
+	return &handshakeReaper{
+		mutex:   new(sync.Mutex),
+		pending: make(map[Client]time.Time),
+		timeout: timeout,
+		limit:   HandshakePendingLimit,
+	}
+}
+
+func (r *handshakeReaper) add(c Client) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pending[c] = time.Now()
+}
+
+// remove returns false if the connection has been reaped already.
+func (r *handshakeReaper) remove(c Client) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, found := r.pending[c]
+	delete(r.pending, c)
+	return found
+}
+
+func (r *handshakeReaper) reap(now time.Time) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var count int
+	for c, at := range r.pending {
+		if now.Sub(at) < r.timeout {
+			continue
+		}
+		delete(r.pending, c)
+		c.Close()
+		count = count + 1
+	}
+	atomic.AddUint64(&r.reaped, uint64(count))
+	metrics.Counter("mixin_peer_handshake_timeouts_total", uint64(count))
+	return count
+}
+
+// full is true if too many connections are pending the handshake, the new
+// ones are refused with a rate limited goodbye then.
+func (r *handshakeReaper) full() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.pending) >= r.limit
+}
+
+func (r *handshakeReaper) size() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.pending)
+}
+
+func (r *handshakeReaper) setTimeout(timeout time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if timeout > 0 {
+		r.timeout = timeout
+	}
+}
+
+func (r *handshakeReaper) interval() time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.timeout / 4
+}
+
+func (me *Peer) loopReapHandshakes() {
+	for {
+		time.Sleep(me.handshakes.interval())
+		if n := me.handshakes.reap(time.Now()); n > 0 {
+			logger.Println("peer handshake timeout reaped", n)
+		}
+	}
+}
+
+func (me *Peer) SetHandshakeTimeout(timeout time.Duration) {
+	me.handshakes.setTimeout(timeout)
+}
+
+func (me *Peer) HandshakeTimeouts() uint64 {
+	return atomic.LoadUint64(&me.handshakes.reaped)
+}
+
+func (r *handshakeReaper) duration() time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.timeout
+}
+
+// buildHandshakeReply echoes the version chosen by the acceptor, along with
+// its own authentication, so the dialer uses the same version.
+func (me *Peer) buildHandshakeReply(version uint8) []byte {
+	versions := ProtocolVersions{Minimum: version, Maximum: version}
+	return buildAuthenticationMessage(versions, me.handle.BuildAuthenticationMessage())
+}
+
+// receiveHandshakeReply waits the reply of the acceptor after the dialer sent
+// its authentication, and returns the acceptor id and the version chosen, or
+// a goodbyeError if the acceptor refused the dialer with a goodbye.
+func (me *Peer) receiveHandshakeReply(client Client) (crypto.Hash, uint8, error) {
+	type reply struct {
+		data []byte
+		err  error
+	}
+	received := make(chan reply, 1)
+	go func() {
+		data, err := client.Receive()
+		received <- reply{data, err}
+	}()
+
+	var r reply
+	select {
+	case r = <-received:
+	case <-time.After(me.handshakes.duration()):
+		return crypto.Hash{}, 0, errors.New("peer handshake reply timeout")
+	}
+	if r.err != nil {
+		return crypto.Hash{}, 0, r.err
+	}
+	msg, err := parseNetworkMessage(r.data)
+	if err != nil {
+		return crypto.Hash{}, 0, err
+	}
+	if msg.Type == PeerMessageTypeGoodbye {
+		return crypto.Hash{}, 0, &goodbyeError{reason: msg.Reason}
+	}
+	if msg.Type != PeerMessageTypeAuthentication {
+		return crypto.Hash{}, 0, errors.New("peer handshake reply invalid message type")
+	}
+	version := msg.Versions.Maximum
+	if msg.Versions.Minimum != version || version < ProtocolVersionMinimum || version > ProtocolVersionMaximum {
+		return crypto.Hash{}, 0, fmt.Errorf("peer handshake reply invalid version %d-%d", msg.Versions.Minimum, msg.Versions.Maximum)
+	}
+	id, err := me.handle.Authenticate(msg.Data)
+	if err != nil {
+		return crypto.Hash{}, 0, err
+	}
+	return id, version, nil
+}
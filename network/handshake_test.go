@@ -0,0 +1,50 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandshakeTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	me := NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7005")
+	me.SetHandshakeTimeout(200 * time.Millisecond)
+	go me.loopReapHandshakes()
+
+	silent, server := testPipe()
+	defer silent.Close()
+	accepted := make(chan error, 1)
+	go func() { accepted <- me.acceptNeighborConnection(server) }()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(1, me.handshakes.size())
+	assert.Equal(uint64(0), me.HandshakeTimeouts())
+
+	select {
+	case err := <-accepted:
+		assert.NotNil(err)
+		assert.Contains(err.Error(), "peer authentication timeout")
+	case <-time.After(2 * time.Second):
+		assert.Fail("half-open connection not reaped")
+	}
+	assert.Equal(0, me.handshakes.size())
+	assert.Equal(uint64(1), me.HandshakeTimeouts())
+	_, err := silent.Receive()
+	assert.NotNil(err)
+
+	me.neighbors.Put(remoteId, NewPeer(nil, remoteId, "127.0.0.1:7006"))
+	client, server := testPipe()
+	defer client.Close()
+	go me.acceptNeighborConnection(server)
+	err = client.Send(buildAuthenticationMessage(LocalProtocolVersions(), remoteId[:]))
+	assert.Nil(err)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(0, me.handshakes.size())
+	assert.Equal(uint64(1), me.HandshakeTimeouts())
+	err = client.Send(buildPingMessage())
+	assert.Nil(err)
+}
@@ -0,0 +1,225 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	MetricsPendingLimit    = 1024
+	MetricsSnapshotSample  = 16
+	MetricsPendingDuration = time.Minute
+	MetricsEmitInterval    = 10 * time.Second
+)
+
+type PeerMetricsInfo struct {
+	Peer             crypto.Hash `json:"peer"`
+	Address          string      `json:"address"`
+	Latency          uint64      `json:"latency"`
+	LatencySamples   uint64      `json:"latency_samples"`
+	BytesSent        uint64      `json:"bytes_sent"`
+	BytesReceived    uint64      `json:"bytes_received"`
+	MessagesSent     uint64      `json:"messages_sent"`
+	MessagesReceived uint64      `json:"messages_received"`
+	LastSeen         uint64      `json:"last_seen"`
+}
+
+// PeerMetrics accumulates the counters with atomic operations only, and the
+// round-trip latency is measured from the transaction request and transaction
+// pairs, and one in every MetricsSnapshotSample snapshot and confirm pairs.
+// The counters reach the metrics backend only in emitMetrics, never on each
+// message.
+type PeerMetrics struct {
+	bytesSent        uint64
+	bytesReceived    uint64
+	messagesSent     uint64
+	messagesReceived uint64
+	lastSeen         int64
+	latencyTotal     uint64
+	latencySamples   uint64
+	snapshots        uint64
+	gossip           [GossipTopicTransaction + 1]uint64
+
+	emitted       PeerMetricsInfo
+	emittedGossip [GossipTopicTransaction + 1]uint64
+
+	mutex   *sync.Mutex
+	pending map[crypto.Hash]time.Time
+}
+
+func NewPeerMetrics() *PeerMetrics {
+	return &PeerMetrics{
+		mutex:   new(sync.Mutex),
+		pending: make(map[crypto.Hash]time.Time),
+	}
+}
+
+func (m *PeerMetrics) onSend(data []byte) {
+	atomic.AddUint64(&m.messagesSent, 1)
+	atomic.AddUint64(&m.bytesSent, uint64(len(data)))
+	if len(data) < 1 {
+		return
+	}
+	switch data[0] {
+	case PeerMessageTypeTransactionRequest:
+		var tx crypto.Hash
+		copy(tx[:], data[1:])
+		m.request(tx)
+	case PeerMessageTypeSnapshot:
+		if atomic.AddUint64(&m.snapshots, 1)%MetricsSnapshotSample != 1 {
+			return
+		}
+		var s common.Snapshot
+		if msgpack.Unmarshal(data[1:], &s) == nil {
+			m.request(s.PayloadHash())
+		}
+	}
+}
+
+func (m *PeerMetrics) onReceive(msg *PeerMessage, size int) {
+	atomic.AddUint64(&m.messagesReceived, 1)
+	atomic.AddUint64(&m.bytesReceived, uint64(size))
+	atomic.StoreInt64(&m.lastSeen, time.Now().UnixNano())
+	switch msg.Type {
+	case PeerMessageTypeSnapshotConfirm:
+		m.respond(msg.SnapshotHash)
+	case PeerMessageTypeTransaction:
+		m.respond(msg.Transaction.PayloadHash())
+	}
+}
+
+func (m *PeerMetrics) gossipSent(topic int) {
+	atomic.AddUint64(&m.gossip[topic], 1)
+}
+
+func (m *PeerMetrics) request(key crypto.Hash) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	if len(m.pending) >= MetricsPendingLimit {
+		for k, t := range m.pending {
+			if now.Sub(t) > MetricsPendingDuration {
+				delete(m.pending, k)
+			}
+		}
+	}
+	if len(m.pending) >= MetricsPendingLimit {
+		return
+	}
+	if _, found := m.pending[key]; !found {
+		m.pending[key] = now
+	}
+}
+
+func (m *PeerMetrics) respond(key crypto.Hash) {
+	m.mutex.Lock()
+	t, found := m.pending[key]
+	delete(m.pending, key)
+	m.mutex.Unlock()
+	if !found {
+		return
+	}
+	atomic.AddUint64(&m.latencyTotal, uint64(time.Since(t)))
+	atomic.AddUint64(&m.latencySamples, 1)
+}
+
+func (m *PeerMetrics) Info() PeerMetricsInfo {
+	info := PeerMetricsInfo{
+		LatencySamples:   atomic.LoadUint64(&m.latencySamples),
+		BytesSent:        atomic.LoadUint64(&m.bytesSent),
+		BytesReceived:    atomic.LoadUint64(&m.bytesReceived),
+		MessagesSent:     atomic.LoadUint64(&m.messagesSent),
+		MessagesReceived: atomic.LoadUint64(&m.messagesReceived),
+		LastSeen:         uint64(atomic.LoadInt64(&m.lastSeen)),
+	}
+	if info.LatencySamples > 0 {
+		info.Latency = atomic.LoadUint64(&m.latencyTotal) / info.LatencySamples
+	}
+	return info
+}
+
+func (me *Peer) PeerMetrics() []PeerMetricsInfo {
+	infos := make([]PeerMetricsInfo, 0)
+	for _, p := range me.neighbors.Slice() {
+		info := p.metrics.Info()
+		info.Peer = p.IdForNetwork
+		info.Address = p.Address
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (me *Peer) loopEmitMetrics() {
+	for {
+		select {
+		case <-me.quit:
+			return
+		case <-time.After(MetricsEmitInterval):
+		}
+		me.emitMetrics()
+	}
+}
+
+// emitMetrics sends the counters of each neighbor accumulated since the last
+// emission to the metrics backend, along with their sums for the network.
+func (me *Peer) emitMetrics() {
+	var total PeerMetricsInfo
+	var gossip [GossipTopicTransaction + 1]uint64
+	for _, p := range me.neighbors.Slice() {
+		info := p.metrics.Info()
+		delta := p.metrics.delta(info)
+		labels := []string{"peer", p.IdForNetwork.String(), "address", p.Address}
+		metrics.Gauge(metrics.Labeled("mixin_peer_latency_seconds", labels...), float64(info.Latency)/1e9)
+		metrics.Gauge(metrics.Labeled("mixin_peer_last_seen_seconds", labels...), float64(info.LastSeen)/1e9)
+		metrics.Counter(metrics.Labeled("mixin_peer_latency_samples_total", labels...), delta.LatencySamples)
+		metrics.Counter(metrics.Labeled("mixin_peer_sent_bytes_total", labels...), delta.BytesSent)
+		metrics.Counter(metrics.Labeled("mixin_peer_received_bytes_total", labels...), delta.BytesReceived)
+		metrics.Counter(metrics.Labeled("mixin_peer_sent_messages_total", labels...), delta.MessagesSent)
+		metrics.Counter(metrics.Labeled("mixin_peer_received_messages_total", labels...), delta.MessagesReceived)
+		total.BytesSent += delta.BytesSent
+		total.BytesReceived += delta.BytesReceived
+		total.MessagesSent += delta.MessagesSent
+		total.MessagesReceived += delta.MessagesReceived
+		for topic := range gossip {
+			sent := atomic.LoadUint64(&p.metrics.gossip[topic])
+			gossip[topic] += sent - p.metrics.emittedGossip[topic]
+			p.metrics.emittedGossip[topic] = sent
+		}
+	}
+	metrics.Counter("mixin_network_sent_messages_total", total.MessagesSent)
+	metrics.Counter("mixin_network_sent_bytes_total", total.BytesSent)
+	metrics.Counter("mixin_network_received_messages_total", total.MessagesReceived)
+	metrics.Counter("mixin_network_received_bytes_total", total.BytesReceived)
+	for topic, sent := range gossip {
+		metrics.Counter("mixin_network_gossip_"+GossipTopicString(topic)+"_sent_total", sent)
+	}
+}
+
+// delta returns the counters increased since the last call, which is only
+// made by emitMetrics.
+func (m *PeerMetrics) delta(info PeerMetricsInfo) PeerMetricsInfo {
+	last := m.emitted
+	m.emitted = info
+	return PeerMetricsInfo{
+		LatencySamples:   info.LatencySamples - last.LatencySamples,
+		BytesSent:        info.BytesSent - last.BytesSent,
+		BytesReceived:    info.BytesReceived - last.BytesReceived,
+		MessagesSent:     info.MessagesSent - last.MessagesSent,
+		MessagesReceived: info.MessagesReceived - last.MessagesReceived,
+	}
+}
+
+func (p *Peer) sendToClient(client Client, data []byte) error {
+	err := client.Send(data)
+	if err == nil {
+		p.metrics.onSend(data)
+	}
+	return err
+}
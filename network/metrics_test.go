@@ -0,0 +1,123 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetricsClient struct {
+	sent [][]byte
+	fail bool
+}
+
+func (c *testMetricsClient) Receive() ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *testMetricsClient) Send(data []byte) error {
+	if c.fail {
+		return errors.New("send failed")
+	}
+	c.sent = append(c.sent, data)
+	return nil
+}
+
+func (c *testMetricsClient) Close() error {
+	return nil
+}
+
+type testMetricsBackend struct {
+	counters map[string]uint64
+	gauges   map[string]float64
+}
+
+func (b *testMetricsBackend) Counter(name string, delta uint64) {
+	b.counters[name] = b.counters[name] + delta
+}
+
+func (b *testMetricsBackend) Gauge(name string, value float64) {
+	b.gauges[name] = value
+}
+
+func (b *testMetricsBackend) Histogram(name string, value float64) {}
+
+func TestPeerMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	me := NewPeer(nil, crypto.NewHash([]byte("me")), "127.0.0.1:7001")
+	id := crypto.NewHash([]byte("neighbor"))
+	peer := NewPeer(nil, id, "127.0.0.1:7002")
+	me.neighbors.Put(id, peer)
+	client := &testMetricsClient{}
+
+	tx := &common.SignedTransaction{Transaction: *common.NewTransaction(common.XINAssetId)}
+	request := buildTransactionRequestMessage(tx.PayloadHash())
+	err := peer.sendToClient(client, request)
+	assert.Nil(err)
+	err = peer.sendToClient(client, buildPingMessage())
+	assert.Nil(err)
+	client.fail = true
+	err = peer.sendToClient(client, buildPingMessage())
+	assert.NotNil(err)
+
+	time.Sleep(10 * time.Millisecond)
+	before := uint64(time.Now().UnixNano())
+	data := buildTransactionMessage(tx)
+	msg, err := parseNetworkMessage(data)
+	assert.Nil(err)
+	peer.metrics.onReceive(msg, len(data))
+	unknown := crypto.NewHash([]byte("unknown"))
+	confirm := buildSnapshotConfirmMessage(unknown, 1)
+	msg, err = parseNetworkMessage(confirm)
+	assert.Nil(err)
+	peer.metrics.onReceive(msg, len(confirm))
+
+	infos := me.PeerMetrics()
+	assert.Len(infos, 1)
+	info := infos[0]
+	assert.Equal(id, info.Peer)
+	assert.Equal("127.0.0.1:7002", info.Address)
+	assert.Equal(uint64(2), info.MessagesSent)
+	assert.Equal(uint64(len(request)+1), info.BytesSent)
+	assert.Equal(uint64(2), info.MessagesReceived)
+	assert.Equal(uint64(len(data)+len(confirm)), info.BytesReceived)
+	assert.Equal(uint64(1), info.LatencySamples)
+	assert.True(info.Latency >= uint64(10*time.Millisecond))
+	assert.True(info.LastSeen >= before)
+
+	peer.metrics.onReceive(msg, len(confirm))
+	assert.Equal(uint64(1), peer.metrics.Info().LatencySamples)
+
+	s := &common.Snapshot{NodeId: id, Transaction: tx.PayloadHash()}
+	for i := 0; i < MetricsSnapshotSample; i++ {
+		err = me.sendToClient(&testMetricsClient{}, buildSnapshotMessage(s))
+		assert.Nil(err)
+	}
+	confirm = buildSnapshotConfirmMessage(s.PayloadHash(), 0)
+	msg, err = parseNetworkMessage(confirm)
+	assert.Nil(err)
+	me.metrics.onReceive(msg, len(confirm))
+	assert.Equal(uint64(1), me.metrics.Info().LatencySamples)
+
+	backend := &testMetricsBackend{counters: make(map[string]uint64), gauges: make(map[string]float64)}
+	metrics.Use(backend)
+	defer metrics.Use(metrics.NewPrometheus())
+	peer.metrics.gossipSent(GossipTopicSnapshot)
+	me.emitMetrics()
+	me.emitMetrics()
+	sent := metrics.Labeled("mixin_peer_sent_messages_total", "peer", id.String(), "address", "127.0.0.1:7002")
+	assert.Equal(uint64(2), backend.counters[sent])
+	assert.Equal(uint64(2), backend.counters["mixin_network_sent_messages_total"])
+	assert.Equal(uint64(3), backend.counters["mixin_network_received_messages_total"])
+	assert.Equal(uint64(len(request)+1), backend.counters["mixin_network_sent_bytes_total"])
+	assert.Equal(uint64(1), backend.counters["mixin_network_gossip_snapshot_sent_total"])
+	assert.Equal(uint64(0), backend.counters["mixin_network_gossip_control_sent_total"])
+	latency := metrics.Labeled("mixin_peer_latency_seconds", "peer", id.String(), "address", "127.0.0.1:7002")
+	assert.True(backend.gauges[latency] >= 0.01)
+}
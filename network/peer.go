@@ -1,6 +1,7 @@
 package network
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sync"
@@ -22,6 +23,11 @@ const (
 	PeerMessageTypeSnapshotConfirm    = 5
 	PeerMessageTypeTransactionRequest = 6
 	PeerMessageTypeTransaction        = 7
+	PeerMessageTypeGoodbye            = 8
+	PeerMessageTypeStatus             = 9
+	PeerMessageTypePause              = 10
+	PeerMessageTypeResume             = 11
+	PeerMessageTypeSnapshotRequest    = 12
 )
 
 type ConfirmMap struct {
@@ -42,6 +48,11 @@ type PeerMessage struct {
 	Transaction     *common.SignedTransaction
 	TransactionHash crypto.Hash
 	FinalCache      []*SyncPoint
+	Versions        ProtocolVersions
+	Reason          uint8
+	Topology        uint64
+	NodeId          crypto.Hash
+	RoundNumber     uint64
 	Data            []byte
 }
 
@@ -55,6 +66,8 @@ type SyncHandle interface {
 	ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error)
 	ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error)
 	UpdateSyncPoint(peerId crypto.Hash, points []*SyncPoint)
+	TopologySequence() uint64
+	UpdatePeerStatus(peerId crypto.Hash, topology uint64)
 }
 
 type SyncPoint struct {
@@ -71,29 +84,52 @@ type ChanMsg struct {
 type Peer struct {
 	IdForNetwork crypto.Hash
 	Address      string
+	Version      uint8
 
 	storeCache             *cache.Cache
 	snapshotsConfirmations *ConfirmMap
 	snapshotsCaches        *ConfirmMap
-	neighbors              map[crypto.Hash]*Peer
+	neighbors              *neighborMap
+	seeds                  *SeedList
+	book                   *AddressBook
+	metrics                *PeerMetrics
 	handle                 SyncHandle
 	transport              Transport
+	dial                   func(addr string) (Client, error)
+	dialer                 *dialLimiter
+	handshakes             *handshakeReaper
+	blacklist              *sync.Map
+	requests               *requestTracker
 	high                   chan *ChanMsg
 	normal                 chan *ChanMsg
+	transactions           chan *ChanMsg
+	gossipTopics           bool
+	lastGossip             int
 	sync                   chan []*SyncPoint
+	quit                   chan struct{}
+	quitOnce               *sync.Once
+	quitReason             uint8
+	done                   chan struct{}
+	goodbyeReason          uint32
+	goodbyeAt              int64
+	pausedUntil            int64
 }
 
 func (me *Peer) AddNeighbor(idForNetwork crypto.Hash, addr string) {
 	peer := NewPeer(nil, idForNetwork, addr)
-	if peer.Address == me.Address || me.neighbors[peer.IdForNetwork] != nil {
+	if peer.Address == me.Address || !me.neighbors.Put(peer.IdForNetwork, peer) {
 		return
 	}
-	me.neighbors[peer.IdForNetwork] = peer
+	me.seeds.Add(peer.Address)
 
 	go me.openPeerStreamLoop(peer)
 	go me.syncToNeighborLoop(peer)
 }
 
+func (me *Peer) GetNeighbor(idForNetwork crypto.Hash) *Peer {
+	return me.neighbors.Get(idForNetwork)
+}
+
 func NewPeer(handle SyncHandle, idForNetwork crypto.Hash, addr string) *Peer {
 	return &Peer{
 		IdForNetwork:           idForNetwork,
@@ -101,12 +137,32 @@ func NewPeer(handle SyncHandle, idForNetwork crypto.Hash, addr string) *Peer {
 		storeCache:             cache.New(config.CacheTTL, 10*time.Minute),
 		snapshotsConfirmations: new(ConfirmMap),
 		snapshotsCaches:        new(ConfirmMap),
-		neighbors:              make(map[crypto.Hash]*Peer),
+		neighbors:              &neighborMap{mutex: new(sync.RWMutex), m: make(map[crypto.Hash]*Peer)},
+		seeds:                  NewSeedList(nil),
+		book:                   NewAddressBook(),
+		metrics:                NewPeerMetrics(),
 		high:                   make(chan *ChanMsg, 1024*1024),
 		normal:                 make(chan *ChanMsg, 1024*1024),
+		transactions:           make(chan *ChanMsg, 1024*1024),
 		sync:                   make(chan []*SyncPoint),
+		quit:                   make(chan struct{}),
+		quitOnce:               new(sync.Once),
+		done:                   make(chan struct{}),
 		handle:                 handle,
+		dial:                   dialQuic,
+		dialer:                 newDialLimiter(DialConcurrencyDefault, DialTimeoutDefault),
+		handshakes:             newHandshakeReaper(HandshakeTimeoutDefault),
+		blacklist:              new(sync.Map),
+		requests:               newRequestTracker(RequestTimeoutDefault, RequestAttemptsDefault),
+	}
+}
+
+func dialQuic(addr string) (Client, error) {
+	transport, err := NewQuicClient(addr)
+	if err != nil {
+		return nil, err
 	}
+	return transport.Dial()
 }
 
 func (me *Peer) SendTransactionRequestMessage(idForNetwork crypto.Hash, tx crypto.Hash) error {
@@ -114,17 +170,23 @@ func (me *Peer) SendTransactionRequestMessage(idForNetwork crypto.Hash, tx crypt
 		return nil
 	}
 
-	key := tx.ForNetwork(idForNetwork)
-	key = crypto.NewHash(append(key[:], 'R', 'Q'))
-	if me.snapshotsCaches.Exist(key, time.Minute) {
+	peer := me.neighbors.Get(idForNetwork)
+	if peer == nil {
 		return nil
 	}
+	if !me.requests.track(tx, idForNetwork, time.Now()) {
+		return nil
+	}
+	return me.sendTransactionRequest(peer, tx)
+}
 
-	peer := me.neighbors[idForNetwork]
-	if peer == nil {
+func (me *Peer) sendTransactionRequest(peer *Peer, tx crypto.Hash) error {
+	key := tx.ForNetwork(peer.IdForNetwork)
+	key = crypto.NewHash(append(key[:], 'R', 'Q'))
+	if me.snapshotsCaches.Exist(key, time.Minute) {
 		return nil
 	}
-	return peer.SendHigh(key, buildTransactionRequestMessage(tx))
+	return peer.SendTopic(me.transactionTopic(), key, buildTransactionRequestMessage(tx))
 }
 
 func (me *Peer) SendTransactionMessage(idForNetwork crypto.Hash, tx *common.SignedTransaction) error {
@@ -138,11 +200,11 @@ func (me *Peer) SendTransactionMessage(idForNetwork crypto.Hash, tx *common.Sign
 		return nil
 	}
 
-	peer := me.neighbors[idForNetwork]
+	peer := me.neighbors.Get(idForNetwork)
 	if peer == nil {
 		return nil
 	}
-	return peer.SendHigh(key, buildTransactionMessage(tx))
+	return peer.SendTopic(me.transactionTopic(), key, buildTransactionMessage(tx))
 }
 
 func (me *Peer) SendSnapshotConfirmMessage(idForNetwork crypto.Hash, snap crypto.Hash, finalized byte) error {
@@ -156,7 +218,7 @@ func (me *Peer) SendSnapshotConfirmMessage(idForNetwork crypto.Hash, snap crypto
 		return nil
 	}
 
-	peer := me.neighbors[idForNetwork]
+	peer := me.neighbors.Get(idForNetwork)
 	if peer == nil {
 		return nil
 	}
@@ -183,7 +245,7 @@ func (me *Peer) SendSnapshotMessage(idForNetwork crypto.Hash, s *common.Snapshot
 		return nil
 	}
 
-	peer := me.neighbors[idForNetwork]
+	peer := me.neighbors.Get(idForNetwork)
 	if peer == nil {
 		return nil
 	}
@@ -191,21 +253,11 @@ func (me *Peer) SendSnapshotMessage(idForNetwork crypto.Hash, s *common.Snapshot
 }
 
 func (p *Peer) SendHigh(key crypto.Hash, data []byte) error {
-	select {
-	case p.high <- &ChanMsg{key, data}:
-		return nil
-	case <-time.After(1 * time.Second):
-		return errors.New("peer send high timeout")
-	}
+	return p.SendTopic(GossipTopicControl, key, data)
 }
 
 func (p *Peer) SendNormal(key crypto.Hash, data []byte) error {
-	select {
-	case p.normal <- &ChanMsg{key, data}:
-		return nil
-	case <-time.After(1 * time.Second):
-		return errors.New("peer send normal timeout")
-	}
+	return p.SendTopic(GossipTopicSnapshot, key, data)
 }
 
 func (me *Peer) ListenNeighbors() error {
@@ -219,6 +271,9 @@ func (me *Peer) ListenNeighbors() error {
 	if err != nil {
 		return err
 	}
+	go me.loopReapHandshakes()
+	go me.loopRetryRequests()
+	go me.loopEmitMetrics()
 
 	for {
 		c, err := me.transport.Accept()
@@ -254,7 +309,11 @@ func parseNetworkMessage(data []byte) (*PeerMessage, error) {
 		}
 	case PeerMessageTypePing:
 	case PeerMessageTypeAuthentication:
-		msg.Data = data[1:]
+		if len(data) < 3 {
+			return nil, errors.New("invalid authentication message data")
+		}
+		msg.Versions = ProtocolVersions{Minimum: data[1], Maximum: data[2]}
+		msg.Data = data[3:]
 	case PeerMessageTypeSnapshotConfirm:
 		msg.Finalized = data[1]
 		copy(msg.SnapshotHash[:], data[2:])
@@ -267,12 +326,30 @@ func parseNetworkMessage(data []byte) (*PeerMessage, error) {
 		msg.Transaction = &tx
 	case PeerMessageTypeTransactionRequest:
 		copy(msg.TransactionHash[:], data[1:])
+	case PeerMessageTypeGoodbye:
+		if len(data) < 2 {
+			return nil, errors.New("invalid goodbye message data")
+		}
+		msg.Reason = data[1]
+	case PeerMessageTypeStatus:
+		if len(data) != 9 {
+			return nil, errors.New("invalid status message data")
+		}
+		msg.Topology = binary.BigEndian.Uint64(data[1:])
+	case PeerMessageTypePause:
+	case PeerMessageTypeResume:
+	case PeerMessageTypeSnapshotRequest:
+		if len(data) != 41 {
+			return nil, errors.New("invalid snapshot request message data")
+		}
+		copy(msg.NodeId[:], data[1:33])
+		msg.RoundNumber = binary.BigEndian.Uint64(data[33:])
 	}
 	return msg, nil
 }
 
-func buildAuthenticationMessage(data []byte) []byte {
-	header := []byte{PeerMessageTypeAuthentication}
+func buildAuthenticationMessage(versions ProtocolVersions, data []byte) []byte {
+	header := []byte{PeerMessageTypeAuthentication, versions.Minimum, versions.Maximum}
 	return append(header, data...)
 }
 
@@ -303,35 +380,69 @@ func buildGraphMessage(points []*SyncPoint) []byte {
 	return append([]byte{PeerMessageTypeGraph}, data...)
 }
 
+func buildStatusMessage(topology uint64) []byte {
+	data := make([]byte, 9)
+	data[0] = PeerMessageTypeStatus
+	binary.BigEndian.PutUint64(data[1:], topology)
+	return data
+}
+
 func (me *Peer) openPeerStreamLoop(p *Peer) {
+	defer close(p.done)
+
 	var resend *ChanMsg
 	for {
 		msg, err := me.openPeerStream(p, resend)
+		if err == errPeerGoodbye {
+			return
+		}
 		if err != nil {
 			logger.Println("neighbor open stream error", err)
 		}
 		resend = msg
-		time.Sleep(1 * time.Second)
+		backoff := me.seeds.Backoff(p.Address)
+		if d := p.goodbyeDelay(); d > backoff {
+			backoff = d
+		}
+		select {
+		case <-p.quit:
+			return
+		case <-time.After(backoff):
+		}
 	}
 }
 
 func (me *Peer) openPeerStream(peer *Peer, resend *ChanMsg) (*ChanMsg, error) {
 	logger.Println("OPEN PEER STREAM", peer.Address)
-	transport, err := NewQuicClient(peer.Address)
-	if err != nil {
+	client, err := me.dialer.dial(peer.Address, peer.quit, me.dial)
+	if err == errPeerGoodbye {
 		return nil, err
 	}
-	client, err := transport.Dial()
 	if err != nil {
+		me.seeds.Fail(peer.Address)
+		me.book.Fail(peer.IdForNetwork, peer.Address)
 		return nil, err
 	}
 	defer client.Close()
+	me.seeds.Succeed(peer.Address)
+	me.book.Succeed(peer.IdForNetwork, peer.Address)
 	logger.Println("DIAL PEER STREAM", peer.Address)
 
-	err = client.Send(buildAuthenticationMessage(me.handle.BuildAuthenticationMessage()))
+	err = peer.sendToClient(client, buildAuthenticationMessage(LocalProtocolVersions(), me.handle.BuildAuthenticationMessage()))
+	if err != nil {
+		return nil, err
+	}
+	id, version, err := me.receiveHandshakeReply(client)
+	if ge, ok := err.(*goodbyeError); ok {
+		peer.recordGoodbye(ge.reason)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if id != peer.IdForNetwork {
+		return nil, fmt.Errorf("peer handshake reply id mismatch %s %s", peer.IdForNetwork.String(), id.String())
+	}
+	peer.Version = version
 	logger.Println("AUTH PEER STREAM", peer.Address)
 
 	pingTicker := time.NewTicker(1 * time.Second)
@@ -343,7 +454,7 @@ func (me *Peer) openPeerStream(peer *Peer, resend *ChanMsg) (*ChanMsg, error) {
 	if resend != nil {
 		logger.Println("RESEND PEER STREAM", resend.key.String())
 		if !me.snapshotsCaches.Exist(resend.key, time.Minute) {
-			err := client.Send(resend.data)
+			err := peer.sendToClient(client, resend.data)
 			if err != nil {
 				return resend, err
 			}
@@ -353,44 +464,48 @@ func (me *Peer) openPeerStream(peer *Peer, resend *ChanMsg) (*ChanMsg, error) {
 
 	logger.Println("LOOP PEER STREAM", peer.Address)
 	for {
-		hd, nd := false, false
-		select {
-		case msg := <-peer.high:
-			if !me.snapshotsCaches.Exist(msg.key, time.Minute) {
-				err := client.Send(msg.data)
-				if err != nil {
-					return msg, err
-				}
-				me.snapshotsCaches.Store(msg.key, time.Now())
+		if peer.goodbyeDelay() > 0 {
+			return nil, fmt.Errorf("peer said goodbye %s", peer.Address)
+		}
+		idle := false
+		msg, topic := peer.nextGossip(me.gossipTopics)
+		if msg != nil && !me.snapshotsCaches.Exist(msg.key, time.Minute) {
+			err := peer.sendToClient(client, msg.data)
+			if err != nil {
+				return msg, err
 			}
-		default:
-			hd = true
+			me.snapshotsCaches.Store(msg.key, time.Now())
+			peer.metrics.gossipSent(topic)
 		}
 
 		select {
-		case msg := <-peer.normal:
-			if !me.snapshotsCaches.Exist(msg.key, time.Minute) {
-				err := client.Send(msg.data)
-				if err != nil {
-					return msg, err
-				}
-				me.snapshotsCaches.Store(msg.key, time.Now())
+		case <-peer.quit:
+			err := peer.sendToClient(client, buildGoodbyeMessage(peer.quitReason))
+			if err != nil {
+				logger.Println("neighbor goodbye error", err)
 			}
+			return nil, errPeerGoodbye
 		case <-graphTicker.C:
-			err := client.Send(buildGraphMessage(me.handle.BuildGraph()))
+			err := peer.sendToClient(client, buildGraphMessage(me.handle.BuildGraph()))
+			if err != nil {
+				return nil, err
+			}
+			err = peer.sendToClient(client, buildStatusMessage(me.handle.TopologySequence()))
 			if err != nil {
 				return nil, err
 			}
+			peer.metrics.gossipSent(GossipTopicBeacon)
 		case <-pingTicker.C:
-			err := client.Send(buildPingMessage())
+			err := peer.sendToClient(client, buildPingMessage())
 			if err != nil {
 				return nil, err
 			}
+			peer.metrics.gossipSent(GossipTopicBeacon)
 		default:
-			nd = true
+			idle = msg == nil
 		}
 
-		if hd && nd {
+		if idle {
 			time.Sleep(100 * time.Millisecond)
 		}
 	}
@@ -404,6 +519,10 @@ func (me *Peer) acceptNeighborConnection(client Client) error {
 		logger.Println("peer authentication error", err)
 		return err
 	}
+	err = peer.sendToClient(client, me.buildHandshakeReply(peer.Version))
+	if err != nil {
+		return err
+	}
 
 	for {
 		data, err := client.Receive()
@@ -414,26 +533,52 @@ func (me *Peer) acceptNeighborConnection(client Client) error {
 		if err != nil {
 			return err
 		}
+		peer.metrics.onReceive(msg, len(data))
 		switch msg.Type {
 		case PeerMessageTypePing:
 		case PeerMessageTypeSnapshot:
-			me.handle.QueueAppendSnapshot(peer.IdForNetwork, msg.Snapshot)
+			err := me.handle.QueueAppendSnapshot(peer.IdForNetwork, msg.Snapshot)
+			if err != nil {
+				logger.Println("peer snapshot error", peer.IdForNetwork.String(), err)
+			}
 		case PeerMessageTypeGraph:
 			me.handle.UpdateSyncPoint(peer.IdForNetwork, msg.FinalCache)
-			peer.sync <- msg.FinalCache
+			select {
+			case peer.sync <- msg.FinalCache:
+			case <-peer.quit:
+				return nil
+			}
+		case PeerMessageTypeStatus:
+			me.handle.UpdatePeerStatus(peer.IdForNetwork, msg.Topology)
+		case PeerMessageTypePause:
+			peer.flowPause()
+		case PeerMessageTypeResume:
+			peer.flowResume()
 		case PeerMessageTypeTransactionRequest:
 			me.handle.SendTransactionToPeer(peer.IdForNetwork, msg.TransactionHash)
+		case PeerMessageTypeSnapshotRequest:
+			me.sendSnapshotsToPeer(peer, msg.NodeId, msg.RoundNumber)
 		case PeerMessageTypeTransaction:
-			me.handle.CachePutTransaction(msg.Transaction)
+			me.handleTransaction(msg.Transaction)
 		case PeerMessageTypeSnapshotConfirm:
 			me.ConfirmSnapshotForPeer(peer.IdForNetwork, msg.SnapshotHash, msg.Finalized)
+		case PeerMessageTypeGoodbye:
+			peer.recordGoodbye(msg.Reason)
+			return nil
 		}
 	}
 }
 
 func (me *Peer) authenticateNeighbor(client Client) (*Peer, error) {
+	if me.handshakes.full() {
+		client.Send(buildGoodbyeMessage(GoodbyeReasonRateLimited))
+		client.Close()
+		return nil, errors.New("peer authentication rate limited")
+	}
+
 	var peer *Peer
-	auth := make(chan error)
+	auth := make(chan error, 1)
+	me.handshakes.add(client)
 	go func() {
 		data, err := client.Receive()
 		if err != nil {
@@ -449,16 +594,28 @@ func (me *Peer) authenticateNeighbor(client Client) (*Peer, error) {
 			auth <- errors.New("peer authentication invalid message type")
 			return
 		}
+		version, err := NegotiateProtocolVersion(LocalProtocolVersions(), msg.Versions)
+		if err != nil {
+			client.Send(buildGoodbyeMessage(GoodbyeReasonVersionIncompatible))
+			auth <- err
+			return
+		}
 
 		id, err := me.handle.Authenticate(msg.Data)
 		if err != nil {
 			auth <- err
 			return
 		}
-		for _, p := range me.neighbors {
+		if _, found := me.blacklist.Load(id); found {
+			client.Send(buildGoodbyeMessage(GoodbyeReasonBlacklisted))
+			auth <- errors.New("peer authentication blacklisted")
+			return
+		}
+		for _, p := range me.neighbors.Slice() {
 			if id != p.IdForNetwork {
 				continue
 			}
+			p.Version = version
 			peer = p
 			auth <- nil
 			return
@@ -466,15 +623,52 @@ func (me *Peer) authenticateNeighbor(client Client) (*Peer, error) {
 		auth <- errors.New("peer authentication message signature invalid")
 	}()
 
-	select {
-	case err := <-auth:
-		if err != nil {
-			client.Close()
-			return nil, fmt.Errorf("peer authentication failed %s", err.Error())
-		}
-	case <-time.After(3 * time.Second):
-		client.Close()
+	err := <-auth
+	if !me.handshakes.remove(client) {
 		return nil, errors.New("peer authentication timeout")
 	}
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("peer authentication failed %s", err.Error())
+	}
 	return peer, nil
 }
+
+type neighborMap struct {
+	mutex *sync.RWMutex
+	m     map[crypto.Hash]*Peer
+}
+
+func (m *neighborMap) Get(k crypto.Hash) *Peer {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.m[k]
+}
+
+func (m *neighborMap) Put(k crypto.Hash, p *Peer) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.m[k] != nil {
+		return false
+	}
+	m.m[k] = p
+	return true
+}
+
+func (m *neighborMap) Delete(k crypto.Hash) *Peer {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	p := m.m[k]
+	delete(m.m, k)
+	return p
+}
+
+func (m *neighborMap) Slice() []*Peer {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	peers := make([]*Peer, 0, len(m.m))
+	for _, p := range m.m {
+		peers = append(peers, p)
+	}
+	return peers
+}
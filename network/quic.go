@@ -105,7 +105,16 @@ func (t *QuicTransport) Accept() (Client, error) {
 	}, nil
 }
 
+// Receive and Send open the stream of the other direction on the first use,
+// which is only the handshake reply, the streams are unidirectional.
 func (c *QuicClient) Receive() ([]byte, error) {
+	if c.receive == nil {
+		stm, err := c.session.AcceptUniStream()
+		if err != nil {
+			return nil, err
+		}
+		c.receive = stm
+	}
 	err := c.receive.SetReadDeadline(time.Now().Add(ReadDeadline))
 	if err != nil {
 		return nil, err
@@ -150,6 +159,13 @@ func (c *QuicClient) Send(data []byte) error {
 	if l := len(data); l < 1 || l > TransportMessageMaxSize {
 		return fmt.Errorf("quic send invalid message size %d", l)
 	}
+	if c.send == nil {
+		stm, err := c.session.OpenUniStreamSync()
+		if err != nil {
+			return err
+		}
+		c.send = stm
+	}
 
 	var buf bytes.Buffer
 	gzWriter, err := gzip.NewWriterLevel(&buf, 3)
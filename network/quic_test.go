@@ -23,6 +23,8 @@ func TestQuic(t *testing.T) {
 		msg, err := server.Receive()
 		assert.Nil(err)
 		assert.Equal("hello mixin", string(msg))
+		err = server.Send([]byte("hello dialer"))
+		assert.Nil(err)
 	}()
 
 	clientTrans, err := NewQuicClient(addr)
@@ -33,5 +35,8 @@ func TestQuic(t *testing.T) {
 	assert.NotNil(client)
 	err = client.Send([]byte("hello mixin"))
 	assert.Nil(err)
+	msg, err := client.Receive()
+	assert.Nil(err)
+	assert.Equal("hello dialer", string(msg))
 	time.Sleep(1 * time.Second)
 }
@@ -0,0 +1,60 @@
+package network
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// RequestSnapshots asks all neighbors for the snapshots of the node round, to
+// re-fetch a snapshot record corrupted in the local store. The snapshots are
+// sent back as normal snapshot messages and verified the same way.
+func (me *Peer) RequestSnapshots(nodeId crypto.Hash, round uint64) int {
+	var sent int
+	for _, p := range me.neighbors.Slice() {
+		key := nodeId.ForNetwork(p.IdForNetwork)
+		key = crypto.NewHash(append(append(key[:], 'S', 'R'), uint64Bytes(round)...))
+		if me.snapshotsCaches.Exist(key, time.Minute) {
+			continue
+		}
+		err := p.SendHigh(key, buildSnapshotRequestMessage(nodeId, round))
+		if err != nil {
+			logger.Println("snapshot request error", p.Address, err)
+			continue
+		}
+		sent = sent + 1
+	}
+	return sent
+}
+
+func (me *Peer) sendSnapshotsToPeer(peer *Peer, nodeId crypto.Hash, round uint64) error {
+	snapshots, err := me.handle.ReadSnapshotsForNodeRound(nodeId, round)
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		if len(s.Signatures) == 0 {
+			continue
+		}
+		key := s.PayloadHash().ForNetwork(peer.IdForNetwork)
+		key = crypto.NewHash(append(key[:], 'S', 'R'))
+		err = peer.SendNormal(key, buildSnapshotMessage(&s.Snapshot))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildSnapshotRequestMessage(nodeId crypto.Hash, round uint64) []byte {
+	data := append([]byte{PeerMessageTypeSnapshotRequest}, nodeId[:]...)
+	return append(data, uint64Bytes(round)...)
+}
+
+func uint64Bytes(n uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, n)
+	return buf
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRefetchHandle struct {
+	testFlowHandle
+	rounds map[uint64][]*common.SnapshotWithTopologicalOrder
+}
+
+func (h *testRefetchHandle) ReadSnapshotsForNodeRound(nodeId crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	return h.rounds[round], nil
+}
+
+func TestSnapshotRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	nodeId := crypto.NewHash([]byte("node"))
+	msg, err := parseNetworkMessage(buildSnapshotRequestMessage(nodeId, 7))
+	assert.Nil(err)
+	assert.Equal(uint8(PeerMessageTypeSnapshotRequest), msg.Type)
+	assert.Equal(nodeId, msg.NodeId)
+	assert.Equal(uint64(7), msg.RoundNumber)
+	_, err = parseNetworkMessage([]byte{PeerMessageTypeSnapshotRequest, 1})
+	assert.NotNil(err)
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	handle := &testFlowHandle{testGoodbyeHandle: testGoodbyeHandle{id: meId}}
+	served := &testRefetchHandle{testFlowHandle: testFlowHandle{testGoodbyeHandle: testGoodbyeHandle{id: remoteId}}}
+	var sig crypto.Signature
+	served.rounds = map[uint64][]*common.SnapshotWithTopologicalOrder{
+		7: {
+			{Snapshot: common.Snapshot{NodeId: nodeId, RoundNumber: 7, Signatures: []*crypto.Signature{&sig}}},
+			{Snapshot: common.Snapshot{NodeId: nodeId, RoundNumber: 7, Transaction: crypto.NewHash([]byte("tx"))}},
+		},
+	}
+	me := NewPeer(handle, meId, "127.0.0.1:7005")
+	remote := NewPeer(served, remoteId, "127.0.0.1:7006")
+	me.dial = func(addr string) (Client, error) {
+		client, server := testPipe()
+		go remote.acceptNeighborConnection(server)
+		return client, nil
+	}
+	remote.dial = func(addr string) (Client, error) {
+		client, server := testPipe()
+		go me.acceptNeighborConnection(server)
+		return client, nil
+	}
+	me.AddNeighbor(remoteId, remote.Address)
+	remote.AddNeighbor(meId, me.Address)
+
+	assert.Equal(1, me.RequestSnapshots(nodeId, 7))
+	for i := 0; i < 50 && atomic.LoadInt64(&handle.snapshots) < 1; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(int64(1), atomic.LoadInt64(&handle.snapshots))
+
+	assert.Nil(me.Shutdown())
+	assert.Nil(remote.Shutdown())
+}
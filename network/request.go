@@ -0,0 +1,172 @@
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+const (
+	RequestTimeoutDefault  = 5 * time.Second
+	RequestAttemptsDefault = 3
+)
+
+type transactionRequest struct {
+	peers    []crypto.Hash
+	deadline time.Time
+}
+
+// requestTracker tracks the transactions requested for the queued snapshots
+// and not received yet, a request not answered before the deadline is sent
+// again to another neighbor, until the attempts are used up.
+type requestTracker struct {
+	mutex    *sync.Mutex
+	pending  map[crypto.Hash]*transactionRequest
+	timeout  time.Duration
+	attempts int
+}
+
+func newRequestTracker(timeout time.Duration, attempts int) *requestTracker {
+	if timeout <= 0 {
+		timeout = RequestTimeoutDefault
+	}
+	if attempts < 1 {
+		attempts = RequestAttemptsDefault
+	}
+	return &requestTracker{
+		mutex:    new(sync.Mutex),
+		pending:  make(map[crypto.Hash]*transactionRequest),
+		timeout:  timeout,
+		attempts: attempts,
+	}
+}
+
+// track returns false if the transaction is requested already, the peer is
+// ignored then because the request is retried by the tracker.
+func (r *requestTracker) track(tx, peerId crypto.Hash, now time.Time) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.pending[tx] != nil {
+		return false
+	}
+	r.pending[tx] = &transactionRequest{
+		peers:    []crypto.Hash{peerId},
+		deadline: now.Add(r.timeout),
+	}
+	return true
+}
+
+func (r *requestTracker) done(tx crypto.Hash) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, found := r.pending[tx]
+	delete(r.pending, tx)
+	return found
+}
+
+// expired returns the peers tried of the requests after the deadline, and
+// drops the requests which have used up the attempts.
+func (r *requestTracker) expired(now time.Time) (map[crypto.Hash][]crypto.Hash, []crypto.Hash) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	retry := make(map[crypto.Hash][]crypto.Hash)
+	var dropped []crypto.Hash
+	for tx, req := range r.pending {
+		if now.Before(req.deadline) {
+			continue
+		}
+		if len(req.peers) >= r.attempts {
+			delete(r.pending, tx)
+			dropped = append(dropped, tx)
+			continue
+		}
+		retry[tx] = append([]crypto.Hash{}, req.peers...)
+	}
+	return retry, dropped
+}
+
+func (r *requestTracker) retry(tx, peerId crypto.Hash, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	req := r.pending[tx]
+	if req == nil {
+		return
+	}
+	req.peers = append(req.peers, peerId)
+	req.deadline = now.Add(r.timeout)
+}
+
+func (r *requestTracker) size() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.pending)
+}
+
+func (r *requestTracker) interval() time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.timeout / 4
+}
+
+// SetRequestPolicy applies to the requests sent after it.
+func (me *Peer) SetRequestPolicy(timeout time.Duration, attempts int) {
+	me.requests = newRequestTracker(timeout, attempts)
+}
+
+func (me *Peer) handleTransaction(tx *common.SignedTransaction) error {
+	me.requests.done(tx.PayloadHash())
+	return me.handle.CachePutTransaction(tx)
+}
+
+func (me *Peer) retryTransactionRequests(now time.Time) int {
+	var retried int
+	retry, dropped := me.requests.expired(now)
+	for tx, tried := range retry {
+		peer := me.pickRequestNeighbor(tried)
+		if peer == nil {
+			me.requests.done(tx)
+			dropped = append(dropped, tx)
+			continue
+		}
+		me.requests.retry(tx, peer.IdForNetwork, now)
+		err := me.sendTransactionRequest(peer, tx)
+		if err != nil {
+			logger.Println("transaction request retry error", peer.Address, err)
+		}
+		retried = retried + 1
+	}
+	for _, tx := range dropped {
+		logger.Printf("TRANSACTION REQUEST GAVE UP %s\n", tx.String())
+	}
+	metrics.Counter("mixin_peer_request_retries_total", uint64(retried))
+	metrics.Counter("mixin_peer_request_failures_total", uint64(len(dropped)))
+	return retried
+}
+
+func (me *Peer) pickRequestNeighbor(tried []crypto.Hash) *Peer {
+	for _, p := range me.neighbors.Slice() {
+		var found bool
+		for _, id := range tried {
+			found = found || id == p.IdForNetwork
+		}
+		if !found {
+			return p
+		}
+	}
+	return nil
+}
+
+func (me *Peer) loopRetryRequests() {
+	for {
+		select {
+		case <-me.quit:
+			return
+		case <-time.After(me.requests.interval()):
+		}
+		me.retryTransactionRequests(time.Now())
+	}
+}
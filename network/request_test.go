@@ -0,0 +1,86 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRequestHandle struct {
+	SyncHandle
+	sync.Mutex
+	received []crypto.Hash
+}
+
+func (h *testRequestHandle) CachePutTransaction(tx *common.SignedTransaction) error {
+	h.Lock()
+	defer h.Unlock()
+	h.received = append(h.received, tx.PayloadHash())
+	return nil
+}
+
+func testReceiveRequest(p *Peer) *PeerMessage {
+	select {
+	case msg := <-p.high:
+		parsed, _ := parseNetworkMessage(msg.data)
+		return parsed
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestTransactionRequestRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	handle := new(testRequestHandle)
+	meId := crypto.NewHash([]byte("me"))
+	me := NewPeer(handle, meId, "127.0.0.1:7007")
+	me.SetRequestPolicy(time.Second, 2)
+	slowId, fastId := crypto.NewHash([]byte("slow")), crypto.NewHash([]byte("fast"))
+	slow, fast := NewPeer(nil, slowId, "127.0.0.1:7008"), NewPeer(nil, fastId, "127.0.0.1:7009")
+	me.neighbors.Put(slowId, slow)
+
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.AddInput(crypto.NewHash([]byte("input")), 0)
+	signed := &common.SignedTransaction{Transaction: *tx}
+	hash := signed.PayloadHash()
+
+	now := time.Now()
+	err := me.SendTransactionRequestMessage(slowId, hash)
+	assert.Nil(err)
+	msg := testReceiveRequest(slow)
+	assert.NotNil(msg)
+	assert.Equal(uint8(PeerMessageTypeTransactionRequest), msg.Type)
+	assert.Equal(hash, msg.TransactionHash)
+	err = me.SendTransactionRequestMessage(slowId, hash)
+	assert.Nil(err)
+	assert.Nil(testReceiveRequest(slow))
+
+	assert.Equal(0, me.retryTransactionRequests(now))
+	me.neighbors.Put(fastId, fast)
+	assert.Equal(1, me.retryTransactionRequests(now.Add(2*time.Second)))
+	assert.Nil(testReceiveRequest(slow))
+	msg = testReceiveRequest(fast)
+	assert.NotNil(msg)
+	assert.Equal(hash, msg.TransactionHash)
+	assert.Equal(1, me.requests.size())
+
+	err = me.handleTransaction(signed)
+	assert.Nil(err)
+	assert.Equal([]crypto.Hash{hash}, handle.received)
+	assert.Equal(0, me.requests.size())
+	assert.Equal(0, me.retryTransactionRequests(now.Add(time.Hour)))
+
+	other := crypto.NewHash([]byte("other"))
+	err = me.SendTransactionRequestMessage(slowId, other)
+	assert.Nil(err)
+	assert.NotNil(testReceiveRequest(slow))
+	assert.Equal(1, me.retryTransactionRequests(now.Add(2*time.Second)))
+	assert.NotNil(testReceiveRequest(fast))
+	assert.Equal(0, me.retryTransactionRequests(now.Add(time.Hour)))
+	assert.Equal(0, me.requests.size())
+}
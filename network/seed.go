@@ -0,0 +1,186 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	SeedBackoffBase    = 1 * time.Second
+	SeedBackoffMaximum = 5 * time.Minute
+	SeedBootstrapCount = 3
+)
+
+type seedHealth struct {
+	failures int
+	retryAt  time.Time
+}
+
+// SeedList tracks the health of the seed peers, a seed failed repeatedly is
+// skipped with an exponential backoff until it responds again.
+type SeedList struct {
+	mutex  *sync.Mutex
+	seeds  []string
+	health map[string]*seedHealth
+}
+
+func NewSeedList(seeds []string) *SeedList {
+	l := &SeedList{
+		mutex:  new(sync.Mutex),
+		health: make(map[string]*seedHealth),
+	}
+	for _, s := range seeds {
+		l.Add(s)
+	}
+	return l
+}
+
+func (l *SeedList) Add(addr string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.health[addr] != nil {
+		return
+	}
+	l.seeds = append(l.seeds, addr)
+	l.health[addr] = &seedHealth{}
+}
+
+func (l *SeedList) Has(addr string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.health[addr] != nil
+}
+
+func (l *SeedList) Available(addr string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	h := l.health[addr]
+	return h != nil && !time.Now().Before(h.retryAt)
+}
+
+func (l *SeedList) Backoff(addr string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	h := l.health[addr]
+	if h == nil || h.failures == 0 {
+		return SeedBackoffBase
+	}
+	return seedBackoff(h.failures)
+}
+
+func (l *SeedList) Fail(addr string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	h := l.health[addr]
+	if h == nil {
+		return
+	}
+	h.failures = h.failures + 1
+	h.retryAt = time.Now().Add(seedBackoff(h.failures))
+}
+
+func (l *SeedList) Succeed(addr string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	h := l.health[addr]
+	if h == nil {
+		return
+	}
+	h.failures = 0
+	h.retryAt = time.Time{}
+}
+
+// Bootstrap dials the available seeds in order until count of them respond,
+// the seeds in backoff are skipped, and it fails only if none responds.
+func (l *SeedList) Bootstrap(count int, dial func(addr string) error) ([]string, error) {
+	l.mutex.Lock()
+	seeds := make([]string, len(l.seeds))
+	copy(seeds, l.seeds)
+	l.mutex.Unlock()
+
+	live := make([]string, 0)
+	for _, s := range seeds {
+		if len(live) >= count {
+			break
+		}
+		if !l.Available(s) {
+			continue
+		}
+		err := dial(s)
+		if err != nil {
+			logger.Println("seed dial error", s, err)
+			l.Fail(s)
+			continue
+		}
+		l.Succeed(s)
+		live = append(live, s)
+	}
+	if len(live) == 0 {
+		return nil, fmt.Errorf("no seed available in %d", len(seeds))
+	}
+	return live, nil
+}
+
+// BootstrapSeeds dials the seeds until count of them respond, each seed proves
+// its identity in the handshake reply and is added as a neighbor if not yet,
+// so a node knowing only a few seed addresses expands to the others.
+func (me *Peer) BootstrapSeeds(count int) ([]string, error) {
+	return me.seeds.Bootstrap(count, me.dialSeed)
+}
+
+// LoopBootstrapSeeds retries the bootstrap with backoff until any seed responds,
+// the seeds may be added later by the DNS seeds resolution.
+func (me *Peer) LoopBootstrapSeeds() error {
+	for failures := 1; ; failures++ {
+		live, err := me.BootstrapSeeds(SeedBootstrapCount)
+		if err == nil {
+			logger.Println("SEEDS BOOTSTRAP", live)
+			return nil
+		}
+		logger.Println("seeds bootstrap error", err)
+		select {
+		case <-me.quit:
+			return nil
+		case <-time.After(seedBackoff(failures)):
+		}
+	}
+}
+
+func (me *Peer) dialSeed(addr string) error {
+	if addr == me.Address {
+		return fmt.Errorf("seed is self %s", addr)
+	}
+	client, err := me.dialer.dial(addr, me.quit, me.dial)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Send(buildAuthenticationMessage(LocalProtocolVersions(), me.handle.BuildAuthenticationMessage()))
+	if err != nil {
+		return err
+	}
+	id, _, err := me.receiveHandshakeReply(client)
+	if err != nil {
+		return err
+	}
+	if id == me.IdForNetwork {
+		return fmt.Errorf("seed is self %s", addr)
+	}
+	me.AddNeighbor(id, addr)
+	return nil
+}
+
+func seedBackoff(failures int) time.Duration {
+	d := SeedBackoffBase
+	for i := 1; i < failures && d < SeedBackoffMaximum; i++ {
+		d = d * 2
+	}
+	if d > SeedBackoffMaximum {
+		return SeedBackoffMaximum
+	}
+	return d
+}
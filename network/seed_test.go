@@ -0,0 +1,103 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedList(t *testing.T) {
+	assert := assert.New(t)
+
+	dead := map[string]bool{
+		"127.0.0.1:7001": true,
+		"127.0.0.1:7002": true,
+		"127.0.0.1:7004": true,
+	}
+	dialed := make(map[string]int)
+	dial := func(addr string) error {
+		dialed[addr] = dialed[addr] + 1
+		if dead[addr] {
+			return errors.New("dead seed")
+		}
+		return nil
+	}
+
+	seeds := NewSeedList([]string{"127.0.0.1:7001", "127.0.0.1:7002", "127.0.0.1:7003", "127.0.0.1:7004", "127.0.0.1:7005", "127.0.0.1:7006"})
+	live, err := seeds.Bootstrap(2, dial)
+	assert.Nil(err)
+	assert.Equal([]string{"127.0.0.1:7003", "127.0.0.1:7005"}, live)
+	assert.Equal(0, dialed["127.0.0.1:7006"])
+	assert.False(seeds.Available("127.0.0.1:7001"))
+	assert.True(seeds.Available("127.0.0.1:7003"))
+	assert.Equal(SeedBackoffBase, seeds.Backoff("127.0.0.1:7003"))
+	assert.Equal(SeedBackoffBase, seeds.Backoff("127.0.0.1:7001"))
+
+	live, err = seeds.Bootstrap(3, dial)
+	assert.Nil(err)
+	assert.Equal([]string{"127.0.0.1:7003", "127.0.0.1:7005", "127.0.0.1:7006"}, live)
+	assert.Equal(1, dialed["127.0.0.1:7001"])
+	assert.Equal(1, dialed["127.0.0.1:7002"])
+	assert.Equal(1, dialed["127.0.0.1:7004"])
+
+	seeds.Fail("127.0.0.1:7001")
+	seeds.Fail("127.0.0.1:7001")
+	assert.Equal(4*SeedBackoffBase, seeds.Backoff("127.0.0.1:7001"))
+	for i := 0; i < 32; i++ {
+		seeds.Fail("127.0.0.1:7001")
+	}
+	assert.Equal(SeedBackoffMaximum, seeds.Backoff("127.0.0.1:7001"))
+	seeds.Succeed("127.0.0.1:7001")
+	assert.True(seeds.Available("127.0.0.1:7001"))
+
+	seeds = NewSeedList([]string{"127.0.0.1:7001", "127.0.0.1:7002"})
+	live, err = seeds.Bootstrap(1, dial)
+	assert.NotNil(err)
+	assert.Len(live, 0)
+
+	seeds = NewSeedList([]string{"127.0.0.1:7001", "127.0.0.1:7003"})
+	seeds.Fail("127.0.0.1:7003")
+	seeds.health["127.0.0.1:7003"].retryAt = time.Now()
+	live, err = seeds.Bootstrap(1, dial)
+	assert.Nil(err)
+	assert.Equal([]string{"127.0.0.1:7003"}, live)
+}
+
+func TestPeerBootstrapSeeds(t *testing.T) {
+	assert := assert.New(t)
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	me := NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7005")
+	remote := NewPeer(&testGoodbyeHandle{id: remoteId}, remoteId, "127.0.0.1:7006")
+	remote.neighbors.Put(meId, NewPeer(nil, meId, me.Address))
+	me.dial = func(addr string) (Client, error) {
+		if addr != remote.Address {
+			return nil, errors.New("dead seed")
+		}
+		client, server := testPipe()
+		go remote.acceptNeighborConnection(server)
+		return client, nil
+	}
+
+	_, err := me.BootstrapSeeds(1)
+	assert.NotNil(err)
+
+	me.seeds.Add("127.0.0.1:7001")
+	me.seeds.Add(me.Address)
+	me.seeds.Add(remote.Address)
+	me.seeds.Add("127.0.0.1:7002")
+	live, err := me.BootstrapSeeds(1)
+	assert.Nil(err)
+	assert.Equal([]string{remote.Address}, live)
+	assert.False(me.seeds.Available("127.0.0.1:7001"))
+	assert.False(me.seeds.Available(me.Address))
+	neighbor := me.GetNeighbor(remoteId)
+	assert.NotNil(neighbor)
+	assert.Equal(remote.Address, neighbor.Address)
+
+	assert.Nil(me.LoopBootstrapSeeds())
+	assert.Nil(me.Shutdown())
+}
@@ -104,6 +104,8 @@ func (me *Peer) syncToNeighborLoop(p *Peer) {
 	var graph map[crypto.Hash]*SyncPoint
 	for {
 		select {
+		case <-p.quit:
+			return
 		case g := <-p.sync:
 			graph = make(map[crypto.Hash]*SyncPoint)
 			for _, r := range g {
@@ -0,0 +1,124 @@
+package network
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// The gossip to a neighbor is separated into topics by priority when enabled.
+// The control messages are always sent first, then the snapshots, and the
+// transactions relayed are sent only when no consensus messages are queued, so
+// a flood of transactions never delays the consensus. The beacons, i.e. the
+// graph, status and ping messages, are built on their tickers instead of
+// queued, because only the latest one is of any use.
+const (
+	GossipTopicControl     = 0
+	GossipTopicSnapshot    = 1
+	GossipTopicBeacon      = 2
+	GossipTopicTransaction = 3
+)
+
+func GossipTopicString(topic int) string {
+	switch topic {
+	case GossipTopicControl:
+		return "control"
+	case GossipTopicSnapshot:
+		return "snapshot"
+	case GossipTopicBeacon:
+		return "beacon"
+	case GossipTopicTransaction:
+		return "transaction"
+	}
+	return "unknown"
+}
+
+// SetGossipTopics separates the transactions relayed from the control queue
+// and sends the topics by priority when enabled, otherwise the transactions
+// share the control queue, which is interleaved with the snapshots queue.
+func (me *Peer) SetGossipTopics(enabled bool) {
+	me.gossipTopics = enabled
+}
+
+// GossipBacklog returns the messages queued for the neighbor by topic.
+func (me *Peer) GossipBacklog(idForNetwork crypto.Hash) map[string]int {
+	peer := me.neighbors.Get(idForNetwork)
+	if peer == nil {
+		return nil
+	}
+	return map[string]int{
+		GossipTopicString(GossipTopicControl):     len(peer.high),
+		GossipTopicString(GossipTopicSnapshot):    len(peer.normal),
+		GossipTopicString(GossipTopicTransaction): len(peer.transactions),
+	}
+}
+
+func (me *Peer) transactionTopic() int {
+	if me.gossipTopics {
+		return GossipTopicTransaction
+	}
+	return GossipTopicControl
+}
+
+func (p *Peer) SendTopic(topic int, key crypto.Hash, data []byte) error {
+	var queue chan *ChanMsg
+	switch topic {
+	case GossipTopicControl:
+		queue = p.high
+	case GossipTopicSnapshot:
+		queue = p.normal
+	case GossipTopicTransaction:
+		queue = p.transactions
+	default:
+		return errors.New("peer send invalid topic")
+	}
+	select {
+	case queue <- &ChanMsg{key, data}:
+		return nil
+	case <-time.After(1 * time.Second):
+		return errors.New("peer send " + GossipTopicString(topic) + " timeout")
+	}
+}
+
+// nextGossip returns the queued message of the topic with the highest priority
+// without blocking, or nil if none queued. Without topics the control and
+// snapshots queues take turns, so neither of them starves the other.
+func (p *Peer) nextGossip(topics bool) (*ChanMsg, int) {
+	snapshotFirst := !topics && p.lastGossip == GossipTopicControl
+	msg, topic := p.pollGossip(snapshotFirst)
+	if msg != nil {
+		p.lastGossip = topic
+	}
+	return msg, topic
+}
+
+func (p *Peer) pollGossip(snapshotFirst bool) (*ChanMsg, int) {
+	normal := p.normal
+	if p.flowPaused() {
+		normal = nil
+	}
+	if snapshotFirst {
+		select {
+		case msg := <-normal:
+			return msg, GossipTopicSnapshot
+		default:
+		}
+	}
+	select {
+	case msg := <-p.high:
+		return msg, GossipTopicControl
+	default:
+	}
+	select {
+	case msg := <-normal:
+		return msg, GossipTopicSnapshot
+	default:
+	}
+	select {
+	case msg := <-p.transactions:
+		return msg, GossipTopicTransaction
+	default:
+	}
+	return nil, 0
+}
@@ -0,0 +1,119 @@
+package network
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTopicHandle struct {
+	testGoodbyeHandle
+	transactions int64
+	delivered    int64
+}
+
+func (h *testTopicHandle) CachePutTransaction(tx *common.SignedTransaction) error {
+	atomic.AddInt64(&h.transactions, 1)
+	return nil
+}
+
+func (h *testTopicHandle) QueueAppendSnapshot(peerId crypto.Hash, s *common.Snapshot) error {
+	atomic.StoreInt64(&h.delivered, atomic.LoadInt64(&h.transactions)+1)
+	return nil
+}
+
+func TestGossipTopics(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("beacon", GossipTopicString(GossipTopicBeacon))
+	assert.Equal("unknown", GossipTopicString(9))
+
+	for i, topics := range []bool{true, false} {
+		meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+		handle := &testTopicHandle{testGoodbyeHandle: testGoodbyeHandle{id: remoteId}}
+		me := NewPeer(&testGoodbyeHandle{id: meId}, meId, fmt.Sprintf("127.0.0.1:%d", 7010+i*2))
+		remote := NewPeer(handle, remoteId, fmt.Sprintf("127.0.0.1:%d", 7011+i*2))
+		remoteNeighbor := NewPeer(nil, meId, me.Address)
+		remote.neighbors.Put(meId, remoteNeighbor)
+		go remote.syncToNeighborLoop(remoteNeighbor)
+		me.dial = func(addr string) (Client, error) {
+			client, server := testPipe()
+			go remote.acceptNeighborConnection(server)
+			return client, nil
+		}
+		me.SetGossipTopics(topics)
+		neighbor := NewPeer(nil, remoteId, remote.Address)
+		me.neighbors.Put(remoteId, neighbor)
+
+		flood := 2000
+		for i := 0; i < flood; i++ {
+			tx := common.NewTransaction(common.XINAssetId)
+			tx.AddInput(crypto.NewHash([]byte("flood")), i)
+			err := me.SendTransactionMessage(remoteId, &common.SignedTransaction{Transaction: *tx})
+			assert.Nil(err)
+		}
+		s := &common.Snapshot{NodeId: meId, Signatures: []*crypto.Signature{{}}}
+		err := me.SendSnapshotMessage(remoteId, s, 0)
+		assert.Nil(err)
+		backlog := me.GossipBacklog(remoteId)
+		assert.Equal(1, backlog["snapshot"])
+		if topics {
+			assert.Equal(flood, backlog["transaction"])
+			assert.Equal(0, backlog["control"])
+		} else {
+			assert.Equal(0, backlog["transaction"])
+			assert.Equal(flood, backlog["control"])
+		}
+
+		start := time.Now()
+		go me.openPeerStreamLoop(neighbor)
+		for i := 0; i < 100 && atomic.LoadInt64(&handle.delivered) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(time.Since(start) < time.Second)
+		delivered := atomic.LoadInt64(&handle.delivered)
+		assert.True(delivered > 0)
+		assert.True(delivered < 16)
+		for i := 0; i < 100 && atomic.LoadInt64(&handle.transactions) < int64(flood); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.Equal(int64(flood), atomic.LoadInt64(&handle.transactions))
+
+		if topics {
+			me.SetGossipTopics(false)
+			assert.Equal(GossipTopicControl, me.transactionTopic())
+			assert.NotNil(neighbor.SendTopic(GossipTopicBeacon, crypto.Hash{}, nil))
+		}
+		assert.Nil(me.Shutdown())
+	}
+}
+
+func TestGossipInterleave(t *testing.T) {
+	assert := assert.New(t)
+
+	peer := NewPeer(nil, crypto.NewHash([]byte("neighbor")), "127.0.0.1:7020")
+	for i := 0; i < 4; i++ {
+		assert.Nil(peer.SendHigh(crypto.NewHash([]byte(fmt.Sprintf("high%d", i))), nil))
+		assert.Nil(peer.SendNormal(crypto.NewHash([]byte(fmt.Sprintf("normal%d", i))), nil))
+	}
+	var topics []int
+	for msg, topic := peer.nextGossip(false); msg != nil; msg, topic = peer.nextGossip(false) {
+		topics = append(topics, topic)
+	}
+	assert.Equal([]int{1, 0, 1, 0, 1, 0, 1, 0}, topics)
+
+	for i := 0; i < 4; i++ {
+		assert.Nil(peer.SendHigh(crypto.NewHash([]byte(fmt.Sprintf("high%d", i))), nil))
+		assert.Nil(peer.SendNormal(crypto.NewHash([]byte(fmt.Sprintf("normal%d", i))), nil))
+	}
+	topics = nil
+	for msg, topic := peer.nextGossip(true); msg != nil; msg, topic = peer.nextGossip(true) {
+		topics = append(topics, topic)
+	}
+	assert.Equal([]int{0, 0, 0, 0, 1, 1, 1, 1}, topics)
+}
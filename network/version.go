@@ -0,0 +1,58 @@
+package network
+
+import (
+	"fmt"
+)
+
+const (
+	ProtocolVersionMinimum = 1
+	ProtocolVersionMaximum = 1
+)
+
+const (
+	ProtocolFeatureSnapshot           = "snapshot"
+	ProtocolFeatureGraph              = "graph"
+	ProtocolFeatureSnapshotConfirm    = "snapshot-confirm"
+	ProtocolFeatureTransactionRequest = "transaction-request"
+)
+
+// protocolFeatures maps each feature to the minimum protocol version supports
+// it, a feature should only be used with the neighbors negotiated a version
+// no lower than it.
+var protocolFeatures = map[string]uint8{
+	ProtocolFeatureSnapshot:           1,
+	ProtocolFeatureGraph:              1,
+	ProtocolFeatureSnapshotConfirm:    1,
+	ProtocolFeatureTransactionRequest: 1,
+}
+
+type ProtocolVersions struct {
+	Minimum uint8
+	Maximum uint8
+}
+
+func LocalProtocolVersions() ProtocolVersions {
+	return ProtocolVersions{Minimum: ProtocolVersionMinimum, Maximum: ProtocolVersionMaximum}
+}
+
+// NegotiateProtocolVersion picks the highest version supported by both sides,
+// it's done by the acceptor, which echoes the version chosen in the handshake
+// reply, so the dialer always uses the same one.
+func NegotiateProtocolVersion(local, remote ProtocolVersions) (uint8, error) {
+	if remote.Minimum > remote.Maximum {
+		return 0, fmt.Errorf("invalid protocol versions %d-%d", remote.Minimum, remote.Maximum)
+	}
+	version := local.Maximum
+	if remote.Maximum < version {
+		version = remote.Maximum
+	}
+	if version < local.Minimum || version < remote.Minimum {
+		return 0, fmt.Errorf("protocol versions incompatible local %d-%d remote %d-%d", local.Minimum, local.Maximum, remote.Minimum, remote.Maximum)
+	}
+	return version, nil
+}
+
+func ProtocolFeatureSupported(version uint8, feature string) bool {
+	min, found := protocolFeatures[feature]
+	return found && version >= min
+}
@@ -0,0 +1,144 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testVersionHandle struct {
+	SyncHandle
+	id crypto.Hash
+}
+
+func (h *testVersionHandle) Authenticate(msg []byte) (crypto.Hash, error) {
+	if string(msg) != "auth" {
+		return crypto.Hash{}, errors.New("invalid auth")
+	}
+	return h.id, nil
+}
+
+type testVersionClient struct {
+	data   []byte
+	closed bool
+}
+
+func (c *testVersionClient) Receive() ([]byte, error) {
+	return c.data, nil
+}
+
+func (c *testVersionClient) Send(data []byte) error {
+	return nil
+}
+
+func (c *testVersionClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestProtocolVersionNegotiation(t *testing.T) {
+	assert := assert.New(t)
+
+	local := LocalProtocolVersions()
+	version, err := NegotiateProtocolVersion(local, local)
+	assert.Nil(err)
+	assert.Equal(uint8(ProtocolVersionMaximum), version)
+
+	newer := ProtocolVersions{Minimum: ProtocolVersionMinimum, Maximum: ProtocolVersionMaximum + 2}
+	version, err = NegotiateProtocolVersion(local, newer)
+	assert.Nil(err)
+	assert.Equal(uint8(ProtocolVersionMaximum), version)
+	version, err = NegotiateProtocolVersion(newer, local)
+	assert.Nil(err)
+	assert.Equal(uint8(ProtocolVersionMaximum), version)
+
+	incompatible := ProtocolVersions{Minimum: ProtocolVersionMaximum + 1, Maximum: ProtocolVersionMaximum + 2}
+	version, err = NegotiateProtocolVersion(local, incompatible)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "protocol versions incompatible")
+	assert.Equal(uint8(0), version)
+	_, err = NegotiateProtocolVersion(local, ProtocolVersions{Minimum: 2, Maximum: 1})
+	assert.NotNil(err)
+
+	for feature := range protocolFeatures {
+		assert.True(ProtocolFeatureSupported(ProtocolVersionMaximum, feature))
+		assert.False(ProtocolFeatureSupported(0, feature))
+	}
+	assert.False(ProtocolFeatureSupported(ProtocolVersionMaximum, "unknown"))
+
+	id := crypto.NewHash([]byte("neighbor"))
+	me := NewPeer(&testVersionHandle{id: id}, crypto.NewHash([]byte("me")), "127.0.0.1:7001")
+	neighbor := NewPeer(nil, id, "127.0.0.1:7002")
+	me.neighbors.Put(id, neighbor)
+
+	for _, versions := range []ProtocolVersions{local, newer} {
+		client := &testVersionClient{data: buildAuthenticationMessage(versions, []byte("auth"))}
+		peer, err := me.authenticateNeighbor(client)
+		assert.Nil(err)
+		assert.Equal(neighbor, peer)
+		assert.Equal(uint8(ProtocolVersionMaximum), peer.Version)
+		assert.False(client.closed)
+	}
+
+	client := &testVersionClient{data: buildAuthenticationMessage(incompatible, []byte("auth"))}
+	peer, err := me.authenticateNeighbor(client)
+	assert.Nil(peer)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "protocol versions incompatible")
+	assert.True(client.closed)
+}
+
+func TestProtocolVersionHandshakeReply(t *testing.T) {
+	assert := assert.New(t)
+
+	id := crypto.NewHash([]byte("neighbor"))
+	me := NewPeer(&testVersionHandle{id: id}, crypto.NewHash([]byte("me")), "127.0.0.1:7001")
+	version := uint8(ProtocolVersionMaximum)
+	for _, c := range []struct {
+		versions ProtocolVersions
+		err      string
+	}{
+		{ProtocolVersions{Minimum: version, Maximum: version}, ""},
+		{ProtocolVersions{Minimum: ProtocolVersionMinimum, Maximum: version + 1}, "peer handshake reply invalid version"},
+		{ProtocolVersions{Minimum: version + 1, Maximum: version + 1}, "peer handshake reply invalid version"},
+	} {
+		client := &testVersionClient{data: buildAuthenticationMessage(c.versions, []byte("auth"))}
+		rid, rv, err := me.receiveHandshakeReply(client)
+		if c.err != "" {
+			assert.NotNil(err)
+			assert.Contains(err.Error(), c.err)
+			continue
+		}
+		assert.Nil(err)
+		assert.Equal(id, rid)
+		assert.Equal(version, rv)
+	}
+	client := &testVersionClient{data: buildPingMessage()}
+	_, _, err := me.receiveHandshakeReply(client)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "peer handshake reply invalid message type")
+
+	meId, remoteId := crypto.NewHash([]byte("me")), crypto.NewHash([]byte("remote"))
+	me = NewPeer(&testGoodbyeHandle{id: meId}, meId, "127.0.0.1:7003")
+	remote := NewPeer(&testGoodbyeHandle{id: remoteId}, remoteId, "127.0.0.1:7004")
+	remoteNeighbor := NewPeer(nil, meId, me.Address)
+	remote.neighbors.Put(meId, remoteNeighbor)
+	go remote.syncToNeighborLoop(remoteNeighbor)
+	me.dial = func(addr string) (Client, error) {
+		client, server := testPipe()
+		go remote.acceptNeighborConnection(server)
+		return client, nil
+	}
+	neighbor := NewPeer(nil, remoteId, remote.Address)
+	me.neighbors.Put(remoteId, neighbor)
+	go me.openPeerStreamLoop(neighbor)
+	for i := 0; i < 100 && (neighbor.Version == 0 || remoteNeighbor.Version == 0); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(version, neighbor.Version)
+	assert.Equal(version, remoteNeighbor.Version)
+	assert.Nil(me.Shutdown())
+}
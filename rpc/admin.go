@@ -0,0 +1,220 @@
+package rpc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/bugsnag/bugsnag-go"
+	"github.com/dimfeld/httptreemux"
+	"github.com/gorilla/handlers"
+	"github.com/unrolled/render"
+)
+
+const (
+	AdminTokenEnv        = "MIXIN_ADMIN_TOKEN"
+	AdminRateLimitWindow = time.Minute
+	AdminRateLimitCount  = 10
+)
+
+type Admin struct {
+	Store   storage.Store
+	token   []byte
+	limiter *rateLimiter
+	running func() bool
+}
+
+// LoadAdminToken prefers the token in the environment to the token file, the
+// admin API is disabled when both are empty.
+func LoadAdminToken(file string) (string, error) {
+	if token := strings.TrimSpace(os.Getenv(AdminTokenEnv)); token != "" {
+		return token, nil
+	}
+	if file == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func NewAdminRouter(store storage.Store, token string) *httptreemux.TreeMux {
+	router := httptreemux.New()
+	impl := &Admin{
+		Store:   store,
+		token:   []byte(token),
+		limiter: newRateLimiter(AdminRateLimitCount, AdminRateLimitWindow),
+		running: kernel.Running,
+	}
+	router.POST("/", impl.handle)
+	router.POST("/snapshots", impl.importSnapshots)
+	router.GET("/snapshots", impl.exportSnapshots)
+	registerHanders(router)
+	return router
+}
+
+func (impl *Admin) authenticate(r *http.Request) bool {
+	if len(impl.token) == 0 {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[7:]), impl.token) == 1
+}
+
+func (impl *Admin) handle(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if !impl.authenticate(r) {
+		render.New().JSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "unauthorized"})
+		return
+	}
+	var call Call
+	d := json.NewDecoder(r.Body)
+	d.UseNumber()
+	if err := d.Decode(&call); err != nil {
+		render.New().JSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	if !impl.limiter.Allow(call.Method) {
+		render.New().JSON(w, http.StatusTooManyRequests, map[string]interface{}{"error": "rate limit exceeded"})
+		return
+	}
+	switch call.Method {
+	case "listorphanedtransactions":
+		hashes, err := impl.Store.FindOrphanedTransactions(r.Context())
+		if err != nil {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+		} else {
+			render.New().JSON(w, http.StatusOK, hashes)
+		}
+	case "listquarantinedsnapshots":
+		snapshots, err := impl.Store.ReadQuarantinedSnapshots()
+		if err != nil {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+		} else {
+			render.New().JSON(w, http.StatusOK, snapshots)
+		}
+	case "verifychecksum":
+		err := impl.Store.VerifyChecksum(r.Context())
+		if err != nil {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{})
+		}
+	default:
+		render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": "invalid method"})
+	}
+}
+
+// importSnapshots imports the stream with ImportSnapshots, the import writes
+// right after the store tip without the kernel knowing it, so it's refused
+// while the kernel loop is running, and the node should be stopped and the
+// snapshots imported offline by the importsnapshots command.
+func (impl *Admin) importSnapshots(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if !impl.authenticate(r) {
+		render.New().JSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "unauthorized"})
+		return
+	}
+	if !impl.limiter.Allow("importsnapshots") {
+		render.New().JSON(w, http.StatusTooManyRequests, map[string]interface{}{"error": "rate limit exceeded"})
+		return
+	}
+	if impl.running != nil && impl.running() {
+		render.New().JSON(w, http.StatusConflict, map[string]interface{}{"error": "import refused while kernel running"})
+		return
+	}
+	imported, err := ImportSnapshots(impl.Store, r.Body)
+	if err != nil {
+		render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error(), "imported": imported})
+		return
+	}
+	render.New().JSON(w, http.StatusOK, map[string]interface{}{"imported": imported})
+}
+
+// ImportSnapshots reads a stream of JSON encoded storage.SnapshotImport, plain
+// or gzip compressed, and imports them in order until the first error, all
+// snapshots before it are kept in the store.
+func ImportSnapshots(store storage.Store, r io.Reader) (int, error) {
+	var imported int
+	body, err := newImportReader(r)
+	if err != nil {
+		return imported, err
+	}
+	d := json.NewDecoder(body)
+	for d.More() {
+		var in storage.SnapshotImport
+		err := d.Decode(&in)
+		if err == nil && in.Error != "" {
+			err = fmt.Errorf("snapshot export error %s", in.Error)
+		}
+		if err == nil && (in.Snapshot == nil || in.Transaction == nil) {
+			err = fmt.Errorf("invalid snapshot import %d", imported)
+		}
+		if err == nil {
+			err = store.ImportSnapshot(in.Snapshot, in.Transaction)
+		}
+		if err != nil {
+			return imported, err
+		}
+		imported = imported + 1
+	}
+	return imported, nil
+}
+
+func StartAdminHTTP(store storage.Store, port int, token string) error {
+	router := NewAdminRouter(store, token)
+	handler := handlers.ProxyHeaders(router)
+	handler = bugsnag.Handler(handler)
+
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: handler}
+	return server.ListenAndServe()
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+type rateLimiter struct {
+	mutex   *sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		mutex:   new(sync.Mutex),
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+func (l *rateLimiter) Allow(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	w := l.windows[key]
+	if w == nil || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count = w.count + 1
+	return true
+}
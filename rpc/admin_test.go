@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuthentication(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-rpc-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := storage.NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	call := func(router http.Handler, auth string) int {
+		body := bytes.NewBufferString(`{"method":"verifychecksum","params":[]}`)
+		req := httptest.NewRequest("POST", "/", body)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	router := NewAdminRouter(store, "admin-secret")
+	assert.Equal(http.StatusUnauthorized, call(router, ""))
+	assert.Equal(http.StatusUnauthorized, call(router, "admin-secret"))
+	assert.Equal(http.StatusUnauthorized, call(router, "Bearer admin-wrong"))
+	assert.Equal(http.StatusOK, call(router, "Bearer admin-secret"))
+	for i := 1; i < AdminRateLimitCount; i++ {
+		assert.Equal(http.StatusOK, call(router, "Bearer admin-secret"))
+	}
+	assert.Equal(http.StatusTooManyRequests, call(router, "Bearer admin-secret"))
+
+	router = NewAdminRouter(store, "")
+	assert.Equal(http.StatusUnauthorized, call(router, "Bearer "))
+
+	file, err := ioutil.TempFile(root, "token")
+	assert.Nil(err)
+	_, err = file.WriteString("file-secret\n")
+	assert.Nil(err)
+	file.Close()
+	os.Unsetenv(AdminTokenEnv)
+	token, err := LoadAdminToken(file.Name())
+	assert.Nil(err)
+	assert.Equal("file-secret", token)
+	os.Setenv(AdminTokenEnv, "env-secret")
+	defer os.Unsetenv(AdminTokenEnv)
+	token, err = LoadAdminToken(file.Name())
+	assert.Nil(err)
+	assert.Equal("env-secret", token)
+}
+
+func TestAdminImportSnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-rpc-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := storage.NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	var rounds []*common.Round
+	var imports []*storage.SnapshotImport
+	for i := 0; i < 6; i++ {
+		seed := make([]byte, 64)
+		rand.Read(seed)
+		tx := common.NewTransaction(common.XINAssetId)
+		tx.Inputs = []*common.Input{{Genesis: seed}}
+		tx.Outputs = []*common.Output{{
+			Type:   common.OutputTypeScript,
+			Amount: common.NewInteger(10000),
+			Script: common.Script{common.OperatorCmp, common.OperatorSum, 1},
+			Keys:   []crypto.Key{crypto.NewKeyFromSeed(seed)},
+		}}
+		signed := &common.SignedTransaction{Transaction: *tx}
+		snap := &common.SnapshotWithTopologicalOrder{
+			Snapshot: common.Snapshot{
+				NodeId:      crypto.NewHash(seed),
+				Transaction: signed.PayloadHash(),
+				Timestamp:   uint64(i + 1),
+			},
+			TopologicalOrder: uint64(i),
+		}
+		snap.Hash = snap.PayloadHash()
+		rounds = append(rounds, &common.Round{Hash: snap.NodeId, NodeId: snap.NodeId})
+		imports = append(imports, &storage.SnapshotImport{Snapshot: snap, Transaction: signed})
+	}
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	var transactions []*common.SignedTransaction
+	for _, in := range imports[:2] {
+		snapshots = append(snapshots, in.Snapshot)
+		transactions = append(transactions, in.Transaction)
+	}
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	assert.Nil(err)
+
+	call := func(router http.Handler, imports []*storage.SnapshotImport) map[string]interface{} {
+		body := new(bytes.Buffer)
+		for _, in := range imports {
+			err := json.NewEncoder(body).Encode(in)
+			assert.Nil(err)
+		}
+		req := httptest.NewRequest("POST", "/snapshots", body)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(http.StatusOK, rec.Code)
+		var resp map[string]interface{}
+		err := json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Nil(err)
+		return resp
+	}
+
+	router := NewAdminRouter(store, "admin-secret")
+	resp := call(router, imports[3:])
+	assert.Equal("import snapshot gap 3 2", resp["error"])
+	assert.Equal(float64(0), resp["imported"])
+	resp = call(router, imports[2:4])
+	assert.Nil(resp["error"])
+	assert.Equal(float64(2), resp["imported"])
+	resp = call(router, imports[3:])
+	assert.Equal("import snapshot overlap 3 4", resp["error"])
+	assert.Equal(float64(0), resp["imported"])
+	resp = call(router, imports[4:])
+	assert.Nil(resp["error"])
+	assert.Equal(float64(2), resp["imported"])
+	assert.Equal(uint64(6), store.TopologySequence())
+
+	impl := &Admin{
+		Store:   store,
+		token:   []byte("admin-secret"),
+		limiter: newRateLimiter(AdminRateLimitCount, AdminRateLimitWindow),
+		running: func() bool { return true },
+	}
+	req := httptest.NewRequest("POST", "/snapshots", new(bytes.Buffer))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rec := httptest.NewRecorder()
+	impl.importSnapshots(rec, req, nil)
+	assert.Equal(http.StatusConflict, rec.Code)
+	assert.Contains(rec.Body.String(), "import refused while kernel running")
+}
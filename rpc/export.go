@@ -0,0 +1,125 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/unrolled/render"
+)
+
+// The zstd compression is not offered, there is no zstd encoder among the
+// dependencies, and gzip is enough for the archive backups.
+const (
+	ExportCompressionNone = ""
+	ExportCompressionGzip = "gzip"
+
+	exportBatchSize = 100
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// exportSnapshots writes the snapshots since the topology offset in the same
+// stream format read by importSnapshots, gzip compressed on the fly if asked,
+// and the Content-Encoding header tells the compression. The status is sent
+// before the first snapshot, so an error partway ends the stream with an error
+// record instead.
+func (impl *Admin) exportSnapshots(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if !impl.authenticate(r) {
+		render.New().JSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "unauthorized"})
+		return
+	}
+	if !impl.limiter.Allow("exportsnapshots") {
+		render.New().JSON(w, http.StatusTooManyRequests, map[string]interface{}{"error": "rate limit exceeded"})
+		return
+	}
+	query := r.URL.Query()
+	offset, count := uint64(0), uint64(math.MaxUint64)
+	var err error
+	if since := query.Get("since"); since != "" {
+		offset, err = strconv.ParseUint(since, 10, 64)
+	}
+	if c := query.Get("count"); err == nil && c != "" {
+		count, err = strconv.ParseUint(c, 10, 64)
+	}
+	compression := query.Get("compression")
+	if err == nil && compression != ExportCompressionNone && compression != ExportCompressionGzip {
+		err = fmt.Errorf("invalid export compression %s", compression)
+	}
+	if err != nil {
+		render.New().JSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	var out io.Writer = w
+	if compression == ExportCompressionGzip {
+		w.Header().Set("Content-Encoding", ExportCompressionGzip)
+		gw := gzip.NewWriter(w)
+		defer gw.Close()
+		out = gw
+	}
+	err = writeSnapshotExport(out, impl.Store, offset, count)
+	if err != nil {
+		logger.Println("export snapshots error", offset, err)
+		json.NewEncoder(out).Encode(&storage.SnapshotImport{Error: err.Error()})
+	}
+}
+
+func writeSnapshotExport(w io.Writer, store storage.Store, offset, count uint64) error {
+	e := json.NewEncoder(w)
+	for count > 0 {
+		batch := count
+		if batch > exportBatchSize {
+			batch = exportBatchSize
+		}
+		snapshots, err := store.ReadSnapshotsSinceTopology(offset, batch)
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			tx, err := store.ReadTransaction(s.Transaction)
+			if err != nil {
+				return err
+			}
+			if tx == nil {
+				return fmt.Errorf("export transaction not found %s", s.Transaction.String())
+			}
+			err = e.Encode(&storage.SnapshotImport{Snapshot: s, Transaction: tx})
+			if err != nil {
+				return err
+			}
+		}
+		if uint64(len(snapshots)) < batch {
+			return nil
+		}
+		offset, count = offset+batch, count-batch
+	}
+	return nil
+}
+
+// newImportReader detects the gzip compressed stream by the magic bytes, so
+// the import doesn't rely on the header, which may be lost when the export is
+// saved to a file.
+func newImportReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err == io.EOF {
+		return br, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminExportSnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-rpc-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	var rounds []*common.Round
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	var transactions []*common.SignedTransaction
+	for i := 0; i < 8; i++ {
+		seed := make([]byte, 64)
+		rand.Read(seed)
+		tx := common.NewTransaction(common.XINAssetId)
+		tx.Inputs = []*common.Input{{Genesis: seed}}
+		tx.Outputs = []*common.Output{{
+			Type:   common.OutputTypeScript,
+			Amount: common.NewInteger(10000),
+			Script: common.Script{common.OperatorCmp, common.OperatorSum, 1},
+			Keys:   []crypto.Key{crypto.NewKeyFromSeed(seed)},
+		}}
+		signed := &common.SignedTransaction{Transaction: *tx}
+		snap := &common.SnapshotWithTopologicalOrder{
+			Snapshot: common.Snapshot{
+				NodeId:      crypto.NewHash(seed),
+				Transaction: signed.PayloadHash(),
+				Timestamp:   uint64(i + 1),
+			},
+			TopologicalOrder: uint64(i),
+		}
+		snap.Hash = snap.PayloadHash()
+		rounds = append(rounds, &common.Round{Hash: snap.NodeId, NodeId: snap.NodeId})
+		snapshots = append(snapshots, snap)
+		transactions = append(transactions, signed)
+	}
+	source, err := storage.NewBadgerStore(root)
+	assert.Nil(err)
+	defer source.Close()
+	err = source.LoadGenesis(rounds, snapshots, transactions)
+	assert.Nil(err)
+	other, err := ioutil.TempDir("", "mixin-rpc-test")
+	assert.Nil(err)
+	defer os.RemoveAll(other)
+	target, err := storage.NewBadgerStore(other)
+	assert.Nil(err)
+	defer target.Close()
+	err = target.LoadGenesis(rounds, snapshots[:3], transactions[:3])
+	assert.Nil(err)
+
+	exportFrom := func(store storage.Store, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/snapshots"+query, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+		NewAdminRouter(store, "admin-secret").ServeHTTP(rec, req)
+		return rec
+	}
+	export := func(query string) *httptest.ResponseRecorder {
+		return exportFrom(source, query)
+	}
+	importTo := func(rec *httptest.ResponseRecorder) map[string]interface{} {
+		req := httptest.NewRequest("POST", "/snapshots", rec.Body)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec = httptest.NewRecorder()
+		NewAdminRouter(target, "admin-secret").ServeHTTP(rec, req)
+		assert.Equal(http.StatusOK, rec.Code)
+		var resp map[string]interface{}
+		err := json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Nil(err)
+		return resp
+	}
+	rec := export("?compression=zstd")
+	assert.Equal(http.StatusBadRequest, rec.Code)
+	assert.Contains(rec.Body.String(), "invalid export compression zstd")
+
+	rec = export("")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("", rec.Header().Get("Content-Encoding"))
+	plain := rec.Body.Bytes()
+	rec = export("?compression=gzip")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("gzip", rec.Header().Get("Content-Encoding"))
+	compressed := rec.Body.Bytes()
+	assert.Equal(gzipMagic, compressed[:2])
+	gr, err := gzip.NewReader(bytes.NewReader(compressed))
+	assert.Nil(err)
+	decompressed, err := ioutil.ReadAll(gr)
+	assert.Nil(err)
+	assert.Equal(plain, decompressed)
+
+	d := json.NewDecoder(bytes.NewReader(plain))
+	for i := 0; d.More(); i++ {
+		var in storage.SnapshotImport
+		err := d.Decode(&in)
+		assert.Nil(err)
+		assert.Equal(common.MsgpackMarshalPanic(snapshots[i]), common.MsgpackMarshalPanic(in.Snapshot))
+		assert.Equal(transactions[i].Marshal(), in.Transaction.Marshal())
+	}
+
+	failing := &testExportStore{Store: source, missing: snapshots[5].Transaction}
+	rec = exportFrom(failing, "?since=3&count=5&compression=gzip")
+	assert.Equal(http.StatusOK, rec.Code)
+	resp := importTo(rec)
+	assert.Contains(resp["error"], "snapshot export error export transaction not found")
+	assert.Equal(float64(2), resp["imported"])
+
+	rec = export("?since=5&count=3&compression=gzip")
+	assert.Equal(http.StatusOK, rec.Code)
+	resp = importTo(rec)
+	assert.Nil(resp["error"])
+	assert.Equal(float64(3), resp["imported"])
+
+	assert.Equal(source.TopologySequence(), target.TopologySequence())
+	expected, err := source.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	imported, err := target.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	assert.Len(imported, len(snapshots))
+	assert.Equal(common.MsgpackMarshalPanic(expected), common.MsgpackMarshalPanic(imported))
+}
+
+// testExportStore loses a transaction to fail the export partway.
+type testExportStore struct {
+	storage.Store
+	missing crypto.Hash
+}
+
+func (s *testExportStore) ReadTransaction(hash crypto.Hash) (*common.SignedTransaction, error) {
+	if hash == s.missing {
+		return nil, nil
+	}
+	return s.Store.ReadTransaction(hash)
+}
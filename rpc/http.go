@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/MixinNetwork/mixin/kernel"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/bugsnag/bugsnag-go"
 	"github.com/bugsnag/bugsnag-go/errors"
@@ -14,7 +15,8 @@ import (
 )
 
 type R struct {
-	Store storage.Store
+	Store   storage.Store
+	replica *replicaForwarder
 }
 
 type Call struct {
@@ -22,8 +24,10 @@ type Call struct {
 	Params []interface{} `json:"params"`
 }
 
-func NewRouter(store storage.Store) *httptreemux.TreeMux {
-	router, impl := httptreemux.New(), &R{Store: store}
+// NewRouter serves a read replica when the primary is set, the mutating
+// methods are forwarded to the primary RPC endpoint.
+func NewRouter(store storage.Store, primary string) *httptreemux.TreeMux {
+	router, impl := httptreemux.New(), &R{Store: store, replica: newReplicaForwarder(primary)}
 	router.POST("/", impl.handle)
 	registerHanders(router)
 	return router
@@ -50,6 +54,13 @@ func (impl *R) handle(w http.ResponseWriter, r *http.Request, _ map[string]strin
 		render.New().JSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
 		return
 	}
+	if impl.replica.forwards(call.Method) {
+		err := impl.replica.forward(w, &call)
+		if err != nil {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+		}
+		return
+	}
 	switch call.Method {
 	case "getinfo":
 		info, err := getInfo(impl.Store)
@@ -79,6 +90,24 @@ func (impl *R) handle(w http.ResponseWriter, r *http.Request, _ map[string]strin
 		} else {
 			render.New().JSON(w, http.StatusOK, snapshots)
 		}
+	case "listactivenodes":
+		nodes, err := kernel.ActiveNodes()
+		if err != nil {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+		} else {
+			render.New().JSON(w, http.StatusOK, nodes)
+		}
+	case "getnodesnapshotrate":
+		rate, err := getNodeSnapshotRate(call.Params)
+		if err != nil {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+		} else {
+			render.New().JSON(w, http.StatusOK, rate)
+		}
+	case "getgenesis":
+		render.New().JSON(w, http.StatusOK, kernel.GetGenesisInfo())
+	case "listpeermetrics":
+		render.New().JSON(w, http.StatusOK, kernel.PeerMetrics())
 	default:
 		render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": "invalid method"})
 	}
@@ -103,8 +132,8 @@ func handleCORS(handler http.Handler) http.Handler {
 	})
 }
 
-func StartHTTP(store storage.Store, port int) error {
-	router := NewRouter(store)
+func StartHTTP(store storage.Store, port int, primary string) error {
+	router := NewRouter(store, primary)
 	handler := handleCORS(router)
 	handler = handlers.ProxyHeaders(handler)
 	handler = bugsnag.Handler(handler)
@@ -1,7 +1,14 @@
 package rpc
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel"
 	"github.com/MixinNetwork/mixin/storage"
 )
@@ -12,6 +19,10 @@ func getInfo(store storage.Store) (map[string]interface{}, error) {
 		"node":    kernel.NodeIdForNetwork(),
 		"version": config.BuildVersion,
 	}
+	lag, synced := kernel.MajorityLag()
+	info["synced"] = synced
+	info["lag"] = lag
+	info["degraded"] = kernel.Degraded()
 	graph, err := kernel.LoadRoundGraph(store, kernel.NetworkId(), kernel.NodeIdForNetwork())
 	if err != nil {
 		return info, err
@@ -49,10 +60,57 @@ func getInfo(store storage.Store) (map[string]interface{}, error) {
 	if err != nil {
 		return info, err
 	}
+	commits, conflicts := store.ConflictInfo()
+	info["store"] = map[string]interface{}{
+		"fsync":     store.FsyncPolicy(),
+		"commits":   commits,
+		"conflicts": conflicts,
+	}
+	info["rejections"] = kernel.RejectionCounts()
 	info["queue"] = map[string]interface{}{
 		"transactions": t,
 		"finals":       f,
 		"caches":       c,
+		"buffer":       kernel.SnapshotBufferInfo(),
+	}
+	size, hits, misses := store.TransactionCacheInfo()
+	vsize, vhits, vmisses := common.VerificationCacheInfo()
+	info["cache"] = map[string]interface{}{
+		"transactions": size,
+		"hits":         hits,
+		"misses":       misses,
+		"verifications": map[string]interface{}{
+			"transactions": vsize,
+			"hits":         vhits,
+			"misses":       vmisses,
+		},
+	}
+	info["fee"] = map[string]interface{}{
+		"asset":   common.XINAssetId,
+		"minimum": common.MinimumFee(),
 	}
 	return info, nil
 }
+
+func getNodeSnapshotRate(params []interface{}) (map[string]interface{}, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	id, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := kernel.NodeSnapshotRate(id, time.Duration(seconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"node":   id,
+		"window": seconds,
+		"rate":   rate,
+	}, nil
+}
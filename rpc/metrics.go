@@ -0,0 +1,18 @@
+package rpc
+
+import (
+	"net/http"
+
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+// PrometheusHandler exposes the metrics emitted when the Prometheus backend is
+// in use, including the peer metrics, in the Prometheus text format.
+func PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if p, ok := metrics.Current().(*metrics.Prometheus); ok {
+			p.Write(w)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const ReplicaForwardTimeout = 10 * time.Second
+
+// the mutating methods a read replica forwards to the primary, all other
+// methods are served from the local store
+var replicaForwardMethods = map[string]bool{
+	"sendrawtransaction": true,
+}
+
+type replicaForwarder struct {
+	primary string
+	client  *http.Client
+}
+
+func newReplicaForwarder(primary string) *replicaForwarder {
+	if primary == "" {
+		return nil
+	}
+	return &replicaForwarder{
+		primary: primary,
+		client:  &http.Client{Timeout: ReplicaForwardTimeout},
+	}
+}
+
+func (f *replicaForwarder) forwards(method string) bool {
+	return f != nil && replicaForwardMethods[method]
+}
+
+// forward responds with the status and body from the primary as is.
+func (f *replicaForwarder) forward(w http.ResponseWriter, call *Call) error {
+	body, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Post(f.primary, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("replica primary unreachable %s", err.Error())
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("replica primary response %s", err.Error())
+	}
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	_, err = w.Write(data)
+	return err
+}
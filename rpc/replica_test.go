@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaForward(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-rpc-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := storage.NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	var forwarded []Call
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call Call
+		err := json.NewDecoder(r.Body).Decode(&call)
+		assert.Nil(err)
+		forwarded = append(forwarded, call)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"primary-tx"}`))
+	}))
+
+	call := func(router http.Handler, body string) map[string]interface{} {
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(http.StatusOK, rec.Code)
+		var resp map[string]interface{}
+		err := json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Nil(err)
+		return resp
+	}
+
+	router := NewRouter(store, primary.URL)
+	hash := crypto.NewHash([]byte("unknown"))
+	resp := call(router, `{"method":"gettransaction","params":["`+hash.String()+`"]}`)
+	assert.Nil(resp["error"])
+	assert.Len(forwarded, 0)
+
+	resp = call(router, `{"method":"sendrawtransaction","params":["deadbeef"]}`)
+	assert.Equal("primary-tx", resp["id"])
+	assert.Len(forwarded, 1)
+	assert.Equal("sendrawtransaction", forwarded[0].Method)
+	assert.Equal([]interface{}{"deadbeef"}, forwarded[0].Params)
+
+	resp = call(NewRouter(store, ""), `{"method":"sendrawtransaction","params":["deadbeef"]}`)
+	assert.NotNil(resp["error"])
+	assert.Len(forwarded, 1)
+
+	primary.Close()
+	resp = call(router, `{"method":"sendrawtransaction","params":["deadbeef"]}`)
+	assert.Contains(resp["error"], "replica primary unreachable")
+	assert.Len(forwarded, 1)
+}
@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/dgraph-io/badger"
 )
@@ -12,11 +15,27 @@ type BadgerStore struct {
 	cacheDB     *badger.DB
 	stateDB     *badger.DB
 	queue       *Queue
+	txCache     *transactionCache
+	mmapScan    bool
+	quarantine  bool
+	genesisJobs int
+	fsync       *fsyncer
+	conflicts   *conflictMetrics
 	closing     bool
 }
 
 func NewBadgerStore(dir string) (*BadgerStore, error) {
-	snapshotsDB, err := openDB(dir+"/snapshots", true)
+	return NewBadgerStoreWithFsync(dir, config.FsyncPolicyImmediate, 0)
+}
+
+// NewBadgerStoreWithFsync applies the fsync policy to the snapshots database
+// only, the cache and state databases always sync writes.
+func NewBadgerStoreWithFsync(dir string, policy string, interval time.Duration) (*BadgerStore, error) {
+	fsync, err := newFsyncer(dir+"/snapshots", policy, interval)
+	if err != nil {
+		return nil, err
+	}
+	snapshotsDB, err := openDB(dir+"/snapshots", policy == config.FsyncPolicyImmediate)
 	if err != nil {
 		return nil, err
 	}
@@ -33,6 +52,9 @@ func NewBadgerStore(dir string) (*BadgerStore, error) {
 		cacheDB:     cacheDB,
 		stateDB:     stateDB,
 		queue:       NewQueue(),
+		txCache:     newTransactionCache(TransactionCacheSize),
+		fsync:       fsync.start(),
+		conflicts:   new(conflictMetrics),
 		closing:     false,
 	}, nil
 }
@@ -40,6 +62,7 @@ func NewBadgerStore(dir string) (*BadgerStore, error) {
 func (store *BadgerStore) Close() error {
 	store.closing = true
 	store.queue.Dispose()
+	store.fsync.stop()
 	err := store.snapshotsDB.Close()
 	if err != nil {
 		return err
@@ -78,3 +101,13 @@ func openDB(dir string, sync bool) (*badger.DB, error) {
 	}()
 	return db, nil
 }
+
+// IsUnavailableError tells whether the error is caused by a read-only or full
+// disk, the store may recover from it without restarting the node.
+func IsUnavailableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, syscall.EROFS.Error()) || strings.Contains(msg, syscall.ENOSPC.Error())
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+	"github.com/vmihailenco/msgpack"
+)
+
+// archivedRound is a final round without the node id, which is the same for
+// all rounds in an archive, and with the round 0 without references.
+type archivedRound struct {
+	Hash      crypto.Hash
+	Number    uint64
+	Timestamp uint64
+	Self      crypto.Hash
+	External  crypto.Hash
+}
+
+type roundArchive struct {
+	Rounds   []archivedRound
+	Checksum crypto.Hash
+}
+
+// ArchiveRounds moves the final rounds of the node from the round index to a
+// single archive record, except the head round and the last final round which
+// may still be referenced by the rounds of the other nodes. The archive keeps
+// the hash chain of the rounds with a checksum, so it's verified on read, and
+// each round hash is indexed to the node, so ReadRound still finds it.
+func (s *BadgerStore) ArchiveRounds(nodeId crypto.Hash) (int, error) {
+	var archived int
+	err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		archived = 0
+		head, err := readRound(txn, nodeId)
+		if err != nil || head == nil || head.References == nil {
+			return err
+		}
+		last, err := readRound(txn, head.References.Self)
+		if err != nil || last == nil || last.References == nil {
+			return err
+		}
+
+		archive, err := readRoundArchive(txn, nodeId)
+		if err != nil {
+			return err
+		}
+		for hash := last.References.Self; ; {
+			round, err := readRound(txn, hash)
+			if err != nil {
+				return err
+			}
+			if round == nil {
+				break
+			}
+			if round.NodeId != nodeId {
+				return fmt.Errorf("archive round node mismatch %s %s", nodeId.String(), round.NodeId.String())
+			}
+			ar := archivedRound{Hash: hash, Number: round.Number, Timestamp: round.Timestamp}
+			if round.References != nil {
+				ar.Self, ar.External = round.References.Self, round.References.External
+			}
+			archive.Rounds = append(archive.Rounds, ar)
+			err = txn.Delete(graphRoundKey(hash))
+			if err != nil {
+				return err
+			}
+			err = txn.Set(graphRoundArchiveKey(hash), nodeId[:])
+			if err != nil {
+				return err
+			}
+			archived = archived + 1
+			if round.References == nil {
+				break
+			}
+			hash = round.References.Self
+		}
+		if archived == 0 {
+			return nil
+		}
+
+		sort.Slice(archive.Rounds, func(i, j int) bool { return archive.Rounds[i].Number < archive.Rounds[j].Number })
+		archive.Checksum = archive.checksum()
+		return txn.Set(graphArchiveKey(nodeId), common.MsgpackMarshalPanic(archive))
+	})
+	return archived, err
+}
+
+// ReadArchivedRounds returns the archived final rounds of the node in order,
+// after checking the checksum and the self references chain of the archive.
+func (s *BadgerStore) ReadArchivedRounds(nodeId crypto.Hash) ([]*common.Round, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	archive, err := readRoundArchive(txn, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	return archive.rounds(nodeId)
+}
+
+func (archive *roundArchive) rounds(nodeId crypto.Hash) ([]*common.Round, error) {
+	if len(archive.Rounds) == 0 {
+		return nil, nil
+	}
+	if archive.Checksum != archive.checksum() {
+		return nil, fmt.Errorf("round archive checksum mismatch %s", nodeId.String())
+	}
+	rounds := make([]*common.Round, len(archive.Rounds))
+	for i, ar := range archive.Rounds {
+		if i > 0 {
+			prev := archive.Rounds[i-1]
+			if ar.Number != prev.Number+1 || ar.Self != prev.Hash {
+				return nil, fmt.Errorf("round archive chain broken %s %d", nodeId.String(), ar.Number)
+			}
+		}
+		rounds[i] = &common.Round{Hash: ar.Hash, NodeId: nodeId, Number: ar.Number, Timestamp: ar.Timestamp}
+		if ar.Self.HasValue() || ar.External.HasValue() {
+			rounds[i].References = &common.RoundLink{Self: ar.Self, External: ar.External}
+		}
+	}
+	return rounds, nil
+}
+
+func (a *roundArchive) checksum() crypto.Hash {
+	var data []byte
+	for _, r := range a.Rounds {
+		buf := make([]byte, 16)
+		binary.BigEndian.PutUint64(buf[:8], r.Number)
+		binary.BigEndian.PutUint64(buf[8:], r.Timestamp)
+		data = append(data, buf...)
+		data = append(data, r.Hash[:]...)
+		data = append(data, r.Self[:]...)
+		data = append(data, r.External[:]...)
+	}
+	return crypto.NewHash(data)
+}
+
+func readRoundArchive(txn *badger.Txn, nodeId crypto.Hash) (*roundArchive, error) {
+	var archive roundArchive
+	item, err := txn.Get(graphArchiveKey(nodeId))
+	if err == badger.ErrKeyNotFound {
+		return &archive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ival, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	err = msgpack.Unmarshal(ival, &archive)
+	return &archive, err
+}
+
+// readFinalRound reads the final round from the round index, or from the
+// archive of its node if already compacted.
+func readFinalRound(txn *badger.Txn, hash crypto.Hash) (*common.Round, error) {
+	round, err := readRound(txn, hash)
+	if err != nil || round != nil {
+		return round, err
+	}
+	item, err := txn.Get(graphRoundArchiveKey(hash))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ival, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var nodeId crypto.Hash
+	copy(nodeId[:], ival)
+	archive, err := readRoundArchive(txn, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	rounds, err := archive.rounds(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rounds {
+		if r.Hash == hash {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("archived round not found %s %s", nodeId.String(), hash.String())
+}
+
+func graphArchiveKey(nodeId crypto.Hash) []byte {
+	return append([]byte(graphPrefixArchive), nodeId[:]...)
+}
+
+func graphRoundArchiveKey(hash crypto.Hash) []byte {
+	return append([]byte(graphPrefixRoundArchive), hash[:]...)
+}
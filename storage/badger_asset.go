@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+	"github.com/vmihailenco/msgpack"
+)
+
+func (s *BadgerStore) ReadAssets() ([]crypto.Hash, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	assets := make([]crypto.Hash, 0)
+	prefix := []byte(graphPrefixAsset)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		var asset crypto.Hash
+		copy(asset[:], it.Item().Key()[len(prefix):])
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// IndexAssets rebuilds the asset index from the transactions of all snapshots,
+// for the stores written before the index, and stops with ctx.Err() as soon
+// as the context is done.
+func (s *BadgerStore) IndexAssets(ctx context.Context) error {
+	assets := make(map[crypto.Hash]bool)
+	err := s.snapshotsDB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(graphPrefixSnapshot)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var snap common.SnapshotWithTopologicalOrder
+			err = msgpack.Unmarshal(v, &snap)
+			if err != nil {
+				return err
+			}
+			tx, err := readTransaction(txn, snap.Transaction)
+			if err != nil {
+				return err
+			}
+			if tx != nil {
+				assets[tx.Asset] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		for asset := range assets {
+			err := writeAsset(txn, asset)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func writeAsset(txn *badger.Txn, asset crypto.Hash) error {
+	return txn.Set(graphAssetKey(asset), []byte{})
+}
+
+func graphAssetKey(asset crypto.Hash) []byte {
+	return append([]byte(graphPrefixAsset), asset[:]...)
+}
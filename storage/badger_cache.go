@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"sort"
+	"time"
+
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -52,17 +55,66 @@ func (s *BadgerStore) CacheListTransactions(hook func(tx *common.SignedTransacti
 	return nil
 }
 
-func (s *BadgerStore) CachePutTransaction(tx *common.SignedTransaction) error {
-	txn := s.cacheDB.NewTransaction(true)
-	defer txn.Discard()
+// CacheEvictTransactions removes the pending transactions put into the cache
+// before the time, then the oldest ones until at most limit pending remain,
+// and returns the numbers removed by age and by size. The put time is derived
+// from the cache TTL, so it's only accurate to seconds.
+func (s *BadgerStore) CacheEvictTransactions(before time.Time, limit int) (int, int, error) {
+	var aged, sized int
+	err := s.update(s.cacheDB, func(txn *badger.Txn) error {
+		aged, sized = 0, 0
+		snapTxn := s.snapshotsDB.NewTransaction(false)
+		defer snapTxn.Discard()
 
-	key := cacheTransactionCacheKey(tx.PayloadHash())
-	val := common.MsgpackMarshalPanic(tx)
-	err := txn.SetWithTTL(key, val, config.CacheTTL)
-	if err != nil {
-		return err
-	}
-	return txn.Commit()
+		type pending struct {
+			key []byte
+			put time.Time
+		}
+		var pendings []pending
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		prefix := []byte(cachePrefixTransactionCache)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := append([]byte(graphPrefixFinalization), item.Key()[len(prefix):]...)
+			_, err := snapTxn.Get(key)
+			if err == nil {
+				continue
+			} else if err != badger.ErrKeyNotFound {
+				it.Close()
+				return err
+			}
+			put := time.Unix(int64(item.ExpiresAt()), 0).Add(-config.CacheTTL)
+			pendings = append(pendings, pending{key: item.KeyCopy(nil), put: put})
+		}
+		it.Close()
+
+		sort.SliceStable(pendings, func(i, j int) bool { return pendings[i].put.Before(pendings[j].put) })
+		for i, p := range pendings {
+			if p.put.Before(before) {
+				aged = aged + 1
+			} else if len(pendings)-i > limit {
+				sized = sized + 1
+			} else {
+				break
+			}
+			err := txn.Delete(p.key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return aged, sized, err
+}
+
+func (s *BadgerStore) CachePutTransaction(tx *common.SignedTransaction) error {
+	return s.update(s.cacheDB, func(txn *badger.Txn) error {
+		key := cacheTransactionCacheKey(tx.PayloadHash())
+		val := common.MsgpackMarshalPanic(tx)
+		return txn.SetWithTTL(key, val, config.CacheTTL)
+	})
 }
 
 func (s *BadgerStore) CacheGetTransaction(hash crypto.Hash) (*common.SignedTransaction, error) {
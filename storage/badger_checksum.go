@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+	"github.com/vmihailenco/msgpack"
+)
+
+type checksumManifest struct {
+	Count    uint64
+	Tip      uint64
+	Checksum crypto.Hash
+}
+
+// VerifyChecksum compares the manifest sealed at the last verification against
+// the snapshot and topology records up to its tip, to catch the data directory
+// tampered or corrupted outside of the node, then seals a new manifest for the
+// current tip. The manifest is not updated along with each snapshot write, so
+// the snapshots after the sealed tip are covered from the next verification.
+func (s *BadgerStore) VerifyChecksum(ctx context.Context) error {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	manifest, err := readChecksum(txn)
+	if err != nil {
+		return err
+	}
+
+	count, err := countSnapshotRecords(ctx, txn)
+	if err != nil {
+		return err
+	}
+	if count == 0 && manifest == nil {
+		return nil
+	}
+	if manifest == nil {
+		return fmt.Errorf("checksum manifest not found for %d snapshots", count)
+	}
+
+	tip := readTopologySequence(txn)
+	if tip == 0 || tip-1 < manifest.Tip {
+		return fmt.Errorf("checksum topology tip mismatch %d %d", manifest.Tip, tip)
+	}
+	sealed, err := countTopologyRecords(ctx, txn, manifest.Tip)
+	if err != nil {
+		return err
+	}
+	if sealed != manifest.Count {
+		return fmt.Errorf("checksum snapshots count mismatch %d %d", manifest.Count, sealed)
+	}
+	checksum, err := computeChecksum(txn, manifest.Tip, sealed)
+	if err != nil {
+		return err
+	}
+	if checksum != manifest.Checksum {
+		return fmt.Errorf("checksum mismatch %s %s", manifest.Checksum.String(), checksum.String())
+	}
+
+	total, err := countTopologyRecords(ctx, txn, tip-1)
+	if err != nil {
+		return err
+	}
+	if total != count {
+		return fmt.Errorf("checksum snapshots count mismatch %d %d", total, count)
+	}
+	if manifest.Tip == tip-1 {
+		return nil
+	}
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		return sealChecksum(txn, tip-1, total)
+	})
+}
+
+func sealChecksum(txn *badger.Txn, tip, count uint64) error {
+	checksum, err := computeChecksum(txn, tip, count)
+	if err != nil {
+		return err
+	}
+	manifest := &checksumManifest{Tip: tip, Count: count, Checksum: checksum}
+	return txn.Set([]byte(graphPrefixChecksum), common.MsgpackMarshalPanic(manifest))
+}
+
+// countTopologyRecords counts the topology orders up to the tip, with both the
+// topology record and the snapshot record it points to found.
+func countTopologyRecords(ctx context.Context, txn *badger.Txn, tip uint64) (uint64, error) {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var count uint64
+	prefix := []byte(graphPrefixTopology)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		item := it.Item()
+		if graphTopologyOrder(item.Key()) > tip {
+			break
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return 0, err
+		}
+		_, err = txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		count = count + 1
+	}
+	return count, nil
+}
+
+func countSnapshotRecords(ctx context.Context, txn *badger.Txn) (uint64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var count uint64
+	prefix := []byte(graphPrefixSnapshot)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		count = count + 1
+	}
+	return count, nil
+}
+
+func readChecksum(txn *badger.Txn) (*checksumManifest, error) {
+	item, err := txn.Get([]byte(graphPrefixChecksum))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ival, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var manifest checksumManifest
+	err = msgpack.Unmarshal(ival, &manifest)
+	return &manifest, err
+}
+
+func computeChecksum(txn *badger.Txn, tip, count uint64) (crypto.Hash, error) {
+	item, err := txn.Get(graphTopologyKey(tip))
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	key, err := item.ValueCopy(nil)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	_, err = txn.Get(key)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], tip)
+	binary.BigEndian.PutUint64(buf[8:], count)
+	return crypto.NewHash(append(key, buf...)), nil
+}
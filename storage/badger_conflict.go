@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"sync/atomic"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	StoreConflictLogThreshold = 3
+)
+
+type conflictMetrics struct {
+	commits   uint64
+	conflicts uint64
+	streak    uint64
+}
+
+// update is badger.DB.Update with the commits and conflicts counted, a conflict
+// is returned to the caller as is, and logged when they happen in a row.
+func (s *BadgerStore) update(db *badger.DB, fn func(txn *badger.Txn) error) error {
+	txn := db.NewTransaction(true)
+	defer txn.Discard()
+
+	err := fn(txn)
+	if err != nil {
+		return err
+	}
+	err = txn.Commit()
+	switch err {
+	case nil:
+		atomic.AddUint64(&s.conflicts.commits, 1)
+		atomic.StoreUint64(&s.conflicts.streak, 0)
+	case badger.ErrConflict:
+		atomic.AddUint64(&s.conflicts.conflicts, 1)
+		metrics.Counter("mixin_store_conflicts_total", 1)
+		streak := atomic.AddUint64(&s.conflicts.streak, 1)
+		if streak == StoreConflictLogThreshold {
+			logger.Printf("STORE CONFLICT %d times in a row\n", streak)
+		}
+	}
+	return err
+}
+
+// ConflictInfo returns the number of write transactions committed and the
+// conflicts failed them.
+func (s *BadgerStore) ConflictInfo() (uint64, uint64) {
+	c := s.conflicts
+	return atomic.LoadUint64(&c.commits), atomic.LoadUint64(&c.conflicts)
+}
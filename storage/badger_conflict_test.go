@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreConflicts(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(3)
+	var rounds []*common.Round
+	for _, s := range snapshots {
+		rounds = append(rounds, &common.Round{Hash: s.NodeId, NodeId: s.NodeId})
+	}
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	assert.Nil(err)
+	commits, conflicts := store.ConflictInfo()
+	assert.Equal(uint64(1), commits)
+	assert.Equal(uint64(0), conflicts)
+
+	key := []byte("conflict")
+	var attempts int
+	conflict := func(txn *badger.Txn) error {
+		attempts = attempts + 1
+		_, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		err = store.snapshotsDB.Update(func(other *badger.Txn) error {
+			return other.Set(key, []byte("other"))
+		})
+		if err != nil {
+			return err
+		}
+		return txn.Set(key, []byte("self"))
+	}
+
+	err = store.update(store.snapshotsDB, conflict)
+	assert.Equal(badger.ErrConflict, err)
+	assert.Equal(1, attempts)
+	commits, conflicts = store.ConflictInfo()
+	assert.Equal(uint64(1), commits)
+	assert.Equal(uint64(1), conflicts)
+
+	err = store.update(store.snapshotsDB, func(txn *badger.Txn) error {
+		return txn.Set(key, []byte("self"))
+	})
+	assert.Nil(err)
+	commits, conflicts = store.ConflictInfo()
+	assert.Equal(uint64(2), commits)
+	assert.Equal(uint64(1), conflicts)
+
+	err = store.update(store.snapshotsDB, func(txn *badger.Txn) error {
+		return badger.ErrEmptyKey
+	})
+	assert.Equal(badger.ErrEmptyKey, err)
+	commits, conflicts = store.ConflictInfo()
+	assert.Equal(uint64(2), commits)
+	assert.Equal(uint64(1), conflicts)
+}
@@ -21,8 +21,15 @@ func (s *BadgerStore) ReadDomains() []common.Domain {
 
 	prefix := []byte(graphPrefixDomainAccept)
 	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-		acc := domainAccountForState(it.Item().Key(), graphPrefixDomainAccept)
-		domains = append(domains, common.Domain{Account: acc})
+		item := it.Item()
+		acc := domainAccountForState(item.Key(), graphPrefixDomainAccept)
+		ival, err := item.ValueCopy(nil)
+		if err != nil {
+			panic(err)
+		}
+		var hash crypto.Hash
+		copy(hash[:], ival)
+		domains = append(domains, common.Domain{Account: acc, Transaction: hash})
 	}
 	return domains
 }
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// fsyncer syncs the value log files of a database opened without synced
+// writes. Badger itself only syncs them when the memtable is flushed, so a
+// write committed but not synced yet may be lost on crash, while it's always
+// safe to sync a file through another descriptor because fsync works on the
+// inode.
+type fsyncer struct {
+	dir      string
+	policy   string
+	interval time.Duration
+	quit     chan struct{}
+	mutex    *sync.Mutex
+	synced   time.Time
+}
+
+func newFsyncer(dir string, policy string, interval time.Duration) (*fsyncer, error) {
+	switch policy {
+	case config.FsyncPolicyImmediate, config.FsyncPolicyOS:
+	case config.FsyncPolicyPeriodic:
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid fsync interval %s", interval)
+		}
+	default:
+		return nil, fmt.Errorf("invalid fsync policy %s", policy)
+	}
+	return &fsyncer{
+		dir:      dir,
+		policy:   policy,
+		interval: interval,
+		quit:     make(chan struct{}),
+		mutex:    new(sync.Mutex),
+	}, nil
+}
+
+func (f *fsyncer) start() *fsyncer {
+	if f.policy == config.FsyncPolicyPeriodic {
+		go f.loop()
+	}
+	return f
+}
+
+func (f *fsyncer) stop() {
+	if f.policy == config.FsyncPolicyPeriodic {
+		close(f.quit)
+	}
+}
+
+func (f *fsyncer) loop() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.quit:
+			return
+		case <-ticker.C:
+			err := f.sync()
+			if err != nil {
+				logger.Println("badger fsync error", f.dir, err)
+			}
+		}
+	}
+}
+
+// sync only syncs the value log files modified since the last sync, and the
+// directory for the newly created ones.
+func (f *fsyncer) sync() error {
+	if f.policy == config.FsyncPolicyImmediate {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	start := time.Now()
+	files, err := filepath.Glob(filepath.Join(f.dir, "*.vlog"))
+	if err != nil {
+		return err
+	}
+	for _, name := range append(files, f.dir) {
+		stat, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if stat.ModTime().Before(f.synced) {
+			continue
+		}
+		err = syncFile(name)
+		if err != nil {
+			return err
+		}
+	}
+	f.synced = start
+	return nil
+}
+
+func syncFile(name string) error {
+	fd, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return fd.Sync()
+}
+
+func (s *BadgerStore) FsyncPolicy() string {
+	return s.fsync.policy
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFsyncPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	_, err = NewBadgerStoreWithFsync(root, "never", time.Second)
+	assert.NotNil(err)
+	assert.Equal("invalid fsync policy never", err.Error())
+	_, err = NewBadgerStoreWithFsync(root, config.FsyncPolicyPeriodic, 0)
+	assert.NotNil(err)
+
+	store, err := NewBadgerStoreWithFsync(root, config.FsyncPolicyPeriodic, 10*time.Millisecond)
+	assert.Nil(err)
+	assert.Equal(config.FsyncPolicyPeriodic, store.FsyncPolicy())
+	snapshots, transactions := testBuildGenesis(3)
+	var rounds []*common.Round
+	for _, s := range snapshots {
+		rounds = append(rounds, &common.Round{Hash: s.NodeId, NodeId: s.NodeId})
+	}
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	assert.Nil(err)
+	lastSynced := func() time.Time {
+		store.fsync.mutex.Lock()
+		defer store.fsync.mutex.Unlock()
+		return store.fsync.synced
+	}
+	synced := lastSynced()
+	assert.False(synced.IsZero())
+	time.Sleep(50 * time.Millisecond)
+	assert.True(lastSynced().After(synced))
+	err = store.Close()
+	assert.Nil(err)
+
+	store, err = NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+	assert.Equal(config.FsyncPolicyImmediate, store.FsyncPolicy())
+	loaded, err := store.CheckGenesisLoad()
+	assert.Nil(err)
+	assert.True(loaded)
+	assert.Equal(uint64(3), store.TopologySequence())
+}
+
+func BenchmarkFsyncPolicy(b *testing.B) {
+	for _, policy := range []string{config.FsyncPolicyImmediate, config.FsyncPolicyPeriodic, config.FsyncPolicyOS} {
+		b.Run(policy, func(b *testing.B) {
+			root, err := ioutil.TempDir("", "mixin-badger-test")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			store, err := NewBadgerStoreWithFsync(root, policy, time.Second)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer store.Close()
+
+			value := make([]byte, 512)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				txn := store.snapshotsDB.NewTransaction(true)
+				key := make([]byte, 8)
+				binary.BigEndian.PutUint64(key, uint64(i))
+				err := txn.Set(append([]byte(graphPrefixSnapshot), key...), value)
+				if err == nil {
+					err = txn.Commit()
+				}
+				txn.Discard()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
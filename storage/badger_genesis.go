@@ -1,36 +1,204 @@
 package storage
 
 import (
+	"sync"
+
 	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/metrics"
 	"github.com/dgraph-io/badger"
 )
 
+const (
+	graphGenesisPendingKey = "GENESISPENDING"
+	genesisRollbackBatch   = 1000
+)
+
+// SetGenesisWriters spreads the LoadGenesis writes across the workers, each
+// with its own write transaction, one worker writes all in one transaction.
+func (s *BadgerStore) SetGenesisWriters(workers int) {
+	s.genesisJobs = workers
+}
+
 func (s *BadgerStore) LoadGenesis(rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.SignedTransaction) error {
-	txn := s.snapshotsDB.NewTransaction(true)
-	defer txn.Discard()
+	pending, err := s.checkGenesisPending()
+	if err != nil {
+		return err
+	}
+	if pending {
+		err = s.rollbackGenesis()
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.genesisJobs > 1 {
+		err = s.loadGenesisParallel(rounds, snapshots, transactions)
+	} else {
+		err = s.loadGenesisSerial(rounds, snapshots, transactions)
+	}
+	if err != nil {
+		return err
+	}
+	metrics.Counter("mixin_store_genesis_snapshots_total", uint64(len(snapshots)))
+	metrics.Gauge("mixin_store_topology", float64(len(snapshots)))
+	return s.fsync.sync()
+}
+
+func (s *BadgerStore) loadGenesisSerial(rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.SignedTransaction) error {
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		if checkGenesisLoad(txn) {
+			return nil
+		}
+
+		for _, r := range rounds {
+			err := writeRound(txn, r.Hash, r)
+			if err != nil {
+				return err
+			}
+		}
+		for i, snap := range snapshots {
+			err := writeTransaction(txn, transactions[i])
+			if err != nil {
+				return err
+			}
+			err = writeSnapshot(txn, snap, transactions[i])
+			if err != nil {
+				return err
+			}
+		}
+		return sealGenesisChecksum(txn, snapshots)
+	})
+}
 
-	if checkGenesisLoad(txn) {
+func sealGenesisChecksum(txn *badger.Txn, snapshots []*common.SnapshotWithTopologicalOrder) error {
+	if len(snapshots) == 0 {
 		return nil
 	}
+	var tip uint64
+	for _, snap := range snapshots {
+		if snap.TopologicalOrder > tip {
+			tip = snap.TopologicalOrder
+		}
+	}
+	return sealChecksum(txn, tip, uint64(len(snapshots)))
+}
+
+// loadGenesisParallel marks the genesis pending before the parallel writes,
+// and removes the mark with the checksum manifest in the last transaction, so
+// the genesis is loaded as a whole or rolled back, and a pending genesis left
+// by a crash is rolled back by the next LoadGenesis.
+func (s *BadgerStore) loadGenesisParallel(rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.SignedTransaction) error {
+	var loaded bool
+	err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		loaded = checkGenesisLoad(txn)
+		if loaded {
+			return nil
+		}
+		return txn.Set([]byte(graphGenesisPendingKey), []byte{})
+	})
+	if err != nil || loaded {
+		return err
+	}
 
-	for _, r := range rounds {
-		err := writeRound(txn, r.Hash, r)
+	var wg sync.WaitGroup
+	workers := s.genesisJobs
+	errs := make([]error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+				for i := w; i < len(rounds); i += workers {
+					err := writeRound(txn, rounds[i].Hash, rounds[i])
+					if err != nil {
+						return err
+					}
+				}
+				for i := w; i < len(snapshots); i += workers {
+					err := writeTransaction(txn, transactions[i])
+					if err != nil {
+						return err
+					}
+					err = writeSnapshot(txn, snapshots[i], transactions[i])
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
 		if err != nil {
+			rerr := s.rollbackGenesis()
+			if rerr != nil {
+				return rerr
+			}
 			return err
 		}
 	}
-	for i, snap := range snapshots {
-		err := writeTransaction(txn, transactions[i])
+
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		err := sealGenesisChecksum(txn, snapshots)
 		if err != nil {
 			return err
 		}
-		err = writeSnapshot(txn, snap, transactions[i])
+		return txn.Delete([]byte(graphGenesisPendingKey))
+	})
+}
+
+// rollbackGenesis deletes all records of a pending genesis, the snapshots
+// database has nothing else before the genesis loaded. The pending mark is
+// deleted at last, so an interrupted rollback is retried.
+func (s *BadgerStore) rollbackGenesis() error {
+	defer s.txCache.Purge()
+	for {
+		var keys [][]byte
+		txn := s.snapshotsDB.NewTransaction(false)
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		for it.Rewind(); it.Valid() && len(keys) < genesisRollbackBatch; it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if string(key) == graphGenesisPendingKey {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		it.Close()
+		txn.Discard()
+		if len(keys) == 0 {
+			break
+		}
+
+		err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+			for _, key := range keys {
+				err := txn.Delete(key)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
 	}
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		return txn.Delete([]byte(graphGenesisPendingKey))
+	})
+}
 
-	return txn.Commit()
+func (s *BadgerStore) checkGenesisPending() (bool, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	_, err := txn.Get([]byte(graphGenesisPendingKey))
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
 }
 
 func (s *BadgerStore) CheckGenesisLoad() (bool, error) {
@@ -40,10 +208,15 @@ func (s *BadgerStore) CheckGenesisLoad() (bool, error) {
 	return checkGenesisLoad(txn), nil
 }
 
+// checkGenesisLoad is false for a pending genesis of the parallel writes
 func checkGenesisLoad(txn *badger.Txn) bool {
 	it := txn.NewIterator(badger.DefaultIteratorOptions)
 	defer it.Close()
 
 	it.Rewind()
-	return it.Valid()
+	if !it.Valid() {
+		return false
+	}
+	_, err := txn.Get([]byte(graphGenesisPendingKey))
+	return err == badger.ErrKeyNotFound
 }
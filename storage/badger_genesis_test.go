@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadGenesisParallel(t *testing.T) {
+	assert := assert.New(t)
+
+	snapshots, transactions := testBuildGenesis(201)
+	rounds := testBuildGenesisRounds(snapshots)
+	serial, err := testLoadGenesis(1, rounds, snapshots, transactions)
+	assert.Nil(err)
+	parallel, err := testLoadGenesis(8, rounds, snapshots, transactions)
+	assert.Nil(err)
+	assert.Len(parallel, len(serial))
+	assert.Equal(serial, parallel)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+	store.SetGenesisWriters(8)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	assert.Nil(err)
+	loaded, err := store.CheckGenesisLoad()
+	assert.Nil(err)
+	assert.True(loaded)
+	assert.Equal(uint64(len(snapshots)), store.TopologySequence())
+	txn := store.snapshotsDB.NewTransaction(false)
+	manifest, err := readChecksum(txn)
+	txn.Discard()
+	assert.Nil(err)
+	assert.Equal(uint64(len(snapshots)), manifest.Count)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	assert.Nil(err)
+}
+
+func TestLoadGenesisParallelRollback(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+	store.SetGenesisWriters(4)
+
+	snapshots, transactions := testBuildGenesis(21)
+	invalid := common.NewTransaction(common.XINAssetId)
+	invalid.AddInput(testRandomHash(), 0)
+	signed := &common.SignedTransaction{Transaction: *invalid}
+	transactions[7] = signed
+	snapshots[7].Transaction = signed.PayloadHash()
+	err = store.LoadGenesis(nil, snapshots, transactions)
+	assert.NotNil(err)
+	loaded, err := store.CheckGenesisLoad()
+	assert.Nil(err)
+	assert.False(loaded)
+	records, err := testDumpSnapshotsDB(store)
+	assert.Nil(err)
+	assert.Len(records, 0)
+
+	snapshots, transactions = testBuildGenesis(21)
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		err := writeTransaction(txn, transactions[0])
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(graphGenesisPendingKey), []byte{})
+	})
+	assert.Nil(err)
+	loaded, err = store.CheckGenesisLoad()
+	assert.Nil(err)
+	assert.False(loaded)
+	store.SetGenesisWriters(1)
+	err = store.LoadGenesis(nil, snapshots[1:], transactions[1:])
+	assert.Nil(err)
+	tx, err := store.ReadTransaction(transactions[0].PayloadHash())
+	assert.Nil(err)
+	assert.Nil(tx)
+	loaded, err = store.CheckGenesisLoad()
+	assert.Nil(err)
+	assert.True(loaded)
+}
+
+func BenchmarkLoadGenesis(b *testing.B) {
+	snapshots, transactions := testBuildGenesis(201)
+	rounds := testBuildGenesisRounds(snapshots)
+	for _, bc := range []struct {
+		name    string
+		workers int
+	}{{"serial", 1}, {"parallel", 8}} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				root, err := ioutil.TempDir("", "mixin-badger-test")
+				if err != nil {
+					b.Fatal(err)
+				}
+				store, err := NewBadgerStore(root)
+				if err != nil {
+					b.Fatal(err)
+				}
+				store.SetGenesisWriters(bc.workers)
+				b.StartTimer()
+				err = store.LoadGenesis(rounds, snapshots, transactions)
+				b.StopTimer()
+				if err != nil {
+					b.Fatal(err)
+				}
+				store.Close()
+				os.RemoveAll(root)
+			}
+		})
+	}
+}
+
+func testBuildGenesisRounds(snapshots []*common.SnapshotWithTopologicalOrder) []*common.Round {
+	rounds := make([]*common.Round, len(snapshots))
+	for i, s := range snapshots {
+		rounds[i] = &common.Round{Hash: testRandomHash(), NodeId: s.NodeId}
+	}
+	return rounds
+}
+
+func testLoadGenesis(workers int, rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.SignedTransaction) (map[string][]byte, error) {
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(root)
+	store, err := NewBadgerStore(root)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	store.SetGenesisWriters(workers)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	if err != nil {
+		return nil, err
+	}
+	return testDumpSnapshotsDB(store)
+}
+
+func testDumpSnapshotsDB(store *BadgerStore) (map[string][]byte, error) {
+	records := make(map[string][]byte)
+	err := store.snapshotsDB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			records[string(it.Item().KeyCopy(nil))] = val
+		}
+		return nil
+	})
+	return records, err
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger"
+	"github.com/vmihailenco/msgpack"
+)
+
+const indexBatchSize = 1000
+
+// IndexGhostKeys points the ghost keys of all stored outputs to their UTXO
+// keys, the ghost keys written before the reference was stored only have a
+// placeholder value, and can't be resolved by ReadGhostUTXO without it.
+func (s *BadgerStore) IndexGhostKeys(ctx context.Context) error {
+	refs := make(map[string][]byte)
+	err := s.snapshotsDB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(graphPrefixUTXO)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var out common.UTXOWithLock
+			err = msgpack.Unmarshal(v, &out)
+			if err != nil {
+				return err
+			}
+			ref := it.Item().KeyCopy(nil)
+			for _, k := range out.Keys {
+				key := graphGhostKey(k)
+				item, err := txn.Get(key)
+				if err != nil && err != badger.ErrKeyNotFound {
+					return err
+				}
+				if err == nil {
+					val, err := item.ValueCopy(nil)
+					if err != nil {
+						return err
+					}
+					if bytes.Equal(val, ref) {
+						continue
+					}
+				}
+				refs[string(key)] = ref
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(refs))
+	for k := range refs {
+		keys = append(keys, k)
+	}
+	for len(keys) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := keys
+		if len(batch) > indexBatchSize {
+			batch = keys[:indexBatchSize]
+		}
+		keys = keys[len(batch):]
+		err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+			for _, k := range batch {
+				err := txn.Set([]byte(k), refs[k])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
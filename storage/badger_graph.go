@@ -4,10 +4,12 @@ import (
 	"encoding/binary"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/metrics"
 	"github.com/dgraph-io/badger"
 	"github.com/vmihailenco/msgpack"
 )
@@ -23,6 +25,12 @@ const (
 	graphPrefixSnapshot     = "SNAPSHOT"     // {
 	graphPrefixLink         = "LINK"         // self-external number
 	graphPrefixTopology     = "TOPOLOGY"
+	graphPrefixChecksum     = "CHECKSUM"   // topology tip and snapshots count manifest
+	graphPrefixSpent        = "SPENT"      // topology|utxo spent outputs with the spending transaction
+	graphPrefixQuarantine   = "QUARANTINE" // topology corrupted snapshot records pending re-fetch
+	graphPrefixAsset        = "ASSET"      // asset ids appeared in any output
+	graphPrefixArchive      = "ARCHIVE"    // node id archived final rounds of a removed node
+	graphPrefixRoundArchive = "RARCHIVE"   // round hash archived to the node id archive
 )
 
 func (s *BadgerStore) ReadSnapshotsForNodeRound(nodeId crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
@@ -60,51 +68,67 @@ func readSnapshotsForNodeRound(txn *badger.Txn, nodeId crypto.Hash, round uint64
 }
 
 func (s *BadgerStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder) error {
-	txn := s.snapshotsDB.NewTransaction(true)
-	defer txn.Discard()
-
-	// FIXME assert only, remove in future
-	if config.Debug {
-		cache, err := readRound(txn, snap.NodeId)
-		if err != nil {
-			return err
-		}
-		if cache == nil || snap.RoundNumber != cache.Number {
-			panic(fmt.Errorf("snapshot round number assert error %d %d", cache.Number, snap.RoundNumber))
-		}
-		if !snap.References.Equal(cache.References) {
-			panic("snapshot references assert error")
+	start := time.Now()
+	err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		// FIXME assert only, remove in future
+		if config.Debug {
+			cache, err := readRound(txn, snap.NodeId)
+			if err != nil {
+				return err
+			}
+			if cache == nil || snap.RoundNumber != cache.Number {
+				panic(fmt.Errorf("snapshot round number assert error %d %d", cache.Number, snap.RoundNumber))
+			}
+			if !snap.References.Equal(cache.References) {
+				panic("snapshot references assert error")
+			}
+			tx, err := readTransaction(txn, snap.Transaction)
+			if err != nil {
+				return err
+			}
+			if tx == nil {
+				panic("snapshot transaction not exist")
+			}
+			key := graphSnapshotKey(snap.NodeId, snap.RoundNumber, snap.Transaction)
+			_, err = txn.Get(key)
+			if err == nil {
+				panic("snapshot duplication")
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
 		}
+		// end assert
+
 		tx, err := readTransaction(txn, snap.Transaction)
 		if err != nil {
 			return err
 		}
-		if tx == nil {
-			panic("snapshot transaction not exist")
-		}
-		key := graphSnapshotKey(snap.NodeId, snap.RoundNumber, snap.Transaction)
-		_, err = txn.Get(key)
-		if err == nil {
-			panic("snapshot duplication")
-		} else if err != badger.ErrKeyNotFound {
-			return err
-		}
+		return writeSnapshot(txn, snap, tx)
+	})
+	if err != nil {
+		return err
 	}
-	// end assert
+	metrics.Counter("mixin_store_snapshots_total", 1)
+	metrics.Gauge("mixin_store_topology", float64(snap.TopologicalOrder+1))
+	metrics.Histogram("mixin_store_snapshot_commit_seconds", time.Since(start).Seconds())
+	return nil
+}
 
-	tx, err := readTransaction(txn, snap.Transaction)
+func writeSnapshot(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder, tx *common.SignedTransaction) error {
+	_, err := txn.Get(graphFinalizationKey(snap.Transaction))
+	if err == badger.ErrKeyNotFound {
+		err = writeSpentOutputs(txn, snap, tx)
+	}
 	if err != nil {
 		return err
 	}
-	err = writeSnapshot(txn, snap, tx)
+
+	err = finalizeTransaction(txn, tx, snap.TopologicalOrder)
 	if err != nil {
 		return err
 	}
-	return txn.Commit()
-}
 
-func writeSnapshot(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder, tx *common.SignedTransaction) error {
-	err := finalizeTransaction(txn, tx)
+	err = writeAsset(txn, tx.Asset)
 	if err != nil {
 		return err
 	}
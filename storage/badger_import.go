@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger"
+)
+
+// SnapshotImport is a record of the export stream, the Error is only set in
+// the last record when the export fails partway, so the truncated stream is
+// never imported as a complete one.
+type SnapshotImport struct {
+	Snapshot    *common.SnapshotWithTopologicalOrder `json:"snapshot,omitempty"`
+	Transaction *common.SignedTransaction            `json:"transaction,omitempty"`
+	Error       string                               `json:"error,omitempty"`
+}
+
+// ImportSnapshot appends a snapshot from a trusted peer right after the local
+// topology tip, without any signature verification. The kernel doesn't know
+// the new tip, so it must not be running during the import, and loads the
+// round graph when started later.
+func (s *BadgerStore) ImportSnapshot(snap *common.SnapshotWithTopologicalOrder, tx *common.SignedTransaction) error {
+	if hash := snap.PayloadHash(); hash != snap.Hash {
+		return fmt.Errorf("import snapshot hash mismatch %s %s", snap.Hash.String(), hash.String())
+	}
+	if hash := tx.PayloadHash(); hash != snap.Transaction {
+		return fmt.Errorf("import transaction hash mismatch %s %s", snap.Transaction.String(), hash.String())
+	}
+
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		tip := readTopologySequence(txn)
+		if snap.TopologicalOrder < tip {
+			return fmt.Errorf("import snapshot overlap %d %d", snap.TopologicalOrder, tip)
+		}
+		if snap.TopologicalOrder > tip {
+			return fmt.Errorf("import snapshot gap %d %d", snap.TopologicalOrder, tip)
+		}
+		_, err := txn.Get(graphSnapshotKey(snap.NodeId, snap.RoundNumber, snap.Transaction))
+		if err == nil {
+			return fmt.Errorf("import snapshot duplication %s", snap.Hash.String())
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		err = importSnapshotRound(txn, snap)
+		if err != nil {
+			return err
+		}
+		old, err := readTransaction(txn, snap.Transaction)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			err = writeTransaction(txn, tx)
+			if err != nil {
+				return err
+			}
+		}
+		return writeSnapshot(txn, snap, tx)
+	})
+}
+
+// importSnapshotRound moves the cache round of the snapshot node forward when
+// the snapshot starts the next round, the same as the kernel does.
+func importSnapshotRound(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder) error {
+	cache, err := readRound(txn, snap.NodeId)
+	if err != nil {
+		return err
+	}
+	if cache == nil {
+		return fmt.Errorf("import snapshot node round not found %s", snap.NodeId.String())
+	}
+	if snap.RoundNumber == cache.Number {
+		if !equalReferences(snap.References, cache.References) {
+			return fmt.Errorf("import snapshot references mismatch %s", snap.Hash.String())
+		}
+		return nil
+	}
+	if snap.RoundNumber != cache.Number+1 || snap.References == nil {
+		return fmt.Errorf("import snapshot round not continuous %d %d", cache.Number, snap.RoundNumber)
+	}
+	external, err := readFinalRound(txn, snap.References.External)
+	if err != nil {
+		return err
+	}
+	if external == nil || external.NodeId == snap.NodeId {
+		return fmt.Errorf("import snapshot external round invalid %s", snap.References.External.String())
+	}
+	snapshots, err := readSnapshotsForNodeRound(txn, snap.NodeId, cache.Number)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("import snapshot after empty round %d", cache.Number)
+	}
+	return startNewRound(txn, snap.NodeId, snap.RoundNumber, snap.References, snapshots[0].Timestamp)
+}
+
+func equalReferences(a, b *common.RoundLink) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildImport(10)
+	var rounds []*common.Round
+	for _, s := range snapshots {
+		rounds = append(rounds, &common.Round{Hash: s.NodeId, NodeId: s.NodeId})
+	}
+	err = store.LoadGenesis(rounds, snapshots[:5], transactions[:5])
+	assert.Nil(err)
+
+	err = store.ImportSnapshot(snapshots[7], transactions[7])
+	assert.NotNil(err)
+	assert.Equal("import snapshot gap 7 5", err.Error())
+	err = store.ImportSnapshot(snapshots[3], transactions[3])
+	assert.NotNil(err)
+	assert.Equal("import snapshot overlap 3 5", err.Error())
+	err = store.ImportSnapshot(snapshots[5], transactions[6])
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "import transaction hash mismatch")
+	tampered := *snapshots[5]
+	tampered.Timestamp += 1
+	err = store.ImportSnapshot(&tampered, transactions[5])
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "import snapshot hash mismatch")
+	assert.Equal(uint64(5), store.TopologySequence())
+
+	for i := 5; i < 10; i++ {
+		err = store.ImportSnapshot(snapshots[i], transactions[i])
+		assert.Nil(err)
+	}
+	assert.Equal(uint64(10), store.TopologySequence())
+	txn := store.snapshotsDB.NewTransaction(false)
+	manifest, err := readChecksum(txn)
+	txn.Discard()
+	assert.Nil(err)
+	assert.Equal(uint64(4), manifest.Tip)
+	assert.Equal(uint64(5), manifest.Count)
+	err = store.VerifyChecksum(context.Background())
+	assert.Nil(err)
+	txn = store.snapshotsDB.NewTransaction(false)
+	manifest, err = readChecksum(txn)
+	txn.Discard()
+	assert.Nil(err)
+	assert.Equal(uint64(9), manifest.Tip)
+	assert.Equal(uint64(10), manifest.Count)
+	err = store.VerifyChecksum(context.Background())
+	assert.Nil(err)
+	read, err := store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	assert.Len(read, 10)
+	for i, s := range read {
+		assert.Equal(snapshots[i].Hash, s.Hash)
+		assert.Equal(uint64(i), s.TopologicalOrder)
+	}
+
+	next, txs := testBuildImport(2)
+	next[0].TopologicalOrder = 10
+	next[0].NodeId = snapshots[0].NodeId
+	next[0].RoundNumber = 1
+	next[0].References = &common.RoundLink{Self: testRandomHash(), External: snapshots[1].NodeId}
+	next[0].Hash = next[0].PayloadHash()
+	next[1].TopologicalOrder = 11
+	next[1].NodeId = snapshots[0].NodeId
+	next[1].RoundNumber = 3
+	next[1].References = &common.RoundLink{Self: testRandomHash(), External: snapshots[1].NodeId}
+	next[1].Hash = next[1].PayloadHash()
+	err = store.ImportSnapshot(next[0], txs[0])
+	assert.Nil(err)
+	round, err := store.ReadRound(snapshots[0].NodeId)
+	assert.Nil(err)
+	assert.Equal(uint64(1), round.Number)
+	assert.Equal(next[0].References, round.References)
+	final, err := store.ReadRound(next[0].References.Self)
+	assert.Nil(err)
+	assert.Equal(uint64(0), final.Number)
+	err = store.ImportSnapshot(next[1], txs[1])
+	assert.NotNil(err)
+	assert.Equal("import snapshot round not continuous 1 3", err.Error())
+	err = store.ImportSnapshot(next[0], txs[0])
+	assert.NotNil(err)
+	assert.Equal("import snapshot overlap 10 11", err.Error())
+}
+
+func testBuildImport(count int) ([]*common.SnapshotWithTopologicalOrder, []*common.SignedTransaction) {
+	snapshots, transactions := testBuildGenesis(count)
+	for i, s := range snapshots {
+		s.Timestamp = uint64(i + 1)
+		s.Hash = s.PayloadHash()
+	}
+	return snapshots, transactions
+}
@@ -54,9 +54,12 @@ func readNodesInState(txn *badger.Txn, nodeState string) []*common.Node {
 			panic(err)
 		}
 		payee := nodePayee(ival)
+		var tx crypto.Hash
+		copy(tx[:], ival[len(payee.PublicSpendKey):])
 		nodes = append(nodes, &common.Node{
-			Signer: signer,
-			Payee:  payee,
+			Signer:      signer,
+			Payee:       payee,
+			Transaction: tx,
 		})
 	}
 	return nodes
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/dgraph-io/badger"
+	"github.com/vmihailenco/msgpack"
+)
+
+// QuarantinedSnapshot is a snapshot record failed verification on read, the
+// record is kept untouched and should be re-fetched from peers by the node
+// and round parsed from its key.
+type QuarantinedSnapshot struct {
+	TopologicalOrder uint64      `json:"topology"`
+	Key              []byte      `json:"key"`
+	NodeId           crypto.Hash `json:"node"`
+	RoundNumber      uint64      `json:"round"`
+	Reason           string      `json:"reason"`
+}
+
+func newQuarantinedSnapshot(topology uint64, key []byte, err error) *QuarantinedSnapshot {
+	q := &QuarantinedSnapshot{TopologicalOrder: topology, Key: key, Reason: err.Error()}
+	if len(key) == len(graphPrefixSnapshot)+len(q.NodeId)+8+len(crypto.Hash{}) {
+		key = key[len(graphPrefixSnapshot):]
+		copy(q.NodeId[:], key)
+		q.RoundNumber = binary.BigEndian.Uint64(key[len(q.NodeId):])
+	}
+	return q
+}
+
+// EnableQuarantine makes the topological reads skip the corrupted snapshot
+// records instead of failing, and quarantine them for re-fetch.
+func (s *BadgerStore) EnableQuarantine(enabled bool) {
+	s.quarantine = enabled
+}
+
+func (s *BadgerStore) ReadQuarantinedSnapshots() ([]*QuarantinedSnapshot, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	snapshots := make([]*QuarantinedSnapshot, 0)
+	prefix := []byte(graphPrefixQuarantine)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return snapshots, err
+		}
+		var q QuarantinedSnapshot
+		err = msgpack.Unmarshal(v, &q)
+		if err != nil {
+			return snapshots, err
+		}
+		snapshots = append(snapshots, &q)
+	}
+	return snapshots, nil
+}
+
+// RepairQuarantinedSnapshot writes the snapshot re-fetched from peers to the
+// quarantined record it matches, and clears the quarantine. The snapshot must
+// be verified finalized by the caller, and false is returned if no quarantined
+// record matches it.
+func (s *BadgerStore) RepairQuarantinedSnapshot(snap *common.Snapshot) (bool, error) {
+	key := graphSnapshotKey(snap.NodeId, snap.RoundNumber, snap.Transaction)
+	quarantined, err := s.ReadQuarantinedSnapshots()
+	if err != nil {
+		return false, err
+	}
+	var q *QuarantinedSnapshot
+	for _, r := range quarantined {
+		if bytes.Equal(r.Key, key) {
+			q = r
+		}
+	}
+	if q == nil {
+		return false, nil
+	}
+
+	err = s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		topo := &common.SnapshotWithTopologicalOrder{Snapshot: *snap, TopologicalOrder: q.TopologicalOrder}
+		err := txn.Set(key, common.MsgpackMarshalPanic(topo))
+		if err != nil {
+			return err
+		}
+		return txn.Delete(graphQuarantineKey(q.TopologicalOrder))
+	})
+	if err != nil {
+		return false, err
+	}
+	logger.Printf("QUARANTINE repaired snapshot %d %s\n", q.TopologicalOrder, snap.PayloadHash().String())
+	return true, nil
+}
+
+func (s *BadgerStore) quarantineSnapshots(snapshots []*QuarantinedSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		for _, q := range snapshots {
+			key := graphQuarantineKey(q.TopologicalOrder)
+			_, err := txn.Get(key)
+			if err == nil {
+				continue
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+			logger.Printf("QUARANTINE snapshot %d %s\n", q.TopologicalOrder, q.Reason)
+			err = txn.Set(key, common.MsgpackMarshalPanic(q))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// decodeSnapshotRecord verifies the record matches the key it is stored at,
+// because the key is derived from the node, round and transaction hash.
+func decodeSnapshotRecord(key, val []byte, topology uint64) (*common.SnapshotWithTopologicalOrder, error) {
+	var snap common.SnapshotWithTopologicalOrder
+	err := msgpack.Unmarshal(val, &snap)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot record malformed %d %s", topology, err.Error())
+	}
+	if !bytes.Equal(key, graphSnapshotKey(snap.NodeId, snap.RoundNumber, snap.Transaction)) {
+		return nil, fmt.Errorf("snapshot record hash mismatch %d", topology)
+	}
+	snap.Hash = snap.PayloadHash()
+	snap.TopologicalOrder = topology
+	return &snap, nil
+}
+
+func graphQuarantineKey(topology uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, topology)
+	return append([]byte(graphPrefixQuarantine), buf...)
+}
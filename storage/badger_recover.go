@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+)
+
+// RecoverTopology truncates the topology index back to the last order with
+// the snapshot record and its transaction found, along with the records of
+// the orders truncated, and returns the truncated orders from the tip down.
+// A badger commit is atomic, so any missing record is a corruption and the
+// recovery is only run when the operator asks for it, and a record failed
+// to decode is never truncated but returned as an error for a manual repair.
+// The checksum manifest is rebuilt for the new tip and the records left.
+func (s *BadgerStore) RecoverTopology() ([]uint64, error) {
+	var truncated []uint64
+	err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		truncated = nil
+		seq := readTopologySequence(txn)
+		for seq > 0 {
+			order := seq - 1
+			consistent, err := truncateTopology(txn, order)
+			if err != nil {
+				return err
+			}
+			if consistent {
+				break
+			}
+			truncated = append(truncated, order)
+			seq = order
+		}
+		if len(truncated) == 0 {
+			return nil
+		}
+		return rewindChecksum(txn, seq)
+	})
+	return truncated, err
+}
+
+// truncateTopology checks the order, and truncates it if not consistent.
+func truncateTopology(txn *badger.Txn, order uint64) (bool, error) {
+	item, err := txn.Get(graphTopologyKey(order))
+	if err != nil {
+		return false, err
+	}
+	key, err := item.ValueCopy(nil)
+	if err != nil {
+		return false, err
+	}
+
+	found := true
+	item, err = txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		found = false
+	} else if err != nil {
+		return false, err
+	}
+	if found {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return false, err
+		}
+		snap, err := decodeSnapshotRecord(key, val, order)
+		if err != nil {
+			return false, err
+		}
+		tx, err := readTransaction(txn, snap.Transaction)
+		if err != nil {
+			return false, err
+		}
+		if tx != nil {
+			return true, nil
+		}
+		err = txn.Delete(graphUniqueKey(snap.NodeId, snap.Transaction))
+		if err != nil {
+			return false, err
+		}
+		err = txn.Delete(key)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = deleteSpentOutputs(txn, order)
+	if err != nil {
+		return false, err
+	}
+	return false, txn.Delete(graphTopologyKey(order))
+}
+
+func deleteSpentOutputs(txn *badger.Txn, order uint64) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	var keys [][]byte
+	prefix := graphSpentPrefix(order)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		keys = append(keys, it.Item().KeyCopy(nil))
+	}
+	it.Close()
+
+	for _, k := range keys {
+		err := txn.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewindChecksum seals the manifest again for the new tip if it's truncated,
+// the snapshots count is taken from the records left up to the new tip.
+func rewindChecksum(txn *badger.Txn, seq uint64) error {
+	manifest, err := readChecksum(txn)
+	if err != nil || manifest == nil || manifest.Tip < seq {
+		return err
+	}
+	if seq == 0 {
+		return txn.Delete([]byte(graphPrefixChecksum))
+	}
+	count, err := countTopologyRecords(context.Background(), txn, seq-1)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return txn.Delete([]byte(graphPrefixChecksum))
+	}
+	return sealChecksum(txn, seq-1, count)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverTopology(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(10)
+	err = store.LoadGenesis(nil, snapshots, transactions)
+	assert.Nil(err)
+	truncated, err := store.RecoverTopology()
+	assert.Nil(err)
+	assert.Len(truncated, 0)
+	assert.Equal(uint64(10), store.TopologySequence())
+
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		s := snapshots[9]
+		err := txn.Delete(graphSnapshotKey(s.NodeId, s.RoundNumber, s.Transaction))
+		if err != nil {
+			return err
+		}
+		return txn.Delete(graphTransactionKey(snapshots[8].Transaction))
+	})
+	assert.Nil(err)
+	assert.NotNil(store.VerifyChecksum(context.Background()))
+
+	truncated, err = store.RecoverTopology()
+	assert.Nil(err)
+	assert.Equal([]uint64{9, 8}, truncated)
+	assert.Equal(uint64(8), store.TopologySequence())
+	assert.Nil(store.VerifyChecksum(context.Background()))
+	err = store.snapshotsDB.View(func(txn *badger.Txn) error {
+		s := snapshots[8]
+		_, err := txn.Get(graphSnapshotKey(s.NodeId, s.RoundNumber, s.Transaction))
+		assert.Equal(badger.ErrKeyNotFound, err)
+		_, err = txn.Get(graphUniqueKey(s.NodeId, s.Transaction))
+		assert.Equal(badger.ErrKeyNotFound, err)
+		return nil
+	})
+	assert.Nil(err)
+	read, err := store.ReadSnapshotsSinceTopology(0, 100)
+	assert.Nil(err)
+	assert.Len(read, 8)
+
+	truncated, err = store.RecoverTopology()
+	assert.Nil(err)
+	assert.Len(truncated, 0)
+
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		s := snapshots[7]
+		return txn.Set(graphSnapshotKey(s.NodeId, s.RoundNumber, s.Transaction), []byte("corrupted"))
+	})
+	assert.Nil(err)
+	truncated, err = store.RecoverTopology()
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "snapshot record malformed 7")
+	assert.Len(truncated, 0)
+	assert.Equal(uint64(8), store.TopologySequence())
+}
@@ -18,101 +18,91 @@ func (s *BadgerStore) ReadLink(from, to crypto.Hash) (uint64, error) {
 func (s *BadgerStore) ReadRound(hash crypto.Hash) (*common.Round, error) {
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
-	return readRound(txn, hash)
+	return readFinalRound(txn, hash)
 }
 
 func (s *BadgerStore) UpdateEmptyHeadRound(node crypto.Hash, number uint64, references *common.RoundLink) error {
-	txn := s.snapshotsDB.NewTransaction(true)
-	defer txn.Discard()
-
-	self, err := readRound(txn, node)
-	if err != nil {
-		return err
-	}
-	if self.Number != number {
-		panic("round number assert error")
-	}
-	if self.References.Self != references.Self {
-		panic("self reference assert error")
-	}
-	external, err := readRound(txn, references.External)
-	if err != nil {
-		return err
-	}
-	if external == nil {
-		panic("external final not exist")
-	}
-	if external.NodeId == self.NodeId {
-		panic("self references loop")
-	}
-	snapshots, err := readSnapshotsForNodeRound(txn, node, number)
-	if err != nil {
-		return err
-	}
-	if len(snapshots) != 0 {
-		panic("round not empty")
-	}
-
-	err = writeLink(txn, node, external.NodeId, external.Number)
-	if err != nil {
-		return err
-	}
-	err = writeRound(txn, node, &common.Round{
-		NodeId:     node,
-		Number:     number,
-		References: references,
-	})
-	if err != nil {
-		return err
-	}
-	return txn.Commit()
-}
-
-func (s *BadgerStore) StartNewRound(node crypto.Hash, number uint64, references *common.RoundLink, finalStart uint64) error {
-	txn := s.snapshotsDB.NewTransaction(true)
-	defer txn.Discard()
-
-	// FIXME assert only, remove in future
-	if config.Debug {
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
 		self, err := readRound(txn, node)
 		if err != nil {
 			return err
 		}
-		external, err := readRound(txn, references.External)
+		if self.Number != number {
+			panic("round number assert error")
+		}
+		if self.References.Self != references.Self {
+			panic("self reference assert error")
+		}
+		external, err := readFinalRound(txn, references.External)
 		if err != nil {
 			return err
 		}
-		if self == nil || self.Number != number-1 {
-			panic("self final assert error")
-		}
 		if external == nil {
 			panic("external final not exist")
 		}
 		if external.NodeId == self.NodeId {
 			panic("self references loop")
 		}
-		old, err := readRound(txn, references.Self)
+		snapshots, err := readSnapshotsForNodeRound(txn, node, number)
 		if err != nil {
 			return err
 		}
-		if old != nil {
-			panic("self final already exist")
+		if len(snapshots) != 0 {
+			panic("round not empty")
 		}
-		link, err := readLink(txn, node, external.NodeId)
+
+		err = writeLink(txn, node, external.NodeId, external.Number)
 		if err != nil {
 			return err
 		}
-		if link > external.Number {
-			panic("external link backward")
+		return writeRound(txn, node, &common.Round{
+			NodeId:     node,
+			Number:     number,
+			References: references,
+		})
+	})
+}
+
+func (s *BadgerStore) StartNewRound(node crypto.Hash, number uint64, references *common.RoundLink, finalStart uint64) error {
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		// FIXME assert only, remove in future
+		if config.Debug {
+			self, err := readRound(txn, node)
+			if err != nil {
+				return err
+			}
+			external, err := readFinalRound(txn, references.External)
+			if err != nil {
+				return err
+			}
+			if self == nil || self.Number != number-1 {
+				panic("self final assert error")
+			}
+			if external == nil {
+				panic("external final not exist")
+			}
+			if external.NodeId == self.NodeId {
+				panic("self references loop")
+			}
+			old, err := readRound(txn, references.Self)
+			if err != nil {
+				return err
+			}
+			if old != nil {
+				panic("self final already exist")
+			}
+			link, err := readLink(txn, node, external.NodeId)
+			if err != nil {
+				return err
+			}
+			if link > external.Number {
+				panic("external link backward")
+			}
 		}
-	}
-	// assert end
+		// assert end
 
-	err := startNewRound(txn, node, number, references, finalStart)
-	if err != nil {
-		return err
-	}
-	return txn.Commit()
+		return startNewRound(txn, node, number, references, finalStart)
+	})
 }
 
 func startNewRound(txn *badger.Txn, node crypto.Hash, number uint64, references *common.RoundLink, finalStart uint64) error {
@@ -121,7 +111,7 @@ func startNewRound(txn *badger.Txn, node crypto.Hash, number uint64, references
 		if err != nil {
 			return err
 		}
-		external, err := readRound(txn, references.External)
+		external, err := readFinalRound(txn, references.External)
 		if err != nil {
 			return err
 		}
@@ -130,6 +120,7 @@ func startNewRound(txn *badger.Txn, node crypto.Hash, number uint64, references
 		if err != nil {
 			return err
 		}
+		self.Hash = references.Self
 		self.Timestamp = finalStart
 		err = writeRound(txn, references.Self, self)
 		if err != nil {
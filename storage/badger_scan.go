@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger"
+)
+
+// The snapshots value log is memory mapped by badger, so a scan can decode
+// the values in place instead of copying them out first. This is only done
+// with a 64 bits address space, because a full value log may not fit in 32.
+var mmapScanViable = strconv.IntSize == 64
+
+// EnableMmapScan returns whether the memory mapped scan is in effect, it is
+// false when not viable and ScanSnapshots falls back to copied values.
+func (s *BadgerStore) EnableMmapScan(enabled bool) bool {
+	s.mmapScan = enabled && mmapScanViable
+	return s.mmapScan
+}
+
+// ScanSnapshots reads all snapshots between the topological order from and
+// to inclusively in a single read transaction, so writes committed during
+// the scan are never visible to the hook. The scan stops with ctx.Err() as
+// soon as the context is done.
+func (s *BadgerStore) ScanSnapshots(ctx context.Context, from, to uint64, hook func(snap *common.SnapshotWithTopologicalOrder) error) error {
+	corrupted, err := s.scanSnapshots(ctx, from, to, hook)
+	if err != nil {
+		return err
+	}
+	return s.quarantineSnapshots(corrupted)
+}
+
+func (s *BadgerStore) scanSnapshots(ctx context.Context, from, to uint64, hook func(snap *common.SnapshotWithTopologicalOrder) error) ([]*QuarantinedSnapshot, error) {
+	var corrupted []*QuarantinedSnapshot
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = !s.mmapScan
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := []byte(graphPrefixTopology)
+	for it.Seek(graphTopologyKey(from)); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return corrupted, err
+		}
+		item := it.Item()
+		topology := graphTopologyOrder(item.Key())
+		if topology > to {
+			break
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return corrupted, err
+		}
+		item, err = txn.Get(key)
+		if err != nil {
+			return corrupted, err
+		}
+		var val []byte
+		if s.mmapScan {
+			val, err = item.Value()
+		} else {
+			val, err = item.ValueCopy(nil)
+		}
+		if err != nil {
+			return corrupted, err
+		}
+		snap, err := decodeSnapshotRecord(key, val, topology)
+		if err != nil && s.quarantine {
+			corrupted = append(corrupted, newQuarantinedSnapshot(topology, key, err))
+			continue
+		}
+		if err != nil {
+			return corrupted, err
+		}
+		err = hook(snap)
+		if err != nil {
+			return corrupted, err
+		}
+	}
+	return corrupted, nil
+}
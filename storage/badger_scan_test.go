@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanSnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(300)
+	err = store.LoadGenesis(nil, snapshots[:200], transactions[:200])
+	assert.Nil(err)
+	assert.False(store.mmapScan)
+	assert.Equal(mmapScanViable, store.EnableMmapScan(true))
+
+	var copied, mapped []*common.SnapshotWithTopologicalOrder
+	store.EnableMmapScan(false)
+	err = store.ScanSnapshots(context.Background(), 0, 199, func(snap *common.SnapshotWithTopologicalOrder) error {
+		copied = append(copied, snap)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Len(copied, 200)
+	store.EnableMmapScan(true)
+	err = store.ScanSnapshots(context.Background(), 0, 199, func(snap *common.SnapshotWithTopologicalOrder) error {
+		mapped = append(mapped, snap)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(copied, mapped)
+	read, err := store.ReadSnapshotsSinceTopology(0, 200)
+	assert.Nil(err)
+	assert.Equal(read, mapped)
+	for i, snap := range mapped {
+		assert.Equal(uint64(i), snap.TopologicalOrder)
+		assert.Equal(snapshots[i].Transaction, snap.Transaction)
+	}
+
+	mapped = nil
+	err = store.ScanSnapshots(context.Background(), 50, 59, func(snap *common.SnapshotWithTopologicalOrder) error {
+		mapped = append(mapped, snap)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(copied[50:60], mapped)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 200; i < len(snapshots); i++ {
+			err := store.snapshotsDB.Update(func(txn *badger.Txn) error {
+				err := writeTransaction(txn, transactions[i])
+				if err != nil {
+					return err
+				}
+				return writeSnapshot(txn, snapshots[i], transactions[i])
+			})
+			assert.Nil(err)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		var scanned []*common.SnapshotWithTopologicalOrder
+		err = store.ScanSnapshots(context.Background(), 0, ^uint64(0), func(snap *common.SnapshotWithTopologicalOrder) error {
+			scanned = append(scanned, snap)
+			return nil
+		})
+		assert.Nil(err)
+		assert.True(len(scanned) >= 200)
+		assert.Equal(copied, scanned[:200])
+		for j, snap := range scanned {
+			assert.Equal(uint64(j), snap.TopologicalOrder)
+			assert.Equal(snapshots[j].Transaction, snap.Transaction)
+		}
+	}
+	wg.Wait()
+
+	var scanned []*common.SnapshotWithTopologicalOrder
+	err = store.ScanSnapshots(context.Background(), 0, ^uint64(0), func(snap *common.SnapshotWithTopologicalOrder) error {
+		scanned = append(scanned, snap)
+		if len(scanned) > 1 {
+			return nil
+		}
+		more, txs := testBuildGenesis(1)
+		more[0].TopologicalOrder = uint64(len(snapshots))
+		return store.snapshotsDB.Update(func(txn *badger.Txn) error {
+			err := writeTransaction(txn, txs[0])
+			if err != nil {
+				return err
+			}
+			return writeSnapshot(txn, more[0], txs[0])
+		})
+	})
+	assert.Nil(err)
+	assert.Len(scanned, len(snapshots))
+	assert.Equal(uint64(len(snapshots)+1), store.TopologySequence())
+}
+
+func TestScanSnapshotsCanceled(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(200)
+	err = store.LoadGenesis(nil, snapshots, transactions)
+	assert.Nil(err)
+	goroutines := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var scanned int
+	start := time.Now()
+	err = store.ScanSnapshots(ctx, 0, ^uint64(0), func(snap *common.SnapshotWithTopologicalOrder) error {
+		scanned++
+		if scanned == 10 {
+			go cancel()
+			<-ctx.Done()
+		}
+		return nil
+	})
+	assert.Equal(context.Canceled, err)
+	assert.Equal(10, scanned)
+	assert.True(time.Since(start) < time.Second)
+
+	_, err = store.FindOrphanedTransactions(ctx)
+	assert.Equal(context.Canceled, err)
+	_, _, err = store.FindTopologyGap(ctx)
+	assert.Equal(context.Canceled, err)
+	_, err = store.FindDuplicateTopologies(ctx)
+	assert.Equal(context.Canceled, err)
+	assert.Equal(context.Canceled, store.VerifyChecksum(ctx))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	_, err = store.FindOrphanedTransactions(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+
+	for i := 0; i < 50 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(runtime.NumGoroutine() <= goroutines)
+	_, err = store.FindOrphanedTransactions(context.Background())
+	assert.Nil(err)
+}
+
+func BenchmarkScanSnapshots(b *testing.B) {
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(4096)
+	err = store.LoadGenesis(nil, snapshots, transactions)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, mmap := range []bool{false, true} {
+		name := "copy"
+		if store.EnableMmapScan(mmap) {
+			name = "mmap"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var count int
+				err := store.ScanSnapshots(context.Background(), 0, ^uint64(0), func(snap *common.SnapshotWithTopologicalOrder) error {
+					count++
+					return nil
+				})
+				if err != nil || count != len(snapshots) {
+					b.Fatal(err, count)
+				}
+			}
+		})
+	}
+}
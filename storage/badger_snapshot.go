@@ -3,7 +3,6 @@ package storage
 import (
 	"bytes"
 	"encoding/hex"
-	"fmt"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -33,6 +32,28 @@ func (s *BadgerStore) ReadUTXO(hash crypto.Hash, index int) (*common.UTXO, error
 	return &out, err
 }
 
+func (s *BadgerStore) ReadUTXOWithLock(hash crypto.Hash, index int) (*common.UTXOWithLock, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	key := graphUtxoKey(hash, index)
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ival, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out common.UTXOWithLock
+	err = msgpack.Unmarshal(ival, &out)
+	return &out, err
+}
+
 func readDepositInput(txn *badger.Txn, deposit *common.DepositData) ([]byte, error) {
 	key := graphDepositKey(deposit)
 	item, err := txn.Get(key)
@@ -55,11 +76,17 @@ func (s *BadgerStore) CheckDepositInput(deposit *common.DepositData, tx crypto.H
 	if bytes.Compare(ival, tx[:]) == 0 {
 		return nil
 	}
-	return fmt.Errorf("invalid lock %s %s", hex.EncodeToString(ival), hex.EncodeToString(tx[:]))
+	return common.NewValidationError(common.ErrInputLocked, "invalid lock %s %s", hex.EncodeToString(ival), hex.EncodeToString(tx[:]))
 }
 
 func (s *BadgerStore) LockDepositInput(deposit *common.DepositData, tx crypto.Hash, fork bool) error {
-	return s.snapshotsDB.Update(func(txn *badger.Txn) error {
+	var pruned crypto.Hash
+	defer func() {
+		if pruned.HasValue() {
+			s.txCache.Remove(pruned)
+		}
+	}()
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
 		key := graphDepositKey(deposit)
 		ival, err := readDepositInput(txn, deposit)
 		save := func() error {
@@ -73,11 +100,11 @@ func (s *BadgerStore) LockDepositInput(deposit *common.DepositData, tx crypto.Ha
 		}
 		if bytes.Compare(ival, tx[:]) != 0 {
 			if !fork {
-				return fmt.Errorf("deposit locked for transaction %s", hex.EncodeToString(ival))
+				return common.NewValidationError(common.ErrInputLocked, "deposit locked for transaction %s", hex.EncodeToString(ival))
 			}
-			var hash crypto.Hash
-			copy(hash[:], ival)
-			err := pruneTransaction(txn, hash)
+			copy(pruned[:], ival)
+			s.txCache.Remove(pruned)
+			err := pruneTransaction(txn, pruned)
 			if err != nil {
 				return err
 			}
@@ -88,7 +115,13 @@ func (s *BadgerStore) LockDepositInput(deposit *common.DepositData, tx crypto.Ha
 
 func (s *BadgerStore) LockUTXO(hash crypto.Hash, index int, tx crypto.Hash, fork bool) (*common.UTXO, error) {
 	var utxo *common.UTXO
-	err := s.snapshotsDB.Update(func(txn *badger.Txn) error {
+	var pruned crypto.Hash
+	defer func() {
+		if pruned.HasValue() {
+			s.txCache.Remove(pruned)
+		}
+	}()
+	err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
 		key := graphUtxoKey(hash, index)
 		item, err := txn.Get(key)
 		if err == badger.ErrKeyNotFound {
@@ -110,9 +143,11 @@ func (s *BadgerStore) LockUTXO(hash crypto.Hash, index int, tx crypto.Hash, fork
 
 		if out.LockHash.HasValue() && out.LockHash != tx {
 			if !fork {
-				return fmt.Errorf("utxo locked for transaction %s", out.LockHash)
+				return common.NewValidationError(common.ErrInputLocked, "utxo locked for transaction %s", out.LockHash)
 			}
-			err := pruneTransaction(txn, out.LockHash)
+			pruned = out.LockHash
+			s.txCache.Remove(pruned)
+			err := pruneTransaction(txn, pruned)
 			if err != nil {
 				return err
 			}
@@ -125,6 +160,40 @@ func (s *BadgerStore) LockUTXO(hash crypto.Hash, index int, tx crypto.Hash, fork
 	return utxo, err
 }
 
+// ReadGhostUTXO finds the output by one of its keys, the ghost keys written
+// before the reference to the output is stored are found only after they are
+// migrated by IndexGhostKeys.
+func (s *BadgerStore) ReadGhostUTXO(key crypto.Key) (*common.UTXOWithLock, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get(graphGhostKey(key))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ref, err := item.ValueCopy(nil)
+	if err != nil || !bytes.HasPrefix(ref, []byte(graphPrefixUTXO)) {
+		return nil, err
+	}
+	item, err = txn.Get(ref)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ival, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var out common.UTXOWithLock
+	err = msgpack.Unmarshal(ival, &out)
+	return &out, err
+}
+
 func (s *BadgerStore) CheckGhost(key crypto.Key) (bool, error) {
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger"
+	"github.com/vmihailenco/msgpack"
+)
+
+func (s *BadgerStore) ReadSpentOutputs(ctx context.Context, from, to uint64, hook func(topo uint64, utxo *common.UTXOWithLock) error) error {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte(graphPrefixSpent)
+	for it.Seek(graphSpentPrefix(from)); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		item := it.Item()
+		topo := binary.BigEndian.Uint64(item.Key()[len(prefix):])
+		if topo > to {
+			break
+		}
+		ival, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var utxo common.UTXOWithLock
+		err = msgpack.Unmarshal(ival, &utxo)
+		if err != nil {
+			return err
+		}
+		err = hook(topo, &utxo)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSpentOutputs(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder, tx *common.SignedTransaction) error {
+	for _, in := range tx.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || len(in.Mint) > 0 || len(in.Rebate) > 0 {
+			continue
+		}
+		item, err := txn.Get(graphUtxoKey(in.Hash, in.Index))
+		if err != nil {
+			return err
+		}
+		ival, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var utxo common.UTXOWithLock
+		err = msgpack.Unmarshal(ival, &utxo)
+		if err != nil {
+			return err
+		}
+		utxo.LockHash = snap.Transaction
+		key := append(graphSpentPrefix(snap.TopologicalOrder), graphUtxoKey(in.Hash, in.Index)[len(graphPrefixUTXO):]...)
+		err = txn.Set(key, common.MsgpackMarshalPanic(utxo))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func graphSpentPrefix(topo uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, topo)
+	return append([]byte(graphPrefixSpent), buf...)
+}
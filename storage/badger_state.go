@@ -24,7 +24,7 @@ func (s *BadgerStore) StateGet(key string, val interface{}) (bool, error) {
 }
 
 func (s *BadgerStore) StateSet(key string, val interface{}) error {
-	return s.stateDB.Update(func(txn *badger.Txn) error {
+	return s.update(s.stateDB, func(txn *badger.Txn) error {
 		ival, err := msgpack.Marshal(val)
 		if err != nil {
 			return err
@@ -1,14 +1,27 @@
 package storage
 
 import (
+	"context"
 	"encoding/binary"
+	"fmt"
+	"sort"
 
 	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/dgraph-io/badger"
 	"github.com/vmihailenco/msgpack"
 )
 
 func (s *BadgerStore) ReadSnapshotsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	snapshots, corrupted, err := s.readSnapshotsSinceTopology(topologyOffset, count)
+	if err != nil {
+		return snapshots, err
+	}
+	return snapshots, s.quarantineSnapshots(corrupted)
+}
+
+func (s *BadgerStore) readSnapshotsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*QuarantinedSnapshot, error) {
+	var corrupted []*QuarantinedSnapshot
 	snapshots := make([]*common.SnapshotWithTopologicalOrder, 0)
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
@@ -18,38 +31,205 @@ func (s *BadgerStore) ReadSnapshotsSinceTopology(topologyOffset, count uint64) (
 	it.Seek(graphTopologyKey(topologyOffset))
 	for ; it.ValidForPrefix([]byte(graphPrefixTopology)) && uint64(len(snapshots)) < count; it.Next() {
 		item := it.Item()
-		v, err := item.ValueCopy(nil)
+		key, err := item.ValueCopy(nil)
 		if err != nil {
-			return snapshots, err
+			return snapshots, corrupted, err
 		}
 		topology := graphTopologyOrder(item.Key())
-		item, err = txn.Get(v)
+		item, err = txn.Get(key)
 		if err != nil {
-			return snapshots, err
+			return snapshots, corrupted, err
 		}
-		v, err = item.ValueCopy(nil)
+		v, err := item.ValueCopy(nil)
 		if err != nil {
-			return snapshots, err
+			return snapshots, corrupted, err
+		}
+		snap, err := decodeSnapshotRecord(key, v, topology)
+		if err != nil && s.quarantine {
+			corrupted = append(corrupted, newQuarantinedSnapshot(topology, key, err))
+			continue
 		}
-		var snap common.SnapshotWithTopologicalOrder
-		err = msgpack.Unmarshal(v, &snap)
 		if err != nil {
-			return snapshots, err
+			return snapshots, corrupted, err
 		}
-		snap.Hash = snap.PayloadHash()
-		snap.TopologicalOrder = topology
-		snapshots = append(snapshots, &snap)
+		snapshots = append(snapshots, snap)
 	}
 
-	return snapshots, nil
+	return snapshots, corrupted, nil
 }
 
 func (s *BadgerStore) TopologySequence() uint64 {
-	var sequence uint64
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readTopologySequence(txn)
+}
+
+// FindTopologyGap scans the topology index from 0, and returns the first
+// missing order, or false if the orders are contiguous.
+func (s *BadgerStore) FindTopologyGap(ctx context.Context) (uint64, bool, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var next uint64
+	prefix := []byte(graphPrefixTopology)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return 0, false, err
+		}
+		if graphTopologyOrder(it.Item().Key()) != next {
+			return next, true, nil
+		}
+		next = next + 1
+	}
+	return 0, false, nil
+}
+
+// FindDuplicateTopologies scans all snapshot records, and returns the sorted
+// topological orders assigned to more than one snapshot. The topology index
+// can't reveal them because a duplicate order overwrites the index entry.
+func (s *BadgerStore) FindDuplicateTopologies(ctx context.Context) ([]uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	counts := make(map[uint64]int)
+	prefix := []byte(graphPrefixSnapshot)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var snap common.SnapshotWithTopologicalOrder
+		err = msgpack.Unmarshal(v, &snap)
+		if err != nil {
+			return nil, err
+		}
+		counts[snap.TopologicalOrder] = counts[snap.TopologicalOrder] + 1
+	}
 
+	duplicates := make([]uint64, 0)
+	for order, c := range counts {
+		if c > 1 {
+			duplicates = append(duplicates, order)
+		}
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i] < duplicates[j] })
+	return duplicates, nil
+}
+
+// ReadTransactionTopology returns the topological order of the first snapshot
+// of the transaction, which is kept in the finalization of the transaction.
+func (s *BadgerStore) ReadTransactionTopology(ctx context.Context, hash crypto.Hash) (uint64, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
 
+	item, err := txn.Get(graphFinalizationKey(hash))
+	if err == badger.ErrKeyNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(v) != 8 {
+		return 0, false, fmt.Errorf("transaction finalization topology missing %s", hash.String())
+	}
+	return binary.BigEndian.Uint64(v), true, nil
+}
+
+// IndexTransactionTopology writes the topological order of the first snapshot
+// to the finalizations written before it's kept there.
+func (s *BadgerStore) IndexTransactionTopology(ctx context.Context) error {
+	orders := make(map[crypto.Hash]uint64)
+	err := s.snapshotsDB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(graphPrefixTopology)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var hash crypto.Hash
+			if len(v) < len(hash) {
+				continue
+			}
+			copy(hash[:], v[len(v)-len(hash):])
+			if _, found := orders[hash]; found {
+				continue
+			}
+			item, err := txn.Get(graphFinalizationKey(hash))
+			if err == badger.ErrKeyNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if len(val) == 0 {
+				orders[hash] = graphTopologyOrder(it.Item().Key())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	hashes := make([]crypto.Hash, 0, len(orders))
+	for h := range orders {
+		hashes = append(hashes, h)
+	}
+	for len(hashes) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := hashes
+		if len(batch) > indexBatchSize {
+			batch = hashes[:indexBatchSize]
+		}
+		hashes = hashes[len(batch):]
+		err := s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+			for _, h := range batch {
+				err := txn.Set(graphFinalizationKey(h), graphFinalizationValue(orders[h]))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTopologySequence(txn *badger.Txn) uint64 {
 	opts := badger.DefaultIteratorOptions
 	opts.PrefetchValues = false
 	opts.Reverse = true
@@ -59,10 +239,9 @@ func (s *BadgerStore) TopologySequence() uint64 {
 
 	it.Seek(graphTopologyKey(^uint64(0)))
 	if it.ValidForPrefix([]byte(graphPrefixTopology)) {
-		item := it.Item()
-		sequence = graphTopologyOrder(item.Key()) + 1
+		return graphTopologyOrder(it.Item().Key()) + 1
 	}
-	return sequence
+	return 0
 }
 
 func writeTopology(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder) error {
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -14,60 +15,64 @@ import (
 )
 
 func (s *BadgerStore) ReadTransaction(hash crypto.Hash) (*common.SignedTransaction, error) {
+	if tx := s.txCache.Get(hash); tx != nil {
+		return tx, nil
+	}
+	generation := s.txCache.Generation()
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
-	return readTransaction(txn, hash)
+	tx, err := readTransaction(txn, hash)
+	if err != nil {
+		return nil, err
+	}
+	s.txCache.Add(hash, tx, generation)
+	return tx, nil
 }
 
 func (s *BadgerStore) WriteTransaction(tx *common.SignedTransaction) error {
-	txn := s.snapshotsDB.NewTransaction(true)
-	defer txn.Discard()
+	return s.update(s.snapshotsDB, func(txn *badger.Txn) error {
+		// FIXME assert kind checks, not needed at all
+		if config.Debug {
+			txHash := tx.PayloadHash()
+			for _, in := range tx.Inputs {
+				if len(in.Genesis) > 0 {
+					continue
+				}
 
-	// FIXME assert kind checks, not needed at all
-	if config.Debug {
-		txHash := tx.PayloadHash()
-		for _, in := range tx.Inputs {
-			if len(in.Genesis) > 0 {
-				continue
-			}
+				if in.Deposit != nil {
+					ival, err := readDepositInput(txn, in.Deposit)
+					if err != nil {
+						panic(fmt.Errorf("deposit check error %s", err.Error()))
+					}
+					if bytes.Compare(ival, txHash[:]) != 0 {
+						panic(fmt.Errorf("deposit locked for transaction %s", hex.EncodeToString(ival)))
+					}
+					continue
+				}
 
-			if in.Deposit != nil {
-				ival, err := readDepositInput(txn, in.Deposit)
+				key := graphUtxoKey(in.Hash, in.Index)
+				item, err := txn.Get(key)
 				if err != nil {
-					panic(fmt.Errorf("deposit check error %s", err.Error()))
+					panic(fmt.Errorf("UTXO check error %s", err.Error()))
 				}
-				if bytes.Compare(ival, txHash[:]) != 0 {
-					panic(fmt.Errorf("deposit locked for transaction %s", hex.EncodeToString(ival)))
+				ival, err := item.ValueCopy(nil)
+				if err != nil {
+					panic(fmt.Errorf("UTXO check error %s", err.Error()))
+				}
+				var out common.UTXOWithLock
+				err = msgpack.Unmarshal(ival, &out)
+				if err != nil {
+					panic(fmt.Errorf("UTXO check error %s", err.Error()))
+				}
+				if out.LockHash != txHash {
+					panic(fmt.Errorf("utxo locked for transaction %s", out.LockHash))
 				}
-				continue
-			}
-
-			key := graphUtxoKey(in.Hash, in.Index)
-			item, err := txn.Get(key)
-			if err != nil {
-				panic(fmt.Errorf("UTXO check error %s", err.Error()))
-			}
-			ival, err := item.ValueCopy(nil)
-			if err != nil {
-				panic(fmt.Errorf("UTXO check error %s", err.Error()))
-			}
-			var out common.UTXOWithLock
-			err = msgpack.Unmarshal(ival, &out)
-			if err != nil {
-				panic(fmt.Errorf("UTXO check error %s", err.Error()))
-			}
-			if out.LockHash != txHash {
-				panic(fmt.Errorf("utxo locked for transaction %s", out.LockHash))
 			}
 		}
-	}
-	// assert end
+		// assert end
 
-	err := writeTransaction(txn, tx)
-	if err != nil {
-		return err
-	}
-	return txn.Commit()
+		return writeTransaction(txn, tx)
+	})
 }
 
 func (s *BadgerStore) CheckTransactionFinalization(hash crypto.Hash) (bool, error) {
@@ -98,6 +103,51 @@ func (s *BadgerStore) CheckTransactionInNode(nodeId, hash crypto.Hash) (bool, er
 	return true, nil
 }
 
+func (s *BadgerStore) FindOrphanedTransactions(ctx context.Context) ([]crypto.Hash, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	orphans := make([]crypto.Hash, 0)
+	referenced := make(map[crypto.Hash]bool)
+	prefix := []byte(graphPrefixSnapshot)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return orphans, err
+		}
+		var hash crypto.Hash
+		key := it.Item().Key()
+		copy(hash[:], key[len(key)-len(hash):])
+		if referenced[hash] {
+			continue
+		}
+		referenced[hash] = true
+		_, err := txn.Get(graphTransactionKey(hash))
+		if err == badger.ErrKeyNotFound {
+			orphans = append(orphans, hash)
+		} else if err != nil {
+			return orphans, err
+		}
+	}
+
+	prefix = []byte(graphPrefixTransaction)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return orphans, err
+		}
+		var hash crypto.Hash
+		copy(hash[:], it.Item().Key()[len(prefix):])
+		if !referenced[hash] {
+			orphans = append(orphans, hash)
+		}
+	}
+	return orphans, nil
+}
+
 func readTransaction(txn *badger.Txn, hash crypto.Hash) (*common.SignedTransaction, error) {
 	var out common.SignedTransaction
 	key := graphTransactionKey(hash)
@@ -138,7 +188,9 @@ func writeTransaction(txn *badger.Txn, tx *common.SignedTransaction) error {
 	return txn.Set(key, val)
 }
 
-func finalizeTransaction(txn *badger.Txn, tx *common.SignedTransaction) error {
+// the finalization keeps the topological order of the first snapshot of the
+// transaction
+func finalizeTransaction(txn *badger.Txn, tx *common.SignedTransaction, topology uint64) error {
 	key := graphFinalizationKey(tx.PayloadHash())
 	_, err := txn.Get(key)
 	if err == nil {
@@ -146,7 +198,7 @@ func finalizeTransaction(txn *badger.Txn, tx *common.SignedTransaction) error {
 	} else if err != badger.ErrKeyNotFound {
 		return err
 	}
-	err = txn.Set(key, []byte{})
+	err = txn.Set(key, graphFinalizationValue(topology))
 	if err != nil {
 		return err
 	}
@@ -169,6 +221,7 @@ func finalizeTransaction(txn *badger.Txn, tx *common.SignedTransaction) error {
 }
 
 func writeUTXO(txn *badger.Txn, utxo *common.UTXO, extra []byte, genesis bool) error {
+	ref := graphUtxoKey(utxo.Hash, utxo.Index)
 	for _, k := range utxo.Keys {
 		key := graphGhostKey(k)
 
@@ -183,12 +236,12 @@ func writeUTXO(txn *badger.Txn, utxo *common.UTXO, extra []byte, genesis bool) e
 		}
 		// assert end
 
-		err := txn.Set(key, []byte{0})
+		err := txn.Set(key, ref)
 		if err != nil {
 			return err
 		}
 	}
-	key := graphUtxoKey(utxo.Hash, utxo.Index)
+	key := ref
 	val := common.MsgpackMarshalPanic(utxo)
 	err := txn.Set(key, val)
 	if err != nil {
@@ -223,6 +276,12 @@ func graphFinalizationKey(hash crypto.Hash) []byte {
 	return append([]byte(graphPrefixFinalization), hash[:]...)
 }
 
+func graphFinalizationValue(topology uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, topology)
+	return buf
+}
+
 func graphUniqueKey(nodeId, hash crypto.Hash) []byte {
 	key := append(hash[:], nodeId[:]...)
 	return append([]byte(graphPrefixUnique), key...)
@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrphanedTransactions(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(7)
+	err = store.LoadGenesis(nil, snapshots, transactions)
+	assert.Nil(err)
+	orphans, err := store.FindOrphanedTransactions(context.Background())
+	assert.Nil(err)
+	assert.Len(orphans, 0)
+
+	missing := transactions[3].PayloadHash()
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(graphTransactionKey(missing))
+	})
+	assert.Nil(err)
+	orphans, err = store.FindOrphanedTransactions(context.Background())
+	assert.Nil(err)
+	assert.Len(orphans, 1)
+	assert.Equal(missing, orphans[0])
+
+	_, loose := testBuildGenesis(1)
+	err = store.WriteTransaction(loose[0])
+	assert.Nil(err)
+	orphans, err = store.FindOrphanedTransactions(context.Background())
+	assert.Nil(err)
+	assert.Len(orphans, 2)
+	assert.Contains(orphans, missing)
+	assert.Contains(orphans, loose[0].PayloadHash())
+}
+
+func TestTransactionCache(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+	store.ResizeTransactionCache(2)
+
+	_, transactions := testBuildGenesis(3)
+	for _, tx := range transactions {
+		err = store.WriteTransaction(tx)
+		assert.Nil(err)
+	}
+	missing := testRandomHash()
+	tx, err := store.ReadTransaction(missing)
+	assert.Nil(err)
+	assert.Nil(tx)
+	size, hits, misses := store.TransactionCacheInfo()
+	assert.Equal(0, size)
+	assert.Equal(uint64(0), hits)
+	assert.Equal(uint64(1), misses)
+
+	for i := 0; i < 2; i++ {
+		for _, tx := range transactions {
+			hash := tx.PayloadHash()
+			cached, err := store.ReadTransaction(hash)
+			assert.Nil(err)
+			txn := store.snapshotsDB.NewTransaction(false)
+			stored, err := readTransaction(txn, hash)
+			txn.Discard()
+			assert.Nil(err)
+			assert.Equal(stored.Marshal(), cached.Marshal())
+			assert.Equal(tx.Marshal(), cached.Marshal())
+		}
+	}
+	size, hits, misses = store.TransactionCacheInfo()
+	assert.Equal(2, size)
+	assert.Equal(uint64(0), hits)
+	assert.Equal(uint64(7), misses)
+
+	hash := transactions[2].PayloadHash()
+	for i := 0; i < 3; i++ {
+		_, err = store.ReadTransaction(hash)
+		assert.Nil(err)
+	}
+	_, hits, misses = store.TransactionCacheInfo()
+	assert.Equal(uint64(3), hits)
+	assert.Equal(uint64(7), misses)
+}
+
+func TestTransactionCachePrune(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	_, transactions := testBuildGenesis(2)
+	loser, winner := transactions[0].PayloadHash(), transactions[1].PayloadHash()
+	deposit := &common.DepositData{Chain: testRandomHash(), AssetKey: "0x", TransactionHash: "0x01", Amount: common.NewInteger(1)}
+	err = store.LockDepositInput(deposit, loser, false)
+	assert.Nil(err)
+	err = store.WriteTransaction(transactions[0])
+	assert.Nil(err)
+
+	tx, err := store.ReadTransaction(loser)
+	assert.Nil(err)
+	assert.NotNil(tx)
+	tx.Outputs[0].Amount = common.NewInteger(1)
+	tx, err = store.ReadTransaction(loser)
+	assert.Nil(err)
+	assert.Equal(transactions[0].Marshal(), tx.Marshal())
+	size, hits, _ := store.TransactionCacheInfo()
+	assert.Equal(1, size)
+	assert.Equal(uint64(1), hits)
+
+	err = store.LockDepositInput(deposit, winner, true)
+	assert.Nil(err)
+	tx, err = store.ReadTransaction(loser)
+	assert.Nil(err)
+	assert.Nil(tx)
+	size, _, _ = store.TransactionCacheInfo()
+	assert.Equal(0, size)
+}
+
+func BenchmarkReadTransaction(b *testing.B) {
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	_, transactions := testBuildGenesis(256)
+	for _, tx := range transactions {
+		err = store.WriteTransaction(tx)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, size := range []int{1, TransactionCacheSize} {
+		store.ResizeTransactionCache(size)
+		b.Run(fmt.Sprintf("cache-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := store.ReadTransaction(transactions[i%len(transactions)].PayloadHash())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func testBuildGenesis(count int) ([]*common.SnapshotWithTopologicalOrder, []*common.SignedTransaction) {
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	var transactions []*common.SignedTransaction
+	for i := 0; i < count; i++ {
+		seed := testRandomHash()
+		tx := common.NewTransaction(common.XINAssetId)
+		tx.Inputs = []*common.Input{{Genesis: seed[:]}}
+		tx.Outputs = []*common.Output{{
+			Type:   common.OutputTypeScript,
+			Amount: common.NewInteger(10000),
+			Script: common.Script{common.OperatorCmp, common.OperatorSum, 1},
+			Keys:   []crypto.Key{testRandomKey()},
+		}}
+		signed := &common.SignedTransaction{Transaction: *tx}
+		snapshots = append(snapshots, &common.SnapshotWithTopologicalOrder{
+			Snapshot: common.Snapshot{
+				NodeId:      testRandomHash(),
+				Transaction: signed.PayloadHash(),
+			},
+			TopologicalOrder: uint64(i),
+		})
+		transactions = append(transactions, signed)
+	}
+	return snapshots, transactions
+}
+
+func testRandomHash() crypto.Hash {
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	return crypto.NewHash(seed)
+}
+
+func testRandomKey() crypto.Key {
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	return crypto.NewKeyFromSeed(seed)
+}
+
+func TestIndexGhostKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(3)
+	err = store.LoadGenesis(nil, snapshots, transactions)
+	assert.Nil(err)
+	for _, tx := range transactions {
+		utxo, err := store.ReadGhostUTXO(tx.Outputs[0].Keys[0])
+		assert.Nil(err)
+		assert.NotNil(utxo)
+	}
+
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		for _, tx := range transactions[:2] {
+			err := txn.Set(graphGhostKey(tx.Outputs[0].Keys[0]), []byte{0})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.Nil(err)
+	for _, tx := range transactions[:2] {
+		utxo, err := store.ReadGhostUTXO(tx.Outputs[0].Keys[0])
+		assert.Nil(err)
+		assert.Nil(utxo)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = store.IndexGhostKeys(ctx)
+	assert.Equal(context.Canceled, err)
+	err = store.IndexGhostKeys(context.Background())
+	assert.Nil(err)
+	for _, tx := range transactions {
+		utxo, err := store.ReadGhostUTXO(tx.Outputs[0].Keys[0])
+		assert.Nil(err)
+		assert.NotNil(utxo)
+		assert.Equal(tx.PayloadHash(), utxo.Hash)
+	}
+}
+
+func TestIndexTransactionTopology(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	snapshots, transactions := testBuildGenesis(5)
+	err = store.LoadGenesis(nil, snapshots, transactions)
+	assert.Nil(err)
+	for i, tx := range transactions {
+		topology, found, err := store.ReadTransactionTopology(context.Background(), tx.PayloadHash())
+		assert.Nil(err)
+		assert.True(found)
+		assert.Equal(snapshots[i].TopologicalOrder, topology)
+	}
+	_, found, err := store.ReadTransactionTopology(context.Background(), testRandomHash())
+	assert.Nil(err)
+	assert.False(found)
+
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		for _, tx := range transactions[1:3] {
+			err := txn.Set(graphFinalizationKey(tx.PayloadHash()), []byte{})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.Nil(err)
+	_, _, err = store.ReadTransactionTopology(context.Background(), transactions[1].PayloadHash())
+	assert.NotNil(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = store.IndexTransactionTopology(ctx)
+	assert.Equal(context.Canceled, err)
+	_, _, err = store.ReadTransactionTopology(ctx, transactions[0].PayloadHash())
+	assert.Equal(context.Canceled, err)
+	err = store.IndexTransactionTopology(context.Background())
+	assert.Nil(err)
+	for i, tx := range transactions {
+		topology, found, err := store.ReadTransactionTopology(context.Background(), tx.PayloadHash())
+		assert.Nil(err)
+		assert.True(found)
+		assert.Equal(snapshots[i].TopologicalOrder, topology)
+	}
+}
@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 )
@@ -17,22 +20,48 @@ type Store interface {
 	CheckTransactionFinalization(hash crypto.Hash) (bool, error)
 	CheckTransactionInNode(nodeId, hash crypto.Hash) (bool, error)
 	ReadTransaction(hash crypto.Hash) (*common.SignedTransaction, error)
+	ResizeTransactionCache(size int)
+	TransactionCacheInfo() (int, uint64, uint64)
 	WriteTransaction(tx *common.SignedTransaction) error
+	FindOrphanedTransactions(ctx context.Context) ([]crypto.Hash, error)
 	StartNewRound(node crypto.Hash, number uint64, references *common.RoundLink, finalStart uint64) error
 	UpdateEmptyHeadRound(node crypto.Hash, number uint64, references *common.RoundLink) error
 	TopologySequence() uint64
+	FindTopologyGap(ctx context.Context) (uint64, bool, error)
+	RecoverTopology() ([]uint64, error)
+	FindDuplicateTopologies(ctx context.Context) ([]uint64, error)
+	ReadTransactionTopology(ctx context.Context, hash crypto.Hash) (uint64, bool, error)
+	VerifyChecksum(ctx context.Context) error
+	FsyncPolicy() string
+	ConflictInfo() (uint64, uint64)
 
 	ReadUTXO(hash crypto.Hash, index int) (*common.UTXO, error)
+	ReadUTXOWithLock(hash crypto.Hash, index int) (*common.UTXOWithLock, error)
+	ReadSpentOutputs(ctx context.Context, from, to uint64, hook func(topo uint64, utxo *common.UTXOWithLock) error) error
 	LockUTXO(hash crypto.Hash, index int, tx crypto.Hash, fork bool) (*common.UTXO, error)
 	CheckDepositInput(deposit *common.DepositData, tx crypto.Hash) error
 	LockDepositInput(deposit *common.DepositData, tx crypto.Hash, fork bool) error
 	CheckGhost(key crypto.Key) (bool, error)
+	ReadGhostUTXO(key crypto.Key) (*common.UTXOWithLock, error)
 	ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ScanSnapshots(ctx context.Context, from, to uint64, hook func(snap *common.SnapshotWithTopologicalOrder) error) error
+	EnableMmapScan(enabled bool) bool
+	EnableQuarantine(enabled bool)
+	SetGenesisWriters(workers int)
+	ReadQuarantinedSnapshots() ([]*QuarantinedSnapshot, error)
+	RepairQuarantinedSnapshot(snap *common.Snapshot) (bool, error)
 	ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error)
 	ReadRound(hash crypto.Hash) (*common.Round, error)
 	ReadLink(from, to crypto.Hash) (uint64, error)
+	ArchiveRounds(nodeId crypto.Hash) (int, error)
+	ReadArchivedRounds(nodeId crypto.Hash) ([]*common.Round, error)
 	WriteSnapshot(*common.SnapshotWithTopologicalOrder) error
+	ImportSnapshot(snap *common.SnapshotWithTopologicalOrder, tx *common.SignedTransaction) error
 	ReadDomains() []common.Domain
+	ReadAssets() ([]crypto.Hash, error)
+	IndexAssets(ctx context.Context) error
+	IndexGhostKeys(ctx context.Context) error
+	IndexTransactionTopology(ctx context.Context) error
 
 	QueueInfo() (uint64, uint64, uint64, error)
 	QueueAppendSnapshot(peerId crypto.Hash, snap *common.Snapshot, finalized bool) error
@@ -40,4 +69,5 @@ type Store interface {
 	CachePutTransaction(tx *common.SignedTransaction) error
 	CacheGetTransaction(hash crypto.Hash) (*common.SignedTransaction, error)
 	CacheListTransactions(hook func(tx *common.SignedTransaction) error) error
+	CacheEvictTransactions(before time.Time, limit int) (int, int, error)
 }
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/hashicorp/golang-lru"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	TransactionCacheSize = 4096
+)
+
+// transactionCache holds the encoded transactions, so every hit decodes a
+// private copy. A forked transaction may be pruned, then the entry is removed
+// and the generation bumped, so a read started before the prune won't put
+// the stale transaction back.
+type transactionCache struct {
+	mutex      sync.Mutex
+	lru        *lru.Cache
+	generation uint64
+	hits       uint64
+	misses     uint64
+}
+
+func newTransactionCache(size int) *transactionCache {
+	c, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+	return &transactionCache{lru: c}
+}
+
+func (c *transactionCache) Get(hash crypto.Hash) *common.SignedTransaction {
+	v, found := c.lru.Get(hash)
+	if !found {
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+	var tx common.SignedTransaction
+	err := msgpack.Unmarshal(v.([]byte), &tx)
+	if err != nil {
+		panic(err)
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return &tx
+}
+
+func (c *transactionCache) Generation() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+func (c *transactionCache) Add(hash crypto.Hash, tx *common.SignedTransaction, generation uint64) {
+	if tx == nil {
+		return
+	}
+	val := common.MsgpackMarshalPanic(tx)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.generation == generation {
+		c.lru.Add(hash, val)
+	}
+}
+
+func (c *transactionCache) Remove(hash crypto.Hash) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.lru.Remove(hash)
+}
+
+func (c *transactionCache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.lru.Purge()
+}
+
+func (s *BadgerStore) ResizeTransactionCache(size int) {
+	if size < 1 {
+		size = TransactionCacheSize
+	}
+	s.txCache.lru.Resize(size)
+}
+
+func (s *BadgerStore) TransactionCacheInfo() (int, uint64, uint64) {
+	c := s.txCache
+	return c.lru.Len(), atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+}